@@ -0,0 +1,144 @@
+// Command kost prints rightsizing recommendations for a cluster without running the
+// server. It reads usage straight from Prometheus and current requests from the
+// Kubernetes API, analyzes them in memory, and needs no database or Redis. Given
+// --database, it also keeps the usage it reads in a SQLite file or the server's
+// Postgres database and analyzes everything stored for the window.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/kubernetes"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.New()
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "kost: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("kost", flag.ExitOnError)
+	namespace := flags.String("namespace", "", "namespace to analyze (default all namespaces)")
+	output := flags.String("output", outputTable, "output format: table, json or yaml")
+	prometheusURL := flags.String("prometheus", envOr("PROMETHEUS_URL", "http://localhost:9090"),
+		"Prometheus address, e.g. through kubectl port-forward (default $PROMETHEUS_URL)")
+	kubeContext := flags.String("context", "", "kubeconfig context (default the current context)")
+	window := flags.Duration("window", 7*24*time.Hour, "usage history to analyze")
+	step := flags.Duration("step", 5*time.Minute, "resolution of the usage samples")
+	percentile := flags.Float64("percentile", 0.95, "usage percentile requests are sized from: 0.50, 0.95 or 0.99")
+	minSavings := flags.Float64("min-savings", 0, "hide recommendations saving less than this many dollars a month")
+	databaseDriver := flags.String("database-driver", store.DriverSQLite, "database driver: sqlite or postgres")
+	database := flags.String("database", "", "SQLite file or Postgres DSN to keep usage in across runs (default none)")
+	cluster := flags.String("cluster", "default", "cluster name usage is stored under")
+	verbose := flags.Bool("verbose", false, "log progress to stderr")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: kost [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if !validOutputs[*output] {
+		return fmt.Errorf("invalid output %q, must be table, json or yaml", *output)
+	}
+	if *window <= 0 || *step <= 0 || *step > *window {
+		return fmt.Errorf("window and step must be positive, with step no longer than window")
+	}
+
+	log.SetOutput(os.Stderr)
+	log.SetLevel(logrus.WarnLevel)
+	if *verbose {
+		log.SetLevel(logrus.InfoLevel)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	k8sClient, err := kubernetes.NewClientForContext(*kubeContext, nil)
+	if err != nil {
+		return fmt.Errorf("connecting to Kubernetes: %w", err)
+	}
+	promClient, err := api.NewClient(api.Config{Address: *prometheusURL})
+	if err != nil {
+		return fmt.Errorf("creating Prometheus client for %s: %w", *prometheusURL, err)
+	}
+
+	rightsizing := analyzer.NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+	if err := rightsizing.SetAnalysisWindow(*window); err != nil {
+		return err
+	}
+	opts := rightsizing.DefaultOptions()
+	opts.Percentile = *percentile
+	opts.MinSavings = *minSavings
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	source := &usageSource{
+		k8sClient: k8sClient,
+		promAPI:   v1.NewAPI(promClient),
+		window:    *window,
+		step:      *step,
+		cluster:   *cluster,
+	}
+	if *database != "" {
+		source.store, err = store.Open(ctx, *databaseDriver, *database)
+		if err != nil {
+			return fmt.Errorf("opening %s database: %w", *databaseDriver, err)
+		}
+		defer source.store.Close()
+	}
+
+	namespaces := []string{*namespace}
+	if *namespace == "" {
+		namespaces, err = source.Namespaces(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var recommendations []analyzer.Recommendation
+	for _, ns := range namespaces {
+		log.Infof("Analyzing namespace %s", ns)
+		usage, err := source.Usage(ctx, ns)
+		if err != nil {
+			return fmt.Errorf("reading usage for namespace %s: %w", ns, err)
+		}
+		recs, err := rightsizing.AnalyzeUsage(ctx, ns, usage, opts)
+		if err != nil {
+			return fmt.Errorf("analyzing namespace %s: %w", ns, err)
+		}
+		recommendations = append(recommendations, recs...)
+	}
+
+	// Biggest savings first
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].PotentialSavings > recommendations[j].PotentialSavings
+	})
+
+	return writeReport(os.Stdout, *output, recommendations)
+}
+
+// envOr returns the environment variable, or fallback when it is unset
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
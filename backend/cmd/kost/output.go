@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"k8s-cost-optimizer/internal/analyzer"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var validOutputs = map[string]bool{outputTable: true, outputJSON: true, outputYAML: true}
+
+// report is the json and yaml output. Recommendations keep the analyzer's field names,
+// as the server's API returns them.
+type report struct {
+	Recommendations []analyzer.Recommendation `json:"recommendations"`
+	TotalSavings    float64                   `json:"total_savings"`
+	AnnualSavings   float64                   `json:"annual_savings"`
+
+	// Monthly cost of sizing containers without a request, not netted against savings
+	InitialSizingCost float64 `json:"initial_sizing_cost"`
+}
+
+func writeReport(w io.Writer, format string, recommendations []analyzer.Recommendation) error {
+	totals := analyzer.TotalSavings(recommendations)
+	if recommendations == nil {
+		recommendations = []analyzer.Recommendation{}
+	}
+
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report{recommendations, totals.Savings, totals.Savings * 12, totals.InitialSizingCost})
+	case outputYAML:
+		data, err := yaml.Marshal(report{recommendations, totals.Savings, totals.Savings * 12, totals.InitialSizingCost})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return writeTable(w, recommendations, totals)
+	}
+}
+
+// writeTable prints one line per recommendation, requests and limits as
+// request/limit, followed by the total savings and what sizing containers without a
+// request adds
+func writeTable(w io.Writer, recommendations []analyzer.Recommendation, totals analyzer.SavingsTotals) error {
+	if len(recommendations) == 0 {
+		_, err := fmt.Fprintln(w, "No recommendations")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tWORKLOAD\tCONTAINER\tRESOURCE\tCURRENT\tRECOMMENDED\tSAVINGS/MONTH\tRISK\tNOTE")
+	for _, rec := range recommendations {
+		workload := rec.OwnerKind + "/" + rec.OwnerName
+		if rec.Replicas > 1 {
+			workload = fmt.Sprintf("%s (%d replicas)", workload, rec.Replicas)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t$%.2f\t%s\t%s\n",
+			rec.Namespace, workload, rec.ContainerName, rec.ResourceType,
+			formatAllocation(rec.ResourceType, rec.CurrentRequest, rec.CurrentLimit),
+			formatAllocation(rec.ResourceType, rec.RecommendedRequest, rec.RecommendedLimit),
+			rec.PotentialSavings, rec.RiskLevel, note(rec))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d recommendations, potential savings $%.2f/month ($%.2f/year)\n",
+		len(recommendations), totals.Savings, totals.Savings*12)
+	if err == nil && totals.InitialSizingCost > 0 {
+		_, err = fmt.Fprintf(w, "Sizing containers without a request adds $%.2f/month\n", totals.InitialSizingCost)
+	}
+	return err
+}
+
+// formatAllocation renders a request and limit in the units kubectl uses
+func formatAllocation(resourceType string, request, limit float64) string {
+	return formatQuantity(resourceType, request) + "/" + formatQuantity(resourceType, limit)
+}
+
+func formatQuantity(resourceType string, value float64) string {
+	if value <= 0 {
+		return "-"
+	}
+	switch resourceType {
+	case "CPU":
+		return fmt.Sprintf("%.0fm", value)
+	case "Memory":
+		return fmt.Sprintf("%.0fMi", value/1048576)
+	default:
+		return fmt.Sprintf("%.2f", value)
+	}
+}
+
+// note flags recommendations that were held back rather than sized from usage
+func note(rec analyzer.Recommendation) string {
+	switch {
+	case rec.OOMObserved:
+		return "OOMKilled"
+	case rec.ThrottleObserved:
+		return "throttled"
+	case rec.CurrentRequest <= 0:
+		return "no request set"
+	default:
+		return ""
+	}
+}
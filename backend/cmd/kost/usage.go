@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+	"k8s-cost-optimizer/internal/collectors"
+	"k8s-cost-optimizer/internal/store"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+)
+
+// rangeChunk bounds each range query so a series stays well under Prometheus' 11,000
+// points per query limit at the default step
+const rangeChunk = 24 * time.Hour
+
+// usageSource gathers what the analyzer needs for a namespace: usage samples from
+// Prometheus and current requests, limits and owners from the Kubernetes API
+type usageSource struct {
+	k8sClient k8sclient.Interface
+	promAPI   v1.API
+	window    time.Duration
+	step      time.Duration
+
+	// Optional store samples are kept in and read back from, so usage older than
+	// Prometheus' retention is still analyzed on later runs. Samples are stored under
+	// cluster.
+	store   store.Store
+	cluster string
+}
+
+// containerKey identifies a container within a namespace
+type containerKey struct {
+	pod       string
+	container string
+}
+
+// sampleKey identifies one of a container's samples
+type sampleKey struct {
+	containerKey
+	timestamp model.Time
+}
+
+// pendingSample is a sample for the store, complete once both the CPU and the memory
+// query have returned a value for it
+type pendingSample struct {
+	store.UsageSample
+	hasCPU, hasMemory bool
+}
+
+// Namespaces lists the cluster's namespaces
+func (s *usageSource) Namespaces(ctx context.Context) ([]string, error) {
+	list, err := s.k8sClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+
+	namespaces := make([]string, 0, len(list.Items))
+	for _, namespace := range list.Items {
+		namespaces = append(namespaces, namespace.Name)
+	}
+	return namespaces, nil
+}
+
+// Usage returns the usage of every container running in the namespace over the
+// window. Containers of pods that no longer exist are left out, since there are no
+// current requests to compare them with.
+func (s *usageSource) Usage(ctx context.Context, namespace string) ([]analyzer.ContainerUsage, error) {
+	pods, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	usage := make(map[containerKey]*analyzer.ContainerUsage)
+	var order []containerKey
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		ownerKind, ownerName := collectors.PodController(pod)
+		for _, container := range pod.Spec.Containers {
+			key := containerKey{pod.Name, container.Name}
+			usage[key] = &analyzer.ContainerUsage{
+				PodName:       pod.Name,
+				ContainerName: container.Name,
+				OwnerKind:     ownerKind,
+				OwnerName:     ownerName,
+				Resources: analyzer.ResourceAllocation{
+					CPURequest:    float64(container.Resources.Requests.Cpu().MilliValue()),
+					CPULimit:      float64(container.Resources.Limits.Cpu().MilliValue()),
+					MemoryRequest: float64(container.Resources.Requests.Memory().Value()),
+					MemoryLimit:   float64(container.Resources.Limits.Memory().Value()),
+				},
+			}
+			order = append(order, key)
+		}
+	}
+	if len(order) == 0 {
+		return nil, nil
+	}
+
+	selector := fmt.Sprintf(`namespace=%q, container!="", container!="POD"`, namespace)
+	cpuQuery := fmt.Sprintf(`sum by (pod, container) (rate(container_cpu_usage_seconds_total{%s}[5m]) * 1000)`, selector)
+	memQuery := fmt.Sprintf(`sum by (pod, container) (container_memory_working_set_bytes{%s})`, selector)
+
+	var pending map[sampleKey]*pendingSample
+	if s.store != nil {
+		pending = make(map[sampleKey]*pendingSample)
+	}
+	sample := func(key containerKey, ts model.Time) *pendingSample {
+		p := pending[sampleKey{key, ts}]
+		if p == nil {
+			p = &pendingSample{UsageSample: store.UsageSample{
+				Cluster:       s.cluster,
+				Namespace:     namespace,
+				PodName:       key.pod,
+				ContainerName: key.container,
+				Timestamp:     ts.Time(),
+			}}
+			pending[sampleKey{key, ts}] = p
+		}
+		return p
+	}
+
+	end := time.Now()
+	for start := end.Add(-s.window); start.Before(end); start = start.Add(rangeChunk) {
+		chunkEnd := start.Add(rangeChunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		r := v1.Range{Start: start, End: chunkEnd, Step: s.step}
+
+		if err := s.appendRange(ctx, cpuQuery, r, usage, func(key containerKey, ts model.Time, v float64) {
+			usage[key].CPU = append(usage[key].CPU, v)
+			if pending != nil {
+				p := sample(key, ts)
+				p.CPU, p.hasCPU = v, true
+			}
+		}); err != nil {
+			return nil, fmt.Errorf("querying CPU usage: %w", err)
+		}
+		if err := s.appendRange(ctx, memQuery, r, usage, func(key containerKey, ts model.Time, v float64) {
+			usage[key].Memory = append(usage[key].Memory, v)
+			if pending != nil {
+				p := sample(key, ts)
+				p.Memory, p.hasMemory = v, true
+			}
+		}); err != nil {
+			return nil, fmt.Errorf("querying memory usage: %w", err)
+		}
+	}
+
+	if err := s.addSignals(ctx, namespace, end, usage); err != nil {
+		log.Warnf("Failed to read OOM kills and throttling for %s, sizing from usage alone: %v", namespace, err)
+	}
+
+	if s.store != nil {
+		if err := s.keep(ctx, namespace, end, pending, usage); err != nil {
+			return nil, fmt.Errorf("storing usage: %w", err)
+		}
+	}
+
+	result := make([]analyzer.ContainerUsage, 0, len(order))
+	for _, key := range order {
+		result = append(result, *usage[key])
+	}
+	return result, nil
+}
+
+// keep stores the samples just read along with the containers' current resources,
+// then replaces each container's samples with every stored one in the window
+func (s *usageSource) keep(ctx context.Context, namespace string, end time.Time,
+	pending map[sampleKey]*pendingSample, usage map[containerKey]*analyzer.ContainerUsage) error {
+	samples := make([]store.UsageSample, 0, len(pending))
+	for _, p := range pending {
+		if p.hasCPU && p.hasMemory {
+			samples = append(samples, p.UsageSample)
+		}
+	}
+	if err := s.store.WriteUsage(ctx, samples); err != nil {
+		return err
+	}
+
+	resources := make([]store.ContainerResources, 0, len(usage))
+	for _, u := range usage {
+		resources = append(resources, store.ContainerResources{
+			Cluster:       s.cluster,
+			Namespace:     namespace,
+			PodName:       u.PodName,
+			ContainerName: u.ContainerName,
+			CPURequest:    u.Resources.CPURequest,
+			CPULimit:      u.Resources.CPULimit,
+			MemoryRequest: u.Resources.MemoryRequest,
+			MemoryLimit:   u.Resources.MemoryLimit,
+			Owner:         store.Owner{Kind: u.OwnerKind, Name: u.OwnerName},
+			Timestamp:     end,
+		})
+	}
+	if err := s.store.WriteResources(ctx, resources); err != nil {
+		return err
+	}
+
+	stored, err := s.store.Usage(ctx, namespace, s.cluster, end.Add(-s.window))
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		u.CPU, u.Memory = u.CPU[:0], u.Memory[:0]
+	}
+	for _, sample := range stored {
+		if u := usage[containerKey{sample.PodName, sample.ContainerName}]; u != nil {
+			u.CPU = append(u.CPU, sample.CPU)
+			u.Memory = append(u.Memory, sample.Memory)
+		}
+	}
+	log.Infof("Read %d stored samples for %s", len(stored), namespace)
+	return nil
+}
+
+// appendRange range-queries per-container values and adds those of the containers
+// being analyzed
+func (s *usageSource) appendRange(ctx context.Context, query string, r v1.Range,
+	usage map[containerKey]*analyzer.ContainerUsage, add func(containerKey, model.Time, float64)) error {
+	result, _, err := s.promAPI.QueryRange(ctx, query, r)
+	if err != nil {
+		return err
+	}
+	matrix, ok := result.(model.Matrix)
+	if !ok {
+		return fmt.Errorf("unexpected result type: %T", result)
+	}
+
+	for _, series := range matrix {
+		key := seriesKey(series.Metric)
+		if usage[key] == nil {
+			continue
+		}
+		for _, point := range series.Values {
+			add(key, point.Timestamp, float64(point.Value))
+		}
+	}
+	return nil
+}
+
+// addSignals marks containers that were OOMKilled over the window, i.e. restarted
+// with an OOM kill as their last termination, and records the share of their CFS
+// periods that were throttled
+func (s *usageSource) addSignals(ctx context.Context, namespace string, ts time.Time,
+	usage map[containerKey]*analyzer.ContainerUsage) error {
+	window := model.Duration(s.window)
+	oomQuery := fmt.Sprintf(`max by (pod, container) (
+		max_over_time(kube_pod_container_status_last_terminated_reason{namespace=%[1]q, reason="OOMKilled"}[%[2]s])
+	) and on (pod, container) (
+		sum by (pod, container) (increase(kube_pod_container_status_restarts_total{namespace=%[1]q}[%[2]s])) > 0
+	)`, namespace, window)
+	throttleQuery := fmt.Sprintf(`sum by (pod, container) (increase(container_cpu_cfs_throttled_periods_total{namespace=%[1]q}[%[2]s]))
+		/ sum by (pod, container) (increase(container_cpu_cfs_periods_total{namespace=%[1]q}[%[2]s]))`, namespace, window)
+
+	oomKilled, err := s.queryVector(ctx, oomQuery, ts)
+	if err != nil {
+		return fmt.Errorf("querying OOM kills: %w", err)
+	}
+	for _, sample := range oomKilled {
+		if u := usage[seriesKey(sample.Metric)]; u != nil {
+			u.OOMKilled = true
+		}
+	}
+
+	throttled, err := s.queryVector(ctx, throttleQuery, ts)
+	if err != nil {
+		return fmt.Errorf("querying CPU throttling: %w", err)
+	}
+	for _, sample := range throttled {
+		if u := usage[seriesKey(sample.Metric)]; u != nil && !math.IsNaN(float64(sample.Value)) {
+			u.ThrottledRatio = float64(sample.Value)
+		}
+	}
+	return nil
+}
+
+func (s *usageSource) queryVector(ctx context.Context, query string, ts time.Time) (model.Vector, error) {
+	result, _, err := s.promAPI.Query(ctx, query, ts)
+	if err != nil {
+		return nil, err
+	}
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type: %T", result)
+	}
+	return vector, nil
+}
+
+func seriesKey(metric model.Metric) containerKey {
+	return containerKey{string(metric["pod"]), string(metric["container"])}
+}
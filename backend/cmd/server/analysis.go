@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/internal/websocket"
+
+	"github.com/spf13/viper"
+)
+
+// startScheduledAnalysis runs rightsizing for every namespace with recent costs each
+// analysis.interval until ctx is cancelled, storing the recommendations so the history
+// stays current without anyone opening the dashboard. Subscribers of each namespace get
+// its totals, and every client a summary of the run. A zero interval disables it.
+func startScheduledAnalysis(ctx context.Context, costs store.CostRepository, rightsizing *analyzer.RightsizingAnalyzer, wsHub *websocket.Hub) {
+	interval := viper.GetDuration("analysis.interval")
+	if interval <= 0 {
+		log.Info("Scheduled analysis disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Infof("Starting scheduled analysis with interval: %v", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			analysisCtx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+
+			if err := runScheduledAnalysis(analysisCtx, costs, rightsizing, wsHub); err != nil {
+				log.Errorf("Failed to run scheduled analysis: %v", err)
+			}
+
+			cancel()
+		}
+	}
+}
+
+func runScheduledAnalysis(ctx context.Context, costs store.CostRepository, rightsizing *analyzer.RightsizingAnalyzer, wsHub *websocket.Hub) error {
+	// Namespaces with costs collected in the last day
+	namespaces, err := costs.CostNamespaces(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	totalRecommendations := 0
+	totalSavings := 0.0
+	failed := 0
+
+	for _, namespace := range namespaces {
+		recommendations, err := rightsizing.AnalyzeNamespace(ctx, namespace)
+		if err != nil {
+			log.Warnf("Failed to analyze namespace %s: %v", namespace, err)
+			failed++
+			continue
+		}
+		if err := rightsizing.SaveRecommendations(ctx, recommendations); err != nil {
+			log.Warnf("Failed to save recommendations for %s: %v", namespace, err)
+		}
+
+		savings := 0.0
+		for _, rec := range recommendations {
+			savings += rec.PotentialSavings
+		}
+		totalRecommendations += len(recommendations)
+		totalSavings += savings
+
+		if wsHub != nil {
+			wsHub.BroadcastToNamespace(namespace, map[string]interface{}{
+				"type":      "recommendations_update",
+				"namespace": namespace,
+				"data": map[string]interface{}{
+					"recommendations": len(recommendations),
+					"total_savings":   savings,
+				},
+				"timestamp": time.Now(),
+			})
+		}
+	}
+
+	log.Infof("Analyzed %d namespaces in %v: %d recommendations, $%.2f/month potential savings, %d failed",
+		len(namespaces), time.Since(start), totalRecommendations, totalSavings, failed)
+
+	if wsHub != nil {
+		wsHub.Broadcast(map[string]interface{}{
+			"type": "analysis_summary",
+			"data": map[string]interface{}{
+				"namespaces":      len(namespaces),
+				"failed":          failed,
+				"recommendations": totalRecommendations,
+				"total_savings":   totalSavings,
+			},
+			"timestamp": time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// startOutcomeEvaluation records the outcome of applied recommendations every
+// analysis.outcomes.interval until ctx is cancelled, once each has run for
+// analysis.outcomes.window. A zero interval disables it.
+func startOutcomeEvaluation(ctx context.Context, evaluator *analyzer.OutcomeEvaluator) {
+	interval := viper.GetDuration("analysis.outcomes.interval")
+	if interval <= 0 {
+		log.Info("Recommendation outcome evaluation disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Infof("Starting recommendation outcome evaluation with interval: %v", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			evaluateCtx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+
+			evaluated, err := evaluator.Evaluate(evaluateCtx)
+			if err != nil {
+				log.Errorf("Failed to evaluate recommendation outcomes: %v", err)
+			} else if evaluated > 0 {
+				log.Infof("Evaluated the outcome of %d applied recommendations", evaluated)
+			}
+
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s-cost-optimizer/internal/costimport"
+
+	"github.com/spf13/viper"
+)
+
+// runImportCosts implements the import-costs subcommand, which seeds namespace_costs
+// from a CSV or JSON file the same way POST /api/costs/import does:
+//
+//	server import-costs [-format csv|json] [-cluster name] [-dry-run] FILE
+//
+// The format defaults from the file extension, and the cluster from cloud.cluster_name.
+// Invalid rows are printed to stderr with their line numbers.
+func runImportCosts(args []string) error {
+	flags := flag.NewFlagSet("import-costs", flag.ExitOnError)
+	format := flags.String("format", "", "file format, csv or json (default from the file extension)")
+	cluster := flags.String("cluster", viper.GetString("cloud.cluster_name"), "cluster for rows without a cluster column")
+	dryRun := flags.Bool("dry-run", false, "validate and count the rows without writing them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: server import-costs [flags] FILE")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one file to import")
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := costimport.Parse(file, *format, *cluster)
+	var invalidRows *costimport.InvalidRowsError
+	if errors.As(err, &invalidRows) {
+		for _, row := range invalidRows.Rows {
+			fmt.Fprintf(os.Stderr, "%s:%d: %s\n", path, row.Line, row.Message)
+		}
+		return fmt.Errorf("%d invalid rows; nothing was imported", len(invalidRows.Rows))
+	}
+	if err != nil {
+		return err
+	}
+
+	st, db, err := initDatabase()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if db == nil {
+		return fmt.Errorf("importing costs needs Postgres")
+	}
+
+	result, err := costimport.Import(context.Background(), db, rows, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		fmt.Printf("Dry run: %d rows would be imported, %d inserted and %d updated\n",
+			result.Rows, result.Inserted, result.Updated)
+	} else {
+		fmt.Printf("Imported %d rows: %d inserted, %d updated\n", result.Rows, result.Inserted, result.Updated)
+	}
+	return nil
+}
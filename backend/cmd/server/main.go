@@ -7,21 +7,30 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"k8s-cost-optimizer/internal/analyzer"
 	"k8s-cost-optimizer/internal/api"
 	"k8s-cost-optimizer/internal/collectors"
+	"k8s-cost-optimizer/internal/database"
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/internal/websocket"
+	"k8s-cost-optimizer/pkg/cache"
 	"k8s-cost-optimizer/pkg/cloudprovider"
 	"k8s-cost-optimizer/pkg/kubernetes"
+	"k8s-cost-optimizer/pkg/resilience"
+	"k8s-cost-optimizer/pkg/tracing"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	k8sclient "k8s.io/client-go/kubernetes"
 )
 
 var log = logrus.New()
@@ -33,30 +42,81 @@ func main() {
 	// Initialize logger
 	initLogger()
 
+	if len(os.Args) > 1 && os.Args[1] == "import-costs" {
+		if err := runImportCosts(os.Args[2:]); err != nil {
+			log.Fatalf("Cost import failed: %v", err)
+		}
+		return
+	}
+
 	log.Info("Starting Kubernetes Cost Optimizer...")
 
-	// Initialize database connection
-	db, err := initDatabase()
+	// Initialize tracing before anything that creates spans
+	shutdownTracing, err := tracing.Init(context.Background(), tracingConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Errorf("Failed to flush traces: %v", err)
+		}
+	}()
+
+	// Initialize database connection. On SQLite db is nil: the server reaches it only
+	// through the repositories, and what needs Postgres is turned off.
+	st, db, err := initDatabase()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer db.Close()
+	defer st.Close()
+	repositories := st.Repositories()
+
+	// Apply schema migrations, unless the schema is managed outside the server.
+	// SQLite creates its schema when opened.
+	if db == nil {
+		log.Warn("Running on SQLite: budgets, reports, notifications, GPU, network, node and workload " +
+			"collection and the endpoints built on them need Postgres and are disabled; data isn't pruned")
+	} else if viper.GetBool("migrate.auto") {
+		status, err := database.Migrate(context.Background(), db)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if status.Applied {
+			log.Infof("Migrated database schema from version %d to %d", status.From, status.To)
+		} else {
+			log.Infof("Database schema is up to date at version %d", status.To)
+		}
+	} else {
+		log.Info("Automatic migrations are disabled; the schema must be migrated separately")
+	}
+
+	if db != nil {
+		if err := database.CheckConflictTargets(context.Background(), db); err != nil {
+			log.Fatalf("Database schema is incomplete: %v", err)
+		}
+	}
 
-	// Initialize Redis cache
+	// Initialize Redis cache. Unless it is required, the service starts without it and
+	// serves uncached; the client reconnects once Redis is back.
 	redisClient, err := initRedis()
 	if err != nil {
-		log.Fatalf("Failed to initialize Redis: %v", err)
+		if viper.GetBool("redis.required") {
+			log.Fatalf("Failed to initialize Redis: %v", err)
+		}
+		log.Warnf("Redis is unavailable, serving without cache until it recovers: %v", err)
 	}
 	defer redisClient.Close()
 
 	// Initialize Kubernetes client
-	k8sClient, err := kubernetes.NewClient()
+	k8sClient, err := kubernetes.NewClient(kubernetesClientConfig())
 	if err != nil {
 		log.Fatalf("Failed to initialize Kubernetes client: %v", err)
 	}
 
 	// Initialize cloud provider
-	costProvider, err := initCloudProvider()
+	costProvider, err := initCloudProvider(k8sClient, db, viper.GetString("cloud.cluster_name"))
 	if err != nil {
 		log.Fatalf("Failed to initialize cloud provider: %v", err)
 	}
@@ -65,19 +125,125 @@ func main() {
 	wsHub := websocket.NewHub()
 	go wsHub.Run()
 
+	// Initialize Kubernetes event emitter
+	eventEmitter := kubernetes.NewEventEmitter(k8sClient, viper.GetBool("events.enabled"))
+	defer eventEmitter.Shutdown()
+
 	// Initialize components
-	metricsCollector := collectors.NewMetricsCollector(k8sClient, db)
-	rightsizingAnalyzer := analyzer.NewRightsizingAnalyzer(db)
-	handler := api.NewHandler(rightsizingAnalyzer, metricsCollector, costProvider, db, redisClient, wsHub)
+	metricsCollector, err := collectors.NewMetricsCollector(k8sClient, db, repositories,
+		collectorConfig(viper.GetString("cloud.cluster_name"), viper.GetString("prometheus.url"),
+			prometheusEndpoints()), wsHub)
+	if err != nil {
+		log.Fatalf("Failed to initialize metrics collector: %v", err)
+	}
+	if metricsClient, err := kubernetes.NewMetricsClient(kubernetesClientConfig()); err != nil {
+		log.Warnf("Failed to initialize metrics client, pod and node usage won't be collected: %v", err)
+	} else {
+		metricsCollector.SetMetricsClient(metricsClient)
+	}
+	costExporter := initCostExporter()
+	if costExporter != nil {
+		metricsCollector.SetCostExporter(costExporter)
+	}
+	rightsizingAnalyzer := analyzer.NewRightsizingAnalyzer(db, repositories, analyzer.NewProviderPricing(costProvider, k8sClient))
+	if err := rightsizingAnalyzer.SetIdleWindow(viper.GetDuration("analyzer.idle_window")); err != nil {
+		log.Fatalf("Invalid idle window: %v", err)
+	}
+	if err := rightsizingAnalyzer.SetMinSavings(viper.GetFloat64("analyzer.min_savings")); err != nil {
+		log.Fatalf("Invalid minimum savings: %v", err)
+	}
+	if err := rightsizingAnalyzer.SetMinConfidence(viper.GetFloat64("analyzer.min_confidence")); err != nil {
+		log.Fatalf("Invalid minimum confidence: %v", err)
+	}
+	consolidationAnalyzer := analyzer.NewConsolidationAnalyzer(k8sClient)
+	if err := consolidationAnalyzer.SetSpotDiscount(viper.GetFloat64("analyzer.spot_discount")); err != nil {
+		log.Fatalf("Invalid spot discount: %v", err)
+	}
+	cacheConfig := cache.DefaultCacheConfig()
+	cacheConfig.Compression = viper.GetBool("cache.compression")
+	cacheConfig.CompressionThreshold = viper.GetInt("cache.compression_threshold")
+	cacheManager, err := cache.NewCacheManager(redisClient, cacheConfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+	handler := api.NewHandler(rightsizingAnalyzer, consolidationAnalyzer, metricsCollector, k8sClient, costProvider, db, repositories, redisClient, cacheManager, wsHub, eventEmitter)
+	handler.SetRedisRequired(viper.GetBool("redis.required"))
+	if err := handler.SetMaxBodyBytes(viper.GetInt64("server.max_body_bytes")); err != nil {
+		log.Fatalf("Invalid max body size: %v", err)
+	}
+	handler.SetSettings(viper.AllSettings())
+	if err := handler.SetCollectionIntervals(viper.GetDuration("metrics.collection_interval"), viper.GetDuration("cost.collection_interval")); err != nil {
+		log.Fatalf("Invalid collection interval: %v", err)
+	}
+	if err := handler.SetIdempotencyTTL(viper.GetDuration("server.idempotency_ttl")); err != nil {
+		log.Fatalf("Invalid idempotency TTL: %v", err)
+	}
+
+	// Warn early if the configured Prometheus labels don't match any series
+	validateCtx, validateCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := metricsCollector.ValidateLabels(validateCtx); err != nil {
+		log.Warnf("Failed to validate Prometheus labels: %v", err)
+	}
+	validateCancel()
+
+	// Watch pods through a shared informer instead of listing them every cycle
+	startInformers(metricsCollector)
+	defer metricsCollector.StopInformers()
 
 	// Initialize router
-	router := initRouter(handler)
+	router := initRouter(handler, wsHub, cacheManager)
+
+	// Background work stops when shutdown cancels ctx, and main waits for it on wg
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+
+	// Backfill usage history from Prometheus so recommendations are available right away
+	if viper.GetBool("collector.backfill.enabled") && !metricsCollector.Lightweight() {
+		runBackground(&wg, func() { backfillPodMetrics(ctx, metricsCollector) })
+	}
+
+	// Prune old metrics and cost data in background
+	if db != nil {
+		pruner, err := collectors.NewPruner(db, retentionOverrides())
+		if err != nil {
+			log.Fatalf("Invalid retention settings: %v", err)
+		}
+		runBackground(&wg, func() { startPruning(ctx, pruner) })
+	}
 
 	// Start metrics collection in background
-	go startMetricsCollection(metricsCollector)
+	runBackground(&wg, func() { startMetricsCollection(ctx, metricsCollector) })
 
-	// Start cost collection in background
-	go startCostCollection(metricsCollector, costProvider)
+	// Start cost collection in background, notifying about large savings and anomalies
+	var alerts *alertChecker
+	if db != nil {
+		alerts = initNotifications(db, repositories.Costs, rightsizingAnalyzer)
+	}
+	runBackground(&wg, func() { startCostCollection(ctx, metricsCollector, costProvider, alerts) })
+
+	// Refresh stored recommendations for every namespace in background
+	runBackground(&wg, func() { startScheduledAnalysis(ctx, repositories.Costs, rightsizingAnalyzer, wsHub) })
+
+	// Check how applied recommendations held up in background
+	if repositories.Outcomes != nil {
+		outcomeEvaluator := analyzer.NewOutcomeEvaluator(repositories.Outcomes, k8sClient, metricsCollector.ClusterName())
+		if err := outcomeEvaluator.SetWindow(viper.GetDuration("analysis.outcomes.window")); err != nil {
+			log.Fatalf("Invalid outcome window: %v", err)
+		}
+		runBackground(&wg, func() { startOutcomeEvaluation(ctx, outcomeEvaluator) })
+	}
+
+	// Send scheduled cost reports in background
+	if repositories.ReportSchedules != nil {
+		reportDelivery := initReportDelivery()
+		runBackground(&wg, func() { startReportSchedules(ctx, handler, repositories.ReportSchedules, reportDelivery) })
+	}
+
+	// Collect from additional clusters through their kubeconfig contexts
+	for _, collector := range startAdditionalClusters(ctx, &wg, db, repositories, wsHub, costExporter) {
+		defer collector.StopInformers()
+	}
 
 	// Start server
 	server := &http.Server{
@@ -88,17 +254,23 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// Graceful shutdown
+	// Graceful shutdown. ListenAndServe returns as soon as Shutdown starts, so main
+	// waits for drained before its deferred calls close the database and Redis.
+	drained := make(chan struct{})
 	go func() {
+		defer close(drained)
+
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
 		log.Info("Shutting down server...")
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+		cancel()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdownCancel()
 
-		if err := server.Shutdown(ctx); err != nil {
+		if err := server.Shutdown(shutdownCtx); err != nil {
 			log.Errorf("Server shutdown error: %v", err)
 		}
 	}()
@@ -107,6 +279,22 @@ func main() {
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server error: %v", err)
 	}
+
+	// Let requests and collection cycles in flight finish before closing the database
+	log.Info("Waiting for in-flight requests to finish...")
+	<-drained
+	log.Info("Waiting for background collection to stop...")
+	wg.Wait()
+	log.Info("Shutdown complete")
+}
+
+// runBackground runs fn in a goroutine tracked by wg
+func runBackground(wg *sync.WaitGroup, fn func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fn()
+	}()
 }
 
 func initConfig() {
@@ -118,15 +306,96 @@ func initConfig() {
 
 	// Set defaults
 	viper.SetDefault("server.port", ":8080")
+	viper.SetDefault("server.max_body_bytes", api.DefaultMaxBodyBytes)
+	viper.SetDefault("server.idempotency_ttl", api.DefaultIdempotencyTTL)
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5432)
 	viper.SetDefault("database.name", "k8s_cost_optimizer")
 	viper.SetDefault("database.user", "postgres")
+	viper.SetDefault("database.max_open_conns", 25)
+	viper.SetDefault("database.max_idle_conns", 5)
+	viper.SetDefault("database.conn_max_lifetime", "5m")
+	viper.SetDefault("database.path", "k8s-cost-optimizer.db") // SQLite database file
+	viper.SetDefault("migrate.auto", true)
 	viper.SetDefault("redis.host", "localhost")
 	viper.SetDefault("redis.port", 6379)
+	viper.SetDefault("redis.required", false)
+	viper.SetDefault("redis.timeout", "1s")
 	viper.SetDefault("prometheus.url", "http://prometheus:9090")
+	viper.SetDefault("prometheus.breaker.threshold", collectors.DefaultBreakerThreshold)
+	viper.SetDefault("prometheus.breaker.timeout", collectors.DefaultBreakerTimeout)
+	viper.SetDefault("kubernetes.qps", 50)
+	viper.SetDefault("kubernetes.burst", 100)
 	viper.SetDefault("metrics.collection_interval", "5m")
+	viper.SetDefault("metrics.export_costs", true)
+	viper.SetDefault("metrics.cost_prefix", collectors.DefaultCostMetricPrefix)
 	viper.SetDefault("cost.collection_interval", "1h")
+	viper.SetDefault("cost.network.internet_per_gb", collectors.DefaultNetworkPricing().InternetPerGB)
+	viper.SetDefault("cost.network.cross_zone_per_gb", collectors.DefaultNetworkPricing().CrossZonePerGB)
+	viper.SetDefault("cost.network.unclassified_as", collectors.DefaultNetworkPricing().UnclassifiedAs)
+	viper.SetDefault("cache.compression", false)
+	viper.SetDefault("cache.compression_threshold", 1024)
+	viper.SetDefault("cloud.cluster_name", "default")
+	viper.BindEnv("cloud.cluster_name", "CLUSTER_NAME")
+	viper.SetDefault("cloud.retry.max_attempts", 3)
+	viper.SetDefault("cloud.breaker.threshold", 5)
+	viper.SetDefault("cloud.breaker.timeout", "5m")
+	viper.SetDefault("cloud.static.per_vcpu_hour", cloudprovider.DefaultStaticPrices().PerVCPUHour)
+	viper.SetDefault("cloud.static.per_gib_hour", cloudprovider.DefaultStaticPrices().PerGiBHour)
+	viper.SetDefault("cloud.static.per_gib_storage_month", cloudprovider.DefaultStaticPrices().PerGiBStorageMonth)
+	viper.SetDefault("events.enabled", true)
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.endpoint", tracing.DefaultConfig().Endpoint)
+	viper.SetDefault("tracing.insecure", tracing.DefaultConfig().Insecure)
+	viper.SetDefault("tracing.service_name", tracing.DefaultConfig().ServiceName)
+	viper.SetDefault("tracing.sample_ratio", tracing.DefaultConfig().SampleRatio)
+	viper.SetDefault("collector.labels.namespace", "namespace")
+	viper.SetDefault("collector.labels.pod", "pod")
+	viper.SetDefault("collector.labels.container", "container")
+	viper.SetDefault("collector.retry_buffer_size", 10000)
+	viper.SetDefault("collector.batch_size", 500)
+	viper.SetDefault("collector.concurrency", collectors.DefaultConcurrency)
+	viper.SetDefault("collector.backfill.enabled", true)
+	viper.SetDefault("collector.backfill.lookback", "168h")
+	viper.SetDefault("collector.informer_sync_timeout", "2m")
+	viper.SetDefault("analyzer.idle_window", "72h")
+	viper.SetDefault("analyzer.min_savings", 0)
+	viper.SetDefault("analyzer.min_confidence", 0)
+	viper.SetDefault("analysis.interval", "6h")
+	viper.SetDefault("analysis.outcomes.interval", "1h")
+	viper.SetDefault("analysis.outcomes.window", analyzer.DefaultOutcomeWindow)
+	viper.SetDefault("analyzer.spot_discount", analyzer.DefaultSpotDiscount)
+	viper.SetDefault("notifications.savings_threshold", 500)
+	viper.SetDefault("notifications.anomaly_sensitivity", 3.0)
+	viper.SetDefault("notifications.cooldown", "24h")
+	viper.BindEnv("notifications.slack.webhook_url", "SLACK_WEBHOOK_URL")
+	viper.SetDefault("reports.check_interval", "1m")
+	viper.SetDefault("reports.smtp.port", 587)
+	viper.BindEnv("reports.smtp.password", "SMTP_PASSWORD")
+	viper.SetDefault("retention.interval", "24h")
+	viper.SetDefault("retention.max_age", "720h")
+	viper.SetDefault("retention.tables", map[string]string{
+		"namespace_costs":    "8760h",
+		"workload_costs":     "8760h",
+		"pod_metrics_rollup": "2160h",
+	})
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.api_key_header", "X-API-Key")
+	viper.SetDefault("auth.exempt_paths", []string{"/health", "/health/detailed", "/ready", "/metrics", "/openapi.json", "/docs"})
+	viper.SetDefault("auth.route_roles", api.DefaultRouteRoles())
+	viper.SetDefault("ratelimit.enabled", true)
+	viper.SetDefault("ratelimit.rate", 10)
+	viper.SetDefault("ratelimit.burst", 20)
+	viper.SetDefault("ratelimit.trust_forwarded_for", false)
+	viper.SetDefault("websocket.read_limit", 4096)
+	viper.SetDefault("websocket.read_timeout", "60s")
+	viper.SetDefault("websocket.ping_period", "54s")
+	viper.SetDefault("websocket.write_timeout", "10s")
+	viper.SetDefault("cors.allowed_origins", []string{})
+	viper.SetDefault("cors.dev_mode", false)
+	viper.BindEnv("cors.dev_mode", "CORS_DEV_MODE")
+	viper.BindEnv("auth.enabled", "AUTH_ENABLED")
+	viper.BindEnv("auth.jwt_signing_key", "JWT_SECRET")
 
 	// Read environment variables
 	viper.AutomaticEnv()
@@ -154,7 +423,24 @@ func initLogger() {
 	}
 }
 
-func initDatabase() (*sql.DB, error) {
+// initDatabase opens the configured database. Postgres is also returned as a *sql.DB
+// for what isn't behind the store yet; SQLite is only reached through the store, so
+// its *sql.DB is nil.
+func initDatabase() (store.Store, *sql.DB, error) {
+	switch driver := viper.GetString("database.driver"); driver {
+	case store.DriverPostgres, "":
+	case store.DriverSQLite:
+		path := viper.GetString("database.path")
+		st, err := store.Open(context.Background(), driver, path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		log.Infof("Using SQLite database %s", path)
+		return st, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("database.driver must be %s or %s, got %q", store.DriverPostgres, store.DriverSQLite, driver)
+	}
+
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		viper.GetString("database.host"),
 		viper.GetInt("database.port"),
@@ -165,28 +451,35 @@ func initDatabase() (*sql.DB, error) {
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Test connection
 	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(viper.GetInt("database.max_open_conns"))
+	db.SetMaxIdleConns(viper.GetInt("database.max_idle_conns"))
+	db.SetConnMaxLifetime(viper.GetDuration("database.conn_max_lifetime"))
 
 	log.Info("Database connection established")
-	return db, nil
+	return store.NewPostgres(db), db, nil
 }
 
+// initRedis returns the Redis client along with any error reaching Redis, so the caller
+// can still use the client once Redis comes up
 func initRedis() (*redis.Client, error) {
+	// Short timeouts keep an unreachable Redis from stalling the requests it caches for
+	timeout := viper.GetDuration("redis.timeout")
 	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", viper.GetString("redis.host"), viper.GetInt("redis.port")),
-		Password: viper.GetString("redis.password"),
-		DB:       viper.GetInt("redis.db"),
+		Addr:         fmt.Sprintf("%s:%d", viper.GetString("redis.host"), viper.GetInt("redis.port")),
+		Password:     viper.GetString("redis.password"),
+		DB:           viper.GetInt("redis.db"),
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
 	})
 
 	// Test connection
@@ -194,77 +487,364 @@ func initRedis() (*redis.Client, error) {
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to ping Redis: %w", err)
+		return client, fmt.Errorf("failed to ping Redis: %w", err)
 	}
 
 	log.Info("Redis connection established")
 	return client, nil
 }
 
-func initCloudProvider() (cloudprovider.Provider, error) {
+func initCloudProvider(k8sClient k8sclient.Interface, db *sql.DB, clusterName string) (cloudprovider.Provider, error) {
 	provider := viper.GetString("cloud.provider")
 	region := viper.GetString("cloud.region")
-	clusterName := viper.GetString("cloud.cluster_name")
+
+	var costProvider cloudprovider.Provider
+	var err error
 
 	switch provider {
 	case "aws":
-		return cloudprovider.NewAWSCostProvider(region, clusterName)
+		costProvider, err = cloudprovider.NewAWSCostProvider(region, clusterName)
 	case "azure":
-		return cloudprovider.NewAzureCostProvider(region, clusterName)
+		costProvider, err = cloudprovider.NewAzureCostProvider(region, clusterName)
 	case "gcp":
-		return cloudprovider.NewGCPCostProvider(region, clusterName)
+		costProvider, err = cloudprovider.NewGCPCostProvider(region, clusterName, &cloudprovider.GCPConfig{
+			ProjectID:    viper.GetString("cloud.gcp.project_id"),
+			BillingTable: viper.GetString("cloud.gcp.billing_table"),
+		})
+	case "static":
+		// Priced from the usage and storage metrics only Postgres collects
+		if db == nil {
+			return nil, fmt.Errorf("cloud.provider static needs Postgres")
+		}
+		costProvider, err = cloudprovider.NewStaticPriceProvider(k8sClient, db, clusterName, &cloudprovider.StaticPrices{
+			PerVCPUHour:        viper.GetFloat64("cloud.static.per_vcpu_hour"),
+			PerGiBHour:         viper.GetFloat64("cloud.static.per_gib_hour"),
+			PerGiBStorageMonth: viper.GetFloat64("cloud.static.per_gib_storage_month"),
+		})
 	default:
 		return cloudprovider.NewMockCostProvider(), nil
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Billing APIs are flaky and rate-limited, so retry and back off when they keep failing
+	breaker := resilience.NewCircuitBreaker(viper.GetInt("cloud.breaker.threshold"), viper.GetDuration("cloud.breaker.timeout"))
+	retry := resilience.DefaultRetryConfig()
+	retry.MaxAttempts = viper.GetInt("cloud.retry.max_attempts")
+
+	return cloudprovider.NewResilientProvider(costProvider, breaker, retry), nil
 }
 
-func initRouter(handler *api.Handler) *mux.Router {
+func initRouter(handler *api.Handler, wsHub *websocket.Hub, cacheManager *cache.CacheManager) *mux.Router {
 	router := mux.NewRouter()
 
+	// Shared by the middleware and the WebSocket endpoint, which authenticates itself
+	corsConfig := &api.CORSConfig{
+		AllowedOrigins: viper.GetStringSlice("cors.allowed_origins"),
+		DevMode:        viper.GetBool("cors.dev_mode"),
+	}
+	if corsConfig.DevMode {
+		log.Warn("CORS dev mode is enabled; the API and WebSocket endpoint accept requests from any origin")
+	}
+	authConfig := &api.AuthConfig{
+		Enabled:       viper.GetBool("auth.enabled"),
+		JWTSigningKey: viper.GetString("auth.jwt_signing_key"),
+		APIKeys:       viper.GetStringSlice("auth.api_keys"),
+		APIKeyHeader:  viper.GetString("auth.api_key_header"),
+		ExemptPaths:   viper.GetStringSlice("auth.exempt_paths"),
+		RouteRoles:    viper.GetStringMapStringSlice("auth.route_roles"),
+	}
+
 	// Health checks
 	router.HandleFunc("/health", handler.HealthCheck).Methods("GET")
 	router.HandleFunc("/ready", handler.ReadyCheck).Methods("GET")
+	router.HandleFunc("/health/detailed", handler.HealthDetailed).Methods("GET")
 
 	// WebSocket endpoint
-	wsHandler := api.NewWebSocketHandler(handler.wsHub)
-	router.HandleFunc("/ws", wsHandler.ServeWebSocket)
+	wsClientConfig := &websocket.ClientConfig{
+		ReadLimit:    viper.GetInt64("websocket.read_limit"),
+		ReadTimeout:  viper.GetDuration("websocket.read_timeout"),
+		PingPeriod:   viper.GetDuration("websocket.ping_period"),
+		WriteTimeout: viper.GetDuration("websocket.write_timeout"),
+	}
+	if err := wsClientConfig.Validate(); err != nil {
+		log.Fatalf("Invalid websocket configuration: %v", err)
+	}
+	wsHandler := api.NewWebSocketHandler(wsHub, corsConfig, authConfig, wsClientConfig)
+	router.HandleFunc(api.WebSocketPath, wsHandler.ServeWebSocket)
 
 	// Metrics endpoint
 	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
+	// API description and interactive docs
+	router.HandleFunc("/openapi.json", api.OpenAPISpec).Methods("GET")
+	router.HandleFunc("/docs", api.SwaggerUI).Methods("GET")
+
 	// API routes
 	apiRouter := router.PathPrefix("/api").Subrouter()
 	
 	// Cost endpoints
 	apiRouter.HandleFunc("/costs/namespace/{namespace}", handler.GetNamespaceCosts).Methods("GET")
+	apiRouter.HandleFunc("/costs/namespaces", handler.RequirePostgres(handler.GetBulkNamespaceCosts)).Methods("POST")
+	apiRouter.HandleFunc("/costs/workload/{namespace}", handler.RequirePostgres(handler.GetWorkloadCosts)).Methods("GET")
+	apiRouter.HandleFunc("/costs/by-label", handler.RequirePostgres(handler.GetCostsByLabel)).Methods("GET")
+	apiRouter.HandleFunc("/costs/compare", handler.RequirePostgres(handler.CompareCosts)).Methods("GET")
 	apiRouter.HandleFunc("/costs/cluster", handler.GetClusterCosts).Methods("GET")
 	apiRouter.HandleFunc("/costs/simulate", handler.SimulateCosts).Methods("POST")
+	apiRouter.HandleFunc("/costs/reconcile", handler.RequirePostgres(handler.ReconcileCosts)).Methods("POST")
+	apiRouter.HandleFunc("/costs/import", handler.RequirePostgres(handler.ImportCosts)).Methods("POST")
 
 	// Recommendations endpoints
+	apiRouter.HandleFunc("/recommendations/outcomes", handler.RequirePostgres(handler.GetRecommendationOutcomes)).Methods("GET")
 	apiRouter.HandleFunc("/recommendations/{namespace}", handler.GetRecommendations).Methods("GET")
-	apiRouter.HandleFunc("/recommendations/apply", handler.ApplyRecommendation).Methods("POST")
-	apiRouter.HandleFunc("/recommendations/bulk-apply", handler.BulkApplyRecommendations).Methods("POST")
+	apiRouter.HandleFunc("/recommendations/{namespace}/hpa", handler.RequirePostgres(handler.GetHorizontalRecommendations)).Methods("GET")
+	apiRouter.HandleFunc("/recommendations/{namespace}/idle", handler.RequirePostgres(handler.GetIdleWorkloads)).Methods("GET")
+	apiRouter.HandleFunc("/recommendations/{namespace}/diff", handler.GetRecommendationDiff).Methods("GET")
+	apiRouter.HandleFunc("/recommendations/{namespace}/spot", handler.GetSpotRecommendations).Methods("GET")
+	apiRouter.HandleFunc("/recommendations/{namespace}/storage", handler.RequirePostgres(handler.GetStorageRecommendations)).Methods("GET")
+	apiRouter.HandleFunc("/recommendations/{namespace}/history", handler.GetRecommendationHistory).Methods("GET")
+	apiRouter.HandleFunc("/recommendations/apply", handler.Idempotent(handler.ApplyRecommendation)).Methods("POST")
+	apiRouter.HandleFunc("/recommendations/bulk-apply", handler.Idempotent(handler.BulkApplyRecommendations)).Methods("POST")
+
+	// Budget endpoints
+	apiRouter.HandleFunc("/budgets", handler.RequirePostgres(handler.CreateBudget)).Methods("POST")
+	apiRouter.HandleFunc("/budgets", handler.RequirePostgres(handler.GetBudgets)).Methods("GET")
+	apiRouter.HandleFunc("/budgets/{namespace}", handler.RequirePostgres(handler.DeleteBudget)).Methods("DELETE")
+
+	// Budget quota endpoints
+	apiRouter.HandleFunc("/quota/{namespace}", handler.GetQuotaSuggestion).Methods("GET")
+	apiRouter.HandleFunc("/quota/{namespace}/apply", handler.ApplyQuotaSuggestion).Methods("POST")
 
 	// Export endpoints
-	apiRouter.HandleFunc("/export", handler.ExportReport).Methods("GET")
+	apiRouter.HandleFunc("/export", handler.RequirePostgres(handler.ExportReport)).Methods("GET")
+	apiRouter.HandleFunc("/reports/schedules", handler.RequirePostgres(handler.CreateReportSchedule)).Methods("POST")
+	apiRouter.HandleFunc("/reports/schedules", handler.RequirePostgres(handler.GetReportSchedules)).Methods("GET")
+	apiRouter.HandleFunc("/reports/schedules/{id}", handler.RequirePostgres(handler.GetReportSchedule)).Methods("GET")
+	apiRouter.HandleFunc("/reports/schedules/{id}", handler.RequirePostgres(handler.UpdateReportSchedule)).Methods("PUT")
+	apiRouter.HandleFunc("/reports/schedules/{id}", handler.RequirePostgres(handler.DeleteReportSchedule)).Methods("DELETE")
 
 	// Resource endpoints
 	apiRouter.HandleFunc("/resources/{namespace}", handler.GetResourceUsage).Methods("GET")
 	apiRouter.HandleFunc("/resources/pods/{namespace}", handler.GetPodResources).Methods("GET")
+	apiRouter.HandleFunc("/metrics-summary", handler.RequirePostgres(handler.GetClusterEfficiency)).Methods("GET")
 
 	// Analytics endpoints
-	apiRouter.HandleFunc("/analytics/trends/{namespace}", handler.GetCostTrends).Methods("GET")
-	apiRouter.HandleFunc("/analytics/anomalies", handler.GetAnomalies).Methods("GET")
+	apiRouter.HandleFunc("/analytics/trends/{namespace}", handler.RequirePostgres(handler.GetCostTrends)).Methods("GET")
+	apiRouter.HandleFunc("/analytics/forecast/{namespace}", handler.GetCostForecast).Methods("GET")
+	apiRouter.HandleFunc("/analytics/anomalies", handler.RequirePostgres(handler.GetAnomalies)).Methods("GET")
+	apiRouter.HandleFunc("/analytics/consolidation", handler.RequirePostgres(handler.GetConsolidationPlan)).Methods("GET")
+	apiRouter.HandleFunc("/analytics/consolidation/nodes", handler.GetConsolidationFeasibility).Methods("GET")
+	apiRouter.HandleFunc("/analytics/consolidation/nodes/{node}", handler.GetNodeConsolidationFeasibility).Methods("GET")
+
+	// Configuration endpoint
+	apiRouter.HandleFunc("/config", handler.GetConfig).Methods("GET")
 
 	// Middleware
+	router.Use(api.TracingMiddleware)
 	router.Use(api.LoggingMiddleware)
-	router.Use(api.CorsMiddleware)
+	router.Use(api.CorsMiddleware(corsConfig))
 	router.Use(api.RecoveryMiddleware)
+	router.Use(api.AuthMiddleware(authConfig))
+	router.Use(api.RateLimitMiddleware(&api.RateLimitConfig{
+		Enabled:           viper.GetBool("ratelimit.enabled"),
+		Rate:              viper.GetFloat64("ratelimit.rate"),
+		Burst:             viper.GetInt("ratelimit.burst"),
+		ExemptPaths:       viper.GetStringSlice("auth.exempt_paths"),
+		TrustForwardedFor: viper.GetBool("ratelimit.trust_forwarded_for"),
+	}, cacheManager))
 
 	return router
 }
 
-func startMetricsCollection(collector *collectors.MetricsCollector) {
+// tracingConfig returns the OTLP export settings
+func tracingConfig() *tracing.Config {
+	return &tracing.Config{
+		Enabled:     viper.GetBool("tracing.enabled"),
+		Endpoint:    viper.GetString("tracing.endpoint"),
+		Insecure:    viper.GetBool("tracing.insecure"),
+		ServiceName: viper.GetString("tracing.service_name"),
+		SampleRatio: viper.GetFloat64("tracing.sample_ratio"),
+	}
+}
+
+// kubernetesClientConfig returns the API server rate limits for Kubernetes clients
+func kubernetesClientConfig() *kubernetes.ClientConfig {
+	return &kubernetes.ClientConfig{
+		QPS:   float32(viper.GetFloat64("kubernetes.qps")),
+		Burst: viper.GetInt("kubernetes.burst"),
+	}
+}
+
+// collectorConfig builds the collector settings for a cluster. Prometheus endpoints,
+// when given, replace prometheusURL.
+func collectorConfig(clusterName, prometheusURL string, endpoints []collectors.PrometheusEndpoint) *collectors.CollectorConfig {
+	config := &collectors.CollectorConfig{
+		ClusterName:         clusterName,
+		PrometheusURL:       prometheusURL,
+		PrometheusEndpoints: endpoints,
+		BreakerThreshold:    viper.GetInt("prometheus.breaker.threshold"),
+		BreakerTimeout:      viper.GetDuration("prometheus.breaker.timeout"),
+		NamespaceLabel:  viper.GetString("collector.labels.namespace"),
+		PodLabel:        viper.GetString("collector.labels.pod"),
+		ContainerLabel:  viper.GetString("collector.labels.container"),
+		RetryBufferSize: viper.GetInt("collector.retry_buffer_size"),
+		BackfillStep:    viper.GetDuration("metrics.collection_interval"),
+		BatchSize:       viper.GetInt("collector.batch_size"),
+		Concurrency:     viper.GetInt("collector.concurrency"),
+		NetworkPricing: collectors.NetworkPricing{
+			InternetPerGB:    viper.GetFloat64("cost.network.internet_per_gb"),
+			CrossZonePerGB:   viper.GetFloat64("cost.network.cross_zone_per_gb"),
+			DestinationLabel: viper.GetString("cost.network.destination_label"),
+			UnclassifiedAs:   viper.GetString("cost.network.unclassified_as"),
+		},
+	}
+	if err := config.NetworkPricing.Validate(); err != nil {
+		log.Fatalf("Invalid network pricing: %v", err)
+	}
+	basis, err := collectors.ParseAllocationBasis(viper.GetString("cost.allocation_basis"))
+	if err != nil {
+		log.Fatalf("Invalid cost.allocation_basis: %v", err)
+	}
+	config.AllocationBasis = basis
+	// Each namespace worker holds a connection while it writes, so leave some of the
+	// pool free for API requests
+	if maxOpen := viper.GetInt("database.max_open_conns"); maxOpen > 0 && config.Concurrency >= maxOpen {
+		log.Fatalf("collector.concurrency (%d) must be less than database.max_open_conns (%d)",
+			config.Concurrency, maxOpen)
+	}
+	return config
+}
+
+// prometheusEndpoints reads the Prometheus or Thanos query endpoints, each with a url
+// and an optional timeout, listed under prometheus.endpoints
+func prometheusEndpoints() []collectors.PrometheusEndpoint {
+	var endpoints []collectors.PrometheusEndpoint
+	if err := viper.UnmarshalKey("prometheus.endpoints", &endpoints); err != nil {
+		log.Fatalf("Invalid prometheus.endpoints: %v", err)
+	}
+	checkPrometheusEndpoints("prometheus.endpoints", endpoints)
+	return endpoints
+}
+
+func checkPrometheusEndpoints(key string, endpoints []collectors.PrometheusEndpoint) {
+	for _, endpoint := range endpoints {
+		if endpoint.URL == "" {
+			log.Fatalf("Every entry in %s needs a url", key)
+		}
+	}
+}
+
+// initCostExporter publishes the namespace costs of every collection cycle on /metrics,
+// unless metrics.export_costs is false
+func initCostExporter() *collectors.CostExporter {
+	if !viper.GetBool("metrics.export_costs") {
+		return nil
+	}
+
+	exporter, err := collectors.NewCostExporter(viper.GetString("metrics.cost_prefix"), prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Fatalf("Failed to initialize cost exporter: %v", err)
+	}
+	return exporter
+}
+
+// clusterConfig is an additional cluster to collect from, reached through a
+// kubeconfig context
+type clusterConfig struct {
+	Name                string                          `mapstructure:"name"`
+	Context             string                          `mapstructure:"context"`
+	PrometheusURL       string                          `mapstructure:"prometheus_url"`
+	PrometheusEndpoints []collectors.PrometheusEndpoint `mapstructure:"prometheus_endpoints"`
+}
+
+// startAdditionalClusters starts collection for each cluster listed under clusters.
+// The API serves costs and recommendations for all of them, while changes are still
+// only applied to the cluster the server runs in. Collection stops when ctx is
+// cancelled and is tracked on wg. Their costs are exported through costExporter
+// when it is set. It returns the started collectors so their informers can be
+// stopped on shutdown.
+func startAdditionalClusters(ctx context.Context, wg *sync.WaitGroup, db *sql.DB, repositories store.Repositories,
+	wsHub *websocket.Hub, costExporter *collectors.CostExporter) []*collectors.MetricsCollector {
+	var clusters []clusterConfig
+	if err := viper.UnmarshalKey("clusters", &clusters); err != nil {
+		log.Fatalf("Invalid clusters configuration: %v", err)
+	}
+
+	var started []*collectors.MetricsCollector
+	for _, cluster := range clusters {
+		if cluster.Name == "" {
+			log.Fatalf("Every entry in clusters needs a name")
+		}
+		checkPrometheusEndpoints("clusters.prometheus_endpoints", cluster.PrometheusEndpoints)
+		prometheusURL := cluster.PrometheusURL
+		if prometheusURL == "" {
+			prometheusURL = viper.GetString("prometheus.url")
+		}
+
+		client, err := kubernetes.NewClientForContext(cluster.Context, kubernetesClientConfig())
+		if err != nil {
+			log.Fatalf("Failed to initialize Kubernetes client for cluster %s: %v", cluster.Name, err)
+		}
+		provider, err := initCloudProvider(client, db, cluster.Name)
+		if err != nil {
+			log.Fatalf("Failed to initialize cloud provider for cluster %s: %v", cluster.Name, err)
+		}
+		collector, err := collectors.NewMetricsCollector(client, db, repositories,
+			collectorConfig(cluster.Name, prometheusURL, cluster.PrometheusEndpoints), wsHub)
+		if err != nil {
+			log.Fatalf("Failed to initialize metrics collector for cluster %s: %v", cluster.Name, err)
+		}
+		if metricsClient, err := kubernetes.NewMetricsClientForContext(cluster.Context, kubernetesClientConfig()); err != nil {
+			log.Warnf("Failed to initialize metrics client for cluster %s, pod and node usage won't be collected: %v", cluster.Name, err)
+		} else {
+			collector.SetMetricsClient(metricsClient)
+		}
+		if costExporter != nil {
+			collector.SetCostExporter(costExporter)
+		}
+
+		log.Infof("Collecting from cluster %s (context %q)", cluster.Name, cluster.Context)
+		startInformers(collector)
+		if viper.GetBool("collector.backfill.enabled") && !collector.Lightweight() {
+			runBackground(wg, func() { backfillPodMetrics(ctx, collector) })
+		}
+		runBackground(wg, func() { startMetricsCollection(ctx, collector) })
+		runBackground(wg, func() { startCostCollection(ctx, collector, provider, nil) })
+		started = append(started, collector)
+	}
+
+	return started
+}
+
+// startInformers warms the collector's pod cache before collection starts. If the
+// cache doesn't sync in time, the collector keeps listing pods from the API server.
+func startInformers(collector *collectors.MetricsCollector) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("collector.informer_sync_timeout"))
+	defer cancel()
+
+	if err := collector.StartInformers(ctx); err != nil {
+		log.Warnf("Failed to start informers, falling back to API listing: %v", err)
+	}
+}
+
+// backfillPodMetrics loads recent usage history from Prometheus. Shutdown cancels it
+// part way, which is safe since the rows are upserted.
+func backfillPodMetrics(ctx context.Context, collector *collectors.MetricsCollector) {
+	lookback := viper.GetDuration("collector.backfill.lookback")
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	log.Infof("Backfilling pod metrics from Prometheus over the last %v", lookback)
+	if err := collector.CollectPodMetricsFromPrometheus(ctx, lookback); err != nil {
+		log.Errorf("Failed to backfill pod metrics: %v", err)
+	}
+}
+
+// startMetricsCollection collects usage and requests every metrics.collection_interval
+// until ctx is cancelled. A cycle in flight runs to completion on its own timeout so
+// shutdown doesn't abandon its writes.
+func startMetricsCollection(ctx context.Context, collector *collectors.MetricsCollector) {
 	interval := viper.GetDuration("metrics.collection_interval")
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -273,23 +853,97 @@ func startMetricsCollection(collector *collectors.MetricsCollector) {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-			
-			if err := collector.CollectNamespaceMetrics(ctx); err != nil {
-				log.Errorf("Failed to collect namespace metrics: %v", err)
-			}
-			
+			ctx, span := tracing.Start(ctx, "metrics collection")
+
+			// Retry writes that failed in earlier cycles before collecting new data
+			collector.FlushFailedWrites(ctx)
+
 			if err := collector.CollectPodMetrics(ctx); err != nil {
 				log.Errorf("Failed to collect pod metrics: %v", err)
 			}
+
+			if err := collector.CollectResourceRequests(ctx); err != nil {
+				log.Errorf("Failed to collect resource requests: %v", err)
+			}
+
+			// The rest is only stored in Postgres
+			if !collector.Lightweight() {
+				collectPostgresMetrics(ctx, collector)
+			}
 			
+			span.End()
+			cancel()
+		}
+	}
+}
+
+// collectPostgresMetrics collects the namespace, node, network and container signal
+// metrics a lightweight collector skips
+func collectPostgresMetrics(ctx context.Context, collector *collectors.MetricsCollector) {
+	if err := collector.CollectNamespaceMetrics(ctx); err != nil {
+		log.Errorf("Failed to collect namespace metrics: %v", err)
+	}
+
+	// Node usage feeds the consolidation plan and node efficiency
+	if err := collector.CollectNodeMetrics(ctx); err != nil {
+		log.Errorf("Failed to collect node metrics: %v", err)
+	}
+
+	if err := collector.CollectNetworkMetrics(ctx); err != nil {
+		log.Errorf("Failed to collect network metrics: %v", err)
+	}
+
+	if err := collector.CollectContainerSignals(ctx); err != nil {
+		log.Errorf("Failed to collect container signals: %v", err)
+	}
+}
+
+// retentionOverrides parses the per-table retention settings, e.g.
+// retention.tables.namespace_costs: 8760h
+func retentionOverrides() map[string]time.Duration {
+	overrides := make(map[string]time.Duration)
+	for table, value := range viper.GetStringMapString("retention.tables") {
+		retention, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid retention for %s: %v", table, err)
+		}
+		overrides[table] = retention
+	}
+	return overrides
+}
+
+// startPruning deletes expired data every retention.interval until ctx is cancelled
+func startPruning(ctx context.Context, pruner *collectors.Pruner) {
+	interval := viper.GetDuration("retention.interval")
+	maxAge := viper.GetDuration("retention.max_age")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Infof("Starting data pruning with interval: %v, max age: %v", interval, maxAge)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+
+			if err := pruner.PruneOldData(ctx, maxAge); err != nil {
+				log.Errorf("Failed to prune old data: %v", err)
+			}
+
 			cancel()
 		}
 	}
 }
 
-func startCostCollection(collector *collectors.MetricsCollector, costProvider cloudprovider.Provider) {
+// startCostCollection collects costs every cost.collection_interval until ctx is
+// cancelled. With alerts set, notification thresholds are checked after each collection.
+func startCostCollection(ctx context.Context, collector *collectors.MetricsCollector, costProvider cloudprovider.Provider, alerts *alertChecker) {
 	interval := viper.GetDuration("cost.collection_interval")
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -298,14 +952,33 @@ func startCostCollection(collector *collectors.MetricsCollector, costProvider cl
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-			
-			if err := collector.CollectCosts(ctx, costProvider); err != nil {
+			collectCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			collectCtx, span := tracing.Start(collectCtx, "cost collection")
+
+			collector.FlushFailedWrites(collectCtx)
+
+			if err := collector.CollectCosts(collectCtx, costProvider); err != nil {
 				log.Errorf("Failed to collect costs: %v", err)
 			}
+
+			if !collector.Lightweight() {
+				if err := collector.CollectWorkloadCosts(collectCtx, costProvider); err != nil {
+					log.Errorf("Failed to collect workload costs: %v", err)
+				}
+			}
 			
+			span.End()
 			cancel()
+
+			// Notifications don't write anything, so shutdown doesn't wait for them
+			if alerts != nil {
+				alertCtx, alertCancel := context.WithTimeout(ctx, 10*time.Minute)
+				alerts.check(alertCtx)
+				alertCancel()
+			}
 		}
 	}
 } 
\ No newline at end of file
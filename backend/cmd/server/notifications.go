@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/notifier"
+
+	"github.com/spf13/viper"
+)
+
+// webhookConfig is one entry of notifications.webhooks
+type webhookConfig struct {
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+// alertChecker notifies when a namespace's potential savings, cost or projected spend
+// against its budget crosses the configured thresholds. It runs after each cost collection.
+type alertChecker struct {
+	db               *sql.DB
+	costs            store.CostRepository
+	analyzer         *analyzer.RightsizingAnalyzer
+	dispatcher       *notifier.Dispatcher
+	savingsThreshold float64
+	sensitivity      float64
+	baseURL          string
+}
+
+// initNotifications builds the alert checker from the notifications settings. It
+// returns nil when no destination is configured.
+func initNotifications(db *sql.DB, costs store.CostRepository, rightsizing *analyzer.RightsizingAnalyzer) *alertChecker {
+	var notifiers []notifier.Notifier
+	if webhookURL := viper.GetString("notifications.slack.webhook_url"); webhookURL != "" {
+		notifiers = append(notifiers, notifier.NewSlackNotifier(webhookURL))
+	}
+
+	var webhooks []webhookConfig
+	if err := viper.UnmarshalKey("notifications.webhooks", &webhooks); err != nil {
+		log.Fatalf("Invalid notifications.webhooks configuration: %v", err)
+	}
+	for _, webhook := range webhooks {
+		if webhook.URL == "" {
+			log.Fatalf("Every entry in notifications.webhooks needs a url")
+		}
+		notifiers = append(notifiers, notifier.NewWebhookNotifier(webhook.URL, webhook.Headers))
+	}
+
+	if len(notifiers) == 0 {
+		return nil
+	}
+
+	log.Infof("Sending notifications to %d destinations", len(notifiers))
+	return &alertChecker{
+		db:               db,
+		costs:            costs,
+		analyzer:         rightsizing,
+		dispatcher:       notifier.NewDispatcher(viper.GetDuration("notifications.cooldown"), notifiers...),
+		savingsThreshold: viper.GetFloat64("notifications.savings_threshold"),
+		sensitivity:      viper.GetFloat64("notifications.anomaly_sensitivity"),
+		baseURL:          viper.GetString("notifications.base_url"),
+	}
+}
+
+// check looks for namespaces over the savings threshold and for cost anomalies on the
+// last complete day, and notifies about each. Repeats are suppressed by the dispatcher.
+func (c *alertChecker) check(ctx context.Context) {
+	if err := c.checkSavings(ctx); err != nil {
+		log.Errorf("Failed to check savings notifications: %v", err)
+	}
+	if err := c.checkAnomalies(ctx); err != nil {
+		log.Errorf("Failed to check anomaly notifications: %v", err)
+	}
+	if err := c.checkBudgets(ctx); err != nil {
+		log.Errorf("Failed to check budget notifications: %v", err)
+	}
+}
+
+func (c *alertChecker) checkSavings(ctx context.Context) error {
+	if c.savingsThreshold <= 0 {
+		return nil
+	}
+
+	namespaces, err := c.costs.CostNamespaces(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		recommendations, err := c.analyzer.AnalyzeNamespace(ctx, namespace)
+		if err != nil {
+			log.Warnf("Failed to analyze namespace %s for notifications: %v", namespace, err)
+			continue
+		}
+
+		savings := 0.0
+		for _, rec := range recommendations {
+			savings += rec.PotentialSavings
+		}
+		if savings < c.savingsThreshold {
+			continue
+		}
+
+		c.dispatcher.Send(ctx, notifier.Notification{
+			Kind:      notifier.KindSavings,
+			Namespace: namespace,
+			Title:     "Potential savings found",
+			Summary: fmt.Sprintf("%d recommendations could save $%.2f/month in %s",
+				len(recommendations), savings, namespace),
+			Details: map[string]float64{
+				"monthly_savings": savings,
+				"recommendations": float64(len(recommendations)),
+			},
+			Link: c.link(namespace),
+		})
+	}
+
+	return nil
+}
+
+// checkAnomalies compares each namespace's cost on the last complete day with the
+// preceding week, flagging days more than the sensitivity in standard deviations above
+// the mean with analyzer.CostZScore, like the anomalies endpoint does
+func (c *alertChecker) checkAnomalies(ctx context.Context) error {
+	if c.sensitivity <= 0 {
+		return nil
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT
+			namespace,
+			DATE_TRUNC('day', timestamp) as day,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total
+		FROM namespace_costs
+		WHERE timestamp >= $1 AND timestamp < $2
+		GROUP BY namespace, day
+		ORDER BY namespace, day ASC
+	`, today.AddDate(0, 0, -(analyzer.AnomalyBaselineDays+1)), today)
+	if err != nil {
+		return fmt.Errorf("querying daily costs: %w", err)
+	}
+	defer rows.Close()
+
+	yesterday := today.AddDate(0, 0, -1)
+	series := make(map[string][]analyzer.DailyCost)
+	var namespaces []string
+	for rows.Next() {
+		var namespace string
+		var point analyzer.DailyCost
+		if err := rows.Scan(&namespace, &point.Day, &point.Total); err != nil {
+			continue
+		}
+		if _, ok := series[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+		series[namespace] = append(series[namespace], point)
+	}
+
+	for _, namespace := range namespaces {
+		days := series[namespace]
+		last := len(days) - 1
+		if !days[last].Day.Equal(yesterday) {
+			continue
+		}
+
+		observed := days[last].Total
+		mean, z, ok := analyzer.CostZScore(days, last)
+		if !ok || z <= c.sensitivity {
+			continue
+		}
+
+		c.dispatcher.Send(ctx, notifier.Notification{
+			Kind:      notifier.KindAnomaly,
+			Namespace: namespace,
+			Title:     "Cost anomaly detected",
+			Summary: fmt.Sprintf("%s cost $%.2f on %s, against $%.2f expected (%.1f standard deviations above normal)",
+				namespace, observed, yesterday.Format("2006-01-02"), mean, z),
+			Details: map[string]float64{
+				"observed_cost": observed,
+				"expected_cost": mean,
+				"z_score":       z,
+			},
+			Link: c.link(namespace),
+		})
+	}
+
+	return nil
+}
+
+// checkBudgets notifies when a namespace's projected spend for the month reaches 80%
+// or 100% of its budget. The two levels are separate kinds, so crossing 100% is still
+// reported within the cooldown of the 80% alert.
+func (c *alertChecker) checkBudgets(ctx context.Context) error {
+	budgets, err := c.analyzer.ListBudgets(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, budget := range budgets {
+		status, err := c.analyzer.CheckBudget(ctx, budget)
+		if err != nil {
+			log.Warnf("Failed to check budget for %s: %v", budget.Namespace, err)
+			continue
+		}
+
+		var kind, title string
+		switch status.Status {
+		case analyzer.BudgetOver:
+			kind, title = notifier.KindBudgetExceeded, "Budget exceeded"
+		case analyzer.BudgetAtRisk:
+			kind, title = notifier.KindBudgetAtRisk, "Budget at risk"
+		default:
+			continue
+		}
+
+		c.dispatcher.Send(ctx, notifier.Notification{
+			Kind:      kind,
+			Namespace: budget.Namespace,
+			Title:     title,
+			Summary: fmt.Sprintf("%s is projected to spend $%.2f this month, %.0f%% of its $%.2f budget",
+				budget.Namespace, status.ProjectedMonthly, status.Utilization*100, budget.MonthlyLimit),
+			Details: map[string]float64{
+				"month_to_date":     status.MonthToDate,
+				"projected_monthly": status.ProjectedMonthly,
+				"monthly_budget":    budget.MonthlyLimit,
+			},
+			Link: c.link(budget.Namespace),
+		})
+	}
+
+	return nil
+}
+
+// link returns the dashboard URL for the namespace, or "" without a base URL
+func (c *alertChecker) link(namespace string) string {
+	if c.baseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/?namespace=%s", strings.TrimSuffix(c.baseURL, "/"), url.QueryEscape(namespace))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s-cost-optimizer/internal/api"
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/mailer"
+	"k8s-cost-optimizer/pkg/notifier"
+
+	"github.com/spf13/viper"
+)
+
+// Time allowed to generate and deliver one schedule's reports
+const reportRunTimeout = 10 * time.Minute
+
+// reportDelivery sends scheduled reports by email or to a webhook, whichever the
+// schedule names. Either is nil when it isn't configured.
+type reportDelivery struct {
+	mailer  *mailer.Mailer
+	webhook *notifier.WebhookNotifier
+}
+
+// reportWebhookPayload is posted to reports.webhook_url for each run of a webhook schedule.
+// Attachment data is base64 encoded in the JSON.
+type reportWebhookPayload struct {
+	Kind        string              `json:"kind"`
+	ScheduleID  int64               `json:"schedule_id"`
+	Schedule    string              `json:"schedule"`
+	Attachments []mailer.Attachment `json:"attachments"`
+	Timestamp   time.Time           `json:"timestamp"`
+}
+
+// initReportDelivery builds the report delivery from the reports settings. SMTP is
+// used when reports.smtp.host is set, and the webhook when reports.webhook_url is.
+func initReportDelivery() *reportDelivery {
+	delivery := &reportDelivery{}
+
+	if host := viper.GetString("reports.smtp.host"); host != "" {
+		m, err := mailer.New(mailer.Config{
+			Host:     host,
+			Port:     viper.GetInt("reports.smtp.port"),
+			Username: viper.GetString("reports.smtp.username"),
+			Password: viper.GetString("reports.smtp.password"),
+			From:     viper.GetString("reports.smtp.from"),
+		})
+		if err != nil {
+			log.Fatalf("Invalid reports.smtp configuration: %v", err)
+		}
+		delivery.mailer = m
+	}
+
+	if webhookURL := viper.GetString("reports.webhook_url"); webhookURL != "" {
+		delivery.webhook = notifier.NewWebhookNotifier(webhookURL, viper.GetStringMapString("reports.webhook_headers"))
+	}
+
+	return delivery
+}
+
+// startReportSchedules checks for due report schedules each reports.check_interval
+// until ctx is cancelled, and sends each due schedule's reports. Runs missed while the
+// server was down are sent once at startup rather than once per missed run. A zero
+// interval disables it.
+func startReportSchedules(ctx context.Context, handler *api.Handler, schedules store.ReportScheduleRepository, delivery *reportDelivery) {
+	interval := viper.GetDuration("reports.check_interval")
+	if interval <= 0 {
+		log.Info("Scheduled reports disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Infof("Starting scheduled reports with check interval: %v", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runReportSchedules(ctx, handler, schedules, delivery)
+		}
+	}
+}
+
+// runReportSchedules sends every due schedule's reports. Each schedule is claimed by
+// moving its next run forward first, so when several replicas check at once only one
+// sends it.
+func runReportSchedules(ctx context.Context, handler *api.Handler, schedules store.ReportScheduleRepository, delivery *reportDelivery) {
+	now := time.Now()
+	due, err := schedules.DueReportSchedules(ctx, now)
+	if err != nil {
+		log.Errorf("Failed to load due report schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range due {
+		next, err := api.NextReportRun(schedule.Cron, now)
+		if err != nil {
+			log.Errorf("Report schedule %d (%s) can't be scheduled: %v", schedule.ID, schedule.Name, err)
+			continue
+		}
+		claimed, err := schedules.ClaimReportSchedule(ctx, schedule.ID, schedule.NextRunAt, next)
+		if err != nil {
+			log.Errorf("Failed to claim report schedule %d: %v", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		runCtx, cancel := context.WithTimeout(context.Background(), reportRunTimeout)
+		runErr := delivery.send(runCtx, handler, schedule)
+		cancel()
+
+		if runErr != nil {
+			log.Errorf("Failed to send report schedule %d (%s): %v", schedule.ID, schedule.Name, runErr)
+		} else {
+			log.Infof("Sent report schedule %d (%s), next run at %s", schedule.ID, schedule.Name, next.Format(time.RFC3339))
+		}
+		if err := schedules.RecordReportScheduleRun(ctx, schedule.ID, now, runErr); err != nil {
+			log.Warnf("Failed to record run of report schedule %d: %v", schedule.ID, err)
+		}
+	}
+}
+
+// send renders a report per namespace of the schedule, or one cluster-wide report, and
+// delivers them together
+func (d *reportDelivery) send(ctx context.Context, handler *api.Handler, schedule store.ReportSchedule) error {
+	namespaces := schedule.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	attachments := make([]mailer.Attachment, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		file, err := handler.RenderReport(ctx, namespace, schedule.Format)
+		if err != nil {
+			return fmt.Errorf("rendering report for %q: %w", namespace, err)
+		}
+		attachments = append(attachments, mailer.Attachment{
+			Filename:    file.Filename,
+			ContentType: file.ContentType,
+			Data:        file.Data,
+		})
+	}
+
+	switch schedule.Delivery {
+	case store.DeliveryEmail:
+		if d.mailer == nil {
+			return fmt.Errorf("email delivery needs reports.smtp.host configured")
+		}
+		scope := "the cluster"
+		if len(schedule.Namespaces) > 0 {
+			scope = strings.Join(schedule.Namespaces, ", ")
+		}
+		return d.mailer.Send(ctx, mailer.Message{
+			To:      schedule.Recipients,
+			Subject: fmt.Sprintf("Kubernetes cost report: %s", schedule.Name),
+			Body: fmt.Sprintf("The attached %s cost report for %s was generated by the %q schedule at %s.\n",
+				strings.ToUpper(schedule.Format), scope, schedule.Name, time.Now().UTC().Format(time.RFC1123)),
+			Attachments: attachments,
+		})
+	case store.DeliveryWebhook:
+		if d.webhook == nil {
+			return fmt.Errorf("webhook delivery needs reports.webhook_url configured")
+		}
+		return d.webhook.Post(ctx, reportWebhookPayload{
+			Kind:        "report",
+			ScheduleID:  schedule.ID,
+			Schedule:    schedule.Name,
+			Attachments: attachments,
+			Timestamp:   time.Now(),
+		})
+	default:
+		return fmt.Errorf("unknown delivery %q", schedule.Delivery)
+	}
+}
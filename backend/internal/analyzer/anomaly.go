@@ -0,0 +1,59 @@
+package analyzer
+
+import (
+	"math"
+	"time"
+)
+
+// Days of daily cost totals forming the baseline an anomalous day is compared against
+const AnomalyBaselineDays = 7
+
+// The baseline standard deviation is floored at this fraction of its mean, and at
+// minAnomalyStdDev dollars, so perfectly flat spend still flags a jump instead of
+// dividing by zero, while cent-level noise on it doesn't
+const (
+	anomalyStdDevFloor = 0.05
+	minAnomalyStdDev   = 0.01
+)
+
+// DailyCost is a namespace's total cost on one day
+type DailyCost struct {
+	Day   time.Time
+	Total float64
+}
+
+// CostZScore compares day i of the series, which is sorted by day, with the days in the
+// AnomalyBaselineDays before it. Baseline days are selected by date, so gaps in the
+// series shorten the baseline rather than stretching it back. It returns the baseline
+// mean and the day's deviation from it in (floored) standard deviations, and false when
+// fewer than two baseline days have costs.
+func CostZScore(series []DailyCost, i int) (expected, z float64, ok bool) {
+	day := series[i].Day
+	from := day.AddDate(0, 0, -AnomalyBaselineDays)
+
+	var baseline []float64
+	for j := i - 1; j >= 0 && !series[j].Day.Before(from); j-- {
+		baseline = append(baseline, series[j].Total)
+	}
+	if len(baseline) < 2 {
+		return 0, 0, false
+	}
+
+	mean, stddev := meanStdDev(baseline)
+	stddev = math.Max(stddev, math.Max(anomalyStdDevFloor*math.Abs(mean), minAnomalyStdDev))
+	return mean, (series[i].Total - mean) / stddev, true
+}
+
+// meanStdDev returns the mean and sample standard deviation of at least two values
+func meanStdDev(values []float64) (mean, stddev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var sumSq float64
+	for _, v := range values {
+		sumSq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sumSq / float64(len(values)-1))
+}
@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// dailyCosts returns the totals as consecutive days from start
+func dailyCosts(start time.Time, totals ...float64) []DailyCost {
+	series := make([]DailyCost, len(totals))
+	for i, total := range totals {
+		series[i] = DailyCost{Day: start.AddDate(0, 0, i), Total: total}
+	}
+	return series
+}
+
+func TestCostZScore(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("scores against the preceding week", func(t *testing.T) {
+		series := dailyCosts(start, 500, 500, 10, 12, 10, 12, 10, 12, 10, 30)
+		expected, z, ok := CostZScore(series, len(series)-1)
+		// The two $500 days fall outside the week before the last day
+		mean, stddev := meanStdDev([]float64{12, 10, 12, 10, 12, 10, 10})
+		if !ok || !approxEqual(expected, mean) || !approxEqual(z, (30-mean)/stddev) {
+			t.Errorf("CostZScore = %v, %v, %v, want %v, %v, true", expected, z, ok, mean, (30-mean)/stddev)
+		}
+	})
+
+	t.Run("flat spend flags a jump", func(t *testing.T) {
+		series := dailyCosts(start, 10, 10, 10, 10, 10, 10, 10, 20)
+		expected, z, ok := CostZScore(series, len(series)-1)
+		if !ok || expected != 10 || math.IsInf(z, 0) || z < 3 {
+			t.Errorf("CostZScore = %v, %v, %v, want a finite z-score above 3 against $10", expected, z, ok)
+		}
+	})
+
+	t.Run("flat spend ignores cent-level noise", func(t *testing.T) {
+		series := dailyCosts(start, 10, 10, 10, 10, 10, 10, 10, 10.01)
+		if _, z, ok := CostZScore(series, len(series)-1); !ok || z > 1 {
+			t.Errorf("CostZScore = %v, %v, want a z-score below 1", z, ok)
+		}
+	})
+
+	t.Run("flat zero spend flags new costs", func(t *testing.T) {
+		series := dailyCosts(start, 0, 0, 0, 0, 0, 0, 0, 5)
+		if _, z, ok := CostZScore(series, len(series)-1); !ok || math.IsInf(z, 0) || z < 3 {
+			t.Errorf("CostZScore = %v, %v, want a finite z-score above 3", z, ok)
+		}
+	})
+
+	t.Run("gaps shorten the baseline instead of reaching back", func(t *testing.T) {
+		// Two days of history a month back, then a single day last week
+		series := []DailyCost{
+			{Day: start, Total: 1},
+			{Day: start.AddDate(0, 0, 1), Total: 1},
+			{Day: start.AddDate(0, 0, 28), Total: 100},
+			{Day: start.AddDate(0, 0, 30), Total: 100},
+		}
+		if _, _, ok := CostZScore(series, 3); ok {
+			t.Error("scored a day with one baseline day in the preceding week")
+		}
+
+		series = append(series[:3], DailyCost{Day: start.AddDate(0, 0, 29), Total: 100}, series[3])
+		if expected, _, ok := CostZScore(series, 4); !ok || expected != 100 {
+			t.Errorf("expected cost = %v, %v, want 100 from the two days in the week", expected, ok)
+		}
+	})
+
+	t.Run("needs two baseline days", func(t *testing.T) {
+		if _, _, ok := CostZScore(dailyCosts(start, 10, 20), 1); ok {
+			t.Error("scored a day with a single baseline day")
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/internal/store"
+)
+
+// Budget statuses, by projected spend as a fraction of the budget
+const (
+	BudgetUnder  = "under"
+	BudgetAtRisk = "at_risk"
+	BudgetOver   = "over"
+)
+
+// Fraction of the budget at which projected spend counts as at risk
+const BudgetAtRiskThreshold = 0.8
+
+// Budget is a namespace's monthly spending limit. It is defined in the store, which
+// saves and loads it.
+type Budget = store.Budget
+
+// BudgetStatus compares a namespace's projected spend for the current month with its budget
+type BudgetStatus struct {
+	Budget
+	MonthToDate      float64 `json:"month_to_date"`
+	ProjectedMonthly float64 `json:"projected_monthly"`
+	Utilization      float64 `json:"utilization"` // Projected spend as a fraction of the budget
+	Status           string  `json:"status"`
+}
+
+// SetBudget creates or replaces the namespace's monthly budget
+func (ra *RightsizingAnalyzer) SetBudget(ctx context.Context, namespace string, monthlyLimit float64) (*Budget, error) {
+	if monthlyLimit <= 0 {
+		return nil, fmt.Errorf("monthly limit must be positive, got %.2f", monthlyLimit)
+	}
+
+	budget, err := ra.budgets.SaveBudget(ctx, namespace, monthlyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("storing budget: %w", err)
+	}
+	return budget, nil
+}
+
+// GetBudget returns the namespace's budget, or nil if it has none. Without a budget
+// repository no namespace has one.
+func (ra *RightsizingAnalyzer) GetBudget(ctx context.Context, namespace string) (*Budget, error) {
+	if ra.budgets == nil {
+		return nil, nil
+	}
+	budget, err := ra.budgets.Budget(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("querying budget: %w", err)
+	}
+	return budget, nil
+}
+
+// ListBudgets returns every budget, ordered by namespace
+func (ra *RightsizingAnalyzer) ListBudgets(ctx context.Context) ([]Budget, error) {
+	budgets, err := ra.budgets.Budgets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("querying budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+// DeleteBudget removes the namespace's budget and reports whether it existed
+func (ra *RightsizingAnalyzer) DeleteBudget(ctx context.Context, namespace string) (bool, error) {
+	deleted, err := ra.budgets.DeleteBudget(ctx, namespace)
+	if err != nil {
+		return false, fmt.Errorf("deleting budget: %w", err)
+	}
+	return deleted, nil
+}
+
+// CheckBudget projects the namespace's spend for the current calendar month (UTC) from
+// its month-to-date costs across all clusters and compares it with the budget. Spend is
+// at risk from 80% of the budget and over from 100%.
+func (ra *RightsizingAnalyzer) CheckBudget(ctx context.Context, budget Budget) (*BudgetStatus, error) {
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+
+	monthToDate, err := ra.costs.CurrentCost(ctx, budget.Namespace, monthStart)
+	if err != nil {
+		return nil, fmt.Errorf("querying month-to-date costs: %w", err)
+	}
+
+	// Project from the elapsed fraction of the month, counting partial days
+	elapsedDays := now.Sub(monthStart).Hours() / 24
+	projected := monthToDate
+	if elapsedDays > 0 {
+		projected = monthToDate / elapsedDays * float64(daysInMonth)
+	}
+
+	status := &BudgetStatus{
+		Budget:           budget,
+		MonthToDate:      monthToDate,
+		ProjectedMonthly: projected,
+		Utilization:      projected / budget.MonthlyLimit,
+	}
+	switch {
+	case status.Utilization >= 1:
+		status.Status = BudgetOver
+	case status.Utilization >= BudgetAtRiskThreshold:
+		status.Status = BudgetAtRisk
+	default:
+		status.Status = BudgetUnder
+	}
+
+	return status, nil
+}
@@ -0,0 +1,388 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Scheduling constraints that can block moving a pod off a node
+const (
+	ConstraintInsufficientCPU    = "insufficient-cpu"
+	ConstraintInsufficientMemory = "insufficient-memory"
+	ConstraintNodeSelector       = "node-selector"
+	ConstraintTaint              = "taint"
+	ConstraintPodAntiAffinity    = "pod-anti-affinity"
+	ConstraintTopologySpread     = "topology-spread"
+	ConstraintNoNodes            = "no-remaining-nodes"
+)
+
+// ConsolidationAnalyzer checks whether nodes can be removed while keeping every
+// displaced pod schedulable under its affinity and topology constraints
+type ConsolidationAnalyzer struct {
+	k8sClient    kubernetes.Interface
+	spotDiscount float64
+	log          *logrus.Logger
+}
+
+// ConsolidationFeasibility is the result of simulating the removal of a node
+type ConsolidationFeasibility struct {
+	NodeName          string            `json:"node_name"`
+	Feasible          bool              `json:"feasible"`
+	BindingConstraint string            `json:"binding_constraint,omitempty"`
+	BlockingPod       string            `json:"blocking_pod,omitempty"`
+	PodsToMove        int               `json:"pods_to_move"`
+	Placements        map[string]string `json:"placements,omitempty"` // namespace/pod -> target node
+}
+
+// nodeState tracks simulated free capacity and pods placed on a node
+type nodeState struct {
+	node    *corev1.Node
+	freeCPU int64 // millicores
+	freeMem int64 // bytes
+	pods    []*corev1.Pod
+}
+
+func NewConsolidationAnalyzer(k8sClient kubernetes.Interface) *ConsolidationAnalyzer {
+	return &ConsolidationAnalyzer{
+		k8sClient:    k8sClient,
+		spotDiscount: DefaultSpotDiscount,
+		log:          logrus.New(),
+	}
+}
+
+// CheckNodeRemoval simulates draining the node and rescheduling its pods onto the
+// remaining schedulable nodes. DaemonSet and static pods are not moved.
+func (ca *ConsolidationAnalyzer) CheckNodeRemoval(ctx context.Context, nodeName string) (*ConsolidationFeasibility, error) {
+	nodes, err := ca.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	pods, err := ca.k8sClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	return simulateNodeRemoval(nodeName, nodes.Items, pods.Items), nil
+}
+
+// CheckAllNodes evaluates the removal of every node, ordered by node name
+func (ca *ConsolidationAnalyzer) CheckAllNodes(ctx context.Context) ([]ConsolidationFeasibility, error) {
+	nodes, err := ca.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	pods, err := ca.k8sClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	var results []ConsolidationFeasibility
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		results = append(results, *simulateNodeRemoval(node.Name, nodes.Items, pods.Items))
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].NodeName < results[j].NodeName })
+	return results, nil
+}
+
+func simulateNodeRemoval(nodeName string, nodes []corev1.Node, pods []corev1.Pod) *ConsolidationFeasibility {
+	result := &ConsolidationFeasibility{
+		NodeName:   nodeName,
+		Feasible:   true,
+		Placements: make(map[string]string),
+	}
+
+	// Build simulated state for the remaining nodes
+	states := make(map[string]*nodeState)
+	var order []string
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Name == nodeName || node.Spec.Unschedulable {
+			continue
+		}
+		states[node.Name] = &nodeState{
+			node:    node,
+			freeCPU: node.Status.Allocatable.Cpu().MilliValue(),
+			freeMem: node.Status.Allocatable.Memory().Value(),
+		}
+		order = append(order, node.Name)
+	}
+	sort.Strings(order)
+
+	var displaced []*corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		if pod.Spec.NodeName == nodeName {
+			if !isDaemonOrStaticPod(pod) {
+				displaced = append(displaced, pod)
+			}
+			continue
+		}
+
+		if state, ok := states[pod.Spec.NodeName]; ok {
+			cpu, mem := podRequests(pod)
+			state.freeCPU -= cpu
+			state.freeMem -= mem
+			state.pods = append(state.pods, pod)
+		}
+	}
+
+	result.PodsToMove = len(displaced)
+
+	// Place the largest pods first, as a scheduler-like first-fit decreasing heuristic
+	sort.Slice(displaced, func(i, j int) bool {
+		ci, mi := podRequests(displaced[i])
+		cj, mj := podRequests(displaced[j])
+		if ci != cj {
+			return ci > cj
+		}
+		return mi > mj
+	})
+
+	for _, pod := range displaced {
+		if len(order) == 0 {
+			result.Feasible = false
+			result.BindingConstraint = ConstraintNoNodes
+			result.BlockingPod = pod.Namespace + "/" + pod.Name
+			result.Placements = nil
+			return result
+		}
+
+		// Track why each node rejected the pod so we can report the binding constraint
+		rejections := make(map[string]int)
+		placed := false
+
+		for _, name := range order {
+			state := states[name]
+			if reason := checkPlacement(pod, state, states); reason != "" {
+				rejections[reason]++
+				continue
+			}
+
+			cpu, mem := podRequests(pod)
+			state.freeCPU -= cpu
+			state.freeMem -= mem
+			state.pods = append(state.pods, pod)
+			result.Placements[pod.Namespace+"/"+pod.Name] = name
+			placed = true
+			break
+		}
+
+		if !placed {
+			result.Feasible = false
+			result.BindingConstraint = mostCommon(rejections)
+			result.BlockingPod = pod.Namespace + "/" + pod.Name
+			result.Placements = nil
+			return result
+		}
+	}
+
+	return result
+}
+
+// checkPlacement returns the first constraint that prevents the pod from running on the node
+func checkPlacement(pod *corev1.Pod, target *nodeState, states map[string]*nodeState) string {
+	cpu, mem := podRequests(pod)
+	if cpu > target.freeCPU {
+		return ConstraintInsufficientCPU
+	}
+	if mem > target.freeMem {
+		return ConstraintInsufficientMemory
+	}
+
+	for key, value := range pod.Spec.NodeSelector {
+		if target.node.Labels[key] != value {
+			return ConstraintNodeSelector
+		}
+	}
+
+	for _, taint := range target.node.Spec.Taints {
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		if !toleratesTaint(pod.Spec.Tolerations, &taint) {
+			return ConstraintTaint
+		}
+	}
+
+	if violatesAntiAffinity(pod, target, states) {
+		return ConstraintPodAntiAffinity
+	}
+
+	if violatesTopologySpread(pod, target, states) {
+		return ConstraintTopologySpread
+	}
+
+	return ""
+}
+
+// violatesAntiAffinity checks required pod anti-affinity in both directions: the
+// incoming pod's terms against pods already in the topology domain, and existing
+// pods' terms against the incoming pod
+func violatesAntiAffinity(pod *corev1.Pod, target *nodeState, states map[string]*nodeState) bool {
+	if pod.Spec.Affinity != nil && pod.Spec.Affinity.PodAntiAffinity != nil {
+		for _, term := range pod.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			domain, ok := target.node.Labels[term.TopologyKey]
+			if !ok {
+				continue
+			}
+			for _, state := range states {
+				if state.node.Labels[term.TopologyKey] != domain {
+					continue
+				}
+				for _, existing := range state.pods {
+					if termMatches(term, pod.Namespace, existing) {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	for _, existing := range target.pods {
+		if existing.Spec.Affinity == nil || existing.Spec.Affinity.PodAntiAffinity == nil {
+			continue
+		}
+		for _, term := range existing.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if termMatches(term, existing.Namespace, pod) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// violatesTopologySpread checks DoNotSchedule topology spread constraints: placing the
+// pod in the target's domain must not push that domain more than MaxSkew above the least
+// populated domain
+func violatesTopologySpread(pod *corev1.Pod, target *nodeState, states map[string]*nodeState) bool {
+	for _, constraint := range pod.Spec.TopologySpreadConstraints {
+		if constraint.WhenUnsatisfiable != corev1.DoNotSchedule {
+			continue
+		}
+
+		targetDomain, ok := target.node.Labels[constraint.TopologyKey]
+		if !ok {
+			return true
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(constraint.LabelSelector)
+		if err != nil {
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, state := range states {
+			domain, ok := state.node.Labels[constraint.TopologyKey]
+			if !ok {
+				continue
+			}
+			if _, seen := counts[domain]; !seen {
+				counts[domain] = 0
+			}
+			for _, existing := range state.pods {
+				if existing.Namespace == pod.Namespace && selector.Matches(labels.Set(existing.Labels)) {
+					counts[domain]++
+				}
+			}
+		}
+
+		minCount := -1
+		for _, count := range counts {
+			if minCount < 0 || count < minCount {
+				minCount = count
+			}
+		}
+
+		if counts[targetDomain]+1-minCount > int(constraint.MaxSkew) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// termMatches reports whether the candidate pod is selected by the affinity term.
+// A term with a namespace selector is treated as matching all namespaces.
+func termMatches(term corev1.PodAffinityTerm, ownerNamespace string, candidate *corev1.Pod) bool {
+	if term.NamespaceSelector == nil {
+		namespaces := term.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = []string{ownerNamespace}
+		}
+		inScope := false
+		for _, ns := range namespaces {
+			if ns == candidate.Namespace {
+				inScope = true
+				break
+			}
+		}
+		if !inScope {
+			return false
+		}
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(candidate.Labels))
+}
+
+func toleratesTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// podRequests sums the container CPU (millicores) and memory (bytes) requests of a pod
+func podRequests(pod *corev1.Pod) (int64, int64) {
+	var cpu, mem int64
+	for _, container := range pod.Spec.Containers {
+		cpu += container.Resources.Requests.Cpu().MilliValue()
+		mem += container.Resources.Requests.Memory().Value()
+	}
+	return cpu, mem
+}
+
+func isDaemonOrStaticPod(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return true
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+func mostCommon(counts map[string]int) string {
+	best, bestCount := "", 0
+	for reason, count := range counts {
+		if count > bestCount || (count == bestCount && reason < best) {
+			best, bestCount = reason, count
+		}
+	}
+	return best
+}
@@ -0,0 +1,86 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// gpuHeadroom is the margin kept above P95 GPU usage when sizing the GPU count
+const gpuHeadroom = 1.2
+
+// AnalyzeGPU recommends reducing the GPU count of containers whose sustained usage,
+// measured as busy-GPU equivalents (utilization x devices), needs fewer GPUs than
+// requested. An empty cluster analyzes the namespace across all clusters.
+func (ra *RightsizingAnalyzer) AnalyzeGPU(ctx context.Context, namespace, cluster string) ([]Recommendation, error) {
+	stats, err := ra.gpu.GPUUsageStats(ctx, namespace, cluster, time.Now().Add(-ra.analysisWindow), ra.minDataPoints)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := ra.resourcePrices(ctx)
+
+	var recommendations []Recommendation
+
+	for _, stat := range stats {
+		podName, containerName := stat.PodName, stat.ContainerName
+		gpuRequest, gpuLimit, err := ra.gpu.GPURequest(ctx, namespace, cluster, podName, containerName)
+		if err != nil {
+			ra.log.Warnf("Failed to get GPU request for %s/%s: %v", podName, containerName, err)
+			continue
+		}
+
+		rec := ra.calculateGPURecommendation(gpuRequest, gpuLimit, usageStats(stat.Usage), stat.Samples, prices)
+		if rec == nil {
+			continue
+		}
+
+		rec.Namespace = namespace
+		rec.PodName = podName
+		rec.ContainerName = containerName
+		rec.LastUpdated = time.Now()
+		recommendations = append(recommendations, *rec)
+	}
+
+	return recommendations, nil
+}
+
+func (ra *RightsizingAnalyzer) calculateGPURecommendation(currentRequest, currentLimit float64,
+	usage usageStats, dataPoints int, prices *ResourcePrices) *Recommendation {
+	cv := 0.0
+	if usage.Avg > 0 {
+		cv = usage.StdDev / usage.Avg
+	}
+	confidence := ra.calculateConfidence(dataPoints, cv)
+
+	// GPUs are allocated whole, and a pod needs at least one to keep running
+	recommended := math.Max(math.Ceil(usage.P95*gpuHeadroom), 1)
+	if recommended >= currentRequest {
+		return nil
+	}
+
+	monthlySavings := (currentRequest - recommended) * prices.PerGPUHour * 24 * 30
+
+	riskLevel := "LOW"
+	if usage.Max > recommended {
+		riskLevel = "MEDIUM"
+	}
+
+	return &Recommendation{
+		ResourceType:       "GPU",
+		CurrentRequest:     currentRequest,
+		CurrentLimit:       currentLimit,
+		RecommendedRequest: recommended,
+		RecommendedLimit:   recommended,
+		P50Usage:           usage.P50,
+		P95Usage:           usage.P95,
+		P99Usage:           usage.P99,
+		MaxUsage:           usage.Max,
+		PotentialSavings:   monthlySavings,
+		Confidence:         confidence,
+		Reasoning: fmt.Sprintf("Sustained GPU usage of %.2f GPUs at P95 across %d requested, using P95 + 20%% rounded up",
+			usage.P95, int(currentRequest)),
+		RiskLevel: riskLevel,
+	}
+}
@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Horizontal scaling analysis parameters
+const (
+	// hpaTargetUtilization is the targetCPUUtilizationPercentage we suggest for HPAs
+	hpaTargetUtilization = 70
+	// hpaSaturationThreshold is the utilization of requested CPU above which a
+	// 5-minute bucket counts as saturated
+	hpaSaturationThreshold = 0.8
+	// hpaBurstRatio is the P95/P50 aggregate usage ratio above which a workload is bursty
+	hpaBurstRatio = 2.0
+)
+
+// deploymentPodPattern matches pods created through a Deployment's ReplicaSet, i.e.
+// <deployment>-<pod-template-hash>-<suffix>, and captures the Deployment name
+const deploymentPodPattern = `^(.*)-[a-z0-9]{5,10}-[a-z0-9]{5}$`
+
+// HorizontalRecommendation suggests replica bounds and an HPA target for a Deployment
+type HorizontalRecommendation struct {
+	Namespace                      string    `json:"namespace"`
+	Deployment                     string    `json:"deployment"`
+	CurrentReplicas                int       `json:"current_replicas"`
+	SuggestedMinReplicas           int       `json:"suggested_min_replicas"`
+	SuggestedMaxReplicas           int       `json:"suggested_max_replicas"`
+	TargetCPUUtilizationPercentage int       `json:"target_cpu_utilization_percentage"`
+	PodCPURequest                  float64   `json:"pod_cpu_request"`
+	P50CPUUsage                    float64   `json:"p50_cpu_usage"`
+	P95CPUUsage                    float64   `json:"p95_cpu_usage"`
+	P95Utilization                 float64   `json:"p95_utilization"`
+	SaturatedFraction              float64   `json:"saturated_fraction"`
+	Reasoning                      string    `json:"reasoning"`
+	LastUpdated                    time.Time `json:"last_updated"`
+}
+
+// AnalyzeHorizontalScaling looks at each Deployment's aggregate CPU usage in 5-minute
+// buckets and recommends an HPA when the current replicas regularly saturate their CPU
+// requests, or when usage is bursty enough that a fixed replica count is sized for peak
+func (ra *RightsizingAnalyzer) AnalyzeHorizontalScaling(ctx context.Context, namespace string) ([]HorizontalRecommendation, error) {
+	rows, err := ra.db.QueryContext(ctx, `
+		WITH latest_requests AS (
+			SELECT DISTINCT ON (pod_name, container_name)
+				pod_name, container_name, cpu_request
+			FROM resource_requests
+			WHERE namespace = $1 AND timestamp > $2
+			ORDER BY pod_name, container_name, timestamp DESC
+		),
+		deployment_requests AS (
+			SELECT deployment, AVG(cpu_request) AS pod_cpu_request
+			FROM (
+				SELECT substring(pod_name from $3) AS deployment, pod_name, SUM(cpu_request) AS cpu_request
+				FROM latest_requests
+				WHERE pod_name ~ $3
+				GROUP BY pod_name
+			) pods
+			GROUP BY deployment
+		),
+		buckets AS (
+			SELECT
+				substring(pod_name from $3) AS deployment,
+				time_bucket('5 minutes', timestamp) AS bucket,
+				SUM(cpu_millicores) AS cpu_usage,
+				COUNT(DISTINCT pod_name) AS replicas
+			FROM pod_metrics
+			WHERE namespace = $1 AND timestamp > $2 AND pod_name ~ $3
+			GROUP BY deployment, bucket
+		)
+		SELECT
+			b.deployment,
+			(ARRAY_AGG(b.replicas ORDER BY b.bucket DESC))[1] AS current_replicas,
+			dr.pod_cpu_request,
+			PERCENTILE_CONT(0.50) WITHIN GROUP (ORDER BY b.cpu_usage) AS p50_usage,
+			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY b.cpu_usage) AS p95_usage,
+			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY b.cpu_usage / (b.replicas * dr.pod_cpu_request)) AS p95_utilization,
+			AVG(CASE WHEN b.cpu_usage / (b.replicas * dr.pod_cpu_request) > $4 THEN 1.0 ELSE 0.0 END) AS saturated_fraction
+		FROM buckets b
+		JOIN deployment_requests dr ON dr.deployment = b.deployment
+		WHERE dr.pod_cpu_request > 0
+		GROUP BY b.deployment, dr.pod_cpu_request
+		HAVING COUNT(*) >= $5
+	`, namespace, time.Now().Add(-ra.analysisWindow), deploymentPodPattern, hpaSaturationThreshold, ra.minDataPoints)
+
+	if err != nil {
+		return nil, fmt.Errorf("querying deployment usage: %w", err)
+	}
+	defer rows.Close()
+
+	var recommendations []HorizontalRecommendation
+
+	for rows.Next() {
+		rec := HorizontalRecommendation{
+			Namespace:                      namespace,
+			TargetCPUUtilizationPercentage: hpaTargetUtilization,
+			LastUpdated:                    time.Now(),
+		}
+
+		err := rows.Scan(&rec.Deployment, &rec.CurrentReplicas, &rec.PodCPURequest,
+			&rec.P50CPUUsage, &rec.P95CPUUsage, &rec.P95Utilization, &rec.SaturatedFraction)
+		if err != nil {
+			ra.log.Warnf("Failed to scan deployment usage: %v", err)
+			continue
+		}
+
+		// Replicas needed to serve a given aggregate usage at the target utilization
+		perReplica := rec.PodCPURequest * hpaTargetUtilization / 100
+		rec.SuggestedMinReplicas = int(math.Max(math.Ceil(rec.P50CPUUsage/perReplica), 1))
+		rec.SuggestedMaxReplicas = int(math.Max(math.Ceil(rec.P95CPUUsage/perReplica), float64(rec.SuggestedMinReplicas)))
+
+		saturated := rec.P95Utilization >= hpaSaturationThreshold
+		bursty := rec.P50CPUUsage > 0 && rec.P95CPUUsage/rec.P50CPUUsage >= hpaBurstRatio &&
+			rec.SuggestedMinReplicas < rec.CurrentReplicas
+
+		switch {
+		case saturated:
+			// Leave headroom above the observed peak for growth
+			rec.SuggestedMaxReplicas = int(math.Max(float64(rec.SuggestedMaxReplicas), float64(rec.CurrentReplicas+1)))
+			rec.Reasoning = fmt.Sprintf("CPU exceeds %.0f%% of requests in %.0f%% of 5-minute intervals (P95 utilization %.0f%%) "+
+				"at %d replicas; scale between %d and %d replicas at %d%% target utilization",
+				hpaSaturationThreshold*100, rec.SaturatedFraction*100, rec.P95Utilization*100,
+				rec.CurrentReplicas, rec.SuggestedMinReplicas, rec.SuggestedMaxReplicas, hpaTargetUtilization)
+		case bursty:
+			rec.Reasoning = fmt.Sprintf("Bursty usage (P95 is %.1fx P50) with %d fixed replicas sized for peak; "+
+				"an HPA could run %d replicas off-peak and scale to %d",
+				rec.P95CPUUsage/rec.P50CPUUsage, rec.CurrentReplicas, rec.SuggestedMinReplicas, rec.SuggestedMaxReplicas)
+		default:
+			continue
+		}
+
+		recommendations = append(recommendations, rec)
+	}
+
+	return recommendations, nil
+}
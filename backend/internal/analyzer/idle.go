@@ -0,0 +1,159 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Pod name shapes used to tell which kind of controller created an idle pod
+var (
+	cronJobPodName    = regexp.MustCompile(`^(.*)-[0-9]{8,10}-[a-z0-9]{5}$`)
+	deploymentPodName = regexp.MustCompile(deploymentPodPattern)
+)
+
+// IdleWorkload is a pod whose CPU usage and network traffic stayed below the idle
+// thresholds for the whole idle window
+type IdleWorkload struct {
+	Namespace      string     `json:"namespace"`
+	PodName        string     `json:"pod_name"`
+	Workload       string     `json:"workload"`
+	WorkloadKind   string     `json:"workload_kind"`
+	PeakCPU        float64    `json:"peak_cpu"`
+	PeakNetwork    *float64   `json:"peak_network_bytes_per_second"` // nil if the pod's traffic wasn't collected
+	CPURequest     float64    `json:"cpu_request"`
+	MemoryRequest  float64    `json:"memory_request"`
+	LastActive     *time.Time `json:"last_active"` // nil if never seen above the threshold
+	MonthlyWaste   float64    `json:"monthly_waste"`
+	Recommendation string     `json:"recommendation"`
+	Reasoning      string     `json:"reasoning"`
+}
+
+// AnalyzeIdleWorkloads finds pods that have been idle for the analyzer's idle window
+func (ra *RightsizingAnalyzer) AnalyzeIdleWorkloads(ctx context.Context, namespace string) ([]IdleWorkload, error) {
+	return ra.AnalyzeIdleWorkloadsWithWindow(ctx, namespace, ra.idleWindow)
+}
+
+// AnalyzeIdleWorkloadsWithWindow finds pods that have been observed for the whole window
+// without their total CPU usage exceeding the idle threshold, or their network traffic
+// (received plus transmitted, averaged per hour) exceeding the idle network threshold.
+// A low-CPU pod serving traffic isn't idle. Pods whose traffic wasn't collected are
+// judged on CPU alone and say so. Pods that are only quiet overnight are excluded as
+// long as the window is longer than the quiet period.
+func (ra *RightsizingAnalyzer) AnalyzeIdleWorkloadsWithWindow(ctx context.Context, namespace string, window time.Duration) ([]IdleWorkload, error) {
+	windowStart := time.Now().Add(-window)
+	// Allow for the first collection cycle landing slightly after the window start
+	observedBy := windowStart.Add(window / 10)
+
+	rows, err := ra.db.QueryContext(ctx, `
+		WITH pod_usage AS (
+			SELECT pod_name, timestamp, SUM(cpu_millicores) AS cpu
+			FROM pod_metrics
+			WHERE namespace = $1 AND timestamp > $2
+			GROUP BY pod_name, timestamp
+		),
+		activity AS (
+			SELECT pod_name, MAX(cpu) AS peak_cpu
+			FROM pod_usage
+			GROUP BY pod_name
+			HAVING MIN(timestamp) <= $3 AND MAX(cpu) < $4
+		),
+		last_active AS (
+			SELECT pod_name, MAX(timestamp) AS last_active
+			FROM pod_metrics
+			WHERE namespace = $1 AND cpu_millicores >= $4
+			GROUP BY pod_name
+		),
+		network AS (
+			SELECT pod_name, MAX(bytes) / 3600 AS peak_rate
+			FROM (
+				SELECT pod_name, DATE_TRUNC('hour', timestamp) AS hour, SUM(bytes) AS bytes
+				FROM network_metrics
+				WHERE namespace = $1 AND pod_name <> '' AND timestamp > $2
+				GROUP BY pod_name, hour
+			) hourly
+			GROUP BY pod_name
+		),
+		latest_requests AS (
+			SELECT DISTINCT ON (pod_name, container_name)
+				pod_name, cpu_request, memory_request
+			FROM resource_requests
+			WHERE namespace = $1 AND timestamp > $2
+			ORDER BY pod_name, container_name, timestamp DESC
+		)
+		SELECT
+			a.pod_name,
+			a.peak_cpu,
+			n.peak_rate,
+			COALESCE(SUM(r.cpu_request), 0),
+			COALESCE(SUM(r.memory_request), 0),
+			la.last_active
+		FROM activity a
+		LEFT JOIN latest_requests r ON r.pod_name = a.pod_name
+		LEFT JOIN last_active la ON la.pod_name = a.pod_name
+		LEFT JOIN network n ON n.pod_name = a.pod_name
+		WHERE n.peak_rate IS NULL OR n.peak_rate < $5
+		GROUP BY a.pod_name, a.peak_cpu, n.peak_rate, la.last_active
+		ORDER BY a.pod_name
+	`, namespace, windowStart, observedBy, ra.idleCPUThreshold, ra.idleNetworkThreshold)
+
+	if err != nil {
+		return nil, fmt.Errorf("querying idle pods: %w", err)
+	}
+	defer rows.Close()
+
+	prices := ra.resourcePrices(ctx)
+	idle := []IdleWorkload{}
+
+	for rows.Next() {
+		w := IdleWorkload{Namespace: namespace}
+		var lastActive *time.Time
+
+		if err := rows.Scan(&w.PodName, &w.PeakCPU, &w.PeakNetwork, &w.CPURequest, &w.MemoryRequest, &lastActive); err != nil {
+			ra.log.Warnf("Failed to scan idle pod: %v", err)
+			continue
+		}
+		w.LastActive = lastActive
+
+		hourlyCost := w.CPURequest*prices.PerMillicoreHour + w.MemoryRequest*prices.PerByteHour
+		w.MonthlyWaste = hourlyCost * 24 * 30
+
+		w.Workload, w.WorkloadKind = classifyPod(w.PodName)
+		switch w.WorkloadKind {
+		case "CronJob":
+			// A job pod that is still running but doing nothing is usually stuck
+			w.Recommendation = "investigate"
+			w.Reasoning = fmt.Sprintf("Job pod from CronJob %s has been running for over %v without using more than %.0fm CPU; "+
+				"it may be hung, consider setting activeDeadlineSeconds", w.Workload, window, ra.idleCPUThreshold)
+		case "Deployment":
+			w.Recommendation = "scale_to_zero"
+			w.Reasoning = fmt.Sprintf("Peak CPU %.1fm over the last %v is below the %.0fm idle threshold; "+
+				"scale Deployment %s to zero or delete it if unused", w.PeakCPU, window, ra.idleCPUThreshold, w.Workload)
+		default:
+			w.Recommendation = "delete"
+			w.Reasoning = fmt.Sprintf("Peak CPU %.1fm over the last %v is below the %.0fm idle threshold; "+
+				"delete the pod or its controller if unused", w.PeakCPU, window, ra.idleCPUThreshold)
+		}
+		if w.PeakNetwork == nil {
+			w.Reasoning += "; network traffic wasn't collected, so check the pod isn't serving requests"
+		} else {
+			w.Reasoning += fmt.Sprintf("; network traffic peaked at %.0f B/s", *w.PeakNetwork)
+		}
+
+		idle = append(idle, w)
+	}
+
+	return idle, nil
+}
+
+// classifyPod guesses the owning workload and its kind from the pod's generated name
+func classifyPod(podName string) (string, string) {
+	if m := cronJobPodName.FindStringSubmatch(podName); m != nil {
+		return m[1], "CronJob"
+	}
+	if m := deploymentPodName.FindStringSubmatch(podName); m != nil {
+		return m[1], "Deployment"
+	}
+	return podName, "Pod"
+}
@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodePoolLabels are the labels managed node groups carry, in the order checked
+var nodePoolLabels = []string{
+	"cloud.google.com/gke-nodepool",
+	"eks.amazonaws.com/nodegroup",
+	"karpenter.sh/nodepool",
+	"kubernetes.azure.com/agentpool",
+	"agentpool",
+}
+
+// PodRequests is a pod's total CPU (millicores) and memory (bytes) request
+type PodRequests struct {
+	CPU    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
+}
+
+// NodePoolConsolidation is how many of a node pool's nodes of one instance type its
+// pods would need with their recommended requests
+type NodePoolConsolidation struct {
+	Pool              string  `json:"pool"`
+	InstanceType      string  `json:"instance_type"`
+	Nodes             int     `json:"nodes"`
+	NodesRequired     int     `json:"nodes_required"`
+	RemovableNodes    int     `json:"removable_nodes"`
+	Pods              int     `json:"pods"`
+	UnplacedPods      int     `json:"unplaced_pods"`      // Pods too large for any node once packed
+	CPURequested      float64 `json:"cpu_requested"`      // Millicores, with recommendations applied
+	MemoryRequested   float64 `json:"memory_requested"`   // Bytes, with recommendations applied
+	CPUAllocatable    float64 `json:"cpu_allocatable"`    // Millicores
+	MemoryAllocatable float64 `json:"memory_allocatable"` // Bytes
+	MonthlySavings    float64 `json:"monthly_savings"`
+}
+
+// ConsolidationPlan estimates the nodes that become unnecessary once every
+// recommendation is applied
+type ConsolidationPlan struct {
+	Pools          []NodePoolConsolidation `json:"pools"`
+	RemovableNodes int                     `json:"removable_nodes"`
+	MonthlySavings float64                 `json:"monthly_savings"`
+}
+
+type nodePoolKey struct {
+	pool         string
+	instanceType string
+}
+
+// nodePool returns the node's pool from the labels of common managed node groups
+func nodePool(node *corev1.Node) string {
+	for _, label := range nodePoolLabels {
+		if pool, ok := node.Labels[label]; ok {
+			return pool
+		}
+	}
+	return ""
+}
+
+// PlanConsolidation bin-packs each node pool's pods, at their recommended requests,
+// onto as few of the pool's nodes as fit them, and prices the nodes left empty.
+// Requests are keyed by namespace/pod; pods without an entry keep their spec requests.
+// Only nodes in reporting are considered, and cordoned nodes are skipped. Nodes are
+// priced from nodeCosts, or from their allocatable CPU and memory at the given
+// prices when the provider has no cost for them.
+//
+// Pods stay in the pool they run in and only their CPU and memory requests are
+// packed, so this is an upper bound: use CheckNodeRemoval to confirm a given node
+// can go under the pods' scheduling constraints.
+func (ca *ConsolidationAnalyzer) PlanConsolidation(ctx context.Context, requests map[string]PodRequests,
+	reporting map[string]bool, nodeCosts map[string]float64, prices *ResourcePrices) (*ConsolidationPlan, error) {
+	nodes, err := ca.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	pods, err := ca.k8sClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	pools := make(map[nodePoolKey][]*nodeState)
+	poolOf := make(map[string]nodePoolKey)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if node.Spec.Unschedulable || !reporting[node.Name] {
+			continue
+		}
+		key := nodePoolKey{nodePool(node), node.Labels[corev1.LabelInstanceTypeStable]}
+		pools[key] = append(pools[key], &nodeState{
+			node:    node,
+			freeCPU: node.Status.Allocatable.Cpu().MilliValue(),
+			freeMem: node.Status.Allocatable.Memory().Value(),
+		})
+		poolOf[node.Name] = key
+	}
+
+	// DaemonSet and static pods stay on their node, so they reduce its capacity;
+	// every other pod is repacked within its pool
+	movable := make(map[nodePoolKey][]PodRequests)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		key, ok := poolOf[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+
+		podRequest, ok := requests[pod.Namespace+"/"+pod.Name]
+		if !ok {
+			cpu, mem := podRequests(pod)
+			podRequest = PodRequests{CPU: float64(cpu), Memory: float64(mem)}
+		}
+
+		if isDaemonOrStaticPod(pod) {
+			for _, state := range pools[key] {
+				if state.node.Name == pod.Spec.NodeName {
+					state.freeCPU -= int64(podRequest.CPU)
+					state.freeMem -= int64(podRequest.Memory)
+					break
+				}
+			}
+			continue
+		}
+		movable[key] = append(movable[key], podRequest)
+	}
+
+	keys := make([]nodePoolKey, 0, len(pools))
+	for key := range pools {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].pool != keys[j].pool {
+			return keys[i].pool < keys[j].pool
+		}
+		return keys[i].instanceType < keys[j].instanceType
+	})
+
+	plan := &ConsolidationPlan{}
+	for _, key := range keys {
+		result := packNodePool(pools[key], movable[key], nodeCosts, prices)
+		result.Pool = key.pool
+		result.InstanceType = key.instanceType
+
+		plan.Pools = append(plan.Pools, result)
+		plan.RemovableNodes += result.RemovableNodes
+		plan.MonthlySavings += result.MonthlySavings
+	}
+
+	return plan, nil
+}
+
+// packNodePool places the pods first-fit decreasing: each pod goes on a node already
+// holding pods if one fits it, and otherwise on the empty node with the most free
+// capacity. Nodes left empty are removable. A pod that fits no node leaves the pool
+// as it is.
+func packNodePool(states []*nodeState, pods []PodRequests, nodeCosts map[string]float64, prices *ResourcePrices) NodePoolConsolidation {
+	result := NodePoolConsolidation{Nodes: len(states), Pods: len(pods)}
+	for _, state := range states {
+		result.CPUAllocatable += float64(state.node.Status.Allocatable.Cpu().MilliValue())
+		result.MemoryAllocatable += float64(state.node.Status.Allocatable.Memory().Value())
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].freeCPU != states[j].freeCPU {
+			return states[i].freeCPU > states[j].freeCPU
+		}
+		if states[i].freeMem != states[j].freeMem {
+			return states[i].freeMem > states[j].freeMem
+		}
+		return states[i].node.Name < states[j].node.Name
+	})
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].CPU != pods[j].CPU {
+			return pods[i].CPU > pods[j].CPU
+		}
+		return pods[i].Memory > pods[j].Memory
+	})
+
+	used := make([]bool, len(states))
+	for _, pod := range pods {
+		result.CPURequested += pod.CPU
+		result.MemoryRequested += pod.Memory
+
+		target := -1
+		cpu, mem := int64(pod.CPU), int64(pod.Memory)
+		for i, state := range states {
+			if cpu > state.freeCPU || mem > state.freeMem {
+				continue
+			}
+			if used[i] {
+				target = i
+				break
+			}
+			if target < 0 {
+				target = i
+			}
+		}
+		if target < 0 {
+			result.UnplacedPods++
+			continue
+		}
+		states[target].freeCPU -= cpu
+		states[target].freeMem -= mem
+		used[target] = true
+	}
+
+	if result.UnplacedPods > 0 {
+		result.NodesRequired = len(states)
+		return result
+	}
+
+	// An empty pool still keeps one node for its DaemonSets and new pods
+	if len(states) > 0 && result.Pods == 0 {
+		used[0] = true
+	}
+
+	for i, state := range states {
+		if used[i] {
+			result.NodesRequired++
+			continue
+		}
+		result.RemovableNodes++
+		result.MonthlySavings += nodeHourlyCost(state.node, nodeCosts, prices) * 24 * 30
+	}
+	return result
+}
+
+// nodeHourlyCost is the provider's cost for the node, or its allocatable CPU and
+// memory at the given prices
+func nodeHourlyCost(node *corev1.Node, nodeCosts map[string]float64, prices *ResourcePrices) float64 {
+	if hourly, ok := nodeCosts[node.Name]; ok && hourly > 0 {
+		return hourly
+	}
+	return float64(node.Status.Allocatable.Cpu().MilliValue())*prices.PerMillicoreHour +
+		float64(node.Status.Allocatable.Memory().Value())*prices.PerByteHour
+}
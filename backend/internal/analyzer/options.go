@@ -0,0 +1,233 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Percentiles that can be used to size requests
+var supportedPercentiles = map[float64]bool{0.50: true, 0.95: true, 0.99: true}
+
+// AnalysisOptions tunes how aggressive the rightsizing recommendations are, e.g.
+// larger margins and a higher percentile for production namespaces
+type AnalysisOptions struct {
+	// Multipliers applied to the usage percentile to get the recommended request
+	CPUSafetyMargin    float64 `json:"cpu_safety_margin"`
+	MemorySafetyMargin float64 `json:"memory_safety_margin"`
+
+	// Minimum fraction of the request that must be unused to recommend a change
+	WasteThreshold float64 `json:"waste_threshold"`
+
+	// Below this confidence a recommendation is made even if waste is under the threshold
+	ConfidenceThreshold float64 `json:"confidence_threshold"`
+
+	// Usage percentile requests are sized from: 0.50, 0.95 or 0.99
+	Percentile float64 `json:"percentile"`
+
+	// Only analyze usage collected from this cluster. Empty analyzes all clusters.
+	Cluster string `json:"cluster,omitempty"`
+
+	// Base limits on the busiest hour of the week instead of the window-wide P99, for
+	// workloads with recurring spikes such as weekday morning batch jobs
+	SeasonalityAware bool `json:"seasonality_aware"`
+
+	// Drop recommendations saving less than this many dollars a month, or with a
+	// confidence below MinConfidence, so the list isn't cluttered with trivial changes
+	MinSavings    float64 `json:"min_savings"`
+	MinConfidence float64 `json:"min_confidence"`
+}
+
+// Validate checks that every option is within its allowed range
+func (o *AnalysisOptions) Validate() error {
+	if o.CPUSafetyMargin < 1 || o.CPUSafetyMargin > 3 {
+		return fmt.Errorf("cpu safety margin must be between 1 and 3, got %v", o.CPUSafetyMargin)
+	}
+	if o.MemorySafetyMargin < 1 || o.MemorySafetyMargin > 3 {
+		return fmt.Errorf("memory safety margin must be between 1 and 3, got %v", o.MemorySafetyMargin)
+	}
+	if o.WasteThreshold < 0 || o.WasteThreshold >= 1 {
+		return fmt.Errorf("waste threshold must be in [0, 1), got %v", o.WasteThreshold)
+	}
+	if o.ConfidenceThreshold < 0 || o.ConfidenceThreshold > 1 {
+		return fmt.Errorf("confidence threshold must be in [0, 1], got %v", o.ConfidenceThreshold)
+	}
+	if !supportedPercentiles[o.Percentile] {
+		return fmt.Errorf("percentile must be one of 0.50, 0.95 or 0.99, got %v", o.Percentile)
+	}
+	if o.MinSavings < 0 {
+		return fmt.Errorf("min savings must not be negative, got %v", o.MinSavings)
+	}
+	if o.MinConfidence < 0 || o.MinConfidence > 1 {
+		return fmt.Errorf("min confidence must be in [0, 1], got %v", o.MinConfidence)
+	}
+	return nil
+}
+
+// DefaultOptions returns the analyzer's configured margins and thresholds
+func (ra *RightsizingAnalyzer) DefaultOptions() *AnalysisOptions {
+	return &AnalysisOptions{
+		CPUSafetyMargin:     ra.cpuSafetyMargin,
+		MemorySafetyMargin:  ra.memorySafetyMargin,
+		WasteThreshold:      ra.wasteThreshold,
+		ConfidenceThreshold: ra.confidenceLevel,
+		Percentile:          ra.requestPercentile,
+		MinSavings:          ra.minSavings,
+		MinConfidence:       ra.minConfidence,
+	}
+}
+
+// Settings are the analyzer's effective thresholds, for inspecting configuration
+type Settings struct {
+	WasteThreshold       float64 `json:"waste_threshold"`
+	ConfidenceLevel      float64 `json:"confidence_level"`
+	CPUSafetyMargin      float64 `json:"cpu_safety_margin"`
+	MemorySafetyMargin   float64 `json:"memory_safety_margin"`
+	RequestPercentile    float64 `json:"request_percentile"`
+	AnalysisWindow       string  `json:"analysis_window"`
+	MinDataPoints        int     `json:"min_data_points"`
+	IdleWindow           string  `json:"idle_window"`
+	IdleCPUThreshold     float64 `json:"idle_cpu_threshold"`
+	IdleNetworkThreshold float64 `json:"idle_network_threshold"`
+	MinSavings           float64 `json:"min_savings"`
+	MinConfidence        float64 `json:"min_confidence"`
+}
+
+// Settings returns the thresholds the analyzer is running with
+func (ra *RightsizingAnalyzer) Settings() Settings {
+	return Settings{
+		WasteThreshold:       ra.wasteThreshold,
+		ConfidenceLevel:      ra.confidenceLevel,
+		CPUSafetyMargin:      ra.cpuSafetyMargin,
+		MemorySafetyMargin:   ra.memorySafetyMargin,
+		RequestPercentile:    ra.requestPercentile,
+		AnalysisWindow:       ra.analysisWindow.String(),
+		MinDataPoints:        ra.minDataPoints,
+		IdleWindow:           ra.idleWindow.String(),
+		IdleCPUThreshold:     ra.idleCPUThreshold,
+		IdleNetworkThreshold: ra.idleNetworkThreshold,
+		MinSavings:           ra.minSavings,
+		MinConfidence:        ra.minConfidence,
+	}
+}
+
+// The setters below change the analyzer's defaults. They are not synchronized with
+// running analyses, so call them while setting up the analyzer.
+
+// SetWasteThreshold sets the minimum unused fraction of a request worth acting on
+func (ra *RightsizingAnalyzer) SetWasteThreshold(threshold float64) error {
+	if threshold < 0 || threshold >= 1 {
+		return fmt.Errorf("waste threshold must be in [0, 1), got %v", threshold)
+	}
+	ra.wasteThreshold = threshold
+	return nil
+}
+
+// SetConfidenceLevel sets the confidence above which low-waste containers are skipped
+func (ra *RightsizingAnalyzer) SetConfidenceLevel(level float64) error {
+	if level < 0 || level > 1 {
+		return fmt.Errorf("confidence level must be in [0, 1], got %v", level)
+	}
+	ra.confidenceLevel = level
+	return nil
+}
+
+// SetSafetyMargins sets the CPU and memory multipliers applied to the usage percentile
+func (ra *RightsizingAnalyzer) SetSafetyMargins(cpu, memory float64) error {
+	if cpu < 1 || cpu > 3 || memory < 1 || memory > 3 {
+		return fmt.Errorf("safety margins must be between 1 and 3, got cpu=%v memory=%v", cpu, memory)
+	}
+	ra.cpuSafetyMargin = cpu
+	ra.memorySafetyMargin = memory
+	return nil
+}
+
+// SetRequestPercentile sets the usage percentile requests are sized from
+func (ra *RightsizingAnalyzer) SetRequestPercentile(percentile float64) error {
+	if !supportedPercentiles[percentile] {
+		return fmt.Errorf("percentile must be one of 0.50, 0.95 or 0.99, got %v", percentile)
+	}
+	ra.requestPercentile = percentile
+	return nil
+}
+
+// SetAnalysisWindow sets how much usage history is analyzed
+func (ra *RightsizingAnalyzer) SetAnalysisWindow(window time.Duration) error {
+	if window < time.Hour {
+		return fmt.Errorf("analysis window must be at least 1h, got %v", window)
+	}
+	ra.analysisWindow = window
+	return nil
+}
+
+// SetMinDataPoints sets the number of samples a container needs before it is analyzed
+func (ra *RightsizingAnalyzer) SetMinDataPoints(points int) error {
+	if points < 1 {
+		return fmt.Errorf("min data points must be positive, got %d", points)
+	}
+	ra.minDataPoints = points
+	return nil
+}
+
+// SetIdleWindow sets how long a pod must stay below the idle CPU threshold to be
+// reported as idle. It should exceed any expected quiet period, e.g. nights or weekends.
+func (ra *RightsizingAnalyzer) SetIdleWindow(window time.Duration) error {
+	if window < time.Hour {
+		return fmt.Errorf("idle window must be at least 1h, got %v", window)
+	}
+	ra.idleWindow = window
+	return nil
+}
+
+// SetMinSavings sets the monthly savings, in dollars, below which recommendations are
+// dropped
+func (ra *RightsizingAnalyzer) SetMinSavings(savings float64) error {
+	if savings < 0 {
+		return fmt.Errorf("min savings must not be negative, got %v", savings)
+	}
+	ra.minSavings = savings
+	return nil
+}
+
+// SetMinConfidence sets the confidence below which recommendations are dropped
+func (ra *RightsizingAnalyzer) SetMinConfidence(confidence float64) error {
+	if confidence < 0 || confidence > 1 {
+		return fmt.Errorf("min confidence must be in [0, 1], got %v", confidence)
+	}
+	ra.minConfidence = confidence
+	return nil
+}
+
+// filterRecommendations drops recommendations below the options' savings and
+// confidence minimums. Initial sizing for containers without a request costs rather
+// than saves, as does holding back a container that was OOMKilled or throttled, so
+// only the confidence minimum applies to them.
+func filterRecommendations(recs []Recommendation, opts *AnalysisOptions) []Recommendation {
+	if opts.MinSavings <= 0 && opts.MinConfidence <= 0 {
+		return recs
+	}
+
+	kept := recs[:0]
+	for _, rec := range recs {
+		held := rec.OOMObserved || rec.ThrottleObserved
+		if rec.CurrentRequest > 0 && !held && rec.PotentialSavings < opts.MinSavings {
+			continue
+		}
+		if rec.Confidence < opts.MinConfidence {
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	return kept
+}
+
+// percentile returns the stat matching one of the supported percentiles
+func (u usageStats) percentile(p float64) float64 {
+	switch p {
+	case 0.50:
+		return u.P50
+	case 0.99:
+		return u.P99
+	default:
+		return u.P95
+	}
+}
@@ -0,0 +1,245 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Outcomes of an applied recommendation. no_data marks workloads that didn't run during
+// the evaluation window and is left out of hit rates.
+const (
+	OutcomeSafe     = "safe"
+	OutcomeTooTight = "too_tight"
+	OutcomeTooLoose = "too_loose"
+	OutcomeNoData   = "no_data"
+)
+
+// DefaultOutcomeWindow is how long an applied recommendation runs before its outcome is
+// evaluated
+const DefaultOutcomeWindow = 7 * 24 * time.Hour
+
+const (
+	// outcomeLimitRatio is the share of the recommended limit at which usage counts as
+	// pinned against it: throttled for CPU, close to an OOM kill for memory
+	outcomeLimitRatio = 0.95
+
+	// outcomeLooseRatio is the share of the recommended request below which P95 usage
+	// means the request could have been cut further
+	outcomeLooseRatio = 0.5
+)
+
+// RecommendationOutcome is how an applied recommendation held up over the window after
+// it was applied. It is defined in the store, which saves and loads it.
+type RecommendationOutcome = store.RecommendationOutcome
+
+// OutcomeStats counts evaluated recommendations by outcome. HitRate is the share of
+// those with data that were safe, or nil without any.
+type OutcomeStats struct {
+	Evaluated int      `json:"evaluated"`
+	Safe      int      `json:"safe"`
+	TooTight  int      `json:"too_tight"`
+	TooLoose  int      `json:"too_loose"`
+	NoData    int      `json:"no_data"`
+	HitRate   *float64 `json:"hit_rate"`
+}
+
+func (s *OutcomeStats) add(outcome string, count int) {
+	s.Evaluated += count
+	switch outcome {
+	case OutcomeSafe:
+		s.Safe += count
+	case OutcomeTooTight:
+		s.TooTight += count
+	case OutcomeTooLoose:
+		s.TooLoose += count
+	case OutcomeNoData:
+		s.NoData += count
+	}
+	if judged := s.Safe + s.TooTight + s.TooLoose; judged > 0 {
+		rate := float64(s.Safe) / float64(judged)
+		s.HitRate = &rate
+	}
+}
+
+// OutcomeFilter narrows and pages GetRecommendationOutcomes
+type OutcomeFilter = store.OutcomeFilter
+
+// OutcomeEvaluator follows up on applied CPU and memory recommendations. Once one has
+// been applied for the evaluation window, it compares the usage of the changed
+// workload's pods since then, and their restarts and OOM kills, against the
+// recommended request and limit, and records whether the recommendation was safe, too
+// tight or too loose.
+type OutcomeEvaluator struct {
+	outcomes    store.OutcomeRepository
+	k8sClient   kubernetes.Interface
+	clusterName string
+	window      time.Duration
+	log         *logrus.Logger
+}
+
+// NewOutcomeEvaluator creates an evaluator for recommendations applied to the cluster
+// the client connects to, whose usage is stored under clusterName
+func NewOutcomeEvaluator(outcomes store.OutcomeRepository, k8sClient kubernetes.Interface, clusterName string) *OutcomeEvaluator {
+	return &OutcomeEvaluator{
+		outcomes:    outcomes,
+		k8sClient:   k8sClient,
+		clusterName: clusterName,
+		window:      DefaultOutcomeWindow,
+		log:         logrus.New(),
+	}
+}
+
+// SetWindow sets how long an applied recommendation runs before it is evaluated
+func (e *OutcomeEvaluator) SetWindow(window time.Duration) error {
+	if window <= 0 {
+		return fmt.Errorf("outcome window must be positive, got %v", window)
+	}
+	e.window = window
+	return nil
+}
+
+// Evaluate records the outcome of every apply action whose window has passed and that
+// hasn't been evaluated yet, returning how many it recorded. Actions that fail to
+// evaluate are logged and retried on the next call.
+func (e *OutcomeEvaluator) Evaluate(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "evaluate recommendation outcomes")
+	defer span.End()
+
+	pending, err := e.outcomes.PendingOutcomes(ctx, time.Now().Add(-e.window))
+	if err != nil {
+		return 0, err
+	}
+
+	evaluated := 0
+	for i := range pending {
+		outcome := &pending[i]
+		if err := e.evaluate(ctx, outcome); err != nil {
+			e.log.Warnf("Failed to evaluate recommendation action %d: %v", outcome.ActionID, err)
+			continue
+		}
+		if err := e.outcomes.SaveOutcome(ctx, outcome); err != nil {
+			e.log.Warnf("Failed to save outcome of recommendation action %d: %v", outcome.ActionID, err)
+			continue
+		}
+		evaluated++
+	}
+	return evaluated, nil
+}
+
+// evaluate fills in the outcome's usage, restarts and verdict over its window
+func (e *OutcomeEvaluator) evaluate(ctx context.Context, outcome *RecommendationOutcome) error {
+	end := outcome.AppliedAt.Add(e.window)
+
+	// The workload's pods are those the collector recorded with it as their owner
+	owner := store.Owner{Kind: outcome.OwnerKind, Name: outcome.OwnerName}
+	pods, err := e.outcomes.WorkloadPods(ctx, outcome.Namespace, owner, outcome.AppliedAt, end)
+	if err != nil {
+		return err
+	}
+
+	if err := e.outcomes.LoadOutcomeUsage(ctx, outcome, e.clusterName, pods, end); err != nil {
+		return err
+	}
+
+	if err := e.countRestarts(ctx, outcome, pods); err != nil {
+		return err
+	}
+
+	outcome.Outcome = classifyOutcome(outcome)
+	outcome.EvaluatedAt = time.Now()
+	return nil
+}
+
+// countRestarts adds up the container's restarts and OOM kills in the workload's pods
+// started since the apply. Only pods still running are seen, and the API only keeps
+// each container's last termination, so both counts are lower bounds.
+func (e *OutcomeEvaluator) countRestarts(ctx context.Context, outcome *RecommendationOutcome, pods []string) error {
+	if len(pods) == 0 {
+		return nil
+	}
+	owned := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		owned[pod] = true
+	}
+
+	list, err := e.k8sClient.CoreV1().Pods(outcome.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing pods: %w", err)
+	}
+
+	for _, pod := range list.Items {
+		if !owned[pod.Name] || pod.CreationTimestamp.Time.Before(outcome.AppliedAt) {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != outcome.ContainerName {
+				continue
+			}
+			outcome.Restarts += int(status.RestartCount)
+			if oomKilled(status.State.Terminated) || oomKilled(status.LastTerminationState.Terminated) {
+				outcome.OOMKills++
+			}
+		}
+	}
+	return nil
+}
+
+func oomKilled(terminated *corev1.ContainerStateTerminated) bool {
+	return terminated != nil && terminated.Reason == "OOMKilled"
+}
+
+// classifyOutcome judges an applied recommendation by what followed. It was too tight
+// if usage ran above the recommended request at P95 or reached the limit, or, for
+// memory, the container was OOMKilled; too loose if P95 usage stayed under half the
+// request; and safe otherwise.
+func classifyOutcome(outcome *RecommendationOutcome) string {
+	switch {
+	case outcome.Samples == 0:
+		return OutcomeNoData
+	case outcome.ResourceType == "Memory" && outcome.OOMKills > 0:
+		return OutcomeTooTight
+	case outcome.RecommendedLimit > 0 && outcome.MaxUsage >= outcome.RecommendedLimit*outcomeLimitRatio:
+		return OutcomeTooTight
+	case outcome.RecommendedRequest > 0 && outcome.P95Usage > outcome.RecommendedRequest:
+		return OutcomeTooTight
+	case outcome.RecommendedRequest > 0 && outcome.P95Usage < outcome.RecommendedRequest*outcomeLooseRatio:
+		return OutcomeTooLoose
+	default:
+		return OutcomeSafe
+	}
+}
+
+// GetRecommendationOutcomes lists evaluated recommendations, newest first, with the
+// total matching the filter
+func (ra *RightsizingAnalyzer) GetRecommendationOutcomes(ctx context.Context, filter OutcomeFilter) ([]RecommendationOutcome, int, error) {
+	return ra.outcomes.Outcomes(ctx, filter)
+}
+
+// GetOutcomeStats counts evaluated recommendations by outcome, overall and per resource
+// type, for the namespace or every namespace when empty
+func (ra *RightsizingAnalyzer) GetOutcomeStats(ctx context.Context, namespace string) (OutcomeStats, map[string]*OutcomeStats, error) {
+	var overall OutcomeStats
+	counts, err := ra.outcomes.OutcomeCounts(ctx, namespace)
+	if err != nil {
+		return overall, nil, err
+	}
+
+	byResource := make(map[string]*OutcomeStats)
+	for _, count := range counts {
+		if byResource[count.ResourceType] == nil {
+			byResource[count.ResourceType] = &OutcomeStats{}
+		}
+		byResource[count.ResourceType].add(count.Outcome, count.Count)
+		overall.add(count.Outcome, count.Count)
+	}
+	return overall, byResource, nil
+}
@@ -0,0 +1,171 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// podOwner is the workload controlling a pod, as resolved by the collector
+type podOwner struct {
+	Kind string
+	Name string
+}
+
+// loadOwners returns the latest recorded owner of each pod in the namespace, in the
+// given cluster or all clusters when empty. Pods collected before owners were recorded
+// are their own owner.
+func (ra *RightsizingAnalyzer) loadOwners(ctx context.Context, namespace, cluster string) (map[string]podOwner, error) {
+	if ra.store == nil {
+		return nil, fmt.Errorf("no usage store configured")
+	}
+
+	stored, err := ra.store.Owners(ctx, namespace, cluster, time.Now().Add(-ra.analysisWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]podOwner, len(stored))
+	for podName, owner := range stored {
+		owners[podName] = podOwner{Kind: owner.Kind, Name: owner.Name}
+	}
+	return owners, nil
+}
+
+// consolidateReplicas tags each recommendation with its pod's owner and replaces the
+// per-pod recommendations of DaemonSets and StatefulSets with one per container. Their
+// pods share a template, so the only change that can be applied is to the template,
+// and it must fit the busiest replica: the consolidated recommendation takes the
+// highest recommended values and risk, and its savings are summed over every replica.
+// A workload is only consolidated when every analyzed replica needs the change;
+// otherwise its per-pod recommendations are kept.
+func consolidateReplicas(recs []Recommendation, owners map[string]podOwner, stats []containerStats, prices *ResourcePrices) []Recommendation {
+	type containerKey struct {
+		owner         podOwner
+		containerName string
+	}
+	type groupKey struct {
+		containerKey
+		resourceType string
+	}
+
+	// Replicas analyzed per workload container
+	replicas := make(map[containerKey]map[string]bool)
+	for _, stat := range stats {
+		key := containerKey{owners[stat.PodName], stat.ContainerName}
+		if replicas[key] == nil {
+			replicas[key] = make(map[string]bool)
+		}
+		replicas[key][stat.PodName] = true
+	}
+
+	var result []Recommendation
+	groups := make(map[groupKey][]Recommendation)
+	var order []groupKey
+
+	for _, rec := range recs {
+		owner, ok := owners[rec.PodName]
+		if !ok {
+			owner = podOwner{Kind: "Pod", Name: rec.PodName}
+		}
+		rec.OwnerKind = owner.Kind
+		rec.OwnerName = owner.Name
+		rec.Replicas = 1
+
+		if owner.Kind != "DaemonSet" && owner.Kind != "StatefulSet" {
+			result = append(result, rec)
+			continue
+		}
+
+		key := groupKey{containerKey{owner, rec.ContainerName}, rec.ResourceType}
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], rec)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		analyzed := len(replicas[key.containerKey])
+		if len(group) < 2 || len(group) < analyzed {
+			result = append(result, group...)
+			continue
+		}
+		result = append(result, mergeReplicas(group, prices))
+	}
+
+	return result
+}
+
+// mergeReplicas combines the recommendations for one container across a workload's
+// replicas into a single template change
+func mergeReplicas(group []Recommendation, prices *ResourcePrices) Recommendation {
+	sort.Slice(group, func(i, j int) bool { return group[i].PodName < group[j].PodName })
+
+	merged := group[0]
+	merged.Replicas = len(group)
+	for _, rec := range group[1:] {
+		merged.CurrentRequest = maxFloat(merged.CurrentRequest, rec.CurrentRequest)
+		merged.CurrentLimit = maxFloat(merged.CurrentLimit, rec.CurrentLimit)
+		merged.RecommendedRequest = maxFloat(merged.RecommendedRequest, rec.RecommendedRequest)
+		merged.RecommendedLimit = maxFloat(merged.RecommendedLimit, rec.RecommendedLimit)
+		merged.P50Usage = maxFloat(merged.P50Usage, rec.P50Usage)
+		merged.P95Usage = maxFloat(merged.P95Usage, rec.P95Usage)
+		merged.P99Usage = maxFloat(merged.P99Usage, rec.P99Usage)
+		merged.MaxUsage = maxFloat(merged.MaxUsage, rec.MaxUsage)
+		if rec.Confidence < merged.Confidence {
+			merged.Confidence = rec.Confidence
+		}
+		if riskRank[rec.RiskLevel] > riskRank[merged.RiskLevel] {
+			merged.RiskLevel = rec.RiskLevel
+		}
+		// Replicas share the template's request and limit, so one variable replica
+		// keeps the workload Burstable
+		if rec.TargetQoS == QoSBurstable {
+			merged.TargetQoS = QoSBurstable
+		}
+		// One replica running out holds back the whole template
+		merged.OOMObserved = merged.OOMObserved || rec.OOMObserved
+		merged.ThrottleObserved = merged.ThrottleObserved || rec.ThrottleObserved
+	}
+
+	var unitPrice float64
+	switch merged.ResourceType {
+	case "CPU":
+		unitPrice = prices.PerMillicoreHour
+	case "Memory":
+		unitPrice = prices.PerByteHour
+	case "GPU":
+		unitPrice = prices.PerGPUHour
+	}
+
+	merged.PotentialSavings, merged.InitialSizingCost = 0, 0
+	for _, rec := range group {
+		savings, initialSizingCost := monthlyChange(rec.CurrentRequest, merged.RecommendedRequest, unitPrice)
+		merged.PotentialSavings += savings
+		merged.InitialSizingCost += initialSizingCost
+	}
+
+	switch merged.OwnerKind {
+	case "DaemonSet":
+		merged.Reasoning = fmt.Sprintf("%s. DaemonSet %s runs one pod per node: $%.2f per node × %d nodes",
+			merged.Reasoning, merged.OwnerName, merged.PotentialSavings/float64(merged.Replicas), merged.Replicas)
+	case "StatefulSet":
+		merged.Reasoning = fmt.Sprintf("%s. Apply to the StatefulSet %s template; the change covers all %d replicas "+
+			"and is sized for the busiest one",
+			merged.Reasoning, merged.OwnerName, merged.Replicas)
+	}
+
+	return merged
+}
+
+// riskRank orders risk levels from lowest to highest
+var riskRank = map[string]int{"LOW": 1, "MEDIUM": 2, "HIGH": 3}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
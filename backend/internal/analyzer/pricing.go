@@ -0,0 +1,154 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s-cost-optimizer/pkg/cloudprovider"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// pricingCacheTTL is how long derived prices are reused before querying the provider again
+const pricingCacheTTL = time.Hour
+
+// ResourcePrices are hourly per-unit prices used to estimate costs and savings
+type ResourcePrices struct {
+	PerMillicoreHour float64 `json:"per_millicore_hour"`
+	PerByteHour      float64 `json:"per_byte_hour"`
+	PerGPUHour       float64 `json:"per_gpu_hour"`
+	// Block storage is billed by capacity, not by node, so it isn't derived from node prices
+	PerGiBStorageMonth float64 `json:"per_gib_storage_month"`
+	Source             string  `json:"source"`
+}
+
+// PriceSource supplies resource prices to the analyzer
+type PriceSource interface {
+	ResourcePrices(ctx context.Context) (*ResourcePrices, error)
+}
+
+// DefaultResourcePrices returns the static fallback prices used when no provider
+// pricing is available
+func DefaultResourcePrices() *ResourcePrices {
+	return &ResourcePrices{
+		PerMillicoreHour:   costPerMillicoreHour,
+		PerByteHour:        costPerByteHour,
+		PerGPUHour:         costPerGPUHour,
+		PerGiBStorageMonth: costPerGiBStorageMonth,
+		Source:             "default",
+	}
+}
+
+// ProviderPricing derives per-unit prices from the cloud provider's node prices divided
+// by node allocatable capacity
+type ProviderPricing struct {
+	provider  cloudprovider.Provider
+	k8sClient kubernetes.Interface
+
+	mu        sync.Mutex
+	cached    *ResourcePrices
+	fetchedAt time.Time
+}
+
+func NewProviderPricing(provider cloudprovider.Provider, k8sClient kubernetes.Interface) *ProviderPricing {
+	return &ProviderPricing{
+		provider:  provider,
+		k8sClient: k8sClient,
+	}
+}
+
+// ResourcePrices returns prices derived from the current nodes, cached for an hour.
+// CPU-only nodes set the CPU and memory prices; the remainder of GPU nodes' prices,
+// after CPU and memory, sets the GPU price.
+func (pp *ProviderPricing) ResourcePrices(ctx context.Context) (*ResourcePrices, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if pp.cached != nil && time.Since(pp.fetchedAt) < pricingCacheTTL {
+		return pp.cached, nil
+	}
+
+	nodeCosts, err := pp.provider.GetNodeCosts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting node costs: %w", err)
+	}
+
+	nodes, err := pp.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	var totalCost, totalCores, totalGiB float64
+	var gpuNodes []corev1.Node
+
+	for _, node := range nodes.Items {
+		hourly, ok := nodeCosts[node.Name]
+		if !ok || hourly <= 0 {
+			continue
+		}
+
+		if gpus := node.Status.Allocatable[corev1.ResourceName("nvidia.com/gpu")]; !gpus.IsZero() {
+			gpuNodes = append(gpuNodes, node)
+			continue
+		}
+
+		totalCost += hourly
+		totalCores += float64(node.Status.Allocatable.Cpu().MilliValue()) / 1000
+		totalGiB += float64(node.Status.Allocatable.Memory().Value()) / (1 << 30)
+	}
+
+	if totalCost == 0 || totalCores+totalGiB == 0 {
+		return nil, fmt.Errorf("no priced nodes with allocatable capacity")
+	}
+
+	// totalCost = cores * ratio * perGiB + GiB * perGiB
+	perGiBHour := totalCost / (totalCores*cloudprovider.CPUToMemoryPriceRatio + totalGiB)
+	prices := &ResourcePrices{
+		PerMillicoreHour:   perGiBHour * cloudprovider.CPUToMemoryPriceRatio / 1000,
+		PerByteHour:        perGiBHour / (1 << 30),
+		PerGPUHour:         costPerGPUHour,
+		PerGiBStorageMonth: costPerGiBStorageMonth,
+		Source:             "provider",
+	}
+
+	var gpuCost, gpuCount float64
+	for _, node := range gpuNodes {
+		gpus := node.Status.Allocatable[corev1.ResourceName("nvidia.com/gpu")]
+		cpuMemCost := float64(node.Status.Allocatable.Cpu().MilliValue())*prices.PerMillicoreHour +
+			float64(node.Status.Allocatable.Memory().Value())*prices.PerByteHour
+		if remainder := nodeCosts[node.Name] - cpuMemCost; remainder > 0 {
+			gpuCost += remainder
+			gpuCount += float64(gpus.Value())
+		}
+	}
+	if gpuCount > 0 {
+		prices.PerGPUHour = gpuCost / gpuCount
+	}
+
+	pp.cached = prices
+	pp.fetchedAt = time.Now()
+	return prices, nil
+}
+
+// Prices returns the prices the analyzer estimates costs and savings with
+func (ra *RightsizingAnalyzer) Prices(ctx context.Context) *ResourcePrices {
+	return ra.resourcePrices(ctx)
+}
+
+// resourcePrices returns the configured prices, falling back to the static defaults
+func (ra *RightsizingAnalyzer) resourcePrices(ctx context.Context) *ResourcePrices {
+	if ra.pricing == nil {
+		return DefaultResourcePrices()
+	}
+
+	prices, err := ra.pricing.ResourcePrices(ctx)
+	if err != nil {
+		ra.log.Warnf("Failed to get provider pricing, using defaults: %v", err)
+		return DefaultResourcePrices()
+	}
+	return prices
+}
@@ -0,0 +1,57 @@
+package analyzer
+
+import "math"
+
+// QoS classes, as Kubernetes assigns them from requests and limits
+const (
+	QoSGuaranteed = "Guaranteed"
+	QoSBurstable  = "Burstable"
+	QoSBestEffort = "BestEffort"
+)
+
+// guaranteedMaxCV is the coefficient of variation below which usage is steady enough
+// for Guaranteed QoS. Steady usage costs little extra to reserve at its peak.
+const guaranteedMaxCV = 0.3
+
+// steady reports whether usage varies little enough for Guaranteed QoS
+func (u usageStats) steady() bool {
+	return u.Avg > 0 && u.StdDev/u.Avg < guaranteedMaxCV
+}
+
+// qosClass returns the QoS class a container's request and limit for one resource
+// give it. A limit without a request defaults the request to the limit.
+func qosClass(request, limit float64) string {
+	switch {
+	case request <= 0 && limit <= 0:
+		return QoSBestEffort
+	case limit > 0 && (request == limit || request <= 0):
+		return QoSGuaranteed
+	default:
+		return QoSBurstable
+	}
+}
+
+// applyQoS sets the recommendation's current and target QoS class for its resource.
+// Steady workloads are recommended Guaranteed, with request and limit both set to
+// guaranteedSize, so they are never throttled or evicted for using more than they
+// requested. Variable and seasonal workloads are recommended Burstable, keeping the
+// request at the usage percentile and headroom in the limit rather than reserving
+// their peak all the time. Savings are repriced at unitPrice for the Guaranteed request.
+func applyQoS(rec *Recommendation, steady bool, guaranteedSize, unitPrice float64) {
+	rec.CurrentQoS = qosClass(rec.CurrentRequest, rec.CurrentLimit)
+
+	if !steady {
+		rec.TargetQoS = QoSBurstable
+		if rec.CurrentQoS == QoSGuaranteed {
+			rec.Reasoning += "; Burstable QoS: usage varies too much to reserve its peak"
+		}
+		return
+	}
+
+	rec.TargetQoS = QoSGuaranteed
+	size := math.Max(guaranteedSize, rec.RecommendedRequest)
+	rec.RecommendedRequest = size
+	rec.RecommendedLimit = size
+	rec.PotentialSavings, rec.InitialSizingCost = monthlyChange(rec.CurrentRequest, size, unitPrice)
+	rec.Reasoning += "; Guaranteed QoS: steady usage, request set equal to limit"
+}
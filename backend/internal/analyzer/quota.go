@@ -0,0 +1,143 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"k8s-cost-optimizer/internal/store"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// QuotaName is the name of the ResourceQuota object suggested for budget enforcement
+const QuotaName = "k8s-cost-optimizer-budget"
+
+// QuotaSuggestion is a ResourceQuota sized to keep a namespace within a monthly budget.
+// The limit quotas are zero, and left out of the ResourceQuota, unless every container
+// in the namespace sets that limit, since a limit quota rejects pods without one.
+type QuotaSuggestion struct {
+	Namespace            string  `json:"namespace"`
+	MonthlyBudget        float64 `json:"monthly_budget"`
+	CurrentMonthlyCost   float64 `json:"current_monthly_cost"`
+	ProjectedMonthlyCost float64 `json:"projected_monthly_cost"` // Spend the request quotas allow, up to the budget
+	ExcessMonthlyCost    float64 `json:"excess_monthly_cost"`    // Spend the quotas allow beyond the budget, to fit running pods
+	CurrentCPURequest    float64 `json:"current_cpu_request"`
+	CurrentMemoryRequest float64 `json:"current_memory_request"`
+	CPURequestQuota      float64 `json:"cpu_request_quota"`
+	MemoryRequestQuota   float64 `json:"memory_request_quota"`
+	CPULimitQuota        float64 `json:"cpu_limit_quota,omitempty"`
+	MemoryLimitQuota     float64 `json:"memory_limit_quota,omitempty"`
+	WithinBudget         bool    `json:"within_budget"`
+	Reasoning            string  `json:"reasoning"`
+}
+
+// SuggestResourceQuota computes a ResourceQuota that keeps the namespace within the
+// given monthly budget at current pricing. The budget is split between CPU and memory
+// in the same proportion as the namespace's current spend, and the quota is never set
+// below what running pods already request so it doesn't immediately block them.
+func (ra *RightsizingAnalyzer) SuggestResourceQuota(ctx context.Context, namespace string, monthlyBudget float64) (*QuotaSuggestion, error) {
+	if monthlyBudget <= 0 {
+		return nil, fmt.Errorf("monthly budget must be positive, got %.2f", monthlyBudget)
+	}
+
+	// Use the most recent resource request snapshot for the namespace
+	current, err := ra.store.NamespaceRequests(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestQuota(namespace, monthlyBudget, current, ra.resourcePrices(ctx)), nil
+}
+
+// suggestQuota sizes the quota for the namespace's current resources
+func suggestQuota(namespace string, monthlyBudget float64, current store.NamespaceRequests, prices *ResourcePrices) *QuotaSuggestion {
+	hoursPerMonth := 24.0 * 30
+	cpuMonthly := current.CPURequest * prices.PerMillicoreHour * hoursPerMonth
+	memoryMonthly := current.MemoryRequest * prices.PerByteHour * hoursPerMonth
+	currentMonthly := cpuMonthly + memoryMonthly
+
+	// Split the budget by the current CPU/memory spend mix, defaulting to an even split
+	cpuShare := 0.5
+	if currentMonthly > 0 {
+		cpuShare = cpuMonthly / currentMonthly
+	}
+
+	suggestion := &QuotaSuggestion{
+		Namespace:            namespace,
+		MonthlyBudget:        monthlyBudget,
+		CurrentMonthlyCost:   currentMonthly,
+		CurrentCPURequest:    current.CPURequest,
+		CurrentMemoryRequest: current.MemoryRequest,
+		CPURequestQuota:      math.Floor(monthlyBudget * cpuShare / hoursPerMonth / prices.PerMillicoreHour),
+		MemoryRequestQuota:   math.Floor(monthlyBudget * (1 - cpuShare) / hoursPerMonth / prices.PerByteHour),
+		WithinBudget:         true,
+		Reasoning:            "Quota derived from monthly budget and current CPU/memory spend mix",
+	}
+
+	// Never suggest a quota below existing usage, or running workloads would be blocked
+	if suggestion.CPURequestQuota < current.CPURequest || suggestion.MemoryRequestQuota < current.MemoryRequest {
+		suggestion.CPURequestQuota = math.Max(suggestion.CPURequestQuota, current.CPURequest)
+		suggestion.MemoryRequestQuota = math.Max(suggestion.MemoryRequestQuota, current.MemoryRequest)
+		suggestion.WithinBudget = false
+		suggestion.Reasoning = "Current requests already exceed the budget; quota capped at existing usage to avoid blocking running pods"
+	}
+
+	// Keep the namespace's current limit-to-request ratio for the limit quotas, only
+	// where every container sets the limit
+	if current.Containers > 0 && current.CPULimited == current.Containers {
+		suggestion.CPULimitQuota = math.Max(suggestion.CPURequestQuota*limitRatio(current.CPULimit, current.CPURequest), current.CPULimit)
+	}
+	if current.Containers > 0 && current.MemoryLimited == current.Containers {
+		suggestion.MemoryLimitQuota = math.Max(suggestion.MemoryRequestQuota*limitRatio(current.MemoryLimit, current.MemoryRequest), current.MemoryLimit)
+	}
+
+	allowed := (suggestion.CPURequestQuota*prices.PerMillicoreHour +
+		suggestion.MemoryRequestQuota*prices.PerByteHour) * hoursPerMonth
+	suggestion.ProjectedMonthlyCost = math.Min(allowed, monthlyBudget)
+	suggestion.ExcessMonthlyCost = math.Max(allowed-monthlyBudget, 0)
+
+	return suggestion
+}
+
+// ResourceQuota builds the Kubernetes ResourceQuota object for the suggestion
+func (qs *QuotaSuggestion) ResourceQuota() *corev1.ResourceQuota {
+	hard := corev1.ResourceList{
+		corev1.ResourceRequestsCPU:    *resource.NewMilliQuantity(int64(qs.CPURequestQuota), resource.DecimalSI),
+		corev1.ResourceRequestsMemory: *resource.NewQuantity(int64(qs.MemoryRequestQuota), resource.BinarySI),
+	}
+	if qs.CPULimitQuota > 0 {
+		hard[corev1.ResourceLimitsCPU] = *resource.NewMilliQuantity(int64(math.Ceil(qs.CPULimitQuota)), resource.DecimalSI)
+	}
+	if qs.MemoryLimitQuota > 0 {
+		hard[corev1.ResourceLimitsMemory] = *resource.NewQuantity(int64(math.Ceil(qs.MemoryLimitQuota)), resource.BinarySI)
+	}
+
+	return &corev1.ResourceQuota{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ResourceQuota",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      QuotaName,
+			Namespace: qs.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "k8s-cost-optimizer",
+			},
+			Annotations: map[string]string{
+				"k8s-cost-optimizer/monthly-budget": fmt.Sprintf("%.2f", qs.MonthlyBudget),
+			},
+		},
+		Spec: corev1.ResourceQuotaSpec{Hard: hard},
+	}
+}
+
+// limitRatio returns the limit-to-request ratio, never less than 1
+func limitRatio(limit, request float64) float64 {
+	if request <= 0 || limit <= request {
+		return 1
+	}
+	return limit / request
+}
@@ -0,0 +1,106 @@
+package analyzer
+
+import (
+	"testing"
+
+	"k8s-cost-optimizer/internal/store"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSuggestQuotaLimits(t *testing.T) {
+	tests := []struct {
+		name                string
+		current             store.NamespaceRequests
+		wantCPU, wantMemory bool
+	}{
+		{
+			name:    "no container sets limits",
+			current: store.NamespaceRequests{Containers: 4, CPURequest: 1000, MemoryRequest: 1024 * mi},
+		},
+		{
+			name: "some containers set limits",
+			current: store.NamespaceRequests{Containers: 4, CPURequest: 1000, CPULimit: 500, CPULimited: 1,
+				MemoryRequest: 1024 * mi, MemoryLimit: 512 * mi, MemoryLimited: 3},
+		},
+		{
+			name: "every container sets a memory limit",
+			current: store.NamespaceRequests{Containers: 4, CPURequest: 1000, MemoryRequest: 1024 * mi,
+				MemoryLimit: 2048 * mi, MemoryLimited: 4},
+			wantMemory: true,
+		},
+		{
+			name: "every container sets both limits",
+			current: store.NamespaceRequests{Containers: 4, CPURequest: 1000, CPULimit: 2000, CPULimited: 4,
+				MemoryRequest: 1024 * mi, MemoryLimit: 2048 * mi, MemoryLimited: 4},
+			wantCPU: true, wantMemory: true,
+		},
+		{
+			name: "empty namespace",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion := suggestQuota("team-a", 500, tt.current, testPrices)
+			hard := suggestion.ResourceQuota().Spec.Hard
+
+			if _, ok := hard[corev1.ResourceRequestsCPU]; !ok {
+				t.Error("quota has no requests.cpu")
+			}
+			if _, ok := hard[corev1.ResourceRequestsMemory]; !ok {
+				t.Error("quota has no requests.memory")
+			}
+			if _, ok := hard[corev1.ResourceLimitsCPU]; ok != tt.wantCPU {
+				t.Errorf("quota sets limits.cpu: %v, want %v", ok, tt.wantCPU)
+			}
+			if _, ok := hard[corev1.ResourceLimitsMemory]; ok != tt.wantMemory {
+				t.Errorf("quota sets limits.memory: %v, want %v", ok, tt.wantMemory)
+			}
+		})
+	}
+
+	// Limit quotas keep the limit-to-request ratio
+	suggestion := suggestQuota("team-a", 500, tests[3].current, testPrices)
+	if !approxEqual(suggestion.CPULimitQuota, 2*suggestion.CPURequestQuota) {
+		t.Errorf("CPU limit quota = %v, want twice the request quota %v", suggestion.CPULimitQuota, suggestion.CPURequestQuota)
+	}
+}
+
+func TestSuggestQuotaProjection(t *testing.T) {
+	const hoursPerMonth = 24 * 30
+	current := store.NamespaceRequests{Containers: 2, CPURequest: 2000, MemoryRequest: 4096 * mi}
+	currentMonthly := (current.CPURequest*costPerMillicoreHour + current.MemoryRequest*costPerByteHour) * hoursPerMonth
+
+	t.Run("within budget", func(t *testing.T) {
+		budget := 2 * currentMonthly
+		suggestion := suggestQuota("team-a", budget, current, testPrices)
+		if !suggestion.WithinBudget || suggestion.ExcessMonthlyCost != 0 {
+			t.Errorf("WithinBudget = %v, ExcessMonthlyCost = %v, want true and 0", suggestion.WithinBudget, suggestion.ExcessMonthlyCost)
+		}
+		if suggestion.ProjectedMonthlyCost > budget || suggestion.ProjectedMonthlyCost < 0.99*budget {
+			t.Errorf("ProjectedMonthlyCost = %v, want just under the %v budget", suggestion.ProjectedMonthlyCost, budget)
+		}
+		if suggestion.CPURequestQuota < current.CPURequest || suggestion.MemoryRequestQuota < current.MemoryRequest {
+			t.Errorf("quota %v/%v is below current requests", suggestion.CPURequestQuota, suggestion.MemoryRequestQuota)
+		}
+	})
+
+	t.Run("current requests over budget", func(t *testing.T) {
+		budget := currentMonthly / 2
+		suggestion := suggestQuota("team-a", budget, current, testPrices)
+		if suggestion.WithinBudget {
+			t.Error("WithinBudget = true for requests twice the budget")
+		}
+		if suggestion.CPURequestQuota != current.CPURequest || suggestion.MemoryRequestQuota != current.MemoryRequest {
+			t.Errorf("quota = %v/%v, want the current requests %v/%v", suggestion.CPURequestQuota,
+				suggestion.MemoryRequestQuota, current.CPURequest, current.MemoryRequest)
+		}
+		if suggestion.ProjectedMonthlyCost != budget {
+			t.Errorf("ProjectedMonthlyCost = %v, want clamped to the %v budget", suggestion.ProjectedMonthlyCost, budget)
+		}
+		if !approxEqual(suggestion.ExcessMonthlyCost, currentMonthly-budget) {
+			t.Errorf("ExcessMonthlyCost = %v, want %v", suggestion.ExcessMonthlyCost, currentMonthly-budget)
+		}
+	})
+}
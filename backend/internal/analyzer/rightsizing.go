@@ -7,37 +7,51 @@ import (
 	"math"
 	"time"
 
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/tracing"
+
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Fallback linear cost model used to estimate savings when no provider pricing is available
+const (
+	costPerMillicoreHour   = 0.00001    // $0.00001 per millicore per hour
+	costPerByteHour        = 0.00000001 // $0.00000001 per byte per hour
+	costPerGPUHour         = 2.50       // $2.50 per NVIDIA GPU per hour
+	costPerGiBStorageMonth = 0.10       // $0.10 per GiB of PVC capacity per month
 )
 
 type RightsizingAnalyzer struct {
 	db                *sql.DB
+	store             store.MetricsRepository // Usage, current resources and owners
+	recommendations   store.RecommendationRepository
+	costs             store.CostRepository
+	gpu               store.GPURepository // Nil skips GPU analysis
+	budgets           store.BudgetRepository
+	outcomes          store.OutcomeRepository
 	wasteThreshold    float64  // Default 30%
 	analysisWindow    time.Duration
 	minDataPoints     int
 	confidenceLevel   float64
+	cpuSafetyMargin   float64
+	memorySafetyMargin float64
+	requestPercentile float64
+	idleWindow        time.Duration
+	idleCPUThreshold  float64
+	idleNetworkThreshold float64
+	minSavings        float64 // Monthly dollars below which recommendations are dropped
+	minConfidence     float64
+	pricing           PriceSource
 	log               *logrus.Logger
 }
 
-type Recommendation struct {
-	Namespace         string
-	PodName           string
-	ContainerName     string
-	ResourceType      string
-	CurrentRequest    float64
-	CurrentLimit      float64
-	RecommendedRequest float64
-	RecommendedLimit  float64
-	P50Usage          float64
-	P95Usage          float64
-	P99Usage          float64
-	MaxUsage          float64
-	PotentialSavings  float64
-	Confidence        float64
-	Reasoning         string
-	RiskLevel         string
-	LastUpdated       time.Time
-}
+// Recommendation is a recommended request and limit for one resource of a container.
+// It is defined in the store, which saves and loads it.
+type Recommendation = store.Recommendation
+
+// RecommendationHistoryFilter narrows and pages GetRecommendationHistory
+type RecommendationHistoryFilter = store.RecommendationFilter
 
 type ResourceAllocation struct {
 	CPURequest    float64
@@ -46,112 +60,217 @@ type ResourceAllocation struct {
 	MemoryLimit   float64
 }
 
-func NewRightsizingAnalyzer(db *sql.DB) *RightsizingAnalyzer {
-	return &RightsizingAnalyzer{
+// NewRightsizingAnalyzer creates an analyzer that reads from the repositories and prices
+// savings with the given source. A nil source uses the static default prices. Without a
+// database, namespace analysis skips what only Postgres holds: rollups, seasonality,
+// and OOM kill and throttling signals.
+func NewRightsizingAnalyzer(db *sql.DB, repositories store.Repositories, pricing PriceSource) *RightsizingAnalyzer {
+	ra := &RightsizingAnalyzer{
 		db:              db,
+		store:           repositories.Metrics,
+		recommendations: repositories.Recommendations,
+		costs:           repositories.Costs,
+		gpu:             repositories.GPU,
+		budgets:         repositories.Budgets,
+		outcomes:        repositories.Outcomes,
+		pricing:         pricing,
 		wasteThreshold:  0.30, // 30% waste threshold
 		analysisWindow:  7 * 24 * time.Hour, // 7 days
 		minDataPoints:   100,  // Minimum data points for analysis
 		confidenceLevel: 0.7,  // 70% confidence threshold
+		cpuSafetyMargin:    1.15, // 15% CPU safety margin
+		memorySafetyMargin: 1.1,  // 10% memory safety margin
+		requestPercentile:  0.95, // Size requests from P95 usage
+		idleWindow:         72 * time.Hour, // Longer than overnight or weekend lulls
+		idleCPUThreshold:   5,    // Millicores below which a pod counts as idle
+		idleNetworkThreshold: 1024, // Bytes/s below which a pod counts as idle; probes and scrapes stay under it
 		log:             logrus.New(),
 	}
+	return ra
 }
 
 func (ra *RightsizingAnalyzer) AnalyzeNamespace(ctx context.Context, namespace string) ([]Recommendation, error) {
-	// Query historical metrics for the namespace
-	rows, err := ra.db.QueryContext(ctx, `
-		SELECT 
-			pm.pod_name,
-			pm.container_name,
-			PERCENTILE_CONT(0.50) WITHIN GROUP (ORDER BY pm.cpu_millicores) as p50_cpu,
-			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY pm.cpu_millicores) as p95_cpu,
-			PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY pm.cpu_millicores) as p99_cpu,
-			MAX(pm.cpu_millicores) as max_cpu,
-			AVG(pm.cpu_millicores) as avg_cpu,
-			STDDEV(pm.cpu_millicores) as stddev_cpu,
-			COUNT(*) as data_points,
-			PERCENTILE_CONT(0.50) WITHIN GROUP (ORDER BY pm.memory_bytes) as p50_mem,
-			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY pm.memory_bytes) as p95_mem,
-			PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY pm.memory_bytes) as p99_mem,
-			MAX(pm.memory_bytes) as max_mem,
-			AVG(pm.memory_bytes) as avg_mem,
-			STDDEV(pm.memory_bytes) as stddev_mem
-		FROM pod_metrics pm
-		WHERE 
-			pm.namespace = $1 
-			AND pm.timestamp > NOW() - INTERVAL '7 days'
-		GROUP BY pm.pod_name, pm.container_name
-		HAVING COUNT(*) >= $2
-	`, namespace, ra.minDataPoints)
-	
-	if err != nil {
-		return nil, fmt.Errorf("querying metrics: %w", err)
+	return ra.AnalyzeNamespaceWithOptions(ctx, namespace, nil)
+}
+
+// AnalyzeNamespaceWithOptions analyzes the namespace with per-request overrides of the
+// analyzer's margins and thresholds. Nil options use the analyzer's defaults.
+func (ra *RightsizingAnalyzer) AnalyzeNamespaceWithOptions(ctx context.Context, namespace string, opts *AnalysisOptions) ([]Recommendation, error) {
+	if opts == nil {
+		opts = ra.DefaultOptions()
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
 	}
-	defer rows.Close()
 
-	var recommendations []Recommendation
+	ctx, span := tracing.Start(ctx, "analyze namespace", attribute.String("namespace", namespace))
+	defer span.End()
 
-	for rows.Next() {
-		var podName, containerName string
-		var p50CPU, p95CPU, p99CPU, maxCPU, avgCPU, stddevCPU float64
-		var dataPoints int
-		var p50Mem, p95Mem, p99Mem, maxMem, avgMem, stddevMem float64
+	// Prefer the incremental rollup; fall back to raw metrics until it covers the window
+	var stats []containerStats
+	var err error
+	if ra.db != nil {
+		stats, err = ra.loadRollupStats(ctx, namespace, opts.Cluster)
+		if err != nil {
+			ra.log.Warnf("Failed to read rollups for %s, using raw metrics: %v", namespace, err)
+		}
+	}
+	if stats == nil {
+		stats, err = ra.loadRawStats(ctx, namespace, opts.Cluster)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		err := rows.Scan(&podName, &containerName, 
-			&p50CPU, &p95CPU, &p99CPU, &maxCPU, &avgCPU, &stddevCPU, &dataPoints,
-			&p50Mem, &p95Mem, &p99Mem, &maxMem, &avgMem, &stddevMem)
+	prices := ra.resourcePrices(ctx)
 
+	var peaks map[[2]string]containerPeaks
+	if opts.SeasonalityAware && ra.db != nil {
+		peaks, err = ra.loadSeasonalPeaks(ctx, namespace, opts.Cluster)
 		if err != nil {
-			ra.log.Warnf("Failed to scan metrics for %s/%s: %v", podName, containerName, err)
-			continue
+			ra.log.Warnf("Failed to load hour-of-week usage for %s, using window-wide percentiles: %v", namespace, err)
 		}
+	}
+
+	var signals map[[2]string]containerSignals
+	if ra.db != nil {
+		signals, err = ra.loadSignals(ctx, namespace, opts.Cluster)
+		if err != nil {
+			ra.log.Warnf("Failed to load OOM kills and throttling for %s, sizing from usage alone: %v", namespace, err)
+		}
+	}
+
+	owners, err := ra.loadOwners(ctx, namespace, opts.Cluster)
+	if err != nil {
+		ra.log.Warnf("Failed to load pod owners for %s, analyzing pods individually: %v", namespace, err)
+	}
+
+	var recommendations []Recommendation
+
+	for _, stat := range stats {
+		podName, containerName := stat.PodName, stat.ContainerName
 
 		// Get current resource requests/limits from database
-		currentRequests, currentLimits, err := ra.getCurrentResources(namespace, podName, containerName)
+		currentRequests, currentLimits, err := ra.getCurrentResources(ctx, namespace, opts.Cluster, podName, containerName)
 		if err != nil {
 			ra.log.Warnf("Failed to get current resources for %s/%s: %v", podName, containerName, err)
 			continue
 		}
 
-		// CPU Recommendation
-		cpuRec := ra.calculateCPURecommendation(
-			currentRequests.CPURequest, currentLimits.CPULimit,
-			p50CPU, p95CPU, p99CPU, maxCPU, avgCPU, stddevCPU,
-			dataPoints,
-		)
-
-		if cpuRec != nil {
-			cpuRec.Namespace = namespace
-			cpuRec.PodName = podName
-			cpuRec.ContainerName = containerName
-			cpuRec.LastUpdated = time.Now()
-			recommendations = append(recommendations, *cpuRec)
+		key := [2]string{podName, containerName}
+		peak, seasonal := peaks[key]
+		recommendations = append(recommendations, ra.recommendContainer(namespace, stat,
+			currentRequests, currentLimits, signals[key], peak, seasonal, opts, prices)...)
+	}
+
+	// GPU recommendations, for containers with DCGM utilization data
+	if ra.gpu != nil {
+		gpuRecs, err := ra.AnalyzeGPU(ctx, namespace, opts.Cluster)
+		if err != nil {
+			ra.log.Warnf("Failed to analyze GPU usage for %s: %v", namespace, err)
+		}
+		recommendations = append(recommendations, gpuRecs...)
+	}
+
+	// Filter after consolidation, which sums a workload's savings across its replicas
+	return filterRecommendations(consolidateReplicas(recommendations, owners, stats, prices), opts), nil
+}
+
+// recommendContainer sizes one container's CPU and memory from its usage statistics,
+// current resources and OOM and throttling signals. seasonal says whether peak holds
+// its busiest hour-of-week buckets.
+func (ra *RightsizingAnalyzer) recommendContainer(
+	namespace string,
+	stat containerStats,
+	currentRequests, currentLimits *ResourceAllocation,
+	signal containerSignals,
+	peak containerPeaks,
+	seasonal bool,
+	opts *AnalysisOptions,
+	prices *ResourcePrices,
+) []Recommendation {
+	podName, containerName := stat.PodName, stat.ContainerName
+	var recommendations []Recommendation
+
+	// CPU Recommendation
+	cpuRec := ra.calculateCPURecommendation(
+		currentRequests.CPURequest, currentLimits.CPULimit,
+		stat.CPU, stat.DataPoints, signal, opts, prices,
+	)
+
+	if cpuRec != nil {
+		if seasonal {
+			applyCPUPeak(cpuRec, peak.CPU)
 		}
+		// As Guaranteed the request must cover the P99, not just the target percentile.
+		// Throttled containers stay Burstable, keeping the limit raised above the request.
+		applyQoS(cpuRec, !seasonal && !signal.Throttled && stat.CPU.steady(),
+			stat.CPU.P99*opts.CPUSafetyMargin, prices.PerMillicoreHour)
+		cpuRec.Namespace = namespace
+		cpuRec.PodName = podName
+		cpuRec.ContainerName = containerName
+		cpuRec.LastUpdated = time.Now()
+		recommendations = append(recommendations, *cpuRec)
+	}
+
+	// Memory Recommendation
+	memRec := ra.calculateMemoryRecommendation(
+		currentRequests.MemoryRequest, currentLimits.MemoryLimit,
+		stat.Memory, stat.DataPoints, signal, opts, prices,
+	)
 
-		// Memory Recommendation
-		memRec := ra.calculateMemoryRecommendation(
-			currentRequests.MemoryRequest, currentLimits.MemoryLimit,
-			p50Mem, p95Mem, p99Mem, maxMem, avgMem, stddevMem,
-			dataPoints,
-		)
-
-		if memRec != nil {
-			memRec.Namespace = namespace
-			memRec.PodName = podName
-			memRec.ContainerName = containerName
-			memRec.LastUpdated = time.Now()
-			recommendations = append(recommendations, *memRec)
+	if memRec != nil {
+		if seasonal {
+			applyMemoryPeak(memRec, peak.Memory)
 		}
+		// As Guaranteed the request is raised to the OOM-safe limit
+		applyQoS(memRec, !seasonal && stat.Memory.steady(), memRec.RecommendedLimit, prices.PerByteHour)
+		memRec.Namespace = namespace
+		memRec.PodName = podName
+		memRec.ContainerName = containerName
+		memRec.LastUpdated = time.Now()
+		recommendations = append(recommendations, *memRec)
 	}
 
-	return recommendations, nil
+	return recommendations
+}
+
+// loadRawStats computes per-container usage statistics from the raw samples in the
+// store, optionally restricted to one cluster
+func (ra *RightsizingAnalyzer) loadRawStats(ctx context.Context, namespace, cluster string) ([]containerStats, error) {
+	if ra.store == nil {
+		return nil, fmt.Errorf("no usage store configured")
+	}
+
+	stored, err := ra.store.UsageStats(ctx, namespace, cluster, time.Now().Add(-ra.analysisWindow), ra.minDataPoints)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]containerStats, 0, len(stored))
+	for _, stat := range stored {
+		stats = append(stats, containerStats{
+			PodName:       stat.PodName,
+			ContainerName: stat.ContainerName,
+			DataPoints:    stat.Samples,
+			CPU:           usageStats(stat.CPU),
+			Memory:        usageStats(stat.Memory),
+		})
+	}
+	return stats, nil
 }
 
 func (ra *RightsizingAnalyzer) calculateCPURecommendation(
-	currentRequest, currentLimit,
-	p50, p95, p99, max, avg, stddev float64,
+	currentRequest, currentLimit float64,
+	usage usageStats,
 	dataPoints int,
+	signals containerSignals,
+	opts *AnalysisOptions,
+	prices *ResourcePrices,
 ) *Recommendation {
+	p50, p95, p99, max, avg, stddev := usage.P50, usage.P95, usage.P99, usage.Max, usage.Avg, usage.StdDev
+	target := usage.percentile(opts.Percentile)
+
 	// Calculate coefficient of variation for stability check
 	cv := stddev / avg
 	if avg == 0 {
@@ -162,9 +281,8 @@ func (ra *RightsizingAnalyzer) calculateCPURecommendation(
 	confidence := ra.calculateConfidence(dataPoints, cv)
 
 	// Calculate recommended values
-	// Use P95 for request with a safety margin
-	safetyMargin := 1.15 // 15% safety margin
-	recommendedRequest := p95 * safetyMargin
+	// Use the target percentile (P95 by default) for request with a safety margin
+	recommendedRequest := target * opts.CPUSafetyMargin
 
 	// Use P99 or max for limit based on variability
 	var recommendedLimit float64
@@ -185,18 +303,21 @@ func (ra *RightsizingAnalyzer) calculateCPURecommendation(
 		riskLevel = "HIGH"
 	}
 
-	// Check if current allocation is wasteful
-	waste := (currentRequest - p95) / currentRequest
-	if waste < ra.wasteThreshold && confidence > 0.7 {
-		return nil // No significant waste
+	// Check if current allocation is wasteful. Without a request there is no waste
+	// to measure, and the scheduler places the container as if it used nothing, so
+	// propose an initial request from its usage instead. A throttled container needs
+	// a higher limit whether or not it wastes its request.
+	if currentRequest <= 0 {
+		reasoning = initialSizingReasoning(opts.Percentile) + ". " + reasoning
+	} else {
+		waste := (currentRequest - target) / currentRequest
+		if waste < opts.WasteThreshold && confidence > opts.ConfidenceThreshold && !signals.Throttled {
+			return nil // No significant waste
+		}
 	}
 
-	// Calculate potential savings
-	// Assume linear cost model for simplicity
-	costPerMillicore := 0.00001 // $0.00001 per millicore per hour
-	hourlyCurrentCost := currentRequest * costPerMillicore
-	hourlyRecommendedCost := recommendedRequest * costPerMillicore
-	monthlySavings := (hourlyCurrentCost - hourlyRecommendedCost) * 24 * 30
+	// Calculate potential savings at the per-unit price
+	monthlySavings, initialSizingCost := monthlyChange(currentRequest, recommendedRequest, prices.PerMillicoreHour)
 
 	// Ensure recommendations are reasonable
 	if recommendedRequest < 10 { // Minimum 10 millicores
@@ -209,7 +330,7 @@ func (ra *RightsizingAnalyzer) calculateCPURecommendation(
 		reasoning += " (adjusted limit to 1.5x request)"
 	}
 
-	return &Recommendation{
+	rec := &Recommendation{
 		ResourceType:       "CPU",
 		CurrentRequest:     currentRequest,
 		CurrentLimit:       currentLimit,
@@ -220,17 +341,27 @@ func (ra *RightsizingAnalyzer) calculateCPURecommendation(
 		P99Usage:          p99,
 		MaxUsage:          max,
 		PotentialSavings:  monthlySavings,
+		InitialSizingCost: initialSizingCost,
 		Confidence:        confidence,
 		Reasoning:         reasoning,
 		RiskLevel:         riskLevel,
+		ThrottleObserved:  signals.Throttled,
 	}
+	holdBack(rec, signals.Throttled, "CPU throttled", prices.PerMillicoreHour)
+	return rec
 }
 
 func (ra *RightsizingAnalyzer) calculateMemoryRecommendation(
-	currentRequest, currentLimit,
-	p50, p95, p99, max, avg, stddev float64,
+	currentRequest, currentLimit float64,
+	usage usageStats,
 	dataPoints int,
+	signals containerSignals,
+	opts *AnalysisOptions,
+	prices *ResourcePrices,
 ) *Recommendation {
+	p50, p95, p99, max, avg, stddev := usage.P50, usage.P95, usage.P99, usage.Max, usage.Avg, usage.StdDev
+	target := usage.percentile(opts.Percentile)
+
 	// Memory recommendations are more conservative due to OOM risks
 	cv := stddev / avg
 	if avg == 0 {
@@ -241,23 +372,27 @@ func (ra *RightsizingAnalyzer) calculateMemoryRecommendation(
 
 	// For memory, always use max observed + buffer to avoid OOM
 	oomBuffer := 1.2 // 20% buffer
-	recommendedRequest := p95 * 1.1
+	recommendedRequest := target * opts.MemorySafetyMargin
 	recommendedLimit := max * oomBuffer
 
 	// Round to nearest sensible value (Mi)
 	recommendedRequest = math.Ceil(recommendedRequest/1048576) * 1048576
 	recommendedLimit = math.Ceil(recommendedLimit/1048576) * 1048576
 
-	waste := (currentRequest - p95) / currentRequest
-	if waste < ra.wasteThreshold && confidence > 0.7 {
-		return nil
+	reasoning := "Memory recommendation with OOM prevention buffer"
+	if currentRequest <= 0 {
+		// Unset requests are sized from usage rather than measured for waste, as for CPU
+		reasoning = initialSizingReasoning(opts.Percentile) + ". " + reasoning
+	} else {
+		// An OOMKilled container needs a higher limit however little of its request it uses
+		waste := (currentRequest - target) / currentRequest
+		if waste < opts.WasteThreshold && confidence > opts.ConfidenceThreshold && !signals.OOMKilled {
+			return nil
+		}
 	}
 
 	// Calculate savings (memory typically more expensive than CPU)
-	costPerByte := 0.00000001 // $0.00000001 per byte per hour
-	hourlyCurrentCost := currentRequest * costPerByte
-	hourlyRecommendedCost := recommendedRequest * costPerByte
-	monthlySavings := (hourlyCurrentCost - hourlyRecommendedCost) * 24 * 30
+	monthlySavings, initialSizingCost := monthlyChange(currentRequest, recommendedRequest, prices.PerByteHour)
 
 	// Determine risk level based on variability
 	var riskLevel string
@@ -278,7 +413,7 @@ func (ra *RightsizingAnalyzer) calculateMemoryRecommendation(
 		recommendedLimit = recommendedRequest * 1.5
 	}
 
-	return &Recommendation{
+	rec := &Recommendation{
 		ResourceType:       "Memory",
 		CurrentRequest:     currentRequest,
 		CurrentLimit:       currentLimit,
@@ -289,27 +424,86 @@ func (ra *RightsizingAnalyzer) calculateMemoryRecommendation(
 		P99Usage:          p99,
 		MaxUsage:          max,
 		PotentialSavings:  monthlySavings,
+		InitialSizingCost: initialSizingCost,
 		Confidence:        confidence,
-		Reasoning:         "Memory recommendation with OOM prevention buffer",
+		Reasoning:         reasoning,
 		RiskLevel:         riskLevel,
+		OOMObserved:       signals.OOMKilled,
 	}
+	holdBack(rec, signals.OOMKilled, "OOMKilled", prices.PerByteHour)
+	return rec
 }
 
-func (ra *RightsizingAnalyzer) calculateConfidence(dataPoints int, cv float64) float64 {
-	// Base confidence on data points
-	baseConfidence := math.Min(float64(dataPoints)/1000.0, 1.0) // Max 1000 data points
+// initialSizingReasoning explains a recommendation for a container with no request.
+// It saves nothing; reserving what the container already uses is its initial sizing
+// cost.
+func initialSizingReasoning(percentile float64) string {
+	return fmt.Sprintf("No request set; proposing initial sizing from P%.0f usage", percentile*100)
+}
 
-	// Adjust for variability
-	variabilityFactor := 1.0 - (cv * 0.5) // Higher CV reduces confidence
-	if variabilityFactor < 0.3 {
-		variabilityFactor = 0.3 // Minimum 30% confidence
+// monthlyChange prices moving a container's request from current to recommended at
+// unitPrice per hour. A container without a request saves nothing: what the new
+// request costs is returned as its initial sizing cost instead, so totals don't net it
+// against other containers' savings.
+func monthlyChange(current, recommended, unitPrice float64) (savings, initialSizingCost float64) {
+	change := (current - recommended) * unitPrice * 24 * 30
+	if current <= 0 {
+		return 0, -change
+	}
+	return change, 0
+}
+
+// SavingsTotals sums recommendations' monthly savings, by resource, and separately the
+// monthly cost of sizing containers that had no request
+type SavingsTotals struct {
+	Savings           float64
+	CPUSavings        float64
+	MemorySavings     float64
+	InitialSizingCost float64
+}
+
+// TotalSavings adds up the recommendations' savings and initial sizing costs
+func TotalSavings(recommendations []Recommendation) SavingsTotals {
+	var totals SavingsTotals
+	for _, rec := range recommendations {
+		totals.Savings += rec.PotentialSavings
+		totals.InitialSizingCost += rec.InitialSizingCost
+		switch rec.ResourceType {
+		case "CPU":
+			totals.CPUSavings += rec.PotentialSavings
+		case "Memory":
+			totals.MemorySavings += rec.PotentialSavings
+		}
+	}
+	return totals
+}
+
+// Relative half-width of the confidence interval at which confidence is 0.5. A mean
+// known to within ±5% is a reasonable basis for a request change.
+const confidenceTolerance = 0.05
+
+// calculateConfidence scores how well the usage mean is known, from the sample count n
+// and coefficient of variation cv (stddev / mean).
+//
+// The standard error of the mean relative to the mean is cv / sqrt(n). At the
+// configured confidence level L the two-sided interval has relative half-width
+//
+//	w = z * cv / sqrt(n),  where z = sqrt(2) * erfinv(L)
+//
+// and confidence is 1 / (1 + w / confidenceTolerance), so narrower intervals score
+// higher: more samples or less variance always raise it. The result is clamped to
+// [0.1, 0.95] since usage samples are autocorrelated and never fully independent.
+func (ra *RightsizingAnalyzer) calculateConfidence(dataPoints int, cv float64) float64 {
+	if dataPoints < 2 {
+		return 0.1
 	}
 
-	// Combine factors
-	confidence := baseConfidence * variabilityFactor
+	z := math.Sqrt2 * math.Erfinv(ra.confidenceLevel)
+	halfWidth := z * math.Abs(cv) / math.Sqrt(float64(dataPoints))
+	confidence := 1 / (1 + halfWidth/confidenceTolerance)
 
 	// Ensure reasonable bounds
-	if confidence < 0.1 {
+	if math.IsNaN(confidence) || confidence < 0.1 {
 		confidence = 0.1
 	}
 	if confidence > 0.95 {
@@ -319,93 +513,85 @@ func (ra *RightsizingAnalyzer) calculateConfidence(dataPoints int, cv float64) f
 	return confidence
 }
 
-func (ra *RightsizingAnalyzer) getCurrentResources(namespace, podName, containerName string) (*ResourceAllocation, *ResourceAllocation, error) {
-	var cpuRequest, cpuLimit, memoryRequest, memoryLimit float64
-
-	err := ra.db.QueryRow(`
-		SELECT cpu_request, cpu_limit, memory_request, memory_limit 
-		FROM resource_requests 
-		WHERE namespace = $1 AND pod_name = $2 AND container_name = $3
-		ORDER BY timestamp DESC LIMIT 1
-	`, namespace, podName, containerName).Scan(&cpuRequest, &cpuLimit, &memoryRequest, &memoryLimit)
+func (ra *RightsizingAnalyzer) getCurrentResources(ctx context.Context, namespace, cluster, podName, containerName string) (*ResourceAllocation, *ResourceAllocation, error) {
+	if ra.store == nil {
+		return nil, nil, fmt.Errorf("no usage store configured")
+	}
 
+	current, err := ra.store.CurrentResources(ctx, namespace, cluster, podName, containerName)
 	if err != nil {
-		return nil, nil, fmt.Errorf("getting current resources: %w", err)
+		return nil, nil, err
 	}
 
 	requests := &ResourceAllocation{
-		CPURequest:    cpuRequest,
-		CPULimit:      cpuLimit,
-		MemoryRequest: memoryRequest,
-		MemoryLimit:   memoryLimit,
+		CPURequest:    current.CPURequest,
+		CPULimit:      current.CPULimit,
+		MemoryRequest: current.MemoryRequest,
+		MemoryLimit:   current.MemoryLimit,
 	}
 
 	limits := &ResourceAllocation{
-		CPURequest:    cpuLimit,
-		CPULimit:      cpuLimit,
-		MemoryRequest: memoryLimit,
-		MemoryLimit:   memoryLimit,
+		CPURequest:    current.CPULimit,
+		CPULimit:      current.CPULimit,
+		MemoryRequest: current.MemoryLimit,
+		MemoryLimit:   current.MemoryLimit,
 	}
 
 	return requests, limits, nil
 }
 
-func (ra *RightsizingAnalyzer) GetRecommendationHistory(ctx context.Context, namespace string) ([]Recommendation, error) {
-	rows, err := ra.db.QueryContext(ctx, `
-		SELECT 
-			namespace, pod_name, container_name, resource_type,
-			current_request, current_limit, recommended_request, recommended_limit,
-			p50_usage, p95_usage, p99_usage, max_usage,
-			potential_savings, confidence, reasoning, risk_level, created_at
-		FROM recommendations
-		WHERE namespace = $1
-		ORDER BY created_at DESC
-	`, namespace)
-
-	if err != nil {
-		return nil, fmt.Errorf("querying recommendation history: %w", err)
-	}
-	defer rows.Close()
-
-	var recommendations []Recommendation
-
-	for rows.Next() {
-		var rec Recommendation
-		var createdAt time.Time
+// GetRecommendationHistory returns a page of the namespace's stored recommendations,
+// newest first, along with the number matching the filter across all pages
+func (ra *RightsizingAnalyzer) GetRecommendationHistory(ctx context.Context, namespace string, filter RecommendationHistoryFilter) ([]Recommendation, int, error) {
+	return ra.recommendations.RecommendationHistory(ctx, namespace, filter)
+}
 
-		err := rows.Scan(
-			&rec.Namespace, &rec.PodName, &rec.ContainerName, &rec.ResourceType,
-			&rec.CurrentRequest, &rec.CurrentLimit, &rec.RecommendedRequest, &rec.RecommendedLimit,
-			&rec.P50Usage, &rec.P95Usage, &rec.P99Usage, &rec.MaxUsage,
-			&rec.PotentialSavings, &rec.Confidence, &rec.Reasoning, &rec.RiskLevel, &createdAt,
-		)
+// GetRecommendation loads a stored recommendation by ID
+func (ra *RightsizingAnalyzer) GetRecommendation(ctx context.Context, id int64) (*Recommendation, error) {
+	return ra.recommendations.Recommendation(ctx, id)
+}
 
-		if err != nil {
-			ra.log.Warnf("Failed to scan recommendation: %v", err)
-			continue
-		}
+// MarkApplied records that a stored recommendation has been applied to the cluster
+func (ra *RightsizingAnalyzer) MarkApplied(ctx context.Context, id int64) error {
+	return ra.recommendations.MarkApplied(ctx, id)
+}
 
-		rec.LastUpdated = createdAt
-		recommendations = append(recommendations, rec)
+// AttachStoredIDs sets the ID of each recommendation that has an unapplied one stored
+// for its container resource, without storing anything. Recommendations without one
+// keep a zero ID.
+func (ra *RightsizingAnalyzer) AttachStoredIDs(ctx context.Context, namespace string, recommendations []Recommendation) error {
+	ids, err := ra.recommendations.OpenRecommendationIDs(ctx, namespace)
+	if err != nil {
+		return err
 	}
-
-	return recommendations, nil
+	for i := range recommendations {
+		recommendations[i].ID = ids[recommendations[i].Key()]
+	}
+	return nil
 }
 
+// SaveRecommendation stores the recommendation and sets its ID. A container has at most
+// one unapplied recommendation per resource, which re-analysis updates in place; once
+// applied it is kept as history and the next analysis starts a new one.
 func (ra *RightsizingAnalyzer) SaveRecommendation(ctx context.Context, rec *Recommendation) error {
-	_, err := ra.db.ExecContext(ctx, `
-		INSERT INTO recommendations 
-		(namespace, pod_name, container_name, resource_type,
-		 current_request, current_limit, recommended_request, recommended_limit,
-		 p50_usage, p95_usage, p99_usage, max_usage,
-		 potential_savings, confidence, reasoning, risk_level, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-	`, rec.Namespace, rec.PodName, rec.ContainerName, rec.ResourceType,
-		rec.CurrentRequest, rec.CurrentLimit, rec.RecommendedRequest, rec.RecommendedLimit,
-		rec.P50Usage, rec.P95Usage, rec.P99Usage, rec.MaxUsage,
-		rec.PotentialSavings, rec.Confidence, rec.Reasoning, rec.RiskLevel, rec.LastUpdated)
-
-	return err
+	return ra.recommendations.SaveRecommendation(ctx, rec)
+}
+
+// SaveRecommendations stores each recommendation, setting its ID, so they can be
+// applied by ID and appear in the history. Recommendations that fail to save keep a
+// zero ID; the first error is returned after trying them all.
+func (ra *RightsizingAnalyzer) SaveRecommendations(ctx context.Context, recommendations []Recommendation) error {
+	ctx, span := tracing.StartQuery(ctx, "save recommendations", attribute.Int("recommendations", len(recommendations)))
+	defer span.End()
+
+	var firstErr error
+	for i := range recommendations {
+		if err := ra.SaveRecommendation(ctx, &recommendations[i]); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("saving recommendation for %s/%s: %w",
+				recommendations[i].PodName, recommendations[i].ContainerName, err)
+		}
+	}
+	return firstErr
 }
 
 func (ra *RightsizingAnalyzer) GetOptimizationSummary(ctx context.Context, namespace string) (map[string]interface{}, error) {
@@ -414,20 +600,11 @@ func (ra *RightsizingAnalyzer) GetOptimizationSummary(ctx context.Context, names
 		return nil, err
 	}
 
-	var totalSavings float64
-	var cpuSavings, memorySavings float64
+	totals := TotalSavings(recommendations)
 	var highConfidenceCount, mediumConfidenceCount, lowConfidenceCount int
 	var highRiskCount, mediumRiskCount, lowRiskCount int
 
 	for _, rec := range recommendations {
-		totalSavings += rec.PotentialSavings
-
-		if rec.ResourceType == "CPU" {
-			cpuSavings += rec.PotentialSavings
-		} else {
-			memorySavings += rec.PotentialSavings
-		}
-
 		// Count by confidence
 		if rec.Confidence >= 0.8 {
 			highConfidenceCount++
@@ -450,10 +627,11 @@ func (ra *RightsizingAnalyzer) GetOptimizationSummary(ctx context.Context, names
 
 	return map[string]interface{}{
 		"total_recommendations": len(recommendations),
-		"total_savings":         totalSavings,
-		"annual_savings":        totalSavings * 12,
-		"cpu_savings":           cpuSavings,
-		"memory_savings":        memorySavings,
+		"total_savings":         totals.Savings,
+		"annual_savings":        totals.Savings * 12,
+		"cpu_savings":           totals.CPUSavings,
+		"memory_savings":        totals.MemorySavings,
+		"initial_sizing_cost":   totals.InitialSizingCost,
 		"confidence_breakdown": map[string]int{
 			"high":   highConfidenceCount,
 			"medium": mediumConfidenceCount,
@@ -464,6 +642,6 @@ func (ra *RightsizingAnalyzer) GetOptimizationSummary(ctx context.Context, names
 			"medium": mediumRiskCount,
 			"high":   highRiskCount,
 		},
-		"optimization_potential": (totalSavings / 1000) * 100, // Percentage of $1000 baseline
+		"optimization_potential": (totals.Savings / 1000) * 100, // Percentage of $1000 baseline
 	}, nil
 } 
\ No newline at end of file
@@ -0,0 +1,484 @@
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-cost-optimizer/internal/store"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const mi = 1024 * 1024
+
+var testPrices = &ResourcePrices{PerMillicoreHour: costPerMillicoreHour, PerByteHour: costPerByteHour}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// withCV returns usage with the given mean and coefficient of variation
+func withCV(usage usageStats, avg, cv float64) usageStats {
+	usage.Avg = avg
+	usage.StdDev = avg * cv
+	return usage
+}
+
+func TestCalculateCPURecommendation(t *testing.T) {
+	usage := usageStats{P50: 150, P95: 200, P99: 400, Max: 700}
+
+	tests := []struct {
+		name       string
+		request    float64
+		limit      float64
+		usage      usageStats
+		dataPoints int
+		signals    containerSignals
+
+		wantNil         bool
+		wantRequest     float64
+		wantLimit       float64
+		wantSavings     float64
+		wantInitialCost float64
+		wantRisk        string
+		wantReasoning   string
+	}{
+		{
+			name:    "low variability limits at P99 plus 20%",
+			request: 1000, usage: withCV(usage, 150, 0.1), dataPoints: 1000,
+			wantRequest: 230, wantLimit: 480, wantRisk: "LOW",
+			wantSavings: (1000 - 230) * costPerMillicoreHour * 24 * 30,
+		},
+		{
+			name:    "medium variability limits at the larger of P99*1.5 and max",
+			request: 1000, usage: withCV(usage, 150, 0.45), dataPoints: 1000,
+			wantRequest: 230, wantLimit: 700, wantRisk: "MEDIUM",
+			wantSavings: (1000 - 230) * costPerMillicoreHour * 24 * 30,
+		},
+		{
+			name:    "high variability limits at max plus 30%",
+			request: 1000, usage: withCV(usage, 150, 0.8), dataPoints: 1000,
+			wantRequest: 230, wantLimit: 910, wantRisk: "HIGH",
+			wantSavings: (1000 - 230) * costPerMillicoreHour * 24 * 30,
+		},
+		{
+			name:    "request clamped to 10m and limit to 1.5x request",
+			request: 1000, usage: withCV(usageStats{P50: 4, P95: 5, P99: 6, Max: 8}, 4, 0.1), dataPoints: 1000,
+			wantRequest: 10, wantLimit: 15, wantRisk: "LOW",
+			wantSavings:   (1000 - 5.75) * costPerMillicoreHour * 24 * 30, // Priced before the clamp
+			wantReasoning: "adjusted to minimum 10m CPU",
+		},
+		{
+			name:    "waste below the threshold at high confidence is not recommended",
+			request: 250, usage: withCV(usage, 150, 0.1), dataPoints: 1000,
+			wantNil: true,
+		},
+		{
+			name:    "waste below the threshold at low confidence is still recommended",
+			request: 250, usage: withCV(usage, 150, 0.1), dataPoints: 1,
+			wantRequest: 230, wantLimit: 480, wantRisk: "LOW",
+			wantSavings: (250 - 230) * costPerMillicoreHour * 24 * 30,
+		},
+		{
+			name:    "zero request proposes initial sizing as a cost, not a saving",
+			request: 0, usage: withCV(usage, 150, 0.1), dataPoints: 1000,
+			wantRequest: 230, wantLimit: 480, wantRisk: "LOW",
+			wantInitialCost: 230 * costPerMillicoreHour * 24 * 30,
+			wantReasoning:   "No request set",
+		},
+		{
+			name:    "zero average usage has zero variability",
+			request: 100, usage: usageStats{}, dataPoints: 1000,
+			wantRequest: 10, wantLimit: 15, wantRisk: "LOW",
+			wantSavings: 100 * costPerMillicoreHour * 24 * 30,
+		},
+		{
+			name:    "throttling holds back the request and raises the limit",
+			request: 1000, limit: 1000, usage: withCV(usage, 150, 0.1), dataPoints: 1000,
+			signals:     containerSignals{Throttled: true},
+			wantRequest: 1000, wantLimit: 1200, wantRisk: "LOW", wantSavings: 0,
+			wantReasoning: "CPU throttled in the analysis window",
+		},
+		{
+			name:    "throttling is recommended even without waste",
+			request: 250, limit: 300, usage: withCV(usage, 150, 0.1), dataPoints: 1000,
+			signals:     containerSignals{Throttled: true},
+			wantRequest: 250, wantLimit: 480, wantRisk: "LOW", wantSavings: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ra := NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+			rec := ra.calculateCPURecommendation(tt.request, tt.limit, tt.usage, tt.dataPoints, tt.signals, ra.DefaultOptions(), testPrices)
+			checkRecommendation(t, rec, tt.wantNil, tt.wantRequest, tt.wantLimit, tt.wantSavings, tt.wantRisk, tt.wantReasoning)
+			if rec != nil && !approxEqual(rec.InitialSizingCost, tt.wantInitialCost) {
+				t.Errorf("InitialSizingCost = %v, want %v", rec.InitialSizingCost, tt.wantInitialCost)
+			}
+			if rec != nil && rec.ThrottleObserved != tt.signals.Throttled {
+				t.Errorf("ThrottleObserved = %v, want %v", rec.ThrottleObserved, tt.signals.Throttled)
+			}
+		})
+	}
+}
+
+func TestCalculateMemoryRecommendation(t *testing.T) {
+	// 99.5Mi P95 with a 10% margin rounds up to 110Mi
+	usage := usageStats{P50: 80 * mi, P95: 99.5 * mi, P99: 120 * mi, Max: 150 * mi}
+
+	tests := []struct {
+		name       string
+		request    float64
+		limit      float64
+		usage      usageStats
+		dataPoints int
+		signals    containerSignals
+
+		wantNil         bool
+		wantRequest     float64
+		wantLimit       float64
+		wantSavings     float64
+		wantInitialCost float64
+		wantRisk        string
+		wantReasoning   string
+	}{
+		{
+			name:    "request from P95 with margin and limit from max plus 20%, rounded up to Mi",
+			request: 500 * mi, usage: withCV(usage, 90*mi, 0.1), dataPoints: 1000,
+			wantRequest: 110 * mi, wantLimit: 180 * mi, wantRisk: "LOW",
+			wantSavings: (500 - 110) * mi * costPerByteHour * 24 * 30,
+		},
+		{
+			name:    "medium variability",
+			request: 500 * mi, usage: withCV(usage, 90*mi, 0.45), dataPoints: 1000,
+			wantRequest: 110 * mi, wantLimit: 180 * mi, wantRisk: "MEDIUM",
+			wantSavings: (500 - 110) * mi * costPerByteHour * 24 * 30,
+		},
+		{
+			name:    "high variability",
+			request: 500 * mi, usage: withCV(usage, 90*mi, 0.8), dataPoints: 1000,
+			wantRequest: 110 * mi, wantLimit: 180 * mi, wantRisk: "HIGH",
+			wantSavings: (500 - 110) * mi * costPerByteHour * 24 * 30,
+		},
+		{
+			name:    "request clamped to 64Mi and limit to 1.5x request",
+			request: 500 * mi, usage: withCV(usageStats{P50: 8 * mi, P95: 9.5 * mi, P99: 12 * mi, Max: 20 * mi}, 9*mi, 0.1), dataPoints: 1000,
+			wantRequest: 64 * mi, wantLimit: 96 * mi, wantRisk: "LOW",
+			wantSavings: (500 - 11) * mi * costPerByteHour * 24 * 30, // Priced at 11Mi, before the clamp
+		},
+		{
+			name:    "waste below the threshold at high confidence is not recommended",
+			request: 120 * mi, usage: withCV(usage, 90*mi, 0.1), dataPoints: 1000,
+			wantNil: true,
+		},
+		{
+			name:    "zero request proposes initial sizing as a cost, not a saving",
+			request: 0, usage: withCV(usage, 90*mi, 0.1), dataPoints: 1000,
+			wantRequest: 110 * mi, wantLimit: 180 * mi, wantRisk: "LOW",
+			wantInitialCost: 110 * mi * costPerByteHour * 24 * 30,
+			wantReasoning:   "No request set",
+		},
+		{
+			name:    "zero average usage has zero variability",
+			request: 256 * mi, usage: usageStats{}, dataPoints: 1000,
+			wantRequest: 64 * mi, wantLimit: 96 * mi, wantRisk: "LOW",
+			wantSavings: 256 * mi * costPerByteHour * 24 * 30,
+		},
+		{
+			name:    "OOM kill holds back the request and raises the limit",
+			request: 256 * mi, limit: 256 * mi, usage: withCV(usage, 90*mi, 0.1), dataPoints: 1000,
+			signals:     containerSignals{OOMKilled: true},
+			wantRequest: 256 * mi, wantLimit: 308 * mi, wantRisk: "LOW", wantSavings: 0,
+			wantReasoning: "OOMKilled in the analysis window",
+		},
+		{
+			name:    "OOM kill is recommended even without waste",
+			request: 120 * mi, limit: 120 * mi, usage: withCV(usage, 90*mi, 0.1), dataPoints: 1000,
+			signals:     containerSignals{OOMKilled: true},
+			wantRequest: 120 * mi, wantLimit: 180 * mi, wantRisk: "LOW", wantSavings: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ra := NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+			rec := ra.calculateMemoryRecommendation(tt.request, tt.limit, tt.usage, tt.dataPoints, tt.signals, ra.DefaultOptions(), testPrices)
+			checkRecommendation(t, rec, tt.wantNil, tt.wantRequest, tt.wantLimit, tt.wantSavings, tt.wantRisk, tt.wantReasoning)
+			if rec != nil && !approxEqual(rec.InitialSizingCost, tt.wantInitialCost) {
+				t.Errorf("InitialSizingCost = %v, want %v", rec.InitialSizingCost, tt.wantInitialCost)
+			}
+			if rec != nil && rec.OOMObserved != tt.signals.OOMKilled {
+				t.Errorf("OOMObserved = %v, want %v", rec.OOMObserved, tt.signals.OOMKilled)
+			}
+		})
+	}
+}
+
+func checkRecommendation(t *testing.T, rec *Recommendation, wantNil bool,
+	wantRequest, wantLimit, wantSavings float64, wantRisk, wantReasoning string) {
+	t.Helper()
+
+	if wantNil {
+		if rec != nil {
+			t.Fatalf("got a recommendation of %v, want none", rec.RecommendedRequest)
+		}
+		return
+	}
+	if rec == nil {
+		t.Fatal("got no recommendation")
+	}
+
+	if !approxEqual(rec.RecommendedRequest, wantRequest) {
+		t.Errorf("RecommendedRequest = %v, want %v", rec.RecommendedRequest, wantRequest)
+	}
+	if !approxEqual(rec.RecommendedLimit, wantLimit) {
+		t.Errorf("RecommendedLimit = %v, want %v", rec.RecommendedLimit, wantLimit)
+	}
+	if !approxEqual(rec.PotentialSavings, wantSavings) {
+		t.Errorf("PotentialSavings = %v, want %v", rec.PotentialSavings, wantSavings)
+	}
+	if rec.RiskLevel != wantRisk {
+		t.Errorf("RiskLevel = %q, want %q", rec.RiskLevel, wantRisk)
+	}
+	if !strings.Contains(rec.Reasoning, wantReasoning) {
+		t.Errorf("Reasoning = %q, want it to contain %q", rec.Reasoning, wantReasoning)
+	}
+	for name, value := range map[string]float64{
+		"Confidence": rec.Confidence, "PotentialSavings": rec.PotentialSavings,
+		"RecommendedRequest": rec.RecommendedRequest, "RecommendedLimit": rec.RecommendedLimit,
+	} {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			t.Errorf("%s = %v", name, value)
+		}
+	}
+	if rec.Confidence < 0.1 || rec.Confidence > 0.95 {
+		t.Errorf("Confidence = %v, want within [0.1, 0.95]", rec.Confidence)
+	}
+}
+
+// TestTotalSavingsExcludeInitialSizing sizes a mix of containers with and without
+// requests, individually and as DaemonSet replicas, and checks that the containers
+// without one add to the initial sizing cost instead of reducing total savings
+func TestTotalSavingsExcludeInitialSizing(t *testing.T) {
+	ra := NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+	opts := ra.DefaultOptions()
+	cpu := withCV(usageStats{P50: 150, P95: 200, P99: 400, Max: 700}, 150, 0.1)
+	memory := withCV(usageStats{P50: 80 * mi, P95: 99.5 * mi, P99: 120 * mi, Max: 150 * mi}, 90*mi, 0.1)
+
+	containers := []struct {
+		pod           string
+		owner         podOwner
+		cpuRequest    float64
+		memoryRequest float64
+	}{
+		{"api-0", podOwner{"Deployment", "api"}, 1000, 500 * mi},
+		{"batch-0", podOwner{"Pod", "batch-0"}, 0, 0},
+		{"agent-a", podOwner{"DaemonSet", "agent"}, 1000, 500 * mi},
+		{"agent-b", podOwner{"DaemonSet", "agent"}, 0, 0},
+	}
+
+	var recs []Recommendation
+	var stats []containerStats
+	owners := map[string]podOwner{}
+	for _, c := range containers {
+		for _, rec := range []*Recommendation{
+			ra.calculateCPURecommendation(c.cpuRequest, 0, cpu, 1000, containerSignals{}, opts, testPrices),
+			ra.calculateMemoryRecommendation(c.memoryRequest, 0, memory, 1000, containerSignals{}, opts, testPrices),
+		} {
+			rec.PodName, rec.ContainerName = c.pod, "app"
+			recs = append(recs, *rec)
+		}
+		stats = append(stats, containerStats{PodName: c.pod, ContainerName: "app"})
+		owners[c.pod] = c.owner
+	}
+
+	cpuSaving := (1000 - 230) * costPerMillicoreHour * 24 * 30
+	memorySaving := (500 - 110) * mi * costPerByteHour * 24 * 30
+	cpuCost := 230 * costPerMillicoreHour * 24 * 30
+	memoryCost := 110 * mi * costPerByteHour * 24 * 30
+
+	// The DaemonSet's two replicas merge into one recommendation per resource
+	consolidated := consolidateReplicas(recs, owners, stats, testPrices)
+	if len(consolidated) != len(recs)-2 {
+		t.Fatalf("consolidated %d recommendations into %d, want %d", len(recs), len(consolidated), len(recs)-2)
+	}
+
+	for name, recs := range map[string][]Recommendation{"per pod": recs, "replicas consolidated": consolidated} {
+		t.Run(name, func(t *testing.T) {
+			totals := TotalSavings(recs)
+			checks := map[string][2]float64{
+				"Savings":           {totals.Savings, 2 * (cpuSaving + memorySaving)},
+				"CPUSavings":        {totals.CPUSavings, 2 * cpuSaving},
+				"MemorySavings":     {totals.MemorySavings, 2 * memorySaving},
+				"InitialSizingCost": {totals.InitialSizingCost, 2 * (cpuCost + memoryCost)},
+			}
+			for field, values := range checks {
+				if !approxEqual(values[0], values[1]) {
+					t.Errorf("%s = %v, want %v", field, values[0], values[1])
+				}
+			}
+			for _, rec := range recs {
+				if rec.PotentialSavings < 0 {
+					t.Errorf("%s %s PotentialSavings = %v, want no negative savings", rec.PodName, rec.ResourceType, rec.PotentialSavings)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateConfidence(t *testing.T) {
+	ra := NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+
+	if got := ra.calculateConfidence(1, 0.1); got != 0.1 {
+		t.Errorf("confidence with one sample = %v, want 0.1", got)
+	}
+	if got := ra.calculateConfidence(1000, 0); got != 0.95 {
+		t.Errorf("confidence without variability = %v, want 0.95", got)
+	}
+
+	// At the default 70% level z = 1.0364, so 100 samples at CV 0.5 give a relative
+	// half-width of 0.0518 and a confidence of 1 / (1 + 0.0518/0.05)
+	if got, want := ra.calculateConfidence(100, 0.5), 1/(1+1.0364334*0.5/10/confidenceTolerance); math.Abs(got-want) > 1e-6 {
+		t.Errorf("confidence with 100 samples at CV 0.5 = %v, want %v", got, want)
+	}
+}
+
+func TestConfidenceRisesWithDataPoints(t *testing.T) {
+	ra := NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+
+	for _, cv := range []float64{0.05, 0.2, 0.5, 1, 3} {
+		previous := ra.calculateConfidence(2, cv)
+		for n := 3; n <= 100000; n = n*3/2 + 1 {
+			got := ra.calculateConfidence(n, cv)
+			if got < previous {
+				t.Errorf("CV %v: confidence fell from %v to %v at %d samples", cv, previous, got, n)
+			}
+			// Strictly higher between the clamps
+			if got == previous && got > 0.1 && got < 0.95 {
+				t.Errorf("CV %v: confidence stayed at %v at %d samples", cv, got, n)
+			}
+			previous = got
+		}
+		if previous <= 0.1 {
+			t.Errorf("CV %v: confidence with 100000 samples = %v, want above the 0.1 floor", cv, previous)
+		}
+	}
+}
+
+func TestConfidenceFallsWithVariance(t *testing.T) {
+	ra := NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+
+	for _, n := range []int{10, 100, 2016, 10000} {
+		previous := ra.calculateConfidence(n, 0)
+		for cv := 0.01; cv <= 10; cv *= 1.5 {
+			got := ra.calculateConfidence(n, cv)
+			if got > previous {
+				t.Errorf("%d samples: confidence rose from %v to %v at CV %v", n, previous, got, cv)
+			}
+			if got == previous && got > 0.1 && got < 0.95 {
+				t.Errorf("%d samples: confidence stayed at %v at CV %v", n, got, cv)
+			}
+			if got < 0.1 || got > 0.95 {
+				t.Errorf("%d samples: confidence at CV %v = %v, want within [0.1, 0.95]", n, cv, got)
+			}
+			previous = got
+		}
+	}
+}
+
+func TestConfidenceLevelWidensInterval(t *testing.T) {
+	// A higher confidence level widens the interval, so the same data scores lower
+	previous := 1.0
+	for _, level := range []float64{0.5, 0.7, 0.9, 0.95, 0.99} {
+		ra := NewRightsizingAnalyzer(nil, store.Repositories{}, nil)
+		if err := ra.SetConfidenceLevel(level); err != nil {
+			t.Fatal(err)
+		}
+		got := ra.calculateConfidence(200, 0.5)
+		if got >= previous {
+			t.Errorf("confidence at level %v = %v, want below %v", level, got, previous)
+		}
+		previous = got
+	}
+}
+
+// TestAnalyzeNamespaceFromPostgres runs namespace analysis against store.Postgres on a
+// sqlmock connection, so the statistics, owners and current requests come through the
+// store's queries and scans rather than being injected
+func TestAnalyzeNamespaceFromPostgres(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stats := sqlmock.NewRows([]string{"pod_name", "container_name",
+		"p50_cpu", "p95_cpu", "p99_cpu", "max_cpu", "avg_cpu", "stddev_cpu", "data_points",
+		"p50_mem", "p95_mem", "p99_mem", "max_mem", "avg_mem", "stddev_mem"}).
+		AddRow("api-0", "app", 150.0, 200.0, 400.0, 700.0, 150.0, 67.5, 1000,
+			700.0*mi, 900.0*mi, 940.0*mi, 950.0*mi, 800.0*mi, 80.0*mi).
+		AddRow("api-0", "sidecar", 5.0, 8.0, 9.0, 10.0, 5.0, 0.0, 1000,
+			mi, mi, mi, mi, mi, 0.0)
+	mock.ExpectQuery(`FROM pod_metrics pm`).
+		WithArgs("web", 100, "prod", sqlmock.AnyArg()).
+		WillReturnRows(stats)
+	mock.ExpectQuery(`SELECT DISTINCT ON \(pod_name\)`).
+		WithArgs("web", sqlmock.AnyArg(), "prod").
+		WillReturnRows(sqlmock.NewRows([]string{"pod_name", "owner_kind", "owner_name"}).
+			AddRow("api-0", "Deployment", "api"))
+	mock.ExpectQuery(`ORDER BY timestamp DESC LIMIT 1`).
+		WithArgs("web", "api-0", "app", "prod").
+		WillReturnRows(sqlmock.NewRows([]string{"cluster", "cpu_request", "cpu_limit", "memory_request",
+			"memory_limit", "owner_kind", "owner_name", "timestamp"}).
+			AddRow("prod", 1000.0, 2000.0, 1024.0*mi, 2048.0*mi, "Deployment", "api", time.Now()))
+	// The sidecar has usage but no recorded requests, so it is skipped
+	mock.ExpectQuery(`ORDER BY timestamp DESC LIMIT 1`).
+		WithArgs("web", "api-0", "sidecar", "prod").
+		WillReturnError(sql.ErrNoRows)
+
+	ra := NewRightsizingAnalyzer(nil, store.Repositories{Metrics: store.NewPostgres(db)}, nil)
+	opts := ra.DefaultOptions()
+	opts.Cluster = "prod"
+	recs, err := ra.AnalyzeNamespaceWithOptions(context.Background(), "web", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// Memory is within the waste threshold, so only CPU is resized
+	if len(recs) != 1 {
+		t.Fatalf("got %d recommendations, want 1: %+v", len(recs), recs)
+	}
+	rec := recs[0]
+	if rec.ResourceType != "CPU" || rec.PodName != "api-0" || rec.ContainerName != "app" || rec.OwnerKind != "Deployment" {
+		t.Errorf("recommendation for %s %s/%s owned by %s, want CPU for api-0/app owned by Deployment",
+			rec.ResourceType, rec.PodName, rec.ContainerName, rec.OwnerKind)
+	}
+	if !approxEqual(rec.RecommendedRequest, 230) || !approxEqual(rec.RecommendedLimit, 700) || rec.RiskLevel != "MEDIUM" {
+		t.Errorf("recommended %vm request, %vm limit at %s risk; want 230m, 700m at MEDIUM",
+			rec.RecommendedRequest, rec.RecommendedLimit, rec.RiskLevel)
+	}
+	if want := (1000 - 230) * costPerMillicoreHour * 24 * 30; !approxEqual(rec.PotentialSavings, want) {
+		t.Errorf("savings = %v, want %v", rec.PotentialSavings, want)
+	}
+}
+
+func TestAnalyzeNamespaceFromPostgresQueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(`FROM pod_metrics pm`).WillReturnError(sql.ErrConnDone)
+
+	ra := NewRightsizingAnalyzer(nil, store.Repositories{Metrics: store.NewPostgres(db)}, nil)
+	if _, err := ra.AnalyzeNamespace(context.Background(), "web"); err == nil {
+		t.Error("analysis succeeded without usage statistics")
+	}
+}
@@ -0,0 +1,159 @@
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tdigest"
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// usageStats summarizes a container's usage of one resource over the analysis window
+type usageStats struct {
+	P50    float64
+	P95    float64
+	P99    float64
+	Max    float64
+	Avg    float64
+	StdDev float64
+}
+
+// containerStats is the per-container input to the recommendation calculations
+type containerStats struct {
+	PodName       string
+	ContainerName string
+	DataPoints    int
+	CPU           usageStats
+	Memory        usageStats
+}
+
+// rollupAccumulator merges hourly rollup rows for a single container
+type rollupAccumulator struct {
+	count                   float64
+	cpuSum, cpuSumSq        float64
+	cpuMax                  float64
+	memSum, memSumSq        float64
+	memMax                  float64
+	cpuDigest, memoryDigest *tdigest.TDigest
+}
+
+// loadRollupStats computes per-container statistics from the hourly pod_metrics_rollup
+// table. It returns nil without an error when the rollups don't yet span the analysis
+// window, so the caller can fall back to the raw metrics.
+func (ra *RightsizingAnalyzer) loadRollupStats(ctx context.Context, namespace, cluster string) ([]containerStats, error) {
+	ctx, span := tracing.StartQuery(ctx, "load rollup stats", attribute.String("namespace", namespace))
+	defer span.End()
+
+	since := time.Now().Add(-ra.analysisWindow)
+
+	var oldest sql.NullTime
+	err := ra.db.QueryRowContext(ctx, `
+		SELECT MIN(bucket) FROM pod_metrics_rollup WHERE namespace = $1 AND ($2 = '' OR cluster = $2)
+	`, namespace, cluster).Scan(&oldest)
+	if err != nil {
+		return nil, fmt.Errorf("checking rollup coverage: %w", err)
+	}
+	if !oldest.Valid || oldest.Time.After(since.Add(time.Hour)) {
+		return nil, nil
+	}
+
+	rows, err := ra.db.QueryContext(ctx, `
+		SELECT pod_name, container_name, sample_count,
+			cpu_sum, cpu_sum_sq, cpu_max, cpu_digest,
+			memory_sum, memory_sum_sq, memory_max, memory_digest
+		FROM pod_metrics_rollup
+		WHERE namespace = $1 AND bucket > $2 AND ($3 = '' OR cluster = $3)
+	`, namespace, since, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying rollups: %w", err)
+	}
+	defer rows.Close()
+
+	accumulators := make(map[[2]string]*rollupAccumulator)
+	var order [][2]string
+
+	for rows.Next() {
+		var podName, containerName string
+		var count, cpuSum, cpuSumSq, cpuMax, memSum, memSumSq, memMax float64
+		var cpuDigest, memoryDigest []byte
+
+		if err := rows.Scan(&podName, &containerName, &count,
+			&cpuSum, &cpuSumSq, &cpuMax, &cpuDigest,
+			&memSum, &memSumSq, &memMax, &memoryDigest); err != nil {
+			return nil, fmt.Errorf("scanning rollup: %w", err)
+		}
+
+		key := [2]string{podName, containerName}
+		acc, ok := accumulators[key]
+		if !ok {
+			acc = &rollupAccumulator{
+				cpuDigest:    tdigest.New(tdigest.DefaultCompression),
+				memoryDigest: tdigest.New(tdigest.DefaultCompression),
+			}
+			accumulators[key] = acc
+			order = append(order, key)
+		}
+
+		acc.count += count
+		acc.cpuSum += cpuSum
+		acc.cpuSumSq += cpuSumSq
+		acc.cpuMax = math.Max(acc.cpuMax, cpuMax)
+		acc.memSum += memSum
+		acc.memSumSq += memSumSq
+		acc.memMax = math.Max(acc.memMax, memMax)
+
+		if digest, err := tdigest.Decode(cpuDigest); err == nil {
+			acc.cpuDigest.Merge(digest)
+		}
+		if digest, err := tdigest.Decode(memoryDigest); err == nil {
+			acc.memoryDigest.Merge(digest)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rollups: %w", err)
+	}
+
+	var stats []containerStats
+	for _, key := range order {
+		acc := accumulators[key]
+		if int(acc.count) < ra.minDataPoints {
+			continue
+		}
+
+		stats = append(stats, containerStats{
+			PodName:       key[0],
+			ContainerName: key[1],
+			DataPoints:    int(acc.count),
+			CPU:           summarize(acc.cpuDigest, acc.count, acc.cpuSum, acc.cpuSumSq, acc.cpuMax),
+			Memory:        summarize(acc.memoryDigest, acc.count, acc.memSum, acc.memSumSq, acc.memMax),
+		})
+	}
+
+	return stats, nil
+}
+
+// summarize derives percentiles from the merged digest and the mean and sample
+// standard deviation from the running sums, matching Postgres' STDDEV
+func summarize(digest *tdigest.TDigest, count, sum, sumSq, max float64) usageStats {
+	stats := usageStats{
+		P50: digest.Quantile(0.50),
+		P95: digest.Quantile(0.95),
+		P99: digest.Quantile(0.99),
+		Max: max,
+	}
+
+	if count > 0 {
+		stats.Avg = sum / count
+	}
+	if count > 1 {
+		variance := (sumSq - sum*sum/count) / (count - 1)
+		stats.StdDev = math.Sqrt(math.Max(variance, 0))
+	}
+
+	return stats
+}
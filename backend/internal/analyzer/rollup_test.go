@@ -0,0 +1,185 @@
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/tdigest"
+)
+
+// rollupDB answers loadRollupStats' two queries from in-memory rollup rows
+type rollupDB struct {
+	oldest time.Time
+	rows   [][]driver.Value
+}
+
+func (db *rollupDB) Connect(context.Context) (driver.Conn, error) { return rollupConn{db}, nil }
+func (db *rollupDB) Driver() driver.Driver                        { return nil }
+
+type rollupConn struct{ db *rollupDB }
+
+func (rollupConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (rollupConn) Close() error                        { return nil }
+func (rollupConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c rollupConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "MIN(bucket)"):
+		if c.db.oldest.IsZero() {
+			return &valueRows{columns: 1, values: [][]driver.Value{{nil}}}, nil
+		}
+		return &valueRows{columns: 1, values: [][]driver.Value{{c.db.oldest}}}, nil
+	case strings.Contains(query, "FROM pod_metrics_rollup"):
+		return &valueRows{columns: 11, values: c.db.rows}, nil
+	}
+	return nil, errors.New("unexpected query")
+}
+
+type valueRows struct {
+	columns int
+	values  [][]driver.Value
+}
+
+func (r *valueRows) Columns() []string { return make([]string, r.columns) }
+func (r *valueRows) Close() error      { return nil }
+
+func (r *valueRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// hourlyRollups builds the rollup rows the collector writes for 5-minute samples,
+// one row per hour
+func hourlyRollups(t *testing.T, pod, container string, cpu, memory []float64) [][]driver.Value {
+	t.Helper()
+
+	const perHour = 12
+	var rows [][]driver.Value
+	for start := 0; start < len(cpu); start += perHour {
+		end := start + perHour
+		cpuDigest, memoryDigest := tdigest.New(tdigest.DefaultCompression), tdigest.New(tdigest.DefaultCompression)
+		var cpuSum, cpuSumSq, cpuMax, memSum, memSumSq, memMax float64
+		for i := start; i < end; i++ {
+			cpuDigest.Add(cpu[i])
+			memoryDigest.Add(memory[i])
+			cpuSum, cpuSumSq, cpuMax = cpuSum+cpu[i], cpuSumSq+cpu[i]*cpu[i], math.Max(cpuMax, cpu[i])
+			memSum, memSumSq, memMax = memSum+memory[i], memSumSq+memory[i]*memory[i], math.Max(memMax, memory[i])
+		}
+
+		encodedCPU, err := cpuDigest.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodedMemory, err := memoryDigest.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, []driver.Value{pod, container, float64(perHour),
+			cpuSum, cpuSumSq, cpuMax, encodedCPU,
+			memSum, memSumSq, memMax, encodedMemory})
+	}
+	return rows
+}
+
+// weekOfUsage returns a week of 5-minute CPU and memory samples with a daily cycle,
+// noise and occasional spikes
+func weekOfUsage(seed int64) (cpu, memory []float64) {
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < 7*24*12; i++ {
+		daily := math.Sin(2 * math.Pi * float64(i) / (24 * 12))
+		c := 200 + 80*daily + rng.NormFloat64()*20
+		if rng.Intn(100) == 0 {
+			c += 500 + rng.Float64()*300
+		}
+		cpu = append(cpu, math.Max(c, 1))
+		memory = append(memory, 300*mi+40*mi*daily+rng.Float64()*20*mi)
+	}
+	return cpu, memory
+}
+
+func TestRollupStatsMatchRawStats(t *testing.T) {
+	fake := &rollupDB{oldest: time.Now().Add(-8 * 24 * time.Hour)}
+	raw := map[string][2][]float64{}
+	for i, pod := range []string{"api-0", "api-1", "worker-0"} {
+		cpu, memory := weekOfUsage(int64(i + 1))
+		raw[pod] = [2][]float64{cpu, memory}
+		fake.rows = append(fake.rows, hourlyRollups(t, pod, "app", cpu, memory)...)
+	}
+
+	db := sql.OpenDB(fake)
+	defer db.Close()
+	ra := NewRightsizingAnalyzer(db, store.NewPostgres(db).Repositories(), nil)
+
+	stats, err := ra.loadRollupStats(context.Background(), "default", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != len(raw) {
+		t.Fatalf("got stats for %d containers, want %d", len(stats), len(raw))
+	}
+
+	for _, stat := range stats {
+		samples := raw[stat.PodName]
+		if stat.DataPoints != len(samples[0]) {
+			t.Errorf("%s: DataPoints = %d, want %d", stat.PodName, stat.DataPoints, len(samples[0]))
+		}
+		compareStats(t, stat.PodName+" CPU", stat.CPU, store.Summarize(samples[0]))
+		compareStats(t, stat.PodName+" memory", stat.Memory, store.Summarize(samples[1]))
+	}
+}
+
+// compareStats checks rollup statistics against those of the raw samples: the
+// percentiles to within 1%, and the moments, which the rollup keeps exactly, to
+// rounding error
+func compareStats(t *testing.T, name string, got usageStats, want store.Stats) {
+	t.Helper()
+
+	percentiles := map[string][2]float64{"P50": {got.P50, want.P50}, "P95": {got.P95, want.P95}, "P99": {got.P99, want.P99}}
+	for p, values := range percentiles {
+		if math.Abs(values[0]-values[1]) > 0.01*values[1] {
+			t.Errorf("%s %s = %v, raw %v (error %.2f%%)", name, p, values[0], values[1],
+				100*math.Abs(values[0]-values[1])/values[1])
+		}
+	}
+
+	moments := map[string][2]float64{"Max": {got.Max, want.Max}, "Avg": {got.Avg, want.Avg}, "StdDev": {got.StdDev, want.StdDev}}
+	for m, values := range moments {
+		if math.Abs(values[0]-values[1]) > 1e-6*values[1] {
+			t.Errorf("%s %s = %v, raw %v", name, m, values[0], values[1])
+		}
+	}
+}
+
+func TestRollupStatsNeedFullCoverage(t *testing.T) {
+	cpu, memory := weekOfUsage(1)
+	for name, oldest := range map[string]time.Time{
+		"no rollups":           {},
+		"rollups a day behind": time.Now().Add(-6 * 24 * time.Hour),
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := sql.OpenDB(&rollupDB{oldest: oldest, rows: hourlyRollups(t, "api-0", "app", cpu, memory)})
+			defer db.Close()
+
+			stats, err := NewRightsizingAnalyzer(db, store.NewPostgres(db).Repositories(), nil).loadRollupStats(context.Background(), "default", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != nil {
+				t.Errorf("got rollup stats for %d containers, want a fall back to raw metrics", len(stats))
+			}
+		})
+	}
+}
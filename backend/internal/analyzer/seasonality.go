@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Headroom above the busiest hour-of-week bucket's P99 used for seasonal CPU limits,
+// matching the low-variability limit on the global P99
+const seasonalLimitHeadroom = 1.2
+
+// Minimum samples in an hour-of-week bucket for its percentile to be trusted. With a
+// 5 minute collection interval a 7 day window gives 12 per bucket.
+const minSeasonalSamples = 6
+
+// seasonalPeak is the hour-of-week bucket (UTC) with the highest P99 usage of one resource
+type seasonalPeak struct {
+	Weekday time.Weekday
+	Hour    int
+	P99     float64
+}
+
+func (p seasonalPeak) String() string {
+	return fmt.Sprintf("%s %02d:00-%02d:00 UTC", p.Weekday, p.Hour, (p.Hour+1)%24)
+}
+
+// containerPeaks holds the CPU and memory peaks for a container, which may fall in
+// different hours
+type containerPeaks struct {
+	CPU    seasonalPeak
+	Memory seasonalPeak
+}
+
+// loadSeasonalPeaks computes per-container P99 usage for every hour of the week in the
+// analysis window and returns each container's busiest bucket. Workloads that spike at
+// the same time every week show up here even when the spike is too short to move the
+// window-wide percentiles.
+func (ra *RightsizingAnalyzer) loadSeasonalPeaks(ctx context.Context, namespace, cluster string) (map[[2]string]containerPeaks, error) {
+	ctx, span := tracing.StartQuery(ctx, "load seasonal peaks", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := ra.db.QueryContext(ctx, `
+		SELECT
+			pm.pod_name,
+			pm.container_name,
+			EXTRACT(dow FROM pm.timestamp AT TIME ZONE 'UTC')::int as dow,
+			EXTRACT(hour FROM pm.timestamp AT TIME ZONE 'UTC')::int as hour,
+			PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY pm.cpu_millicores) as p99_cpu,
+			PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY pm.memory_bytes) as p99_mem
+		FROM pod_metrics pm
+		WHERE
+			pm.namespace = $1
+			AND pm.timestamp > $2
+			AND ($3 = '' OR pm.cluster = $3)
+		GROUP BY pm.pod_name, pm.container_name, dow, hour
+		HAVING COUNT(*) >= $4
+	`, namespace, time.Now().Add(-ra.analysisWindow), cluster, minSeasonalSamples)
+	if err != nil {
+		return nil, fmt.Errorf("querying hour-of-week usage: %w", err)
+	}
+	defer rows.Close()
+
+	peaks := make(map[[2]string]containerPeaks)
+	for rows.Next() {
+		var podName, containerName string
+		var dow, hour int
+		var cpu, memory float64
+		if err := rows.Scan(&podName, &containerName, &dow, &hour, &cpu, &memory); err != nil {
+			ra.log.Warnf("Failed to scan hour-of-week usage for %s/%s: %v", podName, containerName, err)
+			continue
+		}
+
+		key := [2]string{podName, containerName}
+		peak := peaks[key]
+		if cpu > peak.CPU.P99 {
+			peak.CPU = seasonalPeak{Weekday: time.Weekday(dow), Hour: hour, P99: cpu}
+		}
+		if memory > peak.Memory.P99 {
+			peak.Memory = seasonalPeak{Weekday: time.Weekday(dow), Hour: hour, P99: memory}
+		}
+		peaks[key] = peak
+	}
+
+	return peaks, rows.Err()
+}
+
+// applyCPUPeak bases the recommended CPU limit on the busiest hour-of-week bucket
+// instead of the window-wide P99, keeping the limit at least 1.5x the request. A
+// throttled container keeps its limit if the peak would lower it.
+func applyCPUPeak(rec *Recommendation, peak seasonalPeak) {
+	if peak.P99 <= 0 {
+		return
+	}
+	if rec.ThrottleObserved && peak.P99*seasonalLimitHeadroom < rec.RecommendedLimit {
+		return
+	}
+
+	rec.RecommendedLimit = peak.P99 * seasonalLimitHeadroom
+	rec.Reasoning = fmt.Sprintf("Seasonal workload, using peak-hour P99 + 20%% for limit (peak %s, P99 %.0fm)",
+		peak, peak.P99)
+
+	if rec.RecommendedLimit < rec.RecommendedRequest*1.5 {
+		rec.RecommendedLimit = rec.RecommendedRequest * 1.5
+		rec.Reasoning += " (adjusted limit to 1.5x request)"
+	}
+}
+
+// applyMemoryPeak notes the busiest hour-of-week bucket for memory. The memory limit
+// already covers the window-wide max, which no bucket percentile can exceed, so only
+// a peak above the recommended limit (e.g. from rounding) raises it.
+func applyMemoryPeak(rec *Recommendation, peak seasonalPeak) {
+	if peak.P99 <= 0 {
+		return
+	}
+
+	if limit := peak.P99 * seasonalLimitHeadroom; limit > rec.RecommendedLimit {
+		rec.RecommendedLimit = limit
+	}
+	rec.Reasoning += fmt.Sprintf(" (peak %s, P99 %.0f MiB)", peak, peak.P99/1048576)
+}
@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Average share of CFS periods throttled above which a container counts as throttled,
+// the threshold of the kube-prometheus CPUThrottlingHigh alert
+const throttledRatioThreshold = 0.25
+
+// Headroom above the current limit for containers that hit it: an OOM kill or
+// throttling shows the limit is too low, by an unknown amount
+const signalLimitHeadroom = 1.2
+
+// containerSignals records whether a container was OOMKilled or throttled during the
+// analysis window
+type containerSignals struct {
+	OOMKilled bool
+	Throttled bool
+}
+
+// loadSignals reads each container's restarts and CPU throttling over the analysis
+// window from container_signals. Containers without signals aren't in the map.
+func (ra *RightsizingAnalyzer) loadSignals(ctx context.Context, namespace, cluster string) (map[[2]string]containerSignals, error) {
+	ctx, span := tracing.StartQuery(ctx, "load container signals", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := ra.db.QueryContext(ctx, `
+		SELECT
+			pod_name,
+			container_name,
+			BOOL_OR(oom_killed AND restarts > 0) as oom_killed,
+			COALESCE(AVG(throttled_ratio), 0) as throttled_ratio
+		FROM container_signals
+		WHERE
+			namespace = $1
+			AND timestamp > $2
+			AND ($3 = '' OR cluster = $3)
+		GROUP BY pod_name, container_name
+	`, namespace, time.Now().Add(-ra.analysisWindow), cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying container signals: %w", err)
+	}
+	defer rows.Close()
+
+	signals := make(map[[2]string]containerSignals)
+	for rows.Next() {
+		var podName, containerName string
+		var oomKilled bool
+		var throttledRatio float64
+		if err := rows.Scan(&podName, &containerName, &oomKilled, &throttledRatio); err != nil {
+			ra.log.Warnf("Failed to scan container signals for %s/%s: %v", podName, containerName, err)
+			continue
+		}
+
+		signals[[2]string{podName, containerName}] = containerSignals{
+			OOMKilled: oomKilled,
+			Throttled: throttledRatio >= throttledRatioThreshold,
+		}
+	}
+
+	return signals, rows.Err()
+}
+
+// holdBack keeps a container that ran out of a resource from getting less of it: the
+// request isn't reduced below the current one and the limit is raised above the
+// current one. observed says whether the container was OOMKilled or throttled, and
+// reason describes it for the reasoning. Savings are repriced at unitPrice.
+func holdBack(rec *Recommendation, observed bool, reason string, unitPrice float64) {
+	if !observed {
+		return
+	}
+
+	if rec.RecommendedRequest < rec.CurrentRequest {
+		rec.RecommendedRequest = rec.CurrentRequest
+	}
+	if limit := rec.CurrentLimit * signalLimitHeadroom; limit > rec.RecommendedLimit {
+		rec.RecommendedLimit = limit
+	}
+	if rec.ResourceType == "Memory" {
+		rec.RecommendedLimit = math.Ceil(rec.RecommendedLimit/1048576) * 1048576
+	}
+	rec.PotentialSavings, rec.InitialSizingCost = monthlyChange(rec.CurrentRequest, rec.RecommendedRequest, unitPrice)
+
+	rec.Reasoning += fmt.Sprintf("; %s in the analysis window, so the request isn't reduced", reason)
+	if rec.CurrentLimit > 0 {
+		rec.Reasoning += " and the limit is at least 20% above the current one"
+	}
+}
@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s-cost-optimizer/pkg/cloudprovider"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DefaultSpotDiscount is the typical discount of spot capacity over on-demand prices
+const DefaultSpotDiscount = 0.65
+
+// Fewest replicas for a workload to tolerate losing a pod to a spot interruption
+const minSpotReplicas = 2
+
+// SpotRecommendation identifies a fault-tolerant workload that could run on spot nodes
+type SpotRecommendation struct {
+	Namespace         string  `json:"namespace"`
+	Workload          string  `json:"workload"`
+	Kind              string  `json:"kind"`
+	Replicas          int32   `json:"replicas"`
+	ReplicasOnSpot    int     `json:"replicas_on_spot"`
+	CPURequest        float64 `json:"cpu_request"`    // Millicores per replica
+	MemoryRequest     float64 `json:"memory_request"` // Bytes per replica
+	MonthlyCost       float64 `json:"monthly_cost"`
+	EstimatedDiscount float64 `json:"estimated_discount"`
+	MonthlySavings    float64 `json:"monthly_savings"`
+	Reasoning         string  `json:"reasoning"`
+}
+
+// SetSpotDiscount sets the expected discount of spot over on-demand capacity
+func (ca *ConsolidationAnalyzer) SetSpotDiscount(discount float64) error {
+	if discount <= 0 || discount >= 1 {
+		return fmt.Errorf("spot discount must be in (0, 1), got %v", discount)
+	}
+	ca.spotDiscount = discount
+	return nil
+}
+
+// FindSpotCandidates returns the namespace's Deployments that could move to spot
+// capacity: at least two replicas, so an interruption doesn't take the workload down,
+// and no PersistentVolumeClaims, whose zonal volumes would pin pods to a zone with
+// spot capacity. Savings are priced from the replicas' requests at the given prices.
+// Deployments whose pods all run on spot nodes already are skipped.
+func (ca *ConsolidationAnalyzer) FindSpotCandidates(ctx context.Context, namespace string, prices *ResourcePrices) ([]SpotRecommendation, error) {
+	deployments, err := ca.k8sClient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing deployments: %w", err)
+	}
+
+	pods, err := ca.k8sClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	nodes, err := ca.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+	lifecycles := make(map[string]string, len(nodes.Items))
+	for i := range nodes.Items {
+		lifecycles[nodes.Items[i].Name] = cloudprovider.NodeLifecycle(&nodes.Items[i])
+	}
+
+	var candidates []SpotRecommendation
+	for _, deployment := range deployments.Items {
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		if replicas < minSpotReplicas || usesPersistentVolumes(&deployment.Spec.Template.Spec) {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			ca.log.Warnf("Invalid selector on deployment %s/%s: %v", namespace, deployment.Name, err)
+			continue
+		}
+		running, onSpot := spotPlacement(pods.Items, selector, lifecycles)
+		if running > 0 && onSpot == running {
+			continue
+		}
+
+		template := &corev1.Pod{Spec: deployment.Spec.Template.Spec}
+		cpu, memory := podRequests(template)
+		hourly := float64(cpu)*prices.PerMillicoreHour + float64(memory)*prices.PerByteHour
+		monthlyCost := hourly * 24 * 30 * float64(replicas)
+		if monthlyCost <= 0 {
+			continue
+		}
+
+		// Replicas already on spot don't save anything more
+		offSpot := 1.0
+		if running > 0 {
+			offSpot = float64(running-onSpot) / float64(running)
+		}
+
+		candidates = append(candidates, SpotRecommendation{
+			Namespace:         namespace,
+			Workload:          deployment.Name,
+			Kind:              "Deployment",
+			Replicas:          replicas,
+			ReplicasOnSpot:    onSpot,
+			CPURequest:        float64(cpu),
+			MemoryRequest:     float64(memory),
+			MonthlyCost:       monthlyCost,
+			EstimatedDiscount: ca.spotDiscount,
+			MonthlySavings:    monthlyCost * offSpot * ca.spotDiscount,
+			Reasoning: fmt.Sprintf("%d replicas without persistent volumes can tolerate spot interruptions; "+
+				"add a spot toleration/node affinity and a PodDisruptionBudget before moving", replicas),
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].MonthlySavings > candidates[j].MonthlySavings
+	})
+	return candidates, nil
+}
+
+// usesPersistentVolumes reports whether the pod spec mounts a PersistentVolumeClaim,
+// including the claims generic ephemeral volumes create
+func usesPersistentVolumes(spec *corev1.PodSpec) bool {
+	for _, volume := range spec.Volumes {
+		if volume.PersistentVolumeClaim != nil || volume.Ephemeral != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// spotPlacement counts the scheduled pods matching the selector and how many of them
+// run on spot nodes
+func spotPlacement(pods []corev1.Pod, selector labels.Selector, lifecycles map[string]string) (int, int) {
+	var running, onSpot int
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		running++
+		if lifecycles[pod.Spec.NodeName] == cloudprovider.LifecycleSpot {
+			onSpot++
+		}
+	}
+	return running, onSpot
+}
@@ -0,0 +1,150 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// pvcHeadroom is the space recommended above a PVC's peak usage, since a full
+	// volume fails writes rather than being throttled or evicted
+	pvcHeadroom = 1.5
+	// pvcGrowthThreshold is how much usage must grow over the window for shrinking to
+	// be high risk
+	pvcGrowthThreshold = 1.2
+	// pvcUnusedBytes is the peak usage below which a PVC looks unused, e.g. a mounted
+	// but empty filesystem
+	pvcUnusedBytes = 1 << 20
+	gib            = 1 << 30
+)
+
+// PVCRecommendation is a persistent volume claim whose requested capacity is well
+// above what it has used. Most storage classes can't shrink a volume in place, so
+// acting on it means recreating the claim at the recommended size and copying the
+// data across.
+type PVCRecommendation struct {
+	Namespace        string  `json:"namespace"`
+	PVCName          string  `json:"pvc_name"`
+	StorageClass     string  `json:"storage_class"`
+	RequestedBytes   float64 `json:"requested_bytes"`
+	PeakUsedBytes    float64 `json:"peak_used_bytes"`
+	RecommendedBytes float64 `json:"recommended_bytes"`
+	Utilization      float64 `json:"utilization"` // Peak used / requested
+	Growth           float64 `json:"growth"`      // Latest used / earliest used over the window
+	MonthlySavings   float64 `json:"monthly_savings"`
+	Action           string  `json:"action"` // "recreate" or "review"
+	RiskLevel        string  `json:"risk_level"`
+	Recommendation   string  `json:"recommendation"`
+	Reasoning        string  `json:"reasoning"`
+}
+
+// AnalyzePVCUsage compares each PVC's requested capacity against its peak used bytes
+// over the analysis window. Claims wasting more than the waste threshold are
+// recommended to be recreated at their peak plus headroom, rounded up to a whole GiB.
+// Claims that never held data are flagged for review instead, as they may be unused.
+func (ra *RightsizingAnalyzer) AnalyzePVCUsage(ctx context.Context, namespace string) ([]PVCRecommendation, error) {
+	rows, err := ra.db.QueryContext(ctx, `
+		WITH samples AS (
+			SELECT pvc_name, used_bytes, requested_bytes, storage_class, timestamp
+			FROM storage_metrics
+			WHERE namespace = $1 AND timestamp > $2
+		),
+		latest AS (
+			SELECT DISTINCT ON (pvc_name)
+				pvc_name, requested_bytes, COALESCE(storage_class, '') as storage_class, used_bytes
+			FROM samples
+			WHERE requested_bytes > 0
+			ORDER BY pvc_name, timestamp DESC
+		),
+		earliest AS (
+			SELECT DISTINCT ON (pvc_name) pvc_name, used_bytes
+			FROM samples
+			ORDER BY pvc_name, timestamp
+		)
+		SELECT
+			s.pvc_name,
+			l.storage_class,
+			l.requested_bytes,
+			MAX(s.used_bytes),
+			COALESCE(e.used_bytes, 0),
+			l.used_bytes
+		FROM samples s
+		JOIN latest l ON l.pvc_name = s.pvc_name
+		LEFT JOIN earliest e ON e.pvc_name = s.pvc_name
+		GROUP BY s.pvc_name, l.storage_class, l.requested_bytes, e.used_bytes, l.used_bytes
+		HAVING COUNT(*) >= $3
+		ORDER BY s.pvc_name
+	`, namespace, time.Now().Add(-ra.analysisWindow), ra.minDataPoints)
+	if err != nil {
+		return nil, fmt.Errorf("querying PVC usage: %w", err)
+	}
+	defer rows.Close()
+
+	prices := ra.resourcePrices(ctx)
+	recommendations := []PVCRecommendation{}
+
+	for rows.Next() {
+		rec := PVCRecommendation{Namespace: namespace}
+		var earliestUsed, latestUsed float64
+
+		if err := rows.Scan(&rec.PVCName, &rec.StorageClass, &rec.RequestedBytes,
+			&rec.PeakUsedBytes, &earliestUsed, &latestUsed); err != nil {
+			ra.log.Warnf("Failed to scan PVC usage: %v", err)
+			continue
+		}
+
+		rec.Utilization = rec.PeakUsedBytes / rec.RequestedBytes
+		if earliestUsed > 0 {
+			rec.Growth = latestUsed / earliestUsed
+		}
+
+		if rec.PeakUsedBytes < pvcUnusedBytes {
+			rec.Action = "review"
+			rec.RiskLevel = "LOW"
+			rec.RecommendedBytes = rec.RequestedBytes
+			rec.MonthlySavings = rec.RequestedBytes / gib * prices.PerGiBStorageMonth
+			rec.Recommendation = "Delete the PVC if it is unused"
+			rec.Reasoning = fmt.Sprintf("Peak usage of %s over the last %v is negligible against %s requested; "+
+				"the claim may be left over from a deleted workload",
+				formatBytes(rec.PeakUsedBytes), ra.analysisWindow, formatBytes(rec.RequestedBytes))
+			recommendations = append(recommendations, rec)
+			continue
+		}
+
+		rec.RecommendedBytes = math.Ceil(rec.PeakUsedBytes*pvcHeadroom/gib) * gib
+		if rec.RecommendedBytes > rec.RequestedBytes*(1-ra.wasteThreshold) {
+			continue
+		}
+		rec.MonthlySavings = (rec.RequestedBytes - rec.RecommendedBytes) / gib * prices.PerGiBStorageMonth
+
+		rec.Action = "recreate"
+		rec.Recommendation = fmt.Sprintf("Recreate at size %s", formatBytes(rec.RecommendedBytes))
+		reasoning := fmt.Sprintf("Peak usage of %s over the last %v is %.0f%% of the %s requested. "+
+			"PVCs can't be shrunk in place: create a new claim at %s, copy the data across and switch the workload over, "+
+			"which needs downtime or a migration",
+			formatBytes(rec.PeakUsedBytes), ra.analysisWindow, rec.Utilization*100,
+			formatBytes(rec.RequestedBytes), formatBytes(rec.RecommendedBytes))
+		if rec.Growth > pvcGrowthThreshold {
+			rec.RiskLevel = "HIGH"
+			reasoning += fmt.Sprintf(". Usage grew %.0f%% over the window, so the smaller volume may fill up",
+				(rec.Growth-1)*100)
+		} else {
+			rec.RiskLevel = "MEDIUM"
+		}
+		rec.Reasoning = reasoning
+
+		recommendations = append(recommendations, rec)
+	}
+
+	return recommendations, nil
+}
+
+// formatBytes formats a byte count in GiB, or MiB below 1 GiB
+func formatBytes(bytes float64) string {
+	if bytes < gib {
+		return fmt.Sprintf("%.0fMi", bytes/(1<<20))
+	}
+	return fmt.Sprintf("%.0fGi", bytes/gib)
+}
@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"context"
+
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ContainerUsage is one container's usage samples and current resources, for
+// analyzing usage gathered in memory instead of read from the database
+type ContainerUsage struct {
+	PodName       string
+	ContainerName string
+
+	// Workload controlling the pod, e.g. Deployment. Empty for a pod without one.
+	OwnerKind string
+	OwnerName string
+
+	// Current requests and limits, CPU in millicores and memory in bytes
+	Resources ResourceAllocation
+
+	// Usage samples, CPU in millicores and memory in bytes
+	CPU    []float64
+	Memory []float64
+
+	// Whether the container was OOMKilled while sampled, and the share of its CFS
+	// periods that were throttled
+	OOMKilled      bool
+	ThrottledRatio float64
+}
+
+// AnalyzeUsage recommends requests and limits for a namespace's containers from usage
+// samples held in memory, such as those a CLI reads straight from Prometheus. The
+// calculations match AnalyzeNamespaceWithOptions, without seasonality or GPUs.
+// Containers with fewer samples than the analyzer's minimum are skipped.
+func (ra *RightsizingAnalyzer) AnalyzeUsage(ctx context.Context, namespace string, usage []ContainerUsage, opts *AnalysisOptions) ([]Recommendation, error) {
+	if opts == nil {
+		opts = ra.DefaultOptions()
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "analyze usage",
+		attribute.String("namespace", namespace), attribute.Int("containers", len(usage)))
+	defer span.End()
+
+	prices := ra.resourcePrices(ctx)
+	owners := make(map[string]podOwner)
+	var stats []containerStats
+	var recommendations []Recommendation
+
+	for _, container := range usage {
+		dataPoints := len(container.CPU)
+		if len(container.Memory) < dataPoints {
+			dataPoints = len(container.Memory)
+		}
+		if dataPoints < ra.minDataPoints {
+			continue
+		}
+
+		if container.OwnerKind != "" {
+			owners[container.PodName] = podOwner{Kind: container.OwnerKind, Name: container.OwnerName}
+		}
+
+		stat := containerStats{
+			PodName:       container.PodName,
+			ContainerName: container.ContainerName,
+			DataPoints:    dataPoints,
+			CPU:           sampleStats(container.CPU),
+			Memory:        sampleStats(container.Memory),
+		}
+		stats = append(stats, stat)
+
+		signal := containerSignals{
+			OOMKilled: container.OOMKilled,
+			Throttled: container.ThrottledRatio >= throttledRatioThreshold,
+		}
+		recommendations = append(recommendations, ra.recommendContainer(namespace, stat,
+			&container.Resources, &container.Resources, signal, containerPeaks{}, false, opts, prices)...)
+	}
+
+	return filterRecommendations(consolidateReplicas(recommendations, owners, stats, prices), opts), nil
+}
+
+// sampleStats computes the statistics loadRawStats reads from the store
+func sampleStats(samples []float64) usageStats {
+	return usageStats(store.Summarize(samples))
+}
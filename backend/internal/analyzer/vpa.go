@@ -0,0 +1,198 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// VPA update modes, from recommend-only to evicting pods to apply new requests
+const (
+	VPAUpdateModeOff     = "Off"
+	VPAUpdateModeInitial = "Initial"
+	VPAUpdateModeAuto    = "Auto"
+)
+
+// Annotations recording the analyzer's numbers on generated VPAs
+const (
+	vpaSourceAnnotation  = "k8s-cost-optimizer/generated-from"
+	vpaSavingsAnnotation = "k8s-cost-optimizer/monthly-savings"
+)
+
+// VerticalPodAutoscaler is an autoscaling.k8s.io/v1 VerticalPodAutoscaler manifest.
+// Only the fields the optimizer sets are modelled, so the VPA CRD client isn't needed.
+type VerticalPodAutoscaler struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Metadata   VPAMetadata `json:"metadata"`
+	Spec       VPASpec     `json:"spec"`
+}
+
+type VPAMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type VPASpec struct {
+	TargetRef      VPATargetRef      `json:"targetRef"`
+	UpdatePolicy   VPAUpdatePolicy   `json:"updatePolicy"`
+	ResourcePolicy VPAResourcePolicy `json:"resourcePolicy"`
+}
+
+type VPATargetRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+type VPAUpdatePolicy struct {
+	UpdateMode string `json:"updateMode"`
+}
+
+type VPAResourcePolicy struct {
+	ContainerPolicies []VPAContainerPolicy `json:"containerPolicies"`
+}
+
+// VPAContainerPolicy bounds the requests the VPA recommender may set for a container
+type VPAContainerPolicy struct {
+	ContainerName       string            `json:"containerName"`
+	ControlledResources []string          `json:"controlledResources"`
+	MinAllowed          map[string]string `json:"minAllowed,omitempty"`
+	MaxAllowed          map[string]string `json:"maxAllowed,omitempty"`
+}
+
+// vpaTargetAPIVersions are the workload kinds a VPA can be generated for from pod names
+var vpaTargetAPIVersions = map[string]string{
+	"Deployment": "apps/v1",
+	"CronJob":    "batch/v1",
+}
+
+// GenerateVPA builds a VerticalPodAutoscaler per workload from CPU and memory
+// recommendations. The recommended request becomes each container's minAllowed and the
+// recommended limit its maxAllowed, so the VPA recommender works within the analyzer's
+// bounds. Workloads are identified from pod names; bare pods and GPU recommendations are
+// skipped since VPA can't manage them. Replicas with different recommendations get the
+// largest values.
+func GenerateVPA(recommendations []Recommendation, updateMode string) ([]VerticalPodAutoscaler, error) {
+	switch updateMode {
+	case VPAUpdateModeOff, VPAUpdateModeInitial, VPAUpdateModeAuto:
+	default:
+		return nil, fmt.Errorf("update mode must be Off, Initial or Auto, got %q", updateMode)
+	}
+
+	type workloadKey struct{ namespace, kind, name string }
+	type bounds struct{ request, limit float64 }
+
+	containers := make(map[workloadKey]map[string]map[string]*bounds)
+	savings := make(map[workloadKey]float64)
+	var workloads []workloadKey
+
+	for _, rec := range recommendations {
+		resourceName, ok := vpaResourceNames[rec.ResourceType]
+		if !ok {
+			continue
+		}
+		name, kind := classifyPod(rec.PodName)
+		if _, ok := vpaTargetAPIVersions[kind]; !ok {
+			continue
+		}
+
+		key := workloadKey{rec.Namespace, kind, name}
+		if _, ok := containers[key]; !ok {
+			containers[key] = make(map[string]map[string]*bounds)
+			workloads = append(workloads, key)
+		}
+		if _, ok := containers[key][rec.ContainerName]; !ok {
+			containers[key][rec.ContainerName] = make(map[string]*bounds)
+		}
+		b, ok := containers[key][rec.ContainerName][resourceName]
+		if !ok {
+			b = &bounds{}
+			containers[key][rec.ContainerName][resourceName] = b
+		}
+		b.request = math.Max(b.request, rec.RecommendedRequest)
+		b.limit = math.Max(b.limit, rec.RecommendedLimit)
+		savings[key] += rec.PotentialSavings
+	}
+
+	sort.Slice(workloads, func(i, j int) bool {
+		a, b := workloads[i], workloads[j]
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.name < b.name
+	})
+
+	vpas := make([]VerticalPodAutoscaler, 0, len(workloads))
+	for _, key := range workloads {
+		var names []string
+		for containerName := range containers[key] {
+			names = append(names, containerName)
+		}
+		sort.Strings(names)
+
+		var policies []VPAContainerPolicy
+		for _, containerName := range names {
+			policy := VPAContainerPolicy{
+				ContainerName: containerName,
+				MinAllowed:    make(map[string]string),
+				MaxAllowed:    make(map[string]string),
+			}
+			for _, resourceName := range []string{"cpu", "memory"} {
+				b, ok := containers[key][containerName][resourceName]
+				if !ok {
+					continue
+				}
+				policy.ControlledResources = append(policy.ControlledResources, resourceName)
+				policy.MinAllowed[resourceName] = vpaQuantity(resourceName, b.request)
+				policy.MaxAllowed[resourceName] = vpaQuantity(resourceName, b.limit)
+			}
+			policies = append(policies, policy)
+		}
+
+		vpas = append(vpas, VerticalPodAutoscaler{
+			APIVersion: "autoscaling.k8s.io/v1",
+			Kind:       "VerticalPodAutoscaler",
+			Metadata: VPAMetadata{
+				Name:      key.name + "-vpa",
+				Namespace: key.namespace,
+				Annotations: map[string]string{
+					vpaSourceAnnotation:  "rightsizing",
+					vpaSavingsAnnotation: fmt.Sprintf("%.2f", savings[key]),
+				},
+			},
+			Spec: VPASpec{
+				TargetRef: VPATargetRef{
+					APIVersion: vpaTargetAPIVersions[key.kind],
+					Kind:       key.kind,
+					Name:       key.name,
+				},
+				UpdatePolicy:   VPAUpdatePolicy{UpdateMode: updateMode},
+				ResourcePolicy: VPAResourcePolicy{ContainerPolicies: policies},
+			},
+		})
+	}
+
+	return vpas, nil
+}
+
+// vpaResourceNames maps recommendation resource types to the resources VPA controls
+var vpaResourceNames = map[string]string{
+	"CPU":    "cpu",
+	"Memory": "memory",
+}
+
+// vpaQuantity formats millicores or bytes as a quantity, rounding memory up to a whole MiB
+func vpaQuantity(resourceName string, value float64) string {
+	if resourceName == "cpu" {
+		return resource.NewMilliQuantity(int64(math.Ceil(value)), resource.DecimalSI).String()
+	}
+	mib := int64(math.Ceil(value / (1024 * 1024)))
+	return resource.NewQuantity(mib*1024*1024, resource.BinarySI).String()
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+)
+
+// forecastDays is how far ahead GetCostTrends projects the regression line
+const forecastDays = 7
+
+// TrendPoint is a single bucket of a namespace's cost time series
+type TrendPoint struct {
+	Date  string  `json:"date"`
+	Total float64 `json:"total"`
+	Trend float64 `json:"trend"`
+}
+
+// GetCostTrends returns the namespace's daily or weekly cost series with a linear
+// regression trend line, the change versus the previous period and a short forecast
+func (h *Handler) GetCostTrends(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "30d"
+	}
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "day"
+	}
+
+	var window time.Duration
+	switch period {
+	case "7d":
+		window = 7 * 24 * time.Hour
+	case "30d":
+		window = 30 * 24 * time.Hour
+	case "90d":
+		window = 90 * 24 * time.Hour
+	default:
+		writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, "Invalid period")
+		return
+	}
+
+	var bucketDays int
+	switch granularity {
+	case "day":
+		bucketDays = 1
+	case "week":
+		bucketDays = 7
+	default:
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "Invalid granularity")
+		return
+	}
+
+	// Check cache first
+	cacheKey := fmt.Sprintf("trends:%s:%s:%s:%s", namespace, period, granularity, time.Now().Format("2006-01-02-15"))
+	cached, err := h.cacheManager.Get(r.Context(), cacheKey)
+	if err == nil && len(cached) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Cache", "HIT")
+		w.Write(cached)
+		return
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-window)
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT
+			DATE_TRUNC($4, timestamp) as bucket,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total
+		FROM namespace_costs
+		WHERE
+			namespace = $1
+			AND timestamp BETWEEN $2 AND $3
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`, namespace, startTime, endTime, granularity)
+
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	var points []TrendPoint
+	var values []float64
+	var currentTotal float64
+
+	for rows.Next() {
+		var bucket time.Time
+		var total float64
+		if err := rows.Scan(&bucket, &total); err != nil {
+			continue
+		}
+
+		points = append(points, TrendPoint{Date: bucket.Format("2006-01-02"), Total: total})
+		values = append(values, total)
+		currentTotal += total
+	}
+
+	// Total for the preceding period of the same length, for the period-over-period change
+	var previousTotal float64
+	err = h.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(compute_cost + storage_cost + network_cost + other_cost), 0)
+		FROM namespace_costs
+		WHERE
+			namespace = $1
+			AND timestamp >= $2 AND timestamp < $3
+	`, namespace, startTime.Add(-window), startTime).Scan(&previousTotal)
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	slope, intercept := linearRegression(values)
+	for i := range points {
+		points[i].Trend = intercept + slope*float64(i)
+	}
+
+	// Project the regression line over the buckets covering the next forecastDays
+	var forecast []TrendPoint
+	var forecastTotal float64
+	if len(points) > 0 {
+		last, _ := time.Parse("2006-01-02", points[len(points)-1].Date)
+		for i := 1; i <= (forecastDays+bucketDays-1)/bucketDays; i++ {
+			value := math.Max(intercept+slope*float64(len(points)-1+i), 0)
+			forecast = append(forecast, TrendPoint{
+				Date:  last.AddDate(0, 0, i*bucketDays).Format("2006-01-02"),
+				Trend: value,
+			})
+			forecastTotal += value
+		}
+	}
+
+	var percentChange interface{}
+	if previousTotal > 0 {
+		percentChange = (currentTotal - previousTotal) / previousTotal * 100
+	}
+
+	response := map[string]interface{}{
+		"namespace":   namespace,
+		"period":      period,
+		"granularity": granularity,
+		"series":      points,
+		"trend": map[string]float64{
+			"slope":     slope,
+			"intercept": intercept,
+		},
+		"current_total":  currentTotal,
+		"previous_total": previousTotal,
+		"percent_change": percentChange,
+		"forecast":       forecast,
+		"forecast_total": forecastTotal,
+	}
+
+	// Cache the response
+	jsonResponse, _ := json.Marshal(response)
+	h.cacheManager.Set(r.Context(), cacheKey, jsonResponse)
+
+	// Record metrics
+	duration := time.Since(start).Seconds()
+	apiRequestDuration.WithLabelValues("GET", "/analytics/trends", "200").Observe(duration)
+	apiRequestTotal.WithLabelValues("GET", "/analytics/trends", "200").Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", "MISS")
+	w.Write(jsonResponse)
+}
+
+// linearRegression fits y = intercept + slope*x by least squares, where x is the
+// index of each value
+func linearRegression(values []float64) (slope, intercept float64) {
+	n := float64(len(values))
+	if n == 0 {
+		return 0, 0
+	}
+	if n == 1 {
+		return 0, values[0]
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	slope = (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept
+}
+
+// Anomaly is a day whose cost spiked well above the trailing baseline
+type Anomaly struct {
+	Namespace    string  `json:"namespace"`
+	Date         string  `json:"date"`
+	ObservedCost float64 `json:"observed_cost"`
+	ExpectedCost float64 `json:"expected_cost"`
+	ZScore       float64 `json:"z_score"`
+}
+
+// Days of daily costs scanned for anomalies
+const anomalyLookbackDays = 30
+
+// GetAnomalies flags days in the last 30 days where a namespace's total cost exceeds
+// the mean of the 7 calendar days before it by more than `sensitivity` standard
+// deviations, as analyzer.CostZScore scores them. Without a namespace filter every
+// namespace is scanned.
+func (h *Handler) GetAnomalies(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	if namespace != "" {
+		if err := validateNamespace(namespace); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+			return
+		}
+	}
+
+	sensitivity := 3.0
+	if s := r.URL.Query().Get("sensitivity"); s != "" {
+		parsed, err := strconv.ParseFloat(s, 64)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "Invalid sensitivity")
+			return
+		}
+		sensitivity = parsed
+	}
+
+	// Include the baseline days preceding the first day we evaluate
+	startTime := time.Now().AddDate(0, 0, -(anomalyLookbackDays + analyzer.AnomalyBaselineDays))
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT
+			namespace,
+			DATE_TRUNC('day', timestamp) as day,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total
+		FROM namespace_costs
+		WHERE
+			timestamp >= $1
+			AND ($2 = '' OR namespace = $2)
+		GROUP BY namespace, day
+		ORDER BY namespace, day ASC
+	`, startTime, namespace)
+
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	series := make(map[string][]analyzer.DailyCost)
+	var namespaces []string
+
+	for rows.Next() {
+		var ns string
+		var point analyzer.DailyCost
+		if err := rows.Scan(&ns, &point.Day, &point.Total); err != nil {
+			continue
+		}
+		if _, ok := series[ns]; !ok {
+			namespaces = append(namespaces, ns)
+		}
+		series[ns] = append(series[ns], point)
+	}
+
+	evaluateFrom := time.Now().AddDate(0, 0, -anomalyLookbackDays)
+	anomalies := []Anomaly{}
+
+	for _, ns := range namespaces {
+		points := series[ns]
+		for i, point := range points {
+			if point.Day.Before(evaluateFrom) {
+				continue
+			}
+
+			expected, z, ok := analyzer.CostZScore(points, i)
+			if ok && z > sensitivity {
+				anomalies = append(anomalies, Anomaly{
+					Namespace:    ns,
+					Date:         point.Day.Format("2006-01-02"),
+					ObservedCost: point.Total,
+					ExpectedCost: expected,
+					ZScore:       z,
+				})
+			}
+		}
+	}
+
+	response := map[string]interface{}{
+		"namespace":   namespace,
+		"sensitivity": sensitivity,
+		"anomalies":   anomalies,
+		"count":       len(anomalies),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// AuthConfig holds API authentication configuration
+type AuthConfig struct {
+	Enabled bool
+
+	// HMAC key used to verify bearer JWTs. JWT auth is disabled when empty.
+	JWTSigningKey string
+
+	// Static API keys accepted in APIKeyHeader, as "key" or "key:role"
+	APIKeys      []string
+	APIKeyHeader string
+
+	// Paths that don't require authentication, e.g. health and metrics endpoints
+	ExemptPaths []string
+
+	// Roles allowed to call each route, keyed by route path template. Routes not
+	// listed are open to any authenticated caller.
+	RouteRoles map[string][]string
+}
+
+// DefaultAuthConfig returns the default authentication configuration
+func DefaultAuthConfig() *AuthConfig {
+	return &AuthConfig{
+		Enabled:      false,
+		APIKeyHeader: "X-API-Key",
+		ExemptPaths:  []string{"/health", "/health/detailed", "/ready", "/metrics", "/openapi.json", "/docs"},
+		RouteRoles:   DefaultRouteRoles(),
+	}
+}
+
+// DefaultRouteRoles restricts the endpoints that change cluster resources or rewrite
+// cost history, the configuration endpoint and report schedules, which hold email
+// recipients, to admins
+func DefaultRouteRoles() map[string][]string {
+	return map[string][]string{
+		"/api/recommendations/apply":      {"admin"},
+		"/api/recommendations/bulk-apply": {"admin"},
+		"/api/quota/{namespace}/apply":    {"admin"},
+		"/api/costs/import":               {"admin"},
+		"/api/config":                     {"admin"},
+		"/api/reports/schedules":          {"admin"},
+		"/api/reports/schedules/{id}":     {"admin"},
+	}
+}
+
+// Principal identifies the authenticated caller
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role,omitempty"`
+
+	// Namespaces the caller may stream live costs for, from the JWT "namespaces"
+	// claim. Nil, when the claim is absent, means every namespace.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errInsufficientRole   = errors.New("insufficient role")
+)
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the caller authenticated by AuthMiddleware, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// AuthMiddleware requires a valid bearer JWT or API key on every request except the
+// exempt paths and CORS preflights, then checks the caller's role against RouteRoles.
+// Authentication failures get a 401 and authorization failures a 403, with a JSON error body.
+func AuthMiddleware(config *AuthConfig) func(http.Handler) http.Handler {
+	if config == nil {
+		config = DefaultAuthConfig()
+	}
+
+	exempt := make(map[string]bool)
+	for _, path := range config.ExemptPaths {
+		exempt[path] = true
+	}
+
+	apiKeys := parseAPIKeys(config.APIKeys)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !config.Enabled || exempt[r.URL.Path] || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Browsers can't set headers on WebSocket upgrades, so the WebSocket handler
+			// authenticates those itself and reports failures with a close code
+			if r.URL.Path == WebSocketPath && gorilla.IsWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := authenticate(config, apiKeys, r)
+			if err != nil {
+				writeUnauthorized(w, err.Error())
+				return
+			}
+
+			if !authorized(config.RouteRoles, principal, r) {
+				writeError(w, http.StatusForbidden, errCodeForbidden, errInsufficientRole.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		})
+	}
+}
+
+// apiKeySubject identifies an API key caller by a fingerprint of the key, so callers
+// can be told apart without the key itself ending up in logs or Redis
+func apiKeySubject(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "api-key:" + hex.EncodeToString(sum[:6])
+}
+
+// parseAPIKeys maps each configured API key to its role
+func parseAPIKeys(entries []string) map[string]string {
+	apiKeys := make(map[string]string)
+	for _, entry := range entries {
+		key, role, _ := strings.Cut(entry, ":")
+		apiKeys[key] = role
+	}
+	return apiKeys
+}
+
+func authenticate(config *AuthConfig, apiKeys map[string]string, r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(config.APIKeyHeader); key != "" {
+		if principal, ok := verifyAPIKey(apiKeys, key); ok {
+			return principal, nil
+		}
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, errMissingCredentials
+	}
+
+	return verifyJWT(config, strings.TrimPrefix(header, "Bearer "))
+}
+
+// authenticateToken accepts either an API key or a JWT, for clients that can only pass
+// a single token
+func authenticateToken(config *AuthConfig, apiKeys map[string]string, token string) (*Principal, error) {
+	if principal, ok := verifyAPIKey(apiKeys, token); ok {
+		return principal, nil
+	}
+	return verifyJWT(config, token)
+}
+
+func verifyAPIKey(apiKeys map[string]string, key string) (*Principal, bool) {
+	for valid, role := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+			return &Principal{Subject: apiKeySubject(valid), Role: role}, true
+		}
+	}
+	return nil, false
+}
+
+func verifyJWT(config *AuthConfig, token string) (*Principal, error) {
+	if config.JWTSigningKey == "" {
+		return nil, fmt.Errorf("bearer tokens are not accepted")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return []byte(config.JWTSigningKey), nil
+		},
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	principal := &Principal{}
+	principal.Subject, _ = claims.GetSubject()
+	if role, ok := claims["role"].(string); ok {
+		principal.Role = role
+	}
+	if namespaces, ok := claims["namespaces"].([]interface{}); ok {
+		principal.Namespaces = []string{}
+		for _, namespace := range namespaces {
+			if s, ok := namespace.(string); ok {
+				principal.Namespaces = append(principal.Namespaces, s)
+			}
+		}
+	}
+
+	return principal, nil
+}
+
+// authorized reports whether the principal's role may call the matched route
+func authorized(routeRoles map[string][]string, principal *Principal, r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return true
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return true
+	}
+
+	roles, restricted := routeRoles[template]
+	if !restricted {
+		return true
+	}
+
+	for _, role := range roles {
+		if principal.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="k8s-cost-optimizer"`)
+	writeError(w, http.StatusUnauthorized, errCodeUnauthorized, message)
+}
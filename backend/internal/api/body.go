@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes is the largest request body handlers accept by default
+const DefaultMaxBodyBytes = 1 << 20
+
+// SetMaxBodyBytes sets the largest request body handlers accept
+func (h *Handler) SetMaxBodyBytes(limit int64) error {
+	if limit <= 0 {
+		return fmt.Errorf("max body size must be positive, got %d", limit)
+	}
+	h.maxBodyBytes = limit
+	return nil
+}
+
+// decodeJSONBody strictly decodes the request body into v. Bodies over the size limit,
+// unknown fields and trailing data after the JSON value are rejected with an error
+// describing the problem, suitable for a 400 response.
+func (h *Handler) decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	limit := h.maxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			return fmt.Errorf("request body must not exceed %d bytes", limit)
+		case errors.Is(err, io.EOF):
+			return fmt.Errorf("request body must not be empty")
+		case errors.As(err, &syntaxErr):
+			return fmt.Errorf("malformed JSON at offset %d", syntaxErr.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return fmt.Errorf("malformed JSON")
+		case errors.As(err, &typeErr):
+			return fmt.Errorf("invalid value for field %q", typeErr.Field)
+		default:
+			// Unknown fields are reported as `json: unknown field "name"`
+			return fmt.Errorf("invalid request body: %v", err)
+		}
+	}
+
+	if decoder.More() {
+		return fmt.Errorf("request body must contain a single JSON object")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// CreateBudget sets a namespace's monthly budget, replacing any existing one
+func (h *Handler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Namespace    string  `json:"namespace"`
+		MonthlyLimit float64 `json:"monthly_limit"`
+	}
+
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	if request.Namespace == "" || request.MonthlyLimit <= 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid request")
+		return
+	}
+	if err := validateNamespace(request.Namespace); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+		return
+	}
+
+	budget, err := h.analyzer.SetBudget(r.Context(), request.Namespace, request.MonthlyLimit)
+	if err != nil {
+		h.log.Errorf("Failed to set budget: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Failed to set budget")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(budget)
+}
+
+// GetBudgets lists every budget with its current status
+func (h *Handler) GetBudgets(w http.ResponseWriter, r *http.Request) {
+	budgets, err := h.analyzer.ListBudgets(r.Context())
+	if err != nil {
+		h.log.Errorf("Failed to list budgets: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	statuses := make([]interface{}, 0, len(budgets))
+	for _, budget := range budgets {
+		status, err := h.analyzer.CheckBudget(r.Context(), budget)
+		if err != nil {
+			h.log.Warnf("Failed to check budget for %s: %v", budget.Namespace, err)
+			statuses = append(statuses, budget)
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+
+	response := map[string]interface{}{
+		"budgets": statuses,
+		"count":   len(statuses),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteBudget removes a namespace's budget
+func (h *Handler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.analyzer.DeleteBudget(r.Context(), namespace)
+	if err != nil {
+		h.log.Errorf("Failed to delete budget: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, errCodeNotFound, "Budget not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxBulkNamespaces limits how many namespaces one GetBulkNamespaceCosts request loads
+const maxBulkNamespaces = 100
+
+// NamespaceCostSummary is a namespace's daily costs and summary in the
+// GetBulkNamespaceCosts response
+type NamespaceCostSummary struct {
+	Costs                []DailyCost        `json:"costs"`
+	Summary              map[string]float64 `json:"summary"`
+	NoData               bool               `json:"no_data"`
+	ReconciliationFactor float64            `json:"reconciliation_factor"`
+}
+
+// GetBulkNamespaceCosts returns the costs of several namespaces over a named period in
+// one request and one query, for dashboards that would otherwise call GetNamespaceCosts
+// per namespace. Each namespace gets the same daily costs and summary, but not the
+// resource breakdown or budget status. Responses are cached per hour like
+// GetNamespaceCosts, keyed by the set of namespaces.
+func (h *Handler) GetBulkNamespaceCosts(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var request struct {
+		Namespaces []string `json:"namespaces"`
+		Period     string   `json:"period"`
+	}
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	if len(request.Namespaces) == 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "namespaces must not be empty")
+		return
+	}
+	seen := make(map[string]bool, len(request.Namespaces))
+	var namespaces []string
+	for _, namespace := range request.Namespaces {
+		if err := validateNamespace(namespace); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+			return
+		}
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	if len(namespaces) > maxBulkNamespaces {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+			fmt.Sprintf("namespaces must not list more than %d namespaces", maxBulkNamespaces))
+		return
+	}
+
+	period, startTime, endTime, err := namedPeriodRange(request.Period)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, err.Error())
+		return
+	}
+
+	// The same namespaces in any order share a cache entry
+	sort.Strings(namespaces)
+	digest := sha256.Sum256([]byte(strings.Join(namespaces, ",")))
+	cluster := r.URL.Query().Get("cluster")
+	cacheKey := fmt.Sprintf("costs:bulk:%s:%s:%s:%s", cluster, period,
+		endTime.Format("2006-01-02-15"), hex.EncodeToString(digest[:]))
+
+	loaded := false
+	jsonResponse, err := h.cacheManager.GetOrLoad(r.Context(), cacheKey, func() ([]byte, error) {
+		loaded = true
+		// Detach from this request so other waiters aren't failed if this client disconnects
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), dbQueryTimeout)
+		defer cancel()
+		return h.loadBulkNamespaceCosts(ctx, cluster, namespaces, period, startTime, endTime)
+	})
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	cacheStatus := "HIT"
+	if loaded {
+		cacheStatus = "MISS"
+	}
+
+	duration := time.Since(start).Seconds()
+	apiRequestDuration.WithLabelValues("POST", "/costs/namespaces", "200").Observe(duration)
+	apiRequestTotal.WithLabelValues("POST", "/costs/namespaces", "200").Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", cacheStatus)
+	w.Write(jsonResponse)
+}
+
+// loadBulkNamespaceCosts builds the GetBulkNamespaceCosts response, summing every
+// namespace's costs per day in a single query. Namespaces without costs are included
+// with no_data set. An empty cluster sums costs across all clusters.
+func (h *Handler) loadBulkNamespaceCosts(ctx context.Context, cluster string, namespaces []string, period string, startTime, endTime time.Time) ([]byte, error) {
+	ctx, span := tracing.StartQuery(ctx, "load bulk namespace costs", attribute.Int("namespaces", len(namespaces)))
+	defer span.End()
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT
+			namespace,
+			DATE_TRUNC('day', timestamp) as day,
+			SUM(compute_cost) as compute,
+			SUM(storage_cost) as storage,
+			SUM(network_cost) as network,
+			SUM(other_cost) as other,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total,
+			SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor) as estimated
+		FROM namespace_costs
+		WHERE
+			namespace = ANY($1)
+			AND timestamp BETWEEN $2 AND $3
+			AND ($4 = '' OR cluster = $4)
+		GROUP BY namespace, day
+		ORDER BY namespace, day DESC
+	`, pq.Array(namespaces), startTime, endTime, cluster)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	costs := make(map[string][]DailyCost, len(namespaces))
+	totals := make(map[string]float64, len(namespaces))
+	estimates := make(map[string]float64, len(namespaces))
+	for rows.Next() {
+		var namespace string
+		var cost DailyCost
+		var day time.Time
+		var estimated float64
+		if err := rows.Scan(&namespace, &day, &cost.Compute, &cost.Storage,
+			&cost.Network, &cost.Other, &cost.Total, &estimated); err != nil {
+			return nil, fmt.Errorf("scanning namespace costs: %w", err)
+		}
+
+		cost.Date = day.Format("2006-01-02")
+		costs[namespace] = append(costs[namespace], cost)
+		totals[namespace] += cost.Total
+		estimates[namespace] += estimated
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]NamespaceCostSummary, len(namespaces))
+	for _, namespace := range namespaces {
+		daily := costs[namespace]
+		if daily == nil {
+			daily = []DailyCost{}
+		}
+		averageDaily, projectedMonthly := costSummary(daily, totals[namespace], endTime)
+		summaries[namespace] = NamespaceCostSummary{
+			Costs: daily,
+			Summary: map[string]float64{
+				"total":             totals[namespace],
+				"average_daily":     averageDaily,
+				"projected_monthly": projectedMonthly,
+			},
+			NoData:               len(daily) == 0,
+			ReconciliationFactor: reconciliationFactor(totals[namespace], estimates[namespace]),
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"cluster":    cluster,
+		"period":     period,
+		"start":      startTime.UTC(),
+		"end":        endTime.UTC(),
+		"namespaces": summaries,
+	})
+}
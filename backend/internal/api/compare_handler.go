@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// compareSide is one operand of a comparison with its costs and utilization
+type compareSide struct {
+	Label        string             `json:"label"`
+	Namespace    string             `json:"namespace"`
+	Start        time.Time          `json:"start"`
+	End          time.Time          `json:"end"`
+	Costs        []DailyCost        `json:"costs"`
+	Totals       map[string]float64 `json:"totals"`
+	AverageDaily float64            `json:"average_daily"`
+	Utilization  map[string]float64 `json:"utilization"`
+	NoData       bool               `json:"no_data"`
+}
+
+// CompareCosts compares costs and utilization side by side, either of two namespaces
+// over the same range (?a=staging&b=production&period=30d) or of one namespace over
+// two periods (?namespace=team-a&a=this-month&b=last-month). Deltas are a minus b, and
+// percentage changes are relative to b, so b is the baseline.
+func (h *Handler) CompareCosts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	a, b := query.Get("a"), query.Get("b")
+	if a == "" || b == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "a and b are required")
+		return
+	}
+	cluster := query.Get("cluster")
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	var sideA, sideB *compareSide
+	var mode string
+	var err error
+
+	if namespace := query.Get("namespace"); namespace != "" {
+		mode = "periods"
+		if err := validateNamespace(namespace); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+			return
+		}
+
+		now := time.Now()
+		startA, endA, parseErr := parsePeriodSpec(a, now)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, "a: "+parseErr.Error())
+			return
+		}
+		startB, endB, parseErr := parsePeriodSpec(b, now)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, "b: "+parseErr.Error())
+			return
+		}
+
+		if sideA, err = h.loadCompareSide(ctx, cluster, namespace, a, startA, endA); err == nil {
+			sideB, err = h.loadCompareSide(ctx, cluster, namespace, b, startB, endB)
+		}
+	} else {
+		mode = "namespaces"
+		for _, namespace := range []string{a, b} {
+			if err := validateNamespace(namespace); err != nil {
+				writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+				return
+			}
+		}
+
+		_, start, end, parseErr := parseTimeRange(r)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, parseErr.Error())
+			return
+		}
+
+		if sideA, err = h.loadCompareSide(ctx, cluster, a, a, start, end); err == nil {
+			sideB, err = h.loadCompareSide(ctx, cluster, b, b, start, end)
+		}
+	}
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	delta := make(map[string]float64)
+	change := make(map[string]*float64)
+	for _, values := range []struct{ a, b map[string]float64 }{
+		{sideA.Totals, sideB.Totals},
+		{sideA.Utilization, sideB.Utilization},
+	} {
+		for key := range values.a {
+			delta[key] = values.a[key] - values.b[key]
+			change[key] = percentChange(values.a[key], values.b[key])
+		}
+	}
+	delta["average_daily"] = sideA.AverageDaily - sideB.AverageDaily
+	change["average_daily"] = percentChange(sideA.AverageDaily, sideB.AverageDaily)
+
+	response := map[string]interface{}{
+		"mode":           mode,
+		"cluster":        cluster,
+		"a":              sideA,
+		"b":              sideB,
+		"delta":          delta,
+		"percent_change": change,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// loadCompareSide loads a namespace's daily costs, cost totals by category and average
+// CPU and memory usage between start and end
+func (h *Handler) loadCompareSide(ctx context.Context, cluster, namespace, label string, start, end time.Time) (*compareSide, error) {
+	costs, total, _, err := h.dailyCosts(ctx, cluster, namespace, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	side := &compareSide{
+		Label:     label,
+		Namespace: namespace,
+		Start:     start.UTC(),
+		End:       end.UTC(),
+		Costs:     costs,
+		Totals:    map[string]float64{"total": total},
+		NoData:    len(costs) == 0,
+	}
+	for _, cost := range costs {
+		side.Totals["compute"] += cost.Compute
+		side.Totals["storage"] += cost.Storage
+		side.Totals["network"] += cost.Network
+		side.Totals["other"] += cost.Other
+	}
+	if len(costs) > 0 {
+		side.AverageDaily = total / float64(len(costs))
+	}
+
+	// Average usage across collections, each the sum over the namespace's containers.
+	// Long periods average hourly sums of each container's mean from the rollups.
+	usage := `
+			SELECT SUM(cpu_millicores) as cpu, SUM(memory_bytes) as memory
+			FROM pod_metrics
+			WHERE namespace = $1 AND timestamp BETWEEN $2 AND $3 AND ($4 = '' OR cluster = $4)
+			GROUP BY cluster, timestamp`
+	if usageFromRollups(start, end) {
+		usage = `
+			SELECT SUM(cpu_sum / sample_count) as cpu, SUM(memory_sum / sample_count) as memory
+			FROM pod_metrics_rollup
+			WHERE namespace = $1 AND bucket BETWEEN $2 AND $3 AND ($4 = '' OR cluster = $4)
+			GROUP BY cluster, bucket`
+	}
+
+	var cpu, memory float64
+	err = h.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT COALESCE(AVG(cpu), 0), COALESCE(AVG(memory), 0)
+		FROM (%s
+		) usage
+	`, usage), namespace, start, end, cluster).Scan(&cpu, &memory)
+	if err != nil {
+		return nil, err
+	}
+	side.Utilization = map[string]float64{
+		"cpu_millicores": cpu,
+		"memory_bytes":   memory,
+	}
+
+	return side, nil
+}
+
+// parsePeriodSpec parses a comparison period: a named period ending now (24h, 7d,
+// 30d), this-month, last-month, a calendar month (2024-05), or an RFC 3339
+// start/end interval
+func parsePeriodSpec(spec string, now time.Time) (time.Time, time.Time, error) {
+	if window, ok := namedPeriods[spec]; ok {
+		return now.Add(-window), now, nil
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	switch spec {
+	case "this-month":
+		return monthStart, now, nil
+	case "last-month":
+		return monthStart.AddDate(0, -1, 0), monthStart, nil
+	}
+
+	if month, err := time.Parse("2006-01", spec); err == nil {
+		return month, month.AddDate(0, 1, 0), nil
+	}
+
+	if startParam, endParam, ok := strings.Cut(spec, "/"); ok {
+		start, err := time.Parse(time.RFC3339, startParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start %q, must be an RFC 3339 time", startParam)
+		}
+		end, err := time.Parse(time.RFC3339, endParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end %q, must be an RFC 3339 time", endParam)
+		}
+		if !start.Before(end) {
+			return time.Time{}, time.Time{}, fmt.Errorf("start must be before end")
+		}
+		if end.Sub(start) > maxRangeSpan {
+			return time.Time{}, time.Time{}, fmt.Errorf("range can't span more than %d days", int(maxRangeSpan.Hours()/24))
+		}
+		return start, end, nil
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, must be 24h, 7d, 30d, this-month, last-month, "+
+		"a month like 2024-05 or a start/end range", spec)
+}
+
+// percentChange returns the change from base to value in percent, or nil when base is 0
+func percentChange(value, base float64) *float64 {
+	if base == 0 {
+		return nil
+	}
+	change := (value - base) / base * 100
+	return &change
+}
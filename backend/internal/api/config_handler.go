@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// redactedValue replaces secret settings in the /config response
+const redactedValue = "[REDACTED]"
+
+// secretKeyFragments mark setting names whose values are credentials. Webhook URLs
+// embed their token and webhook headers usually carry authorization.
+var secretKeyFragments = []string{"password", "secret", "token", "api_keys", "signing_key", "webhook_url", "headers"}
+
+// SetSettings records the resolved configuration served by GetConfig. Secrets are
+// redacted here, so the handler never holds them.
+func (h *Handler) SetSettings(settings map[string]interface{}) {
+	h.settings = redactSettings(settings)
+}
+
+// GetConfig returns the server's effective configuration with secrets redacted, along
+// with the analyzer's thresholds, for debugging misconfiguration
+func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	settings := h.settings
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+
+	response := map[string]interface{}{
+		"settings": settings,
+		"analyzer": h.analyzer.Settings(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// redactSettings copies the settings, replacing the values of secret keys at any depth
+func redactSettings(settings map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if isSecretKey(key) {
+			redacted[key] = redactedValue
+			continue
+		}
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactSettings(v)
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if s, ok := key.(string); ok {
+				converted[s] = item
+			}
+		}
+		return redactSettings(converted)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range secretKeyFragments {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+
+	"github.com/gorilla/mux"
+)
+
+// consolidationWindow is how recently a node or container must have been collected
+// to be included in the consolidation plan
+const consolidationWindow = time.Hour
+
+// GetConsolidationFeasibility reports, for every schedulable node, whether it could be
+// removed with all of its pods still schedulable elsewhere
+func (h *Handler) GetConsolidationFeasibility(w http.ResponseWriter, r *http.Request) {
+	results, err := h.consolidation.CheckAllNodes(r.Context())
+	if err != nil {
+		h.log.Errorf("Consolidation analysis failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Consolidation analysis failed")
+		return
+	}
+
+	removable := 0
+	for _, result := range results {
+		if result.Feasible {
+			removable++
+		}
+	}
+
+	response := map[string]interface{}{
+		"nodes":           results,
+		"removable_nodes": removable,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetNodeConsolidationFeasibility simulates removing a single node and returns the
+// feasibility flag and, when blocked, the binding scheduling constraint
+func (h *Handler) GetNodeConsolidationFeasibility(w http.ResponseWriter, r *http.Request) {
+	nodeName := mux.Vars(r)["node"]
+
+	result, err := h.consolidation.CheckNodeRemoval(r.Context(), nodeName)
+	if err != nil {
+		h.log.Errorf("Consolidation analysis failed for node %s: %v", nodeName, err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Consolidation analysis failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetConsolidationPlan estimates how many nodes of each node pool and instance type
+// become unnecessary if every open recommendation is applied, and the monthly savings
+// of removing them. Pods are repacked within their pool at their recommended requests
+// against the allocatable of the nodes still reporting metrics.
+func (h *Handler) GetConsolidationPlan(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	cluster := h.collector.ClusterName()
+	since := time.Now().Add(-consolidationWindow)
+
+	requests, err := h.recommendedPodRequests(ctx, cluster, since)
+	if err != nil {
+		h.log.Errorf("Failed to load recommended requests: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	reporting, err := h.reportingNodes(ctx, cluster, since)
+	if err != nil {
+		h.log.Errorf("Failed to load reporting nodes: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	// Nodes the provider can't price are priced from their allocatable capacity
+	nodeCosts, err := h.costProvider.GetNodeCosts(ctx)
+	if err != nil {
+		h.log.Warnf("Failed to get node costs, pricing nodes from their capacity: %v", err)
+	}
+	prices := h.analyzer.Prices(ctx)
+
+	plan, err := h.consolidation.PlanConsolidation(ctx, requests, reporting, nodeCosts, prices)
+	if err != nil {
+		h.log.Errorf("Consolidation planning failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Consolidation analysis failed")
+		return
+	}
+
+	response := map[string]interface{}{
+		"pools":           plan.Pools,
+		"removable_nodes": plan.RemovableNodes,
+		"monthly_savings": plan.MonthlySavings,
+		"price_source":    prices.Source,
+		"timestamp":       time.Now().UTC(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// recommendedPodRequests totals each of the cluster's pods' latest CPU and memory requests, keyed by
+// namespace/pod, with open recommendations applied. A DaemonSet or StatefulSet
+// recommendation covers every replica of the workload's container.
+func (h *Handler) recommendedPodRequests(ctx context.Context, cluster string, since time.Time) (map[string]analyzer.PodRequests, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		WITH requests AS (
+			SELECT DISTINCT ON (namespace, pod_name, container_name)
+				namespace, pod_name, container_name, cpu_request, memory_request, owner_kind, owner_name
+			FROM resource_requests
+			WHERE cluster = $3 AND timestamp > $1
+			ORDER BY namespace, pod_name, container_name, timestamp DESC
+		), recs AS (
+			SELECT r.namespace, r.pod_name, r.container_name, r.resource_type,
+				r.recommended_request, r.owner_kind, rr.owner_name
+			FROM recommendations r
+			LEFT JOIN requests rr ON
+				r.namespace = rr.namespace AND
+				r.pod_name = rr.pod_name AND
+				r.container_name = rr.container_name
+			WHERE NOT r.applied AND r.created_at > $2 AND r.resource_type IN ('CPU', 'Memory')
+		)
+		SELECT rr.namespace, rr.pod_name,
+			SUM(COALESCE(cpu.recommended_request, rr.cpu_request, 0)),
+			SUM(COALESCE(mem.recommended_request, rr.memory_request, 0))
+		FROM requests rr
+		LEFT JOIN LATERAL (
+			SELECT recommended_request FROM recs
+			WHERE recs.resource_type = 'CPU' AND
+				recs.namespace = rr.namespace AND
+				recs.container_name = rr.container_name AND
+				(recs.pod_name = rr.pod_name OR
+					(recs.owner_kind IN ('DaemonSet', 'StatefulSet') AND
+						recs.owner_kind = rr.owner_kind AND recs.owner_name = rr.owner_name))
+			ORDER BY recs.pod_name = rr.pod_name DESC
+			LIMIT 1
+		) cpu ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT recommended_request FROM recs
+			WHERE recs.resource_type = 'Memory' AND
+				recs.namespace = rr.namespace AND
+				recs.container_name = rr.container_name AND
+				(recs.pod_name = rr.pod_name OR
+					(recs.owner_kind IN ('DaemonSet', 'StatefulSet') AND
+						recs.owner_kind = rr.owner_kind AND recs.owner_name = rr.owner_name))
+			ORDER BY recs.pod_name = rr.pod_name DESC
+			LIMIT 1
+		) mem ON TRUE
+		GROUP BY rr.namespace, rr.pod_name
+	`, since, time.Now().Add(-efficiencySavingsMaxAge), cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying pod requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make(map[string]analyzer.PodRequests)
+	for rows.Next() {
+		var namespace, podName string
+		var request analyzer.PodRequests
+		if err := rows.Scan(&namespace, &podName, &request.CPU, &request.Memory); err != nil {
+			return nil, fmt.Errorf("scanning pod requests: %w", err)
+		}
+		requests[namespace+"/"+podName] = request
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading pod requests: %w", err)
+	}
+	return requests, nil
+}
+
+// reportingNodes returns the cluster's nodes with metrics collected since the given time
+func (h *Handler) reportingNodes(ctx context.Context, cluster string, since time.Time) (map[string]bool, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT DISTINCT node_name
+		FROM node_metrics
+		WHERE cluster = $1 AND timestamp > $2
+	`, cluster, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying node metrics: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning node metrics: %w", err)
+		}
+		nodes[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading node metrics: %w", err)
+	}
+	return nodes, nil
+}
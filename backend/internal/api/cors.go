@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CORSConfig holds the origins allowed to call the API and open WebSocket connections
+// from a browser
+type CORSConfig struct {
+	// Origins allowed to make cross-origin requests, e.g. https://cost.example.com
+	AllowedOrigins []string
+
+	// Allow every origin, restoring the wildcard behavior for local development. Never
+	// enable this in production.
+	DevMode bool
+}
+
+// DefaultCORSConfig returns the default CORS configuration, which allows no
+// cross-origin requests
+func DefaultCORSConfig() *CORSConfig {
+	return &CORSConfig{}
+}
+
+// allowed reports whether the origin is in the allowlist. Origins are compared without
+// case or a trailing slash, as browsers send them.
+func (c *CORSConfig) allowed(origin string) bool {
+	if c.DevMode {
+		return true
+	}
+	origin = normalizeOrigin(origin)
+	for _, allowed := range c.AllowedOrigins {
+		if normalizeOrigin(allowed) == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin reports whether a WebSocket upgrade may proceed. Requests without an
+// Origin header come from non-browser clients and same-origin requests come from the
+// dashboard served alongside the API; both are accepted.
+func (c *CORSConfig) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return c.allowed(origin)
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+}
+
+// CorsMiddleware allows the dashboard to call the API from the configured origins. Only
+// an allowed request origin is reflected in Access-Control-Allow-Origin, so browsers
+// block responses to every other origin. Preflights are answered here either way.
+func CorsMiddleware(config *CORSConfig) func(http.Handler) http.Handler {
+	if config == nil {
+		config = DefaultCORSConfig()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			switch {
+			case config.DevMode:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && config.allowed(origin):
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if !config.DevMode {
+				// The response depends on the request origin, so caches must key on it
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, Idempotency-Key")
+			w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed")
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
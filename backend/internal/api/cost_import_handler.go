@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"k8s-cost-optimizer/internal/costimport"
+)
+
+// maxImportBytes is the largest cost file ImportCosts accepts, well above a year of
+// daily costs for a few hundred namespaces
+const maxImportBytes = 32 << 20
+
+// ImportCosts seeds namespace_costs with daily namespace costs from a CSV or JSON file
+// sent as the request body, e.g. a Kubecost or billing export. The format comes from
+// the format query parameter or the Content-Type. Rows without a cluster column are
+// imported for the cluster query parameter, defaulting to this cluster. A file with
+// any invalid row is rejected with the line number of each; dry_run=true validates
+// the file and reports how many rows would be inserted or updated without writing.
+func (h *Handler) ImportCosts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = costimport.FormatCSV
+		case "application/json":
+			format = costimport.FormatJSON
+		}
+	}
+	if format != costimport.FormatCSV && format != costimport.FormatJSON {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+			"format must be csv or json, or the Content-Type text/csv or application/json")
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "invalid dry_run")
+			return
+		}
+	}
+
+	cluster := r.URL.Query().Get("cluster")
+	if cluster == "" {
+		cluster = h.collector.ClusterName()
+	}
+
+	rows, err := costimport.Parse(http.MaxBytesReader(w, r.Body, maxImportBytes), format, cluster)
+	if err != nil {
+		var invalidRows *costimport.InvalidRowsError
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &invalidRows):
+			writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidRequest,
+				fmt.Sprintf("%d invalid rows; nothing was imported", len(invalidRows.Rows)), invalidRows.Rows)
+		case errors.As(err, &maxBytesErr):
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest,
+				fmt.Sprintf("request body must not exceed %d bytes", maxImportBytes))
+		default:
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		}
+		return
+	}
+
+	result, err := costimport.Import(r.Context(), h.db, rows, dryRun)
+	if err != nil {
+		h.log.Errorf("Cost import failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Cost import failed")
+		return
+	}
+
+	if !dryRun {
+		h.log.Infof("Imported %d namespace cost rows: %d inserted, %d updated",
+			result.Rows, result.Inserted, result.Updated)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"k8s-cost-optimizer/pkg/kubernetes"
+)
+
+// GetRecommendationDiff returns, per owning workload, the current and recommended
+// resources block of each affected container as a unified diff, plus a strategic merge
+// patch for the workload. Nothing is applied. Replicas of the same workload can get
+// slightly different recommendations, so the largest request and limit is used.
+func (h *Handler) GetRecommendationDiff(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	opts, err := h.analysisOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	recommendations, err := h.analyzer.AnalyzeNamespaceWithOptions(r.Context(), namespace, opts)
+	if err != nil {
+		h.log.Errorf("Analysis failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Analysis failed")
+		return
+	}
+
+	type workloadKey struct{ kind, name string }
+	type updateKey struct{ container, resource string }
+
+	owners := make(map[string]workloadKey)
+	updates := make(map[workloadKey]map[updateKey]*kubernetes.ResourceUpdate)
+	var workloads []workloadKey
+	var failures []map[string]string
+
+	for _, rec := range recommendations {
+		owner, ok := owners[rec.PodName]
+		if !ok {
+			kind, name, err := kubernetes.ResolveOwner(r.Context(), h.k8sClient, namespace, rec.PodName)
+			if err != nil {
+				h.log.Warnf("Failed to resolve owner of %s/%s: %v", namespace, rec.PodName, err)
+				failures = append(failures, map[string]string{"pod": rec.PodName, "error": err.Error()})
+				owners[rec.PodName] = workloadKey{}
+				continue
+			}
+			owner = workloadKey{kind, name}
+			owners[rec.PodName] = owner
+		}
+		if owner.kind == "" {
+			continue
+		}
+
+		if _, ok := updates[owner]; !ok {
+			updates[owner] = make(map[updateKey]*kubernetes.ResourceUpdate)
+			workloads = append(workloads, owner)
+		}
+		key := updateKey{rec.ContainerName, rec.ResourceType}
+		update, ok := updates[owner][key]
+		if !ok {
+			update = &kubernetes.ResourceUpdate{Container: rec.ContainerName, ResourceType: rec.ResourceType}
+			updates[owner][key] = update
+		}
+		if rec.RecommendedRequest > update.Request {
+			update.Request = rec.RecommendedRequest
+		}
+		if rec.RecommendedLimit > update.Limit {
+			update.Limit = rec.RecommendedLimit
+		}
+	}
+
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].kind != workloads[j].kind {
+			return workloads[i].kind < workloads[j].kind
+		}
+		return workloads[i].name < workloads[j].name
+	})
+
+	diffs := make([]*kubernetes.WorkloadDiff, 0, len(workloads))
+	for _, workload := range workloads {
+		var list []kubernetes.ResourceUpdate
+		for _, update := range updates[workload] {
+			list = append(list, *update)
+		}
+
+		diff, err := kubernetes.DiffWorkloadResources(r.Context(), h.k8sClient, namespace, workload.kind, workload.name, list)
+		if err != nil {
+			h.log.Warnf("Failed to diff %s %s/%s: %v", workload.kind, namespace, workload.name, err)
+			failures = append(failures, map[string]string{"workload": workload.kind + "/" + workload.name, "error": err.Error()})
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+
+	response := map[string]interface{}{
+		"namespace": namespace,
+		"workloads": diffs,
+		"count":     len(diffs),
+	}
+	if len(failures) > 0 {
+		response["errors"] = failures
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// efficiencyUsageWindow is how much recent usage the cluster summary averages
+	efficiencyUsageWindow = time.Hour
+
+	// efficiencySavingsMaxAge excludes open recommendations not refreshed recently,
+	// e.g. for pods that no longer exist
+	efficiencySavingsMaxAge = 7 * 24 * time.Hour
+)
+
+// ResourceEfficiency compares what was requested or allocatable with what was used
+type ResourceEfficiency struct {
+	Capacity    float64 `json:"capacity"`
+	Used        float64 `json:"used"`
+	Utilization float64 `json:"utilization"` // Used as a percentage of capacity
+}
+
+func newResourceEfficiency(capacity, used float64) ResourceEfficiency {
+	efficiency := ResourceEfficiency{Capacity: capacity, Used: used}
+	if capacity > 0 {
+		efficiency.Utilization = used / capacity * 100
+	}
+	return efficiency
+}
+
+// ContainerProvisioning counts containers by how their requests fit their usage
+type ContainerProvisioning struct {
+	Total            int `json:"total"`
+	OverProvisioned  int `json:"over_provisioned"`
+	UnderProvisioned int `json:"under_provisioned"`
+	WithoutRequests  int `json:"without_requests"`
+}
+
+// GetClusterEfficiency returns the cluster-wide summary for the landing dashboard:
+// requested versus used CPU and memory over the last hour, how many containers are
+// over- or under-provisioned, node allocatable versus consumed, and the potential
+// savings of every open recommendation. A container is under-provisioned when its
+// peak usage exceeds its request, and over-provisioned when its average leaves more
+// than the analyzer's waste threshold of the request unused.
+func (h *Handler) GetClusterEfficiency(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	cluster := h.collector.ClusterName()
+	since := time.Now().Add(-efficiencyUsageWindow)
+
+	containers, cpu, memory, err := h.containerEfficiency(ctx, cluster, since)
+	if err != nil {
+		h.log.Errorf("Failed to summarize container efficiency: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	var savings float64
+	var openRecommendations int
+	err = h.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(potential_savings), 0), COUNT(*)
+		FROM recommendations
+		WHERE NOT applied AND potential_savings > 0 AND created_at > $1
+	`, time.Now().Add(-efficiencySavingsMaxAge)).Scan(&savings, &openRecommendations)
+	if err != nil {
+		h.log.Errorf("Failed to sum potential savings: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	response := map[string]interface{}{
+		"cluster":    cluster,
+		"window":     efficiencyUsageWindow.String(),
+		"containers": containers,
+		"requests": map[string]ResourceEfficiency{
+			"cpu_millicores": cpu,
+			"memory_bytes":   memory,
+		},
+		"potential_savings": map[string]interface{}{
+			"monthly":         savings,
+			"recommendations": openRecommendations,
+		},
+		"timestamp": time.Now().UTC(),
+	}
+
+	// Node capacity comes from the API server; the rest of the summary is still
+	// useful without it
+	nodes, err := h.nodeEfficiency(ctx, cluster, since)
+	if err != nil {
+		h.log.Warnf("Failed to summarize node efficiency: %v", err)
+	} else {
+		response["nodes"] = nodes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// containerEfficiency totals the cluster's container requests and usage since the
+// given time and classifies each container's provisioning
+func (h *Handler) containerEfficiency(ctx context.Context, cluster string, since time.Time) (ContainerProvisioning, ResourceEfficiency, ResourceEfficiency, error) {
+	var provisioning ContainerProvisioning
+	var cpu, memory ResourceEfficiency
+
+	rows, err := h.db.QueryContext(ctx, `
+		WITH usage AS (
+			SELECT namespace, pod_name, container_name,
+				AVG(cpu_millicores) as avg_cpu, MAX(cpu_millicores) as max_cpu,
+				AVG(memory_bytes) as avg_memory, MAX(memory_bytes) as max_memory
+			FROM pod_metrics
+			WHERE cluster = $1 AND timestamp > $2
+			GROUP BY namespace, pod_name, container_name
+		), requests AS (
+			SELECT DISTINCT ON (namespace, pod_name, container_name)
+				namespace, pod_name, container_name, cpu_request, memory_request
+			FROM resource_requests
+			WHERE cluster = $1 AND timestamp > $2
+			ORDER BY namespace, pod_name, container_name, timestamp DESC
+		)
+		SELECT u.avg_cpu, u.max_cpu, u.avg_memory, u.max_memory,
+			COALESCE(rr.cpu_request, 0), COALESCE(rr.memory_request, 0)
+		FROM usage u
+		LEFT JOIN requests rr ON
+			u.namespace = rr.namespace AND
+			u.pod_name = rr.pod_name AND
+			u.container_name = rr.container_name
+	`, cluster, since)
+	if err != nil {
+		return provisioning, cpu, memory, fmt.Errorf("querying container usage: %w", err)
+	}
+	defer rows.Close()
+
+	wasteThreshold := h.analyzer.Settings().WasteThreshold
+	var cpuRequested, cpuUsed, memoryRequested, memoryUsed float64
+
+	for rows.Next() {
+		var avgCPU, maxCPU, avgMemory, maxMemory, cpuRequest, memoryRequest float64
+		if err := rows.Scan(&avgCPU, &maxCPU, &avgMemory, &maxMemory, &cpuRequest, &memoryRequest); err != nil {
+			return provisioning, cpu, memory, fmt.Errorf("scanning container usage: %w", err)
+		}
+
+		provisioning.Total++
+		cpuRequested += cpuRequest
+		cpuUsed += avgCPU
+		memoryRequested += memoryRequest
+		memoryUsed += avgMemory
+
+		switch {
+		case cpuRequest <= 0 && memoryRequest <= 0:
+			provisioning.WithoutRequests++
+		case (cpuRequest > 0 && maxCPU > cpuRequest) || (memoryRequest > 0 && maxMemory > memoryRequest):
+			provisioning.UnderProvisioned++
+		case (cpuRequest > 0 && avgCPU < cpuRequest*(1-wasteThreshold)) ||
+			(memoryRequest > 0 && avgMemory < memoryRequest*(1-wasteThreshold)):
+			provisioning.OverProvisioned++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return provisioning, cpu, memory, fmt.Errorf("reading container usage: %w", err)
+	}
+
+	return provisioning, newResourceEfficiency(cpuRequested, cpuUsed), newResourceEfficiency(memoryRequested, memoryUsed), nil
+}
+
+// nodeEfficiency compares the nodes' allocatable CPU and memory with their latest
+// recorded usage. Until the metrics server has reported any nodes, usage falls back to
+// the latest sample of each of the cluster's containers, which leaves out system
+// overhead outside pods.
+func (h *Handler) nodeEfficiency(ctx context.Context, cluster string, since time.Time) (map[string]interface{}, error) {
+	nodes, err := h.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	var cpuAllocatable, memoryAllocatable float64
+	for _, node := range nodes.Items {
+		cpuAllocatable += float64(node.Status.Allocatable.Cpu().MilliValue())
+		memoryAllocatable += float64(node.Status.Allocatable.Memory().Value())
+	}
+
+	var reported int
+	var cpuUsed, memoryUsed float64
+	err = h.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(cpu_millicores), 0), COALESCE(SUM(memory_bytes), 0)
+		FROM (
+			SELECT DISTINCT ON (node_name) cpu_millicores, memory_bytes
+			FROM node_metrics
+			WHERE cluster = $1 AND timestamp > $2
+			ORDER BY node_name, timestamp DESC
+		) latest
+	`, cluster, since).Scan(&reported, &cpuUsed, &memoryUsed)
+	if err != nil {
+		return nil, fmt.Errorf("querying node usage: %w", err)
+	}
+
+	usageSource := "node_metrics"
+	if reported == 0 {
+		usageSource = "pod_metrics"
+		err = h.db.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(cpu_millicores), 0), COALESCE(SUM(memory_bytes), 0)
+			FROM (
+				SELECT DISTINCT ON (namespace, pod_name, container_name) cpu_millicores, memory_bytes
+				FROM pod_metrics
+				WHERE cluster = $1 AND timestamp > $2
+				ORDER BY namespace, pod_name, container_name, timestamp DESC
+			) latest
+		`, cluster, since).Scan(&cpuUsed, &memoryUsed)
+		if err != nil {
+			return nil, fmt.Errorf("querying pod usage: %w", err)
+		}
+	}
+
+	return map[string]interface{}{
+		"count":          len(nodes.Items),
+		"cpu_millicores": newResourceEfficiency(cpuAllocatable, cpuUsed),
+		"memory_bytes":   newResourceEfficiency(memoryAllocatable, memoryUsed),
+		"usage_source":   usageSource,
+	}, nil
+}
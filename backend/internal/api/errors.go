@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes returned in error responses. They are part of the API, so clients can
+// branch on them; don't rename them.
+const (
+	errCodeInvalidRequest    = "INVALID_REQUEST"
+	errCodeInvalidParameter  = "INVALID_PARAMETER"
+	errCodeInvalidPeriod     = "INVALID_PERIOD"
+	errCodeInvalidNamespace  = "INVALID_NAMESPACE"
+	errCodeNamespaceNotFound = "NAMESPACE_NOT_FOUND"
+	errCodeNotFound          = "NOT_FOUND"
+	errCodeUnauthorized      = "UNAUTHORIZED"
+	errCodeForbidden         = "FORBIDDEN"
+	errCodeRateLimited       = "RATE_LIMITED"
+	errCodeDatabase          = "DB_ERROR"
+	errCodeAnalysisFailed    = "ANALYSIS_FAILED"
+	errCodeKubernetes        = "KUBERNETES_ERROR"
+	errCodeInternal          = "INTERNAL_ERROR"
+	errCodeIdempotencyInUse  = "IDEMPOTENCY_KEY_IN_USE"
+	errCodeIdempotencyReused = "IDEMPOTENCY_KEY_REUSED"
+	errCodePostgresRequired  = "POSTGRES_REQUIRED"
+)
+
+// errorResponse is the body of every error response:
+// {"error": {"code": "DB_ERROR", "message": "Database error"}}
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// writeError writes a JSON error response with a stable code and a human-readable message
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: errorDetail{Code: code, Message: message}})
+}
+
+// writeErrorDetails is writeError with details of the problem, such as each invalid
+// row of an uploaded file
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: errorDetail{Code: code, Message: message, Details: details}})
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Cost forecast horizon and the daily history it is fitted to, in days
+const (
+	costForecastDays   = 30
+	costForecastWindow = 90
+)
+
+// Holt-Winters uses a weekly season and needs two full seasons to initialise. Shorter
+// histories fall back to a linear projection.
+const (
+	forecastSeason         = 7
+	minHoltWintersDays     = 2 * forecastSeason
+	highConfidenceForecast = 4 * forecastSeason
+)
+
+// z-score of the 95% confidence bands
+const forecastBandZ = 1.96
+
+// Forecast methods and confidence levels reported by GetCostForecast
+const (
+	forecastMethodHoltWinters = "holt_winters"
+	forecastMethodLinear      = "linear"
+
+	forecastConfidenceHigh   = "high"
+	forecastConfidenceMedium = "medium"
+	forecastConfidenceLow    = "low"
+)
+
+// ForecastPoint is the forecast cost for one day with its 95% confidence band
+type ForecastPoint struct {
+	Date     string  `json:"date"`
+	Forecast float64 `json:"forecast"`
+	Lower    float64 `json:"lower"`
+	Upper    float64 `json:"upper"`
+}
+
+// GetCostForecast forecasts the namespace's daily cost for the next 30 days from up to
+// 90 days of history. With two weeks or more it fits an additive Holt-Winters model
+// with a weekly season; shorter histories get a linear projection flagged as low
+// confidence. Forecasts only change as days complete, so they are cached per day.
+func (h *Handler) GetCostForecast(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Today is still being collected, so the history ends at midnight UTC
+	cluster := r.URL.Query().Get("cluster")
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	cacheKey := fmt.Sprintf("forecast:%s:%s:%s", cluster, namespace, today.Format("2006-01-02"))
+	loaded := false
+	jsonResponse, err := h.cacheManager.GetOrLoad(r.Context(), cacheKey, func() ([]byte, error) {
+		loaded = true
+		// Detach from this request so other waiters aren't failed if this client disconnects
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), dbQueryTimeout)
+		defer cancel()
+		return h.loadCostForecast(ctx, cluster, namespace, today)
+	})
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	cacheStatus := "HIT"
+	if loaded {
+		cacheStatus = "MISS"
+	}
+
+	duration := time.Since(start).Seconds()
+	apiRequestDuration.WithLabelValues("GET", "/analytics/forecast", "200").Observe(duration)
+	apiRequestTotal.WithLabelValues("GET", "/analytics/forecast", "200").Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", cacheStatus)
+	w.Write(jsonResponse)
+}
+
+// loadCostForecast builds the GetCostForecast response from the namespace's complete
+// days before today
+func (h *Handler) loadCostForecast(ctx context.Context, cluster, namespace string, today time.Time) ([]byte, error) {
+	costs, _, _, err := h.dailyCosts(ctx, cluster, namespace,
+		today.AddDate(0, 0, -costForecastWindow), today.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	history := dailyHistory(costs, today)
+
+	response := map[string]interface{}{
+		"cluster":      cluster,
+		"namespace":    namespace,
+		"history_days": len(history),
+		"horizon_days": costForecastDays,
+		"no_data":      len(history) == 0,
+	}
+	if len(history) == 0 {
+		return json.Marshal(response)
+	}
+
+	var forecast, spread []float64
+	method, confidence := forecastMethodLinear, forecastConfidenceLow
+	if len(history) >= minHoltWintersDays {
+		forecast, spread = holtWintersForecast(history, costForecastDays)
+		method, confidence = forecastMethodHoltWinters, forecastConfidenceMedium
+		if len(history) >= highConfidenceForecast {
+			confidence = forecastConfidenceHigh
+		}
+	} else {
+		forecast, spread = linearForecast(history, costForecastDays)
+	}
+
+	// Costs can't go negative, so neither can the forecast or its lower band. The
+	// total's band sums the daily bands, which overstates its width.
+	points := make([]ForecastPoint, len(forecast))
+	var total, totalLower, totalUpper float64
+	for i, value := range forecast {
+		value = math.Max(value, 0)
+		points[i] = ForecastPoint{
+			Date:     today.AddDate(0, 0, i).Format("2006-01-02"),
+			Forecast: value,
+			Lower:    math.Max(value-spread[i], 0),
+			Upper:    value + spread[i],
+		}
+		total += points[i].Forecast
+		totalLower += points[i].Lower
+		totalUpper += points[i].Upper
+	}
+
+	response["method"] = method
+	response["confidence"] = confidence
+	response["forecast"] = points
+	response["total"] = map[string]float64{
+		"forecast": total,
+		"lower":    totalLower,
+		"upper":    totalUpper,
+	}
+
+	return json.Marshal(response)
+}
+
+// dailyHistory orders the namespace's daily totals oldest first, from its first day
+// with costs through yesterday. A missing day is more often a collection outage than a
+// day without cost, so it takes the previous day's total.
+func dailyHistory(costs []DailyCost, today time.Time) []float64 {
+	totals := make(map[string]float64, len(costs))
+	var first time.Time
+	for _, cost := range costs {
+		day, err := time.Parse("2006-01-02", cost.Date)
+		if err != nil {
+			continue
+		}
+		totals[cost.Date] = cost.Total
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+
+	var history []float64
+	var previous float64
+	for day := first; day.Before(today); day = day.AddDate(0, 0, 1) {
+		if total, ok := totals[day.Format("2006-01-02")]; ok {
+			previous = total
+		}
+		history = append(history, previous)
+	}
+	return history
+}
+
+// holtWintersForecast fits an additive Holt-Winters model with a weekly season to the
+// history and forecasts the next horizon days. The smoothing parameters are picked by
+// grid search on the one-step-ahead squared error, and the returned spreads are the
+// half-widths of the 95% prediction intervals, which widen with the horizon.
+func holtWintersForecast(history []float64, horizon int) (forecast, spread []float64) {
+	best := holtWinters{sse: math.Inf(1)}
+	for a := 1; a <= 9; a++ {
+		for b := 1; b <= 9; b++ {
+			for g := 1; g <= 9; g++ {
+				fit := fitHoltWinters(history, float64(a)/10, float64(b)/10, float64(g)/10)
+				if fit.sse < best.sse {
+					best = fit
+				}
+			}
+		}
+	}
+
+	sigma := math.Sqrt(best.sse / float64(best.fitted))
+	forecast = make([]float64, horizon)
+	spread = make([]float64, horizon)
+	variance := 1.0
+	for h := 1; h <= horizon; h++ {
+		forecast[h-1] = best.level + float64(h)*best.trend + best.seasonal[(len(history)+h-1)%forecastSeason]
+		spread[h-1] = forecastBandZ * sigma * math.Sqrt(variance)
+
+		// Each step adds the error carried forward through level, trend and, once a
+		// season has passed, the seasonal component
+		c := best.alpha * (1 + float64(h)*best.beta)
+		if h%forecastSeason == 0 {
+			c += best.gamma
+		}
+		variance += c * c
+	}
+	return forecast, spread
+}
+
+// holtWinters is the state of a fitted Holt-Winters model after the last observation
+type holtWinters struct {
+	alpha, beta, gamma float64
+	level, trend       float64
+	seasonal           []float64
+	sse                float64
+	fitted             int
+}
+
+// fitHoltWinters runs additive Holt-Winters over the history, initialised from its
+// first two seasons. seasonal is indexed by day position modulo the season length.
+func fitHoltWinters(history []float64, alpha, beta, gamma float64) holtWinters {
+	m := forecastSeason
+	var firstMean, secondMean float64
+	for i := 0; i < m; i++ {
+		firstMean += history[i] / float64(m)
+		secondMean += history[m+i] / float64(m)
+	}
+
+	fit := holtWinters{
+		alpha:    alpha,
+		beta:     beta,
+		gamma:    gamma,
+		level:    firstMean,
+		trend:    (secondMean - firstMean) / float64(m),
+		seasonal: make([]float64, m),
+	}
+	for i := 0; i < m; i++ {
+		fit.seasonal[i] = history[i] - firstMean
+	}
+
+	for t := m; t < len(history); t++ {
+		season := fit.seasonal[t%m]
+		predicted := fit.level + fit.trend + season
+		fit.sse += (history[t] - predicted) * (history[t] - predicted)
+		fit.fitted++
+
+		level := alpha*(history[t]-season) + (1-alpha)*(fit.level+fit.trend)
+		fit.trend = beta*(level-fit.level) + (1-beta)*fit.trend
+		fit.level = level
+		fit.seasonal[t%m] = gamma*(history[t]-level) + (1-gamma)*season
+	}
+	return fit
+}
+
+// linearForecast projects the history's regression line over the next horizon days,
+// with 95% prediction intervals from the residuals. Under three days there are no
+// residuals to estimate them from, so the band spans zero to twice the forecast.
+func linearForecast(history []float64, horizon int) (forecast, spread []float64) {
+	slope, intercept := linearRegression(history)
+	n := float64(len(history))
+
+	var sigma, meanX, sumSquaresX float64
+	if len(history) >= 3 {
+		var sse float64
+		for i, y := range history {
+			residual := y - (intercept + slope*float64(i))
+			sse += residual * residual
+		}
+		sigma = math.Sqrt(sse / (n - 2))
+
+		meanX = (n - 1) / 2
+		for i := range history {
+			sumSquaresX += (float64(i) - meanX) * (float64(i) - meanX)
+		}
+	}
+
+	forecast = make([]float64, horizon)
+	spread = make([]float64, horizon)
+	for h := 0; h < horizon; h++ {
+		x := n + float64(h)
+		forecast[h] = intercept + slope*x
+		if len(history) >= 3 {
+			spread[h] = forecastBandZ * sigma * math.Sqrt(1+1/n+(x-meanX)*(x-meanX)/sumSquaresX)
+		} else {
+			spread[h] = math.Abs(forecast[h])
+		}
+	}
+	return forecast, spread
+}
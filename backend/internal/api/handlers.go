@@ -5,29 +5,65 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"k8s-cost-optimizer/internal/analyzer"
 	"k8s-cost-optimizer/internal/collectors"
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/pkg/cache"
 	"k8s-cost-optimizer/pkg/cloudprovider"
+	"k8s-cost-optimizer/pkg/kubernetes"
 	"k8s-cost-optimizer/internal/websocket"
 
-	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	k8s "k8s.io/client-go/kubernetes"
 )
 
 type Handler struct {
-	analyzer      *analyzer.RightsizingAnalyzer
-	collector     *collectors.MetricsCollector
-	costProvider  cloudprovider.Provider
-	db            *sql.DB
-	cache         *redis.Client
-	wsHub         *websocket.Hub
-	log           *logrus.Logger
+	analyzer        *analyzer.RightsizingAnalyzer
+	consolidation   *analyzer.ConsolidationAnalyzer
+	collector       *collectors.MetricsCollector
+	k8sClient       k8s.Interface
+	costProvider    cloudprovider.Provider
+	db              *sql.DB
+	costs           store.CostRepository
+	metrics         store.MetricsRepository
+	recommendations store.RecommendationRepository
+	reportSchedules store.ReportScheduleRepository
+	cache           *redis.Client
+	cacheManager    *cache.CacheManager
+	wsHub           *websocket.Hub
+	events          *kubernetes.EventEmitter
+	settings        map[string]interface{} // Redacted configuration served by GetConfig
+	maxBodyBytes    int64
+	log             *logrus.Logger
+
+	// Collection intervals HealthDetailed checks freshness against
+	metricsInterval time.Duration
+	costInterval    time.Duration
+
+	// How long responses to requests with an Idempotency-Key are replayed
+	idempotencyTTL time.Duration
+
+	// Whether the service is not ready without Redis, rather than degraded
+	redisRequired bool
+}
+
+// dbQueryTimeout bounds the database queries made while serving a request, so a slow
+// query can't hold the request and its connection indefinitely
+const dbQueryTimeout = 10 * time.Second
+
+// queryContext returns the request's context with the query timeout applied. It is
+// cancelled when the client disconnects, which frees the connection.
+func queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), dbQueryTimeout)
 }
 
 // Metrics for monitoring
@@ -49,17 +85,26 @@ var (
 	)
 )
 
-func NewHandler(analyzer *analyzer.RightsizingAnalyzer, collector *collectors.MetricsCollector, 
-	costProvider cloudprovider.Provider, db *sql.DB, cache *redis.Client, wsHub *websocket.Hub) *Handler {
-	
+func NewHandler(analyzer *analyzer.RightsizingAnalyzer, consolidation *analyzer.ConsolidationAnalyzer,
+	collector *collectors.MetricsCollector, k8sClient k8s.Interface,
+	costProvider cloudprovider.Provider, db *sql.DB, repositories store.Repositories, cache *redis.Client,
+	cacheManager *cache.CacheManager, wsHub *websocket.Hub, events *kubernetes.EventEmitter) *Handler {
 	return &Handler{
-		analyzer:     analyzer,
-		collector:    collector,
-		costProvider: costProvider,
-		db:           db,
-		cache:        cache,
-		wsHub:        wsHub,
-		log:          logrus.New(),
+		analyzer:        analyzer,
+		consolidation:   consolidation,
+		collector:       collector,
+		k8sClient:       k8sClient,
+		costProvider:    costProvider,
+		db:              db,
+		costs:           repositories.Costs,
+		metrics:         repositories.Metrics,
+		recommendations: repositories.Recommendations,
+		reportSchedules: repositories.ReportSchedules,
+		cache:           cache,
+		cacheManager:    cacheManager,
+		wsHub:           wsHub,
+		events:          events,
+		log:             logrus.New(),
 	}
 }
 
@@ -72,21 +117,45 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *Handler) ReadyCheck(w http.ResponseWriter, r *http.Request) {
-	// Check database connection
-	if err := h.db.Ping(); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status": "not ready",
-			"error":  "database connection failed",
-		})
-		return
+// SetRedisRequired sets whether ReadyCheck fails while Redis is unreachable. When Redis
+// isn't required the service is reported as degraded instead, and serves uncached.
+func (h *Handler) SetRedisRequired(required bool) {
+	h.redisRequired = required
+}
+
+// RequirePostgres serves the handler only when the server runs on Postgres. On SQLite
+// the handler's tables and queries aren't available, so it is answered with a 501.
+func (h *Handler) RequirePostgres(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.db == nil {
+			writeError(w, http.StatusNotImplemented, errCodePostgresRequired,
+				"This endpoint needs Postgres; the server is running on SQLite")
+			return
+		}
+		next(w, r)
 	}
+}
 
-	// Check Redis connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (h *Handler) ReadyCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	if err := h.cache.Ping(ctx).Err(); err != nil {
+
+	// Check database connection. SQLite is a local file, with no connection to lose.
+	if h.db != nil {
+		if err := h.db.PingContext(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "not ready",
+				"error":  "database connection failed",
+			})
+			return
+		}
+	}
+
+	// Check Redis connection. Without it requests bypass the cache, so unless it is
+	// required the service is only degraded.
+	redisErr := h.cache.Ping(ctx).Err()
+	if redisErr != nil && h.redisRequired {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status": "not ready",
@@ -95,125 +164,182 @@ func (h *Handler) ReadyCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"status": "ready",
 		"time":   time.Now().UTC(),
-	})
-}
+	}
 
-func (h *Handler) GetNamespaceCosts(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	vars := mux.Vars(r)
-	namespace := vars["namespace"]
-
-	// Check cache first
-	cacheKey := fmt.Sprintf("costs:%s:%s", namespace, time.Now().Format("2006-01-02-15"))
-	cached, err := h.cache.Get(r.Context(), cacheKey).Result()
-	if err == nil && cached != "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache", "HIT")
-		w.Write([]byte(cached))
-		return
+	if redisErr != nil {
+		response["status"] = "degraded"
+		response["redis"] = "unavailable"
 	}
 
-	// Parse query parameters
-	period := r.URL.Query().Get("period")
-	if period == "" {
-		period = "30d"
+	// A tripped billing API breaker degrades cost collection but we can still serve
+	if provider, ok := h.costProvider.(*cloudprovider.ResilientProvider); ok {
+		state := provider.BreakerState()
+		response["cloud_provider"] = state
+		if state != "closed" {
+			response["status"] = "degraded"
+		}
 	}
 
-	// Calculate time range
-	endTime := time.Now()
-	var startTime time.Time
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
 
-	switch period {
-	case "24h":
-		startTime = endTime.Add(-24 * time.Hour)
-	case "7d":
-		startTime = endTime.Add(-7 * 24 * time.Hour)
-	case "30d":
-		startTime = endTime.Add(-30 * 24 * time.Hour)
-	default:
-		http.Error(w, "Invalid period", http.StatusBadRequest)
+func (h *Handler) GetNamespaceCosts(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
 		return
 	}
 
-	// Query costs from database
-	rows, err := h.db.Query(`
-		SELECT 
-			DATE_TRUNC('day', timestamp) as day,
-			SUM(compute_cost) as compute,
-			SUM(storage_cost) as storage,
-			SUM(network_cost) as network,
-			SUM(other_cost) as other,
-			SUM(compute_cost + storage_cost + network_cost + other_cost) as total
-		FROM namespace_costs
-		WHERE 
-			namespace = $1 
-			AND timestamp BETWEEN $2 AND $3
-		GROUP BY day
-		ORDER BY day DESC
-	`, namespace, startTime, endTime)
+	// A named period or an explicit start/end range
+	period, startTime, endTime, err := parseTimeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, err.Error())
+		return
+	}
 
+	// Serve from cache, with concurrent misses for the same key sharing one load.
+	// Named periods move with the clock, so they are cached per hour.
+	cluster := r.URL.Query().Get("cluster")
+	cacheKey := fmt.Sprintf("costs:%s:%s:%s:%s", cluster, namespace, period, endTime.Format("2006-01-02-15"))
+	if period == customPeriod {
+		cacheKey = fmt.Sprintf("costs:%s:%s:%s:%s", cluster, namespace,
+			startTime.UTC().Format(time.RFC3339), endTime.UTC().Format(time.RFC3339))
+	}
+	loaded := false
+	jsonResponse, err := h.cacheManager.GetOrLoad(r.Context(), cacheKey, func() ([]byte, error) {
+		loaded = true
+		// Detach from this request so other waiters aren't failed if this client disconnects
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), dbQueryTimeout)
+		defer cancel()
+		return h.loadNamespaceCosts(ctx, cluster, namespace, period, startTime, endTime)
+	})
 	if err != nil {
 		h.log.Errorf("Database error: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
 		return
 	}
-	defer rows.Close()
 
-	type DailyCost struct {
-		Date    string  `json:"date"`
-		Compute float64 `json:"compute"`
-		Storage float64 `json:"storage"`
-		Network float64 `json:"network"`
-		Other   float64 `json:"other"`
-		Total   float64 `json:"total"`
+	cacheStatus := "HIT"
+	if loaded {
+		cacheStatus = "MISS"
 	}
 
-	var costs []DailyCost
-	var totalCost float64
+	// Record metrics
+	duration := time.Since(start).Seconds()
+	apiRequestDuration.WithLabelValues("GET", "/costs/namespace", "200").Observe(duration)
+	apiRequestTotal.WithLabelValues("GET", "/costs/namespace", "200").Inc()
 
-	for rows.Next() {
-		var cost DailyCost
-		var day time.Time
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", cacheStatus)
+	w.Write(jsonResponse)
+}
 
-		err := rows.Scan(&day, &cost.Compute, &cost.Storage, 
-			&cost.Network, &cost.Other, &cost.Total)
-		if err != nil {
-			continue
-		}
+// DailyCost is a namespace's cost for one day
+type DailyCost struct {
+	Date    string  `json:"date"`
+	Compute float64 `json:"compute"`
+	Storage float64 `json:"storage"`
+	Network float64 `json:"network"`
+	Other   float64 `json:"other"`
+	Total   float64 `json:"total"`
+}
 
-		cost.Date = day.Format("2006-01-02")
-		costs = append(costs, cost)
-		totalCost += cost.Total
+// dailyCosts sums the namespace's costs per day between startTime and endTime, newest
+// first. It also returns the total and the total before reconciliation scaled it. An
+// empty cluster sums the namespace's costs across all clusters.
+func (h *Handler) dailyCosts(ctx context.Context, cluster, namespace string, startTime, endTime time.Time) ([]DailyCost, float64, float64, error) {
+	daily, err := h.costs.DailyCosts(ctx, cluster, namespace, startTime, endTime)
+	if err != nil {
+		return nil, 0, 0, err
 	}
 
-	// Get current month projection
+	var costs []DailyCost
+	var totalCost, estimatedCost float64
+
+	for _, day := range daily {
+		costs = append(costs, DailyCost{
+			Date:    day.Day.Format("2006-01-02"),
+			Compute: day.Compute,
+			Storage: day.Storage,
+			Network: day.Network,
+			Other:   day.Other,
+			Total:   day.Total,
+		})
+		totalCost += day.Total
+		estimatedCost += day.Estimated
+	}
+
+	return costs, totalCost, estimatedCost, nil
+}
+
+// costSummary returns the average daily cost over the days with costs and the total
+// projected over the month endTime falls in, or zeros without any costs
+func costSummary(costs []DailyCost, totalCost float64, endTime time.Time) (averageDaily, projectedMonthly float64) {
+	if len(costs) == 0 {
+		return 0, 0
+	}
 	daysInMonth := time.Date(endTime.Year(), endTime.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
 	daysPassed := endTime.Day()
-	projectedMonthly := (totalCost / float64(daysPassed)) * float64(daysInMonth)
+	averageDaily = totalCost / float64(len(costs))
+	projectedMonthly = (totalCost / float64(daysPassed)) * float64(daysInMonth)
+	return averageDaily, projectedMonthly
+}
+
+// loadNamespaceCosts builds the GetNamespaceCosts response from the database. An empty
+// cluster sums the namespace's costs across all clusters.
+func (h *Handler) loadNamespaceCosts(ctx context.Context, cluster, namespace, period string, startTime, endTime time.Time) ([]byte, error) {
+	costs, totalCost, estimatedCost, err := h.dailyCosts(ctx, cluster, namespace, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get current month projection. Without any cost rows there is nothing to average
+	// or project, which no_data tells apart from a namespace that costs nothing.
+	noData := len(costs) == 0
+	averageDaily, projectedMonthly := costSummary(costs, totalCost, endTime)
 
 	// Get resource breakdown
-	breakdown := h.getResourceBreakdown(namespace, startTime, endTime)
+	breakdown := h.getResourceBreakdown(ctx, cluster, namespace, startTime, endTime)
 
 	response := map[string]interface{}{
+		"cluster":   cluster,
 		"namespace": namespace,
 		"period":    period,
+		"start":     startTime.UTC(),
+		"end":       endTime.UTC(),
 		"costs":     costs,
 		"summary": map[string]float64{
 			"total":            totalCost,
-			"average_daily":    totalCost / float64(len(costs)),
+			"average_daily":    averageDaily,
 			"projected_monthly": projectedMonthly,
 		},
+		"no_data":   noData,
 		"breakdown": breakdown,
+		"reconciliation_factor": reconciliationFactor(totalCost, estimatedCost),
 	}
 
-	// Cache the response
-	jsonResponse, _ := json.Marshal(response)
-	h.cache.Set(r.Context(), cacheKey, jsonResponse, 15*time.Minute)
+	// Budget status for color-coding, projected from the month to date across clusters
+	budget, err := h.analyzer.GetBudget(ctx, namespace)
+	if err != nil {
+		h.log.Warnf("Failed to load budget for %s: %v", namespace, err)
+	} else if budget != nil {
+		status, err := h.analyzer.CheckBudget(ctx, *budget)
+		if err != nil {
+			h.log.Warnf("Failed to check budget for %s: %v", namespace, err)
+		} else {
+			response["budget"] = status
+		}
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Broadcast real-time update via WebSocket
 	if h.wsHub != nil {
@@ -225,66 +351,83 @@ func (h *Handler) GetNamespaceCosts(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Record metrics
-	duration := time.Since(start).Seconds()
-	apiRequestDuration.WithLabelValues("GET", "/costs/namespace", "200").Observe(duration)
-	apiRequestTotal.WithLabelValues("GET", "/costs/namespace", "200").Inc()
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Cache", "MISS")
-	w.Write(jsonResponse)
+	return jsonResponse, nil
 }
 
 func (h *Handler) GetClusterCosts(w http.ResponseWriter, r *http.Request) {
-	// Get costs across all namespaces
-	rows, err := h.db.Query(`
-		SELECT 
-			namespace,
-			SUM(compute_cost) as compute,
-			SUM(storage_cost) as storage,
-			SUM(network_cost) as network,
-			SUM(other_cost) as other,
-			SUM(compute_cost + storage_cost + network_cost + other_cost) as total
-		FROM namespace_costs
-		WHERE timestamp > NOW() - INTERVAL '30 days'
-		GROUP BY namespace
-		ORDER BY total DESC
-	`)
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	// Optional cluster filter; without it namespaces from every cluster are listed
+	cluster := r.URL.Query().Get("cluster")
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	// Fresh costs straight from the billing API, falling back to the stored costs
+	var fallbackReason string
+	if r.URL.Query().Get("source") == "provider" {
+		response, err := h.providerClusterCosts(r.Context(), cluster, limit, offset)
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		h.log.Warnf("Failed to get cluster costs from provider, using database: %v", err)
+		fallbackReason = err.Error()
+	}
 
+	// Totals across every namespace, independent of the page
+	costs, err := h.costs.ClusterCosts(ctx, cluster, time.Now().Add(-30*24*time.Hour), limit, offset)
 	if err != nil {
 		h.log.Errorf("Database error: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
 		return
 	}
-	defer rows.Close()
 
 	type NamespaceCost struct {
+		Cluster   string  `json:"cluster"`
 		Namespace string  `json:"namespace"`
 		Compute   float64 `json:"compute"`
 		Storage   float64 `json:"storage"`
 		Network   float64 `json:"network"`
 		Other     float64 `json:"other"`
 		Total     float64 `json:"total"`
+		ReconciliationFactor float64 `json:"reconciliation_factor"`
 	}
 
 	var namespaceCosts []NamespaceCost
-	var clusterTotal float64
 
-	for rows.Next() {
-		var cost NamespaceCost
-		err := rows.Scan(&cost.Namespace, &cost.Compute, &cost.Storage, 
-			&cost.Network, &cost.Other, &cost.Total)
-		if err != nil {
-			continue
-		}
-		namespaceCosts = append(namespaceCosts, cost)
-		clusterTotal += cost.Total
+	for _, cost := range costs.Namespaces {
+		namespaceCosts = append(namespaceCosts, NamespaceCost{
+			Cluster:              cost.Cluster,
+			Namespace:            cost.Namespace,
+			Compute:              cost.Compute,
+			Storage:              cost.Storage,
+			Network:              cost.Network,
+			Other:                cost.Other,
+			Total:                cost.Total,
+			ReconciliationFactor: reconciliationFactor(cost.Total, cost.Estimated),
+		})
 	}
 
 	response := map[string]interface{}{
-		"cluster_total": clusterTotal,
+		"cluster":       cluster,
+		"cluster_total": costs.Total,
 		"namespaces":    namespaceCosts,
 		"period":        "30d",
+		"total_count":   costs.Count,
+		"limit":         limit,
+		"offset":        offset,
+		"next_offset":   nextOffset(limit, offset, costs.Count),
+		"source":        "database",
+		"reconciliation_factor": reconciliationFactor(costs.Total, costs.Estimated),
+	}
+	if fallbackReason != "" {
+		response["fallback_reason"] = fallbackReason
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -292,37 +435,115 @@ func (h *Handler) GetClusterCosts(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetRecommendations(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	namespace := vars["namespace"]
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	opts, err := h.analysisOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	// Optionally show only candidates for moving to a QoS class
+	var qos string
+	switch strings.ToLower(r.URL.Query().Get("qos")) {
+	case "":
+	case "guaranteed":
+		qos = analyzer.QoSGuaranteed
+	case "burstable":
+		qos = analyzer.QoSBurstable
+	default:
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "qos must be guaranteed or burstable")
+		return
+	}
 
 	// Get recommendations from analyzer
-	recommendations, err := h.analyzer.AnalyzeNamespace(r.Context(), namespace)
+	recommendations, err := h.analyzer.AnalyzeNamespaceWithOptions(r.Context(), namespace, opts)
 	if err != nil {
 		h.log.Errorf("Analysis failed: %v", err)
-		http.Error(w, "Analysis failed", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Analysis failed")
+		return
+	}
+
+	// Reading recommendations stores nothing; the scheduled analysis does. Analyses with
+	// the default options carry the IDs of the stored ones to bulk-apply them by, while
+	// what-if analyses with overridden options have nothing stored to refer to.
+	if *opts == *h.analyzer.DefaultOptions() {
+		if err := h.analyzer.AttachStoredIDs(r.Context(), namespace, recommendations); err != nil {
+			h.log.Warnf("Failed to look up stored recommendations for %s: %v", namespace, err)
+		}
+	}
+
+	if qos != "" {
+		var candidates []analyzer.Recommendation
+		for _, rec := range recommendations {
+			if rec.IsQoSTransition(qos) {
+				candidates = append(candidates, rec)
+			}
+		}
+		recommendations = candidates
+	}
+
+	// Adopt recommendations through the VPA controller instead of raw patches
+	if r.URL.Query().Get("format") == "vpa" {
+		h.writeVPA(w, r, recommendations)
 		return
 	}
 
 	// Group recommendations by pod
 	podRecommendations := make(map[string][]analyzer.Recommendation)
-	totalSavings := 0.0
+	var podNames []string
+	totals := analyzer.TotalSavings(recommendations)
 
 	for _, rec := range recommendations {
+		if _, ok := podRecommendations[rec.PodName]; !ok {
+			podNames = append(podNames, rec.PodName)
+		}
 		podRecommendations[rec.PodName] = append(podRecommendations[rec.PodName], rec)
-		totalSavings += rec.PotentialSavings
+	}
+
+	// Paginate by pod so a pod's recommendations are never split across pages
+	sort.Strings(podNames)
+	totalCount := len(podNames)
+	if offset > totalCount {
+		offset = totalCount
+	}
+	end := offset + limit
+	if end > totalCount {
+		end = totalCount
+	}
+
+	pageRecommendations := make(map[string][]analyzer.Recommendation)
+	var pageList []analyzer.Recommendation
+	for _, pod := range podNames[offset:end] {
+		pageRecommendations[pod] = podRecommendations[pod]
+		pageList = append(pageList, podRecommendations[pod]...)
 	}
 
 	// Generate YAML patches for applying recommendations
-	patches := h.generateResourcePatches(recommendations)
+	patches := h.generateResourcePatches(pageList)
 
 	response := map[string]interface{}{
 		"namespace":         namespace,
-		"recommendations":   podRecommendations,
-		"total_savings":     totalSavings,
-		"annual_savings":    totalSavings * 12,
+		"recommendations":   pageRecommendations,
+		"total_savings":     totals.Savings,
+		"annual_savings":    totals.Savings * 12,
+		"initial_sizing_cost": totals.InitialSizingCost,
 		"patches":          patches,
 		"apply_command":    fmt.Sprintf("kubectl apply -f recommendations-%s.yaml", namespace),
 		"confidence_score": h.calculateOverallConfidence(recommendations),
+		"total_count":      totalCount,
+		"limit":            limit,
+		"offset":           offset,
+		"next_offset":      nextOffset(limit, offset, totalCount),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -338,15 +559,15 @@ func (h *Handler) ApplyRecommendation(w http.ResponseWriter, r *http.Request) {
 		Action        string `json:"action"` // "apply", "reject", "modify"
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Get the specific recommendation
 	recommendations, err := h.analyzer.AnalyzeNamespace(r.Context(), request.Namespace)
 	if err != nil {
-		http.Error(w, "Failed to get recommendations", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Failed to get recommendations")
 		return
 	}
 
@@ -361,27 +582,29 @@ func (h *Handler) ApplyRecommendation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if targetRecommendation == nil {
-		http.Error(w, "Recommendation not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, errCodeNotFound, "Recommendation not found")
 		return
 	}
 
-	// Save recommendation action
-	_, err = h.db.Exec(`
-		INSERT INTO recommendation_actions 
-		(namespace, pod_name, container_name, resource_type, action, applied_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, request.Namespace, request.PodName, request.ContainerName, 
-		request.ResourceType, request.Action, time.Now())
-
-	if err != nil {
-		h.log.Errorf("Failed to save recommendation action: %v", err)
+	var change *kubernetes.ResourceChange
+	if request.Action == "apply" {
+		change, err = h.applyRecommendation(r.Context(), targetRecommendation, false)
+		if err != nil {
+			h.log.Errorf("Failed to apply recommendation: %v", err)
+			writeError(w, http.StatusInternalServerError, errCodeKubernetes, "Failed to apply recommendation")
+			return
+		}
 	}
 
+	// Save recommendation action
+	h.recordAction(r.Context(), targetRecommendation, request.Action, change)
+
 	response := map[string]interface{}{
 		"status": "success",
 		"action": request.Action,
 		"message": fmt.Sprintf("Recommendation %s for %s/%s/%s", 
 			request.Action, request.Namespace, request.PodName, request.ContainerName),
+		"change": change,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -393,70 +616,190 @@ func (h *Handler) BulkApplyRecommendations(w http.ResponseWriter, r *http.Reques
 		Namespace      string   `json:"namespace"`
 		RecommendationIDs []string `json:"recommendation_ids"`
 		Action         string   `json:"action"`
+		DryRun         bool     `json:"dry_run"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
 		return
 	}
 
+	type itemResult struct {
+		ID     string                     `json:"id"`
+		Status string                     `json:"status"` // "applied", "validated" or "failed"
+		Error  string                     `json:"error,omitempty"`
+		Change *kubernetes.ResourceChange `json:"change,omitempty"`
+	}
+
 	// Apply multiple recommendations
 	appliedCount := 0
 	failedCount := 0
+	results := make([]itemResult, 0, len(request.RecommendationIDs))
 
 	for _, id := range request.RecommendationIDs {
-		// Parse recommendation ID and apply
-		// This is a simplified implementation
+		result := itemResult{ID: id}
+
+		recID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = "invalid recommendation ID"
+			results = append(results, result)
+			failedCount++
+			continue
+		}
+
+		rec, err := h.analyzer.GetRecommendation(r.Context(), recID)
+		if err == nil && request.Namespace != "" && rec.Namespace != request.Namespace {
+			err = fmt.Errorf("recommendation %d is not in namespace %s", recID, request.Namespace)
+		}
+		if err == nil {
+			result.Change, err = h.applyRecommendation(r.Context(), rec, request.DryRun)
+		}
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+			results = append(results, result)
+			failedCount++
+			continue
+		}
+
+		if request.DryRun {
+			result.Status = "validated"
+		} else {
+			result.Status = "applied"
+			h.recordAction(r.Context(), rec, "apply", result.Change)
+		}
+		results = append(results, result)
 		appliedCount++
 	}
 
+	status := "success"
+	if failedCount > 0 {
+		status = "partial"
+	}
+
+	verb := "Applied"
+	if request.DryRun {
+		verb = "Validated"
+	}
+
 	response := map[string]interface{}{
-		"status": "success",
+		"status": status,
+		"dry_run": request.DryRun,
 		"applied": appliedCount,
 		"failed": failedCount,
-		"message": fmt.Sprintf("Applied %d recommendations, %d failed", appliedCount, failedCount),
+		"results": results,
+		"message": fmt.Sprintf("%s %d recommendations, %d failed", verb, appliedCount, failedCount),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// applyRecommendation updates the owning workload with the recommended request and limit.
+// Outside dry runs it also marks stored recommendations applied and emits an event.
+func (h *Handler) applyRecommendation(ctx context.Context, rec *analyzer.Recommendation, dryRun bool) (*kubernetes.ResourceChange, error) {
+	change, err := kubernetes.ApplyContainerResources(ctx, h.k8sClient, rec.Namespace, rec.PodName,
+		rec.ContainerName, rec.ResourceType, rec.RecommendedRequest, rec.RecommendedLimit, dryRun)
+	if err != nil || dryRun {
+		return change, err
+	}
+
+	if rec.ID != 0 {
+		if err := h.analyzer.MarkApplied(ctx, rec.ID); err != nil {
+			h.log.Warnf("Failed to mark recommendation %d applied: %v", rec.ID, err)
+		}
+	}
+
+	// Surface the applied change in the cluster's event stream
+	if err := h.events.RecommendationApplied(ctx, rec.Namespace, rec.PodName, rec.ContainerName,
+		rec.ResourceType, rec.CurrentRequest, rec.RecommendedRequest, rec.PotentialSavings); err != nil {
+		h.log.Warnf("Failed to emit recommendation event: %v", err)
+	}
+
+	return change, nil
+}
+
+// recordAction saves an action taken on a recommendation for auditing. For applied
+// changes it also keeps the workload changed and the values applied, which the outcome
+// evaluator later checks usage against.
+func (h *Handler) recordAction(ctx context.Context, rec *analyzer.Recommendation, action string, change *kubernetes.ResourceChange) {
+	record := store.RecommendationAction{
+		RecommendationID:   rec.ID,
+		Namespace:          rec.Namespace,
+		PodName:            rec.PodName,
+		ContainerName:      rec.ContainerName,
+		ResourceType:       rec.ResourceType,
+		Action:             action,
+		TakenAt:            time.Now(),
+		RecommendedRequest: rec.RecommendedRequest,
+		RecommendedLimit:   rec.RecommendedLimit,
+	}
+	if change != nil {
+		record.OwnerKind = change.Kind
+		record.OwnerName = change.Name
+	}
+
+	if err := h.recommendations.RecordAction(ctx, record); err != nil {
+		h.log.Errorf("Failed to save recommendation action: %v", err)
+	}
+}
+
 func (h *Handler) SimulateCosts(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Namespace string `json:"namespace"`
-		Changes   []struct {
-			PodName       string  `json:"pod_name"`
-			ContainerName string  `json:"container_name"`
-			CPURequest    float64 `json:"cpu_request"`
-			CPULimit      float64 `json:"cpu_limit"`
-			MemoryRequest float64 `json:"memory_request"`
-			MemoryLimit   float64 `json:"memory_limit"`
-			Replicas      int     `json:"replicas"`
-		} `json:"changes"`
-		Period string `json:"period"` // "daily", "monthly", "yearly"
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		Namespace string            `json:"namespace"`
+		Cluster   string            `json:"cluster"` // Empty takes the latest resources from any cluster
+		Changes   []simulatedChange `json:"changes"`
+		Period    string            `json:"period"` // "daily", "monthly", "yearly"
+
+		// Share of the headroom between requests and higher limits that is billed, for
+		// clouds that charge for bursting above requests. 0 prices requests only.
+		BurstFactor float64 `json:"burst_factor"`
+	}
+
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
 		return
 	}
+	if request.BurstFactor < 0 || request.BurstFactor > 1 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+			fmt.Sprintf("burst_factor must be between 0 and 1, got %v", request.BurstFactor))
+		return
+	}
+	for _, change := range request.Changes {
+		if err := change.validate(); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+			return
+		}
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
 
 	// Get current costs
-	currentCosts := h.getCurrentCosts(request.Namespace)
+	currentCosts := h.getCurrentCosts(ctx, request.Namespace)
+
+	prices := h.analyzer.Prices(ctx)
+	replicas := h.replicaCounts(ctx, request.Namespace, request.Cluster)
 
-	// Calculate new costs based on changes
-	newCosts := currentCosts
+	// Price each change's replicas before and after it, per hour
+	changes := make([]changeCost, 0, len(request.Changes))
 	costDelta := 0.0
 
 	for _, change := range request.Changes {
-		// Get current resource allocation
-		current := h.getCurrentAllocation(request.Namespace, change.PodName, change.ContainerName)
-
-		// Calculate cost difference
-		cpuDelta := (change.CPURequest - current["cpu_request"]) * 0.00001 * float64(change.Replicas)
-		memoryDelta := (change.MemoryRequest - current["memory_request"]) * 0.00000001 * float64(change.Replicas)
+		// A container without collected resources is simulated as added by the change
+		current := store.ContainerResources{}
+		if stored, err := h.metrics.CurrentResources(ctx, request.Namespace, request.Cluster, change.PodName, change.ContainerName); err == nil {
+			current = *stored
+		}
+		currentReplicas := replicas[change.PodName]
+		if currentReplicas == 0 {
+			currentReplicas = 1
+		}
 
-		costDelta += cpuDelta + memoryDelta
+		cost := simulateChange(change, current, currentReplicas, prices, request.BurstFactor)
+		costDelta += cost.CostDifference
+		changes = append(changes, cost)
 	}
 
 	// Apply period multiplier
@@ -472,21 +815,35 @@ func (h *Handler) SimulateCosts(w http.ResponseWriter, r *http.Request) {
 		multiplier = 24 * 30
 	}
 
+	for i := range changes {
+		changes[i] = changes[i].scale(multiplier)
+	}
+
 	projectedCost := (currentCosts + costDelta) * multiplier
 	savings := currentCosts*multiplier - projectedCost
 
+	// Split the projection like the namespace's costs over the last 30 days
+	now := time.Now()
+	shares := costShares(h.getResourceBreakdown(ctx, request.Cluster, request.Namespace, now.AddDate(0, 0, -30), now))
+	breakdown := make(map[string]float64, len(shares))
+	for component, share := range shares {
+		breakdown[component] = projectedCost * share
+	}
+
+	// Without recent cost data there is no baseline to express savings against
+	savingsPercent := 0.0
+	if currentCosts > 0 {
+		savingsPercent = (savings / (currentCosts * multiplier)) * 100
+	}
+
 	response := map[string]interface{}{
 		"current_cost":    currentCosts * multiplier,
 		"projected_cost":  projectedCost,
 		"cost_difference": costDelta * multiplier,
 		"savings":         savings,
-		"savings_percent": (savings / (currentCosts * multiplier)) * 100,
-		"breakdown": map[string]float64{
-			"compute": projectedCost * 0.6,  // Rough estimates
-			"storage": projectedCost * 0.2,
-			"network": projectedCost * 0.15,
-			"other":   projectedCost * 0.05,
-		},
+		"savings_percent": savingsPercent,
+		"breakdown": breakdown,
+		"changes":         changes,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -496,22 +853,38 @@ func (h *Handler) SimulateCosts(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) ExportReport(w http.ResponseWriter, r *http.Request) {
 	namespace := r.URL.Query().Get("namespace")
 	format := r.URL.Query().Get("format") // "csv", "pdf", "xlsx"
+	if namespace != "" {
+		if err := validateNamespace(namespace); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+			return
+		}
+	}
 
 	// Generate comprehensive report
-	report := h.generateComprehensiveReport(namespace)
+	report, err := h.generateComprehensiveReport(r.Context(), namespace)
+	if err != nil {
+		h.log.Errorf("Failed to generate report: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeInternal, "Failed to generate report")
+		return
+	}
+
+	filename := namespace
+	if filename == "" {
+		filename = "cluster"
+	}
 
 	switch format {
 	case "csv":
 		w.Header().Set("Content-Type", "text/csv")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=cost-report-%s.csv", namespace))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=cost-report-%s.csv", filename))
 		h.exportCSV(w, report)
 	case "pdf":
 		w.Header().Set("Content-Type", "application/pdf")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=cost-report-%s.pdf", namespace))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=cost-report-%s.pdf", filename))
 		h.exportPDF(w, report)
 	case "xlsx":
 		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=cost-report-%s.xlsx", namespace))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=cost-report-%s.xlsx", filename))
 		h.exportExcel(w, report)
 	default:
 		json.NewEncoder(w).Encode(report)
@@ -519,41 +892,26 @@ func (h *Handler) ExportReport(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetResourceUsage(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	namespace := vars["namespace"]
-
-	// Get current resource usage
-	rows, err := h.db.Query(`
-		SELECT 
-			pm.pod_name,
-			pm.container_name,
-			AVG(pm.cpu_millicores) as avg_cpu,
-			MAX(pm.cpu_millicores) as max_cpu,
-			AVG(pm.memory_bytes) as avg_memory,
-			MAX(pm.memory_bytes) as max_memory,
-			rr.cpu_request,
-			rr.cpu_limit,
-			rr.memory_request,
-			rr.memory_limit
-		FROM pod_metrics pm
-		LEFT JOIN resource_requests rr ON 
-			pm.namespace = rr.namespace AND 
-			pm.pod_name = rr.pod_name AND 
-			pm.container_name = rr.container_name
-		WHERE pm.namespace = $1 
-			AND pm.timestamp > NOW() - INTERVAL '1 hour'
-		GROUP BY pm.pod_name, pm.container_name, 
-			rr.cpu_request, rr.cpu_limit, rr.memory_request, rr.memory_limit
-	`, namespace)
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	cluster := r.URL.Query().Get("cluster")
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
 
+	// Get current resource usage, against each container's latest requests and limits
+	stored, err := h.metrics.ResourceUsage(ctx, namespace, cluster, time.Now().Add(-time.Hour))
 	if err != nil {
 		h.log.Errorf("Database error: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
 		return
 	}
-	defer rows.Close()
 
 	type ResourceUsage struct {
+		Cluster        string  `json:"cluster"`
 		PodName        string  `json:"pod_name"`
 		ContainerName  string  `json:"container_name"`
 		AvgCPU         float64 `json:"avg_cpu"`
@@ -570,13 +928,19 @@ func (h *Handler) GetResourceUsage(w http.ResponseWriter, r *http.Request) {
 
 	var usage []ResourceUsage
 
-	for rows.Next() {
-		var res ResourceUsage
-		err := rows.Scan(&res.PodName, &res.ContainerName, 
-			&res.AvgCPU, &res.MaxCPU, &res.AvgMemory, &res.MaxMemory,
-			&res.CPURequest, &res.CPULimit, &res.MemoryRequest, &res.MemoryLimit)
-		if err != nil {
-			continue
+	for _, u := range stored {
+		res := ResourceUsage{
+			Cluster:       u.Cluster,
+			PodName:       u.PodName,
+			ContainerName: u.ContainerName,
+			AvgCPU:        u.AvgCPU,
+			MaxCPU:        u.MaxCPU,
+			AvgMemory:     u.AvgMemory,
+			MaxMemory:     u.MaxMemory,
+			CPURequest:    u.CPURequest,
+			CPULimit:      u.CPULimit,
+			MemoryRequest: u.MemoryRequest,
+			MemoryLimit:   u.MemoryLimit,
 		}
 
 		// Calculate utilization percentages
@@ -591,6 +955,7 @@ func (h *Handler) GetResourceUsage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := map[string]interface{}{
+		"cluster":   cluster,
 		"namespace": namespace,
 		"usage":     usage,
 		"timestamp": time.Now().UTC(),
@@ -602,20 +967,11 @@ func (h *Handler) GetResourceUsage(w http.ResponseWriter, r *http.Request) {
 
 // Helper methods
 
-func (h *Handler) getResourceBreakdown(namespace string, startTime, endTime time.Time) map[string]float64 {
+// getResourceBreakdown sums the namespace's costs by resource type. An empty cluster
+// sums them across all clusters.
+func (h *Handler) getResourceBreakdown(ctx context.Context, cluster, namespace string, startTime, endTime time.Time) map[string]float64 {
 	// Get cost breakdown by resource type
-	var compute, storage, network, other float64
-
-	err := h.db.QueryRow(`
-		SELECT 
-			SUM(compute_cost) as compute,
-			SUM(storage_cost) as storage,
-			SUM(network_cost) as network,
-			SUM(other_cost) as other
-		FROM namespace_costs
-		WHERE namespace = $1 AND timestamp BETWEEN $2 AND $3
-	`, namespace, startTime, endTime).Scan(&compute, &storage, &network, &other)
-
+	breakdown, err := h.costs.CostBreakdown(ctx, cluster, namespace, startTime, endTime)
 	if err != nil {
 		h.log.Warnf("Failed to get resource breakdown: %v", err)
 		return map[string]float64{
@@ -627,11 +983,53 @@ func (h *Handler) getResourceBreakdown(namespace string, startTime, endTime time
 	}
 
 	return map[string]float64{
-		"compute": compute,
-		"storage": storage,
-		"network": network,
-		"other":   other,
+		"compute": breakdown.Compute,
+		"storage": breakdown.Storage,
+		"network": breakdown.Network,
+		"other":   breakdown.Other,
+	}
+}
+
+// analysisOptions overrides the analyzer defaults with the cpu_safety_margin,
+// memory_safety_margin, waste_threshold, confidence_threshold, percentile and
+// seasonality_aware query parameters
+func (h *Handler) analysisOptions(r *http.Request) (*analyzer.AnalysisOptions, error) {
+	opts := h.analyzer.DefaultOptions()
+
+	params := map[string]*float64{
+		"cpu_safety_margin":    &opts.CPUSafetyMargin,
+		"memory_safety_margin": &opts.MemorySafetyMargin,
+		"waste_threshold":      &opts.WasteThreshold,
+		"confidence_threshold": &opts.ConfidenceThreshold,
+		"percentile":           &opts.Percentile,
+		"min_savings":          &opts.MinSavings,
+		"min_confidence":       &opts.MinConfidence,
+	}
+
+	for name, target := range params {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", name)
+		}
+		*target = parsed
+	}
+	opts.Cluster = r.URL.Query().Get("cluster")
+	if value := r.URL.Query().Get("seasonality_aware"); value != "" {
+		seasonal, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seasonality_aware")
+		}
+		opts.SeasonalityAware = seasonal
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
 	}
+	return opts, nil
 }
 
 func (h *Handler) generateResourcePatches(recommendations []analyzer.Recommendation) []string {
@@ -662,12 +1060,21 @@ spec:
 	return patches
 }
 
+// formatResourceValue renders a recommendation value as a Kubernetes quantity the way
+// kubectl prints it, e.g. 1500m, 2 (cores), 384Mi or 2Gi. Values are rounded up, memory
+// to a whole MiB, so a patch never requests less than recommended.
 func (h *Handler) formatResourceValue(resourceType string, value float64) string {
-	if resourceType == "CPU" {
-		return fmt.Sprintf("%dm", int(value))
+	if resourceType == "Memory" {
+		value = math.Ceil(value/(1024*1024)) * 1024 * 1024
 	} else {
-		return fmt.Sprintf("%dMi", int(value/1024/1024))
+		value = math.Ceil(value)
 	}
+
+	_, quantity, err := kubernetes.ResourceQuantity(resourceType, value)
+	if err != nil {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return quantity.String()
 }
 
 func (h *Handler) calculateOverallConfidence(recommendations []analyzer.Recommendation) float64 {
@@ -683,67 +1090,34 @@ func (h *Handler) calculateOverallConfidence(recommendations []analyzer.Recommen
 	return totalConfidence / float64(len(recommendations))
 }
 
-func (h *Handler) getCurrentCosts(namespace string) float64 {
-	var totalCost float64
-	err := h.db.QueryRow(`
-		SELECT SUM(compute_cost + storage_cost + network_cost + other_cost)
-		FROM namespace_costs
-		WHERE namespace = $1 AND timestamp > NOW() - INTERVAL '1 hour'
-	`, namespace).Scan(&totalCost)
-
-	if err != nil {
-		return 0
+// costShares converts a cost breakdown into each component's fraction of the total.
+// Without any costs everything is attributed to compute, the only component that
+// resource changes affect.
+func costShares(breakdown map[string]float64) map[string]float64 {
+	total := 0.0
+	for _, cost := range breakdown {
+		total += cost
 	}
-	return totalCost
-}
 
-func (h *Handler) getCurrentAllocation(namespace, podName, containerName string) map[string]float64 {
-	var cpuRequest, cpuLimit, memoryRequest, memoryLimit float64
-
-	err := h.db.QueryRow(`
-		SELECT cpu_request, cpu_limit, memory_request, memory_limit
-		FROM resource_requests
-		WHERE namespace = $1 AND pod_name = $2 AND container_name = $3
-		ORDER BY timestamp DESC LIMIT 1
-	`, namespace, podName, containerName).Scan(&cpuRequest, &cpuLimit, &memoryRequest, &memoryLimit)
-
-	if err != nil {
-		return map[string]float64{
-			"cpu_request":    0,
-			"cpu_limit":      0,
-			"memory_request": 0,
-			"memory_limit":   0,
-		}
+	shares := map[string]float64{"compute": 1, "storage": 0, "network": 0, "other": 0}
+	if total <= 0 {
+		return shares
 	}
-
-	return map[string]float64{
-		"cpu_request":    cpuRequest,
-		"cpu_limit":      cpuLimit,
-		"memory_request": memoryRequest,
-		"memory_limit":   memoryLimit,
+	for component := range shares {
+		shares[component] = breakdown[component] / total
 	}
+	return shares
 }
 
-func (h *Handler) generateComprehensiveReport(namespace string) map[string]interface{} {
-	// This would generate a comprehensive report with costs, recommendations, trends, etc.
-	return map[string]interface{}{
-		"namespace": namespace,
-		"generated_at": time.Now().UTC(),
-		"summary": "Comprehensive cost optimization report",
+func (h *Handler) getCurrentCosts(ctx context.Context, namespace string) float64 {
+	totalCost, err := h.costs.CurrentCost(ctx, namespace, time.Now().Add(-time.Hour))
+	if err != nil {
+		return 0
 	}
+	return totalCost
 }
 
-func (h *Handler) exportCSV(w http.ResponseWriter, report map[string]interface{}) {
-	// CSV export implementation
-	w.Write([]byte("Namespace,Cost,Date\n"))
-}
-
-func (h *Handler) exportPDF(w http.ResponseWriter, report map[string]interface{}) {
-	// PDF export implementation
-	w.Write([]byte("PDF report would be generated here"))
-}
-
-func (h *Handler) exportExcel(w http.ResponseWriter, report map[string]interface{}) {
+func (h *Handler) exportExcel(w http.ResponseWriter, report *Report) {
 	// Excel export implementation
 	w.Write([]byte("Excel report would be generated here"))
 } 
\ No newline at end of file
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Collection intervals assumed when the server hasn't set them
+const (
+	defaultMetricsInterval = 5 * time.Minute
+	defaultCostInterval    = time.Hour
+)
+
+// SetCollectionIntervals sets how often metrics and costs are collected, which
+// HealthDetailed uses to decide whether collection has stalled
+func (h *Handler) SetCollectionIntervals(metrics, costs time.Duration) error {
+	if metrics <= 0 || costs <= 0 {
+		return fmt.Errorf("collection intervals must be positive, got %v and %v", metrics, costs)
+	}
+	h.metricsInterval = metrics
+	h.costInterval = costs
+	return nil
+}
+
+// collectorFreshness reports when a collector last stored data
+type collectorFreshness struct {
+	LastCollected *time.Time `json:"last_collected"`
+	AgeSeconds    *float64   `json:"age_seconds"`
+	Interval      string     `json:"interval"`
+	Stale         bool       `json:"stale"`
+}
+
+// HealthDetailed reports when pod metrics and namespace costs were last collected for
+// this cluster. The service is degraded when either is older than twice its
+// collection interval, or was never collected, which is how a collection loop that
+// died without taking the server down shows up. Like /ready it returns 503 only when
+// the database is unreachable.
+func (h *Handler) HealthDetailed(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	metricsInterval, costInterval := h.metricsInterval, h.costInterval
+	if metricsInterval <= 0 {
+		metricsInterval = defaultMetricsInterval
+	}
+	if costInterval <= 0 {
+		costInterval = defaultCostInterval
+	}
+
+	cluster := h.collector.ClusterName()
+	now := time.Now()
+	response := map[string]interface{}{
+		"status":  "healthy",
+		"time":    now.UTC(),
+		"cluster": cluster,
+	}
+
+	collectors := make(map[string]*collectorFreshness)
+	for name, source := range map[string]struct {
+		latest   func(ctx context.Context, cluster string) (*time.Time, error)
+		interval time.Duration
+	}{
+		"pod_metrics":     {h.metrics.LastUsageTime, metricsInterval},
+		"namespace_costs": {h.costs.LastCostTime, costInterval},
+	} {
+		latest, err := source.latest(ctx, cluster)
+		if err != nil {
+			h.log.Errorf("Failed to read %s freshness: %v", name, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "unhealthy",
+				"time":   now.UTC(),
+				"error":  "database query failed",
+			})
+			return
+		}
+
+		freshness := &collectorFreshness{Interval: source.interval.String(), Stale: true}
+		if latest != nil {
+			age := now.Sub(*latest).Seconds()
+			freshness.LastCollected = latest
+			freshness.AgeSeconds = &age
+			freshness.Stale = now.Sub(*latest) > 2*source.interval
+		}
+		if freshness.Stale {
+			response["status"] = "degraded"
+		}
+		collectors[name] = freshness
+	}
+	response["collectors"] = collectors
+
+	// Each Prometheus endpoint's breaker. Open breakers mean metrics collection is
+	// being skipped or missing some shards.
+	prometheus := h.collector.PrometheusStatus()
+	for _, endpoint := range prometheus {
+		if endpoint.State != "closed" {
+			response["status"] = "degraded"
+		}
+	}
+	response["prometheus"] = prometheus
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
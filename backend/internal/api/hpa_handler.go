@@ -0,0 +1,30 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetHorizontalRecommendations returns HPA recommendations for the namespace's Deployments
+func (h *Handler) GetHorizontalRecommendations(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	recommendations, err := h.analyzer.AnalyzeHorizontalScaling(r.Context(), namespace)
+	if err != nil {
+		h.log.Errorf("Horizontal scaling analysis failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Analysis failed")
+		return
+	}
+
+	response := map[string]interface{}{
+		"namespace":       namespace,
+		"recommendations": recommendations,
+		"count":           len(recommendations),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
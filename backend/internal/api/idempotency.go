@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultIdempotencyTTL is how long a processed Idempotency-Key's response is kept
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyLockTTL bounds how long a key stays reserved by a request that never
+// finished, e.g. because the server restarted while processing it
+const idempotencyLockTTL = 5 * time.Minute
+
+// maxIdempotencyKeyLength limits the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// idempotencyRecord is stored in Redis per key. A zero Status marks a request that is
+// still being processed.
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// SetIdempotencyTTL sets how long responses to requests with an Idempotency-Key are
+// replayed
+func (h *Handler) SetIdempotencyTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("idempotency TTL must be positive, got %v", ttl)
+	}
+	h.idempotencyTTL = ttl
+	return nil
+}
+
+// Idempotent makes a mutating handler safe to retry. A request with an
+// Idempotency-Key header is processed once per caller and key; retries get the
+// original response back with an Idempotent-Replayed header instead of being processed
+// again. Reusing a key for a different request is rejected with a 422, and a retry
+// while the first request is still running with a 409. Server errors aren't stored,
+// so those requests can be retried. Requests without the header, or made while Redis
+// is unavailable, are processed as usual; Redis calls go through the cache manager's
+// breaker, so that doesn't mean waiting out its timeout on every request.
+func (h *Handler) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+				fmt.Sprintf("Idempotency-Key must not exceed %d characters", maxIdempotencyKeyLength))
+			return
+		}
+
+		// The body is read here to fingerprint the request, then handed on unchanged
+		limit := h.maxBodyBytes
+		if limit <= 0 {
+			limit = DefaultMaxBodyBytes
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(w, http.StatusBadRequest, errCodeInvalidRequest,
+					fmt.Sprintf("request body must not exceed %d bytes", limit))
+				return
+			}
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		record := idempotencyRecord{Fingerprint: hex.EncodeToString(fingerprint[:])}
+
+		subject := ""
+		if principal, ok := PrincipalFromContext(r.Context()); ok {
+			subject = principal.Subject
+		}
+		cacheKey := fmt.Sprintf("idempotency:%s:%s:%s", subject, r.URL.Path, key)
+
+		// Outlive the request, so a client that disconnects still gets its key released
+		// or its response stored
+		ctx := context.WithoutCancel(r.Context())
+
+		pending, _ := json.Marshal(record)
+		var reserved bool
+		err = h.cacheManager.Redis(ctx, func(client *redis.Client) error {
+			var err error
+			reserved, err = client.SetNX(ctx, cacheKey, pending, idempotencyLockTTL).Result()
+			return err
+		})
+		if err != nil {
+			h.log.Warnf("Failed to reserve Idempotency-Key, processing without it: %v", err)
+			next(w, r)
+			return
+		}
+
+		if !reserved {
+			h.replayIdempotent(ctx, w, cacheKey, record.Fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			if err := h.cacheManager.Redis(ctx, func(client *redis.Client) error {
+				return client.Del(ctx, cacheKey).Err()
+			}); err != nil {
+				h.log.Warnf("Failed to release Idempotency-Key: %v", err)
+			}
+			return
+		}
+
+		record.Status = recorder.status
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Body = recorder.body.Bytes()
+		data, _ := json.Marshal(record)
+
+		ttl := h.idempotencyTTL
+		if ttl <= 0 {
+			ttl = DefaultIdempotencyTTL
+		}
+		if err := h.cacheManager.Redis(ctx, func(client *redis.Client) error {
+			return client.Set(ctx, cacheKey, data, ttl).Err()
+		}); err != nil {
+			h.log.Warnf("Failed to store response for Idempotency-Key: %v", err)
+		}
+	}
+}
+
+// replayIdempotent answers a request whose key is already reserved with the stored
+// response, or with an error if the key was used for a different request or its first
+// request hasn't finished
+func (h *Handler) replayIdempotent(ctx context.Context, w http.ResponseWriter, cacheKey, fingerprint string) {
+	var data []byte
+	err := h.cacheManager.Redis(ctx, func(client *redis.Client) error {
+		var err error
+		data, err = client.Get(ctx, cacheKey).Bytes()
+		return err
+	})
+	if err != nil {
+		// Released by a failed first request in the meantime
+		writeError(w, http.StatusConflict, errCodeIdempotencyInUse,
+			"A request with this Idempotency-Key is being processed; retry shortly")
+		return
+	}
+
+	var stored idempotencyRecord
+	if err := json.Unmarshal(data, &stored); err != nil {
+		h.log.Errorf("Invalid stored Idempotency-Key record: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeInternal, "Failed to replay request")
+		return
+	}
+
+	switch {
+	case stored.Fingerprint != fingerprint:
+		writeError(w, http.StatusUnprocessableEntity, errCodeIdempotencyReused,
+			"Idempotency-Key was already used for a different request")
+	case stored.Status == 0:
+		writeError(w, http.StatusConflict, errCodeIdempotencyInUse,
+			"A request with this Idempotency-Key is being processed; retry shortly")
+	default:
+		if stored.ContentType != "" {
+			w.Header().Set("Content-Type", stored.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.Status)
+		w.Write(stored.Body)
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and
+// body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+)
+
+// GetIdleWorkloads returns the namespace's idle pods and their estimated monthly waste.
+// The optional window query parameter (e.g. 168h) overrides the configured idle window.
+func (h *Handler) GetIdleWorkloads(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	var idle []analyzer.IdleWorkload
+	var err error
+
+	if window := r.URL.Query().Get("window"); window != "" {
+		duration, parseErr := time.ParseDuration(window)
+		if parseErr != nil || duration < time.Hour {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "Invalid window")
+			return
+		}
+		idle, err = h.analyzer.AnalyzeIdleWorkloadsWithWindow(r.Context(), namespace, duration)
+	} else {
+		idle, err = h.analyzer.AnalyzeIdleWorkloads(r.Context(), namespace)
+	}
+
+	if err != nil {
+		h.log.Errorf("Idle workload analysis failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Analysis failed")
+		return
+	}
+
+	var totalWaste float64
+	for _, workload := range idle {
+		totalWaste += workload.MonthlyWaste
+	}
+
+	response := map[string]interface{}{
+		"namespace":           namespace,
+		"idle":                idle,
+		"count":               len(idle),
+		"total_monthly_waste": totalWaste,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s-cost-optimizer/internal/collectors"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// unlabeledValue groups the costs of workloads whose pods lack the requested label
+const unlabeledValue = "unlabeled"
+
+// GetCostsByLabel reports costs over the period grouped by the value of a pod label,
+// e.g. /costs/by-label?key=team&period=30d, for showback and chargeback. Compute cost
+// comes from workload_costs, keyed by each workload's pod labels. Each namespace's
+// storage, network and other costs from namespace_costs are split between its label
+// values in proportion to their compute cost, so the groups add up to namespace spend.
+// Idle node cost belongs to no workload and is reported separately.
+func (h *Handler) GetCostsByLabel(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "key is required")
+		return
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "Invalid label key: "+strings.Join(errs, "; "))
+		return
+	}
+	cluster := r.URL.Query().Get("cluster")
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "30d"
+	}
+	var window time.Duration
+	switch period {
+	case "24h":
+		window = 24 * time.Hour
+	case "7d":
+		window = 7 * 24 * time.Hour
+	case "30d":
+		window = 30 * 24 * time.Hour
+	default:
+		writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, "Invalid period")
+		return
+	}
+	startTime := time.Now().Add(-window)
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	rows, err := h.db.QueryContext(ctx, `
+		WITH labelled AS (
+			SELECT
+				wc.cluster,
+				wc.namespace,
+				COALESCE(wl.labels->>$1, $2) as value,
+				SUM(wc.cpu_cost + wc.memory_cost) as compute
+			FROM workload_costs wc
+			LEFT JOIN workload_labels wl
+				ON wl.cluster = wc.cluster AND wl.namespace = wc.namespace
+				AND wl.workload_kind = wc.workload_kind AND wl.workload = wc.workload
+			WHERE wc.timestamp >= $3 AND wc.namespace <> $4 AND ($5 = '' OR wc.cluster = $5)
+			GROUP BY wc.cluster, wc.namespace, value
+		),
+		shares AS (
+			SELECT
+				cluster, namespace, value, compute,
+				compute / NULLIF(SUM(compute) OVER (PARTITION BY cluster, namespace), 0) as share
+			FROM labelled
+		),
+		other AS (
+			SELECT cluster, namespace, SUM(storage_cost + network_cost + other_cost)::DOUBLE PRECISION as other
+			FROM namespace_costs
+			WHERE timestamp >= $3 AND ($5 = '' OR cluster = $5)
+			GROUP BY cluster, namespace
+		)
+		SELECT
+			s.value,
+			SUM(s.compute) as compute,
+			SUM(COALESCE(o.other, 0) * COALESCE(s.share, 0)) as other,
+			COUNT(DISTINCT s.namespace) as namespaces
+		FROM shares s
+		LEFT JOIN other o ON o.cluster = s.cluster AND o.namespace = s.namespace
+		GROUP BY s.value
+		ORDER BY SUM(s.compute) + SUM(COALESCE(o.other, 0) * COALESCE(s.share, 0)) DESC
+	`, key, unlabeledValue, startTime, collectors.IdleNamespace, cluster)
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	type LabelCost struct {
+		Value      string  `json:"value"`
+		Compute    float64 `json:"compute"`
+		Other      float64 `json:"other"` // Storage, network and other costs
+		Total      float64 `json:"total"`
+		Namespaces int     `json:"namespaces"`
+	}
+
+	groups := []LabelCost{}
+	total := 0.0
+	for rows.Next() {
+		var cost LabelCost
+		if err := rows.Scan(&cost.Value, &cost.Compute, &cost.Other, &cost.Namespaces); err != nil {
+			h.log.Errorf("Failed to scan label cost: %v", err)
+			continue
+		}
+		cost.Total = cost.Compute + cost.Other
+		total += cost.Total
+		groups = append(groups, cost)
+	}
+
+	var idle float64
+	err = h.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(cpu_cost + memory_cost), 0)
+		FROM workload_costs
+		WHERE namespace = $1 AND timestamp >= $2 AND ($3 = '' OR cluster = $3)
+	`, collectors.IdleNamespace, startTime, cluster).Scan(&idle)
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	response := map[string]interface{}{
+		"key":     key,
+		"cluster": cluster,
+		"period":  period,
+		"groups":  groups,
+		"total":   total,
+		"idle":    idle,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateNamespace checks that the name is a legal namespace name, a DNS-1123 label,
+// so typos are rejected instead of returning an empty result that looks like zero cost
+func validateNamespace(namespace string) error {
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// namespaceParam returns the validated namespace path parameter. On an invalid name it
+// writes a 400 and returns false. Namespaces that no longer exist are accepted, since
+// their cost history is still stored.
+func namespaceParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	namespace := mux.Vars(r)["namespace"]
+	if err := validateNamespace(namespace); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+		return "", false
+	}
+	return namespace, true
+}
+
+// liveNamespaceParam is namespaceParam for handlers that inspect the namespace's
+// current workloads, additionally writing a 404 if it doesn't exist in the cluster.
+// Requests for another cluster's data are only validated, as are lookups that fail
+// for reasons other than the namespace being missing.
+func (h *Handler) liveNamespaceParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
+		return "", false
+	}
+
+	if cluster := r.URL.Query().Get("cluster"); cluster != "" && cluster != h.collector.ClusterName() {
+		return namespace, true
+	}
+
+	_, err := h.k8sClient.CoreV1().Namespaces().Get(r.Context(), namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		writeError(w, http.StatusNotFound, errCodeNamespaceNotFound, fmt.Sprintf("Namespace %s not found", namespace))
+		return "", false
+	}
+	if err != nil {
+		h.log.Warnf("Failed to look up namespace %s: %v", namespace, err)
+	}
+	return namespace, true
+}
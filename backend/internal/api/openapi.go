@@ -0,0 +1,58 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"sigs.k8s.io/yaml"
+)
+
+// openAPIYAML is the hand-written OpenAPI 3 description of the routes registered in
+// cmd/server. Keep it in step with the handlers' responses when changing them.
+//
+//go:embed openapi.yaml
+var openAPIYAML []byte
+
+// openAPIJSON is the spec converted to JSON once at startup
+var openAPIJSON = mustYAMLToJSON(openAPIYAML)
+
+func mustYAMLToJSON(doc []byte) []byte {
+	converted, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		panic("invalid embedded OpenAPI spec: " + err.Error())
+	}
+	return converted
+}
+
+// swaggerUIPage renders the spec with Swagger UI loaded from a CDN, so the UI assets
+// don't have to be vendored into the binary
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Kubernetes Cost Optimizer API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+// OpenAPISpec serves the API description as JSON
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIJSON)
+}
+
+// SwaggerUI serves an interactive page for browsing and calling the API
+func SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Page sizes for paginated list endpoints. Requested limits above maxPageSize are
+// clamped to it.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// parsePagination reads the limit and offset query parameters, applying the default
+// page size and clamping the limit to maxPageSize
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit")
+		}
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// nextOffset returns the offset of the following page, or nil on the last page
+func nextOffset(limit, offset, total int) interface{} {
+	if offset+limit >= total {
+		return nil
+	}
+	return offset + limit
+}
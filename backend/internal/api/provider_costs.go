@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// providerClusterCosts builds the GetClusterCosts response from the cloud provider's
+// billing data, including per-node costs. The provider only covers the cluster this
+// server runs in, so requests for other clusters fail and fall back to the database.
+func (h *Handler) providerClusterCosts(ctx context.Context, cluster string, limit, offset int) (map[string]interface{}, error) {
+	if h.costProvider == nil {
+		return nil, fmt.Errorf("no cloud provider configured")
+	}
+
+	clusterName := h.collector.ClusterName()
+	if cluster != "" && cluster != clusterName {
+		return nil, fmt.Errorf("cloud provider only covers cluster %s", clusterName)
+	}
+
+	costs, err := h.costProvider.GetClusterCosts(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	type NamespaceCost struct {
+		Cluster   string  `json:"cluster"`
+		Namespace string  `json:"namespace"`
+		Compute   float64 `json:"compute"`
+		Storage   float64 `json:"storage"`
+		Network   float64 `json:"network"`
+		Other     float64 `json:"other"`
+		Total     float64 `json:"total"`
+	}
+
+	namespaceCosts := make([]NamespaceCost, 0, len(costs.Namespaces))
+	for namespace, cost := range costs.Namespaces {
+		namespaceCosts = append(namespaceCosts, NamespaceCost{
+			Cluster:   clusterName,
+			Namespace: namespace,
+			Compute:   cost.Compute,
+			Storage:   cost.Storage,
+			Network:   cost.Network,
+			Other:     cost.Other,
+			Total:     cost.Total,
+		})
+	}
+	sort.Slice(namespaceCosts, func(i, j int) bool {
+		if namespaceCosts[i].Total != namespaceCosts[j].Total {
+			return namespaceCosts[i].Total > namespaceCosts[j].Total
+		}
+		return namespaceCosts[i].Namespace < namespaceCosts[j].Namespace
+	})
+
+	totalCount := len(namespaceCosts)
+	start, end := offset, offset+limit
+	if start > totalCount {
+		start = totalCount
+	}
+	if end > totalCount {
+		end = totalCount
+	}
+
+	return map[string]interface{}{
+		"cluster":       clusterName,
+		"cluster_total": costs.Total,
+		"namespaces":    namespaceCosts[start:end],
+		"nodes":         costs.Nodes,
+		"period":        costs.Period,
+		"total_count":   totalCount,
+		"limit":         limit,
+		"offset":        offset,
+		"next_offset":   nextOffset(limit, offset, totalCount),
+		"source":        "provider",
+	}, nil
+}
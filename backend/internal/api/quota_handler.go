@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"k8s-cost-optimizer/pkg/kubernetes"
+
+	"sigs.k8s.io/yaml"
+)
+
+// GetQuotaSuggestion returns a ResourceQuota manifest that keeps the namespace within
+// the monthly budget given in the `budget` query parameter
+func (h *Handler) GetQuotaSuggestion(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	budget, err := strconv.ParseFloat(r.URL.Query().Get("budget"), 64)
+	if err != nil || budget <= 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "Invalid budget")
+		return
+	}
+
+	suggestion, err := h.analyzer.SuggestResourceQuota(r.Context(), namespace, budget)
+	if err != nil {
+		h.log.Errorf("Quota suggestion failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Quota suggestion failed")
+		return
+	}
+
+	manifest, err := yaml.Marshal(suggestion.ResourceQuota())
+	if err != nil {
+		h.log.Errorf("Failed to render quota manifest: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeInternal, "Failed to render manifest")
+		return
+	}
+
+	response := map[string]interface{}{
+		"suggestion": suggestion,
+		"manifest":   string(manifest),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ApplyQuotaSuggestion computes the budget quota for the namespace and applies it to the cluster
+func (h *Handler) ApplyQuotaSuggestion(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		MonthlyBudget float64 `json:"monthly_budget"`
+	}
+
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	if request.MonthlyBudget <= 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid request")
+		return
+	}
+
+	suggestion, err := h.analyzer.SuggestResourceQuota(r.Context(), namespace, request.MonthlyBudget)
+	if err != nil {
+		h.log.Errorf("Quota suggestion failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Quota suggestion failed")
+		return
+	}
+
+	quota, err := kubernetes.ApplyResourceQuota(r.Context(), h.k8sClient, suggestion.ResourceQuota())
+	if err != nil {
+		h.log.Errorf("Failed to apply resource quota: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeKubernetes, "Failed to apply resource quota")
+		return
+	}
+
+	response := map[string]interface{}{
+		"status":     "success",
+		"suggestion": suggestion,
+		"quota":      quota.Name,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
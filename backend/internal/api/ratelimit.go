@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"k8s-cost-optimizer/pkg/cache"
+)
+
+// localLimiterIdle is how long an unused in-process bucket is kept before it is dropped
+const localLimiterIdle = 10 * time.Minute
+
+// RateLimitConfig holds per-client API rate limiting configuration
+type RateLimitConfig struct {
+	Enabled bool
+
+	// Sustained requests per second and bucket size for each client
+	Rate  float64
+	Burst int
+
+	// Paths that aren't rate limited, e.g. health and metrics endpoints
+	ExemptPaths []string
+
+	// Use the first X-Forwarded-For address as the client IP. Only enable this
+	// behind a proxy that sets the header.
+	TrustForwardedFor bool
+}
+
+// DefaultRateLimitConfig returns the default rate limiting configuration
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Enabled:     true,
+		Rate:        10,
+		Burst:       20,
+		ExemptPaths: []string{"/health", "/health/detailed", "/ready", "/metrics"},
+	}
+}
+
+// tokenBucketScript refills the client's bucket based on the time since its last
+// request and takes a token if one is available. It returns whether the request is
+// allowed and, if not, the seconds until a token is available. Redis time is used so
+// replicas with skewed clocks share one bucket consistently.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = (1 - tokens) / rate
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(burst / rate) + 1)
+return {allowed, tostring(wait)}
+`)
+
+type localLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter applies a token bucket per client, shared across replicas through Redis
+// and falling back to in-process buckets while Redis is unavailable. Redis calls go
+// through the cache manager's breaker, so while Redis is down requests don't each wait
+// out its timeout.
+type rateLimiter struct {
+	config *RateLimitConfig
+	cache  *cache.CacheManager
+	log    *logrus.Logger
+
+	mu           sync.Mutex
+	local        map[string]*localLimiter
+	lastPrune    time.Time
+	redisFailing bool
+}
+
+// setRedisFailing logs when the limiter switches between Redis and in-process buckets
+func (rl *rateLimiter) setRedisFailing(failing bool, err error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if failing && !rl.redisFailing {
+		rl.log.Warnf("Redis rate limiter unavailable, using in-process limiter: %v", err)
+	} else if !failing && rl.redisFailing {
+		rl.log.Info("Redis rate limiter recovered")
+	}
+	rl.redisFailing = failing
+}
+
+// allow reports whether the client may make a request, and if not how long it
+// should wait before retrying
+func (rl *rateLimiter) allow(ctx context.Context, client string) (bool, time.Duration) {
+	if rl.cache != nil {
+		var result []interface{}
+		err := rl.cache.Redis(ctx, func(redisClient *redis.Client) error {
+			var err error
+			result, err = tokenBucketScript.Run(ctx, redisClient, []string{"ratelimit:" + client},
+				rl.config.Rate, rl.config.Burst).Slice()
+			return err
+		})
+		if err == nil && len(result) == 2 {
+			rl.setRedisFailing(false, nil)
+			allowed, _ := result[0].(int64)
+			wait, _ := strconv.ParseFloat(fmt.Sprint(result[1]), 64)
+			return allowed == 1, time.Duration(wait * float64(time.Second))
+		}
+		rl.setRedisFailing(true, err)
+	}
+
+	return rl.allowLocal(client)
+}
+
+func (rl *rateLimiter) allowLocal(client string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.lastPrune) > localLimiterIdle {
+		for key, entry := range rl.local {
+			if now.Sub(entry.lastSeen) > localLimiterIdle {
+				delete(rl.local, key)
+			}
+		}
+		rl.lastPrune = now
+	}
+
+	entry, ok := rl.local[client]
+	if !ok {
+		entry = &localLimiter{limiter: rate.NewLimiter(rate.Limit(rl.config.Rate), rl.config.Burst)}
+		rl.local[client] = entry
+	}
+	entry.lastSeen = now
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// RateLimitMiddleware limits each client to config.Rate requests per second with
+// bursts of config.Burst. Clients are identified by their authenticated subject when
+// AuthMiddleware ran first, otherwise by IP. Limited requests get a 429 with a
+// Retry-After header.
+func RateLimitMiddleware(config *RateLimitConfig, cacheManager *cache.CacheManager) func(http.Handler) http.Handler {
+	if config == nil {
+		config = DefaultRateLimitConfig()
+	}
+
+	exempt := make(map[string]bool)
+	for _, path := range config.ExemptPaths {
+		exempt[path] = true
+	}
+
+	limiter := &rateLimiter{
+		config: config,
+		cache:  cacheManager,
+		log:    logrus.New(),
+		local:  make(map[string]*localLimiter),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !config.Enabled || config.Rate <= 0 || exempt[r.URL.Path] || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, wait := limiter.allow(r.Context(), clientKey(r, config.TrustForwardedFor))
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, errCodeRateLimited, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the caller for rate limiting
+func clientKey(r *http.Request, trustForwardedFor bool) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok && principal.Subject != "" {
+		return "sub:" + principal.Subject
+	}
+
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return "ip:" + strings.TrimSpace(ip)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
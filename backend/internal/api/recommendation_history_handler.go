@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s-cost-optimizer/internal/analyzer"
+)
+
+// Values accepted by the history filters, matching what the analyzer stores
+var (
+	historyResourceTypes = map[string]bool{"cpu": true, "memory": true, "gpu": true}
+	historyRiskLevels    = map[string]bool{"low": true, "medium": true, "high": true}
+)
+
+// GetRecommendationHistory lists the recommendations stored for a namespace, newest
+// first, so users can see how they evolved and whether applied ones brought usage and
+// requests down. Optional resource_type and risk_level parameters filter the list.
+func (h *Handler) GetRecommendationHistory(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	resourceType := r.URL.Query().Get("resource_type")
+	if resourceType != "" && !historyResourceTypes[strings.ToLower(resourceType)] {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+			fmt.Sprintf("invalid resource_type %q, must be cpu, memory or gpu", resourceType))
+		return
+	}
+
+	riskLevel := r.URL.Query().Get("risk_level")
+	if riskLevel != "" && !historyRiskLevels[strings.ToLower(riskLevel)] {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+			fmt.Sprintf("invalid risk_level %q, must be low, medium or high", riskLevel))
+		return
+	}
+
+	recommendations, totalCount, err := h.analyzer.GetRecommendationHistory(r.Context(), namespace,
+		analyzer.RecommendationHistoryFilter{
+			ResourceType: resourceType,
+			RiskLevel:    riskLevel,
+			Limit:        limit,
+			Offset:       offset,
+		})
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	if recommendations == nil {
+		recommendations = []analyzer.Recommendation{}
+	}
+
+	response := map[string]interface{}{
+		"namespace":       namespace,
+		"recommendations": recommendations,
+		"total_count":     totalCount,
+		"limit":           limit,
+		"offset":          offset,
+		"next_offset":     nextOffset(limit, offset, totalCount),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
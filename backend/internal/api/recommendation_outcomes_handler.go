@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s-cost-optimizer/internal/analyzer"
+)
+
+// outcomeValues are the outcomes the evaluator records
+var outcomeValues = map[string]bool{
+	analyzer.OutcomeSafe:     true,
+	analyzer.OutcomeTooTight: true,
+	analyzer.OutcomeTooLoose: true,
+	analyzer.OutcomeNoData:   true,
+}
+
+// GetRecommendationOutcomes reports how applied recommendations held up once they had
+// run for the evaluation window: hit rates overall and per resource type, and the
+// evaluated recommendations, newest first. Optional namespace, resource_type and
+// outcome parameters filter them.
+func (h *Handler) GetRecommendationOutcomes(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	if namespace != "" {
+		if err := validateNamespace(namespace); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidNamespace, err.Error())
+			return
+		}
+	}
+
+	resourceType := query.Get("resource_type")
+	if resourceType != "" && strings.ToLower(resourceType) != "cpu" && strings.ToLower(resourceType) != "memory" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+			fmt.Sprintf("invalid resource_type %q, must be cpu or memory", resourceType))
+		return
+	}
+
+	outcome := query.Get("outcome")
+	if outcome != "" && !outcomeValues[outcome] {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+			fmt.Sprintf("invalid outcome %q, must be safe, too_tight, too_loose or no_data", outcome))
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	overall, byResource, err := h.analyzer.GetOutcomeStats(ctx, namespace)
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	outcomes, totalCount, err := h.analyzer.GetRecommendationOutcomes(ctx, analyzer.OutcomeFilter{
+		Namespace:    namespace,
+		ResourceType: resourceType,
+		Outcome:      outcome,
+		Limit:        limit,
+		Offset:       offset,
+	})
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	if outcomes == nil {
+		outcomes = []analyzer.RecommendationOutcome{}
+	}
+
+	response := map[string]interface{}{
+		"namespace":   namespace,
+		"summary":     overall,
+		"by_resource": byResource,
+		"outcomes":    outcomes,
+		"total_count": totalCount,
+		"limit":       limit,
+		"offset":      offset,
+		"next_offset": nextOffset(limit, offset, totalCount),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
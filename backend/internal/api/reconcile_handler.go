@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"k8s-cost-optimizer/internal/collectors"
+)
+
+// ReconcileCosts scales this cluster's stored namespace costs over the last 30 days so
+// they sum to the cloud provider's cluster total, keeping chargeback in line with the
+// invoice. Without a billing-backed provider there is nothing to reconcile against.
+func (h *Handler) ReconcileCosts(w http.ResponseWriter, r *http.Request) {
+	result, err := h.collector.ReconcileCosts(r.Context(), h.costProvider)
+	if errors.Is(err, collectors.ErrNoBillingData) {
+		writeError(w, http.StatusConflict, errCodeInvalidRequest, "Reconciliation needs a cloud provider with billing data")
+		return
+	}
+	if err != nil {
+		h.log.Errorf("Cost reconciliation failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeInternal, "Cost reconciliation failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// reconciliationFactor returns how much stored costs were scaled from their estimates,
+// or 1 when nothing was stored
+func reconciliationFactor(total, estimated float64) float64 {
+	if estimated == 0 {
+		return 1
+	}
+	return total / estimated
+}
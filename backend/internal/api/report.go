@@ -0,0 +1,306 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+)
+
+// Report periods for cost and utilization data
+const (
+	reportCostPeriod        = 30 * 24 * time.Hour
+	reportUtilizationPeriod = 7 * 24 * time.Hour
+)
+
+// rawUsageMaxRange is the longest range usage is read from raw pod_metrics for. Longer
+// ranges read the hourly pod_metrics_rollup, which has one row per container per hour.
+const rawUsageMaxRange = 24 * time.Hour
+
+// usageFromRollups reports whether usage between start and end should be read from
+// the hourly rollups
+func usageFromRollups(start, end time.Time) bool {
+	return end.Sub(start) > rawUsageMaxRange
+}
+
+// Report is a cost optimization report for a namespace, or the whole cluster when
+// Namespace is empty
+type Report struct {
+	Namespace        string                    `json:"namespace"`
+	GeneratedAt      time.Time                 `json:"generated_at"`
+	PeriodStart      time.Time                 `json:"period_start"`
+	PeriodEnd        time.Time                 `json:"period_end"`
+	TotalCost        float64                   `json:"total_cost"`
+	PotentialSavings float64                   `json:"potential_savings"`
+	DailyCosts       []ReportDailyCost         `json:"daily_costs"`
+	NamespaceCosts   []ReportNamespaceCost     `json:"namespace_costs"`
+	Recommendations  []analyzer.Recommendation `json:"recommendations"`
+	Utilization      []ReportUtilization       `json:"utilization"`
+}
+
+// ReportDailyCost is a namespace's cost for a single day
+type ReportDailyCost struct {
+	Date      time.Time `json:"date"`
+	Namespace string    `json:"namespace"`
+	Compute   float64   `json:"compute"`
+	Storage   float64   `json:"storage"`
+	Network   float64   `json:"network"`
+	Other     float64   `json:"other"`
+	Total     float64   `json:"total"`
+}
+
+// ReportNamespaceCost is a namespace's total cost over the report period
+type ReportNamespaceCost struct {
+	Namespace string  `json:"namespace"`
+	Total     float64 `json:"total"`
+}
+
+// ReportUtilization compares a container's average usage with its requests
+type ReportUtilization struct {
+	Namespace         string  `json:"namespace"`
+	PodName           string  `json:"pod_name"`
+	ContainerName     string  `json:"container_name"`
+	AvgCPU            float64 `json:"avg_cpu"`
+	CPURequest        float64 `json:"cpu_request"`
+	CPUUtilization    float64 `json:"cpu_utilization"`
+	AvgMemory         float64 `json:"avg_memory"`
+	MemoryRequest     float64 `json:"memory_request"`
+	MemoryUtilization float64 `json:"memory_utilization"`
+}
+
+func (h *Handler) generateComprehensiveReport(ctx context.Context, namespace string) (*Report, error) {
+	end := time.Now().UTC()
+	report := &Report{
+		Namespace:   namespace,
+		GeneratedAt: end,
+		PeriodStart: end.Add(-reportCostPeriod),
+		PeriodEnd:   end,
+	}
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT 
+			DATE_TRUNC('day', timestamp) as day,
+			namespace,
+			SUM(compute_cost) as compute,
+			SUM(storage_cost) as storage,
+			SUM(network_cost) as network,
+			SUM(other_cost) as other,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total
+		FROM namespace_costs
+		WHERE ($1 = '' OR namespace = $1) AND timestamp BETWEEN $2 AND $3
+		GROUP BY day, namespace
+		ORDER BY day, namespace
+	`, namespace, report.PeriodStart, report.PeriodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("querying daily costs: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]float64)
+	var namespaces []string
+
+	for rows.Next() {
+		var cost ReportDailyCost
+		if err := rows.Scan(&cost.Date, &cost.Namespace, &cost.Compute, &cost.Storage,
+			&cost.Network, &cost.Other, &cost.Total); err != nil {
+			continue
+		}
+
+		report.DailyCosts = append(report.DailyCosts, cost)
+		report.TotalCost += cost.Total
+		if _, seen := totals[cost.Namespace]; !seen {
+			namespaces = append(namespaces, cost.Namespace)
+		}
+		totals[cost.Namespace] += cost.Total
+	}
+
+	for _, ns := range namespaces {
+		report.NamespaceCosts = append(report.NamespaceCosts, ReportNamespaceCost{Namespace: ns, Total: totals[ns]})
+	}
+	sort.Slice(report.NamespaceCosts, func(i, j int) bool {
+		return report.NamespaceCosts[i].Total > report.NamespaceCosts[j].Total
+	})
+
+	// Recommendations for the namespace, or every namespace with costs
+	targets := namespaces
+	if namespace != "" {
+		targets = []string{namespace}
+	}
+	for _, ns := range targets {
+		recs, err := h.analyzer.AnalyzeNamespace(ctx, ns)
+		if err != nil {
+			h.log.Warnf("Failed to analyze namespace %s for report: %v", ns, err)
+			continue
+		}
+		for _, rec := range recs {
+			report.PotentialSavings += rec.PotentialSavings
+		}
+		report.Recommendations = append(report.Recommendations, recs...)
+	}
+
+	report.Utilization, err = h.reportUtilization(ctx, namespace, end.Add(-reportUtilizationPeriod))
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (h *Handler) reportUtilization(ctx context.Context, namespace string, since time.Time) ([]ReportUtilization, error) {
+	usage := `
+		SELECT cluster, namespace, pod_name, container_name,
+			AVG(cpu_millicores) as avg_cpu, AVG(memory_bytes) as avg_memory
+		FROM pod_metrics
+		WHERE ($1 = '' OR namespace = $1) AND timestamp > $2
+		GROUP BY cluster, namespace, pod_name, container_name`
+	if usageFromRollups(since, time.Now()) {
+		usage = `
+		SELECT cluster, namespace, pod_name, container_name,
+			SUM(cpu_sum) / SUM(sample_count) as avg_cpu, SUM(memory_sum) / SUM(sample_count) as avg_memory
+		FROM pod_metrics_rollup
+		WHERE ($1 = '' OR namespace = $1) AND bucket > $2
+		GROUP BY cluster, namespace, pod_name, container_name`
+	}
+
+	rows, err := h.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT 
+			pm.namespace,
+			pm.pod_name,
+			pm.container_name,
+			pm.avg_cpu,
+			pm.avg_memory,
+			COALESCE(rr.cpu_request, 0),
+			COALESCE(rr.memory_request, 0)
+		FROM (%s) pm
+		LEFT JOIN (
+			SELECT DISTINCT ON (cluster, namespace, pod_name, container_name)
+				cluster, namespace, pod_name, container_name, cpu_request, memory_request
+			FROM resource_requests
+			WHERE ($1 = '' OR namespace = $1)
+			ORDER BY cluster, namespace, pod_name, container_name, timestamp DESC
+		) rr ON 
+			pm.cluster = rr.cluster AND
+			pm.namespace = rr.namespace AND 
+			pm.pod_name = rr.pod_name AND 
+			pm.container_name = rr.container_name
+		ORDER BY pm.namespace, pm.pod_name, pm.container_name
+	`, usage), namespace, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying utilization: %w", err)
+	}
+	defer rows.Close()
+
+	var utilization []ReportUtilization
+	for rows.Next() {
+		var u ReportUtilization
+		if err := rows.Scan(&u.Namespace, &u.PodName, &u.ContainerName,
+			&u.AvgCPU, &u.AvgMemory, &u.CPURequest, &u.MemoryRequest); err != nil {
+			continue
+		}
+
+		if u.CPURequest > 0 {
+			u.CPUUtilization = (u.AvgCPU / u.CPURequest) * 100
+		}
+		if u.MemoryRequest > 0 {
+			u.MemoryUtilization = (u.AvgMemory / u.MemoryRequest) * 100
+		}
+		utilization = append(utilization, u)
+	}
+
+	return utilization, nil
+}
+
+// exportCSV writes the report as consecutive CSV sections (daily costs,
+// recommendations, utilization), each with its own header row
+func (h *Handler) exportCSV(w http.ResponseWriter, report *Report) {
+	if err := writeCSV(w, report); err != nil {
+		h.log.Errorf("Failed to write CSV report: %v", err)
+	}
+}
+
+func writeCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"Date", "Namespace", "Compute", "Storage", "Network", "Other", "Total"})
+	for _, cost := range report.DailyCosts {
+		writer.Write([]string{
+			cost.Date.Format("2006-01-02"), cost.Namespace,
+			formatAmount(cost.Compute), formatAmount(cost.Storage), formatAmount(cost.Network),
+			formatAmount(cost.Other), formatAmount(cost.Total),
+		})
+	}
+	writer.Write(nil)
+
+	writer.Write([]string{"Namespace", "Pod", "Container", "Resource", "Current Request",
+		"Recommended Request", "Monthly Savings", "Confidence", "Risk"})
+	for _, rec := range report.Recommendations {
+		writer.Write([]string{
+			rec.Namespace, rec.PodName, rec.ContainerName, rec.ResourceType,
+			formatAmount(rec.CurrentRequest), formatAmount(rec.RecommendedRequest),
+			formatAmount(rec.PotentialSavings), formatAmount(rec.Confidence), rec.RiskLevel,
+		})
+	}
+	writer.Write(nil)
+
+	writer.Write([]string{"Namespace", "Pod", "Container", "Avg CPU (m)", "CPU Request (m)",
+		"CPU Utilization %", "Avg Memory (bytes)", "Memory Request (bytes)", "Memory Utilization %"})
+	for _, u := range report.Utilization {
+		writer.Write([]string{
+			u.Namespace, u.PodName, u.ContainerName,
+			formatAmount(u.AvgCPU), formatAmount(u.CPURequest), formatAmount(u.CPUUtilization),
+			formatAmount(u.AvgMemory), formatAmount(u.MemoryRequest), formatAmount(u.MemoryUtilization),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReportFile is a rendered report, named as the export endpoint names it
+type ReportFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// RenderReport generates the namespace's report, or the cluster-wide report for an
+// empty namespace, as a CSV or PDF file
+func (h *Handler) RenderReport(ctx context.Context, namespace, format string) (*ReportFile, error) {
+	report, err := h.generateComprehensiveReport(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("generating report: %w", err)
+	}
+
+	name := namespace
+	if name == "" {
+		name = "cluster"
+	}
+
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		if err := writeCSV(&buf, report); err != nil {
+			return nil, fmt.Errorf("writing CSV report: %w", err)
+		}
+		return &ReportFile{Filename: "cost-report-" + name + ".csv", ContentType: "text/csv", Data: buf.Bytes()}, nil
+	case "pdf":
+		data, err := renderPDF(report)
+		if err != nil {
+			return nil, fmt.Errorf("rendering PDF report: %w", err)
+		}
+		return &ReportFile{Filename: "cost-report-" + name + ".pdf", ContentType: "application/pdf", Data: data}, nil
+	default:
+		return nil, fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+func formatAmount(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Number of namespaces shown in the PDF cost table and bar chart
+const pdfTopNamespaces = 10
+
+// exportPDF writes the report as a PDF
+func (h *Handler) exportPDF(w http.ResponseWriter, report *Report) {
+	data, err := renderPDF(report)
+	if err != nil {
+		h.log.Errorf("Failed to render PDF report: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeInternal, "Failed to render PDF report")
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// renderPDF renders the report as a PDF with a cost summary table, a savings section
+// and a bar chart of the top namespaces by cost
+func renderPDF(report *Report) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Kubernetes Cost Report", false)
+	pdf.AddPage()
+
+	scope := report.Namespace
+	if scope == "" {
+		scope = "Cluster-wide"
+	}
+
+	// Header
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Kubernetes Cost Report", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Scope: %s", scope), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, fmt.Sprintf("Period: %s to %s", report.PeriodStart.Format("2006-01-02"),
+		report.PeriodEnd.Format("2006-01-02")), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, fmt.Sprintf("Generated: %s", report.GeneratedAt.Format("2006-01-02T15:04:05Z")), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	topNamespaces := report.NamespaceCosts
+	if len(topNamespaces) > pdfTopNamespaces {
+		topNamespaces = topNamespaces[:pdfTopNamespaces]
+	}
+
+	// Cost summary table
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "Cost Summary", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(120, 7, "Namespace", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(60, 7, "Total Cost ($)", "1", 1, "R", true, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, cost := range topNamespaces {
+		pdf.CellFormat(120, 7, cost.Namespace, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(60, 7, formatAmount(cost.Total), "1", 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(120, 7, "Total", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(60, 7, formatAmount(report.TotalCost), "1", 1, "R", true, 0, "")
+	pdf.Ln(6)
+
+	// Savings section
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "Potential Savings", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%d recommendations, $%s per month ($%s per year)",
+		len(report.Recommendations), formatAmount(report.PotentialSavings),
+		formatAmount(report.PotentialSavings*12)), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	// Bar chart of the top namespaces by cost
+	if len(topNamespaces) > 0 {
+		pdf.SetFont("Helvetica", "B", 13)
+		pdf.CellFormat(0, 8, "Top Namespaces by Cost", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+
+		const labelWidth, chartWidth, barHeight = 45.0, 115.0, 6.0
+		maxCost := topNamespaces[0].Total
+		pdf.SetFillColor(66, 133, 244)
+
+		for _, cost := range topNamespaces {
+			x, y := pdf.GetX(), pdf.GetY()
+			pdf.CellFormat(labelWidth, barHeight, cost.Namespace, "", 0, "L", false, 0, "")
+
+			width := 0.0
+			if maxCost > 0 {
+				width = cost.Total / maxCost * chartWidth
+			}
+			pdf.Rect(x+labelWidth, y+1, width, barHeight-2, "F")
+
+			pdf.SetXY(x+labelWidth+width+2, y)
+			pdf.CellFormat(0, barHeight, "$"+formatAmount(cost.Total), "", 1, "L", false, 0, "")
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-cost-optimizer/internal/store"
+
+	"github.com/gorilla/mux"
+	"github.com/robfig/cron/v3"
+)
+
+// reportScheduleRequest is the body of a report schedule create or update
+type reportScheduleRequest struct {
+	Name       string   `json:"name"`
+	Cron       string   `json:"cron"`
+	Namespaces []string `json:"namespaces"`
+	Format     string   `json:"format"`
+	Delivery   string   `json:"delivery"`
+	Recipients []string `json:"recipients"`
+	Enabled    *bool    `json:"enabled"` // Defaults to true
+}
+
+// NextReportRun returns the first time after the given time the cron expression
+// matches. Expressions are standard five-field cron, or descriptors such as @weekly,
+// in UTC unless prefixed with CRON_TZ=<zone>.
+func NextReportRun(expression string, after time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+	}
+	next := schedule.Next(after)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never matches", expression)
+	}
+	return next, nil
+}
+
+// schedule validates the request and builds the schedule it describes, due at the
+// cron expression's next match
+func (req reportScheduleRequest) schedule(now time.Time) (*store.ReportSchedule, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	next, err := NextReportRun(req.Cron, now)
+	if err != nil {
+		return nil, err
+	}
+	if req.Format != "csv" && req.Format != "pdf" {
+		return nil, fmt.Errorf("format must be csv or pdf, got %q", req.Format)
+	}
+	for _, namespace := range req.Namespaces {
+		if err := validateNamespace(namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	switch req.Delivery {
+	case store.DeliveryEmail:
+		if len(req.Recipients) == 0 {
+			return nil, fmt.Errorf("email delivery needs at least one recipient")
+		}
+		for _, recipient := range req.Recipients {
+			if !strings.Contains(recipient, "@") || strings.ContainsAny(recipient, " \r\n,<>") {
+				return nil, fmt.Errorf("invalid recipient %q", recipient)
+			}
+		}
+	case store.DeliveryWebhook:
+		if len(req.Recipients) > 0 {
+			return nil, fmt.Errorf("recipients only apply to email delivery")
+		}
+	default:
+		return nil, fmt.Errorf("delivery must be %s or %s, got %q", store.DeliveryEmail, store.DeliveryWebhook, req.Delivery)
+	}
+
+	schedule := &store.ReportSchedule{
+		Name:       strings.TrimSpace(req.Name),
+		Cron:       req.Cron,
+		Namespaces: req.Namespaces,
+		Format:     req.Format,
+		Delivery:   req.Delivery,
+		Recipients: req.Recipients,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		NextRunAt:  next,
+	}
+	if schedule.Namespaces == nil {
+		schedule.Namespaces = []string{}
+	}
+	if schedule.Recipients == nil {
+		schedule.Recipients = []string{}
+	}
+	return schedule, nil
+}
+
+// reportScheduleID returns the id path parameter. On an invalid ID it writes a 400 and
+// returns false.
+func reportScheduleID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "Invalid report schedule ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateReportSchedule stores a schedule reports are generated and sent on
+func (h *Handler) CreateReportSchedule(w http.ResponseWriter, r *http.Request) {
+	var request reportScheduleRequest
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	schedule, err := request.schedule(time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+	if err := h.reportSchedules.CreateReportSchedule(ctx, schedule); err != nil {
+		h.log.Errorf("Failed to create report schedule: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Failed to create report schedule")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// GetReportSchedules lists every report schedule with its last run
+func (h *Handler) GetReportSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	schedules, err := h.reportSchedules.ReportSchedules(ctx)
+	if err != nil {
+		h.log.Errorf("Failed to list report schedules: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	response := map[string]interface{}{
+		"schedules": schedules,
+		"count":     len(schedules),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetReportSchedule returns one report schedule
+func (h *Handler) GetReportSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := reportScheduleID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	schedule, err := h.reportSchedules.ReportSchedule(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, errCodeNotFound, "Report schedule not found")
+		return
+	}
+	if err != nil {
+		h.log.Errorf("Failed to get report schedule: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// UpdateReportSchedule replaces a report schedule's settings. Its next run is
+// recalculated from the cron expression.
+func (h *Handler) UpdateReportSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := reportScheduleID(w, r)
+	if !ok {
+		return
+	}
+
+	var request reportScheduleRequest
+	if err := h.decodeJSONBody(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	schedule, err := request.schedule(time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	schedule.ID = id
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	err = h.reportSchedules.UpdateReportSchedule(ctx, schedule)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, errCodeNotFound, "Report schedule not found")
+		return
+	}
+	if err != nil {
+		h.log.Errorf("Failed to update report schedule: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Failed to update report schedule")
+		return
+	}
+
+	// Reload for the run history the update keeps
+	if updated, err := h.reportSchedules.ReportSchedule(ctx, id); err == nil {
+		schedule = updated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// DeleteReportSchedule removes a report schedule
+func (h *Handler) DeleteReportSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := reportScheduleID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	deleted, err := h.reportSchedules.DeleteReportSchedule(ctx, id)
+	if err != nil {
+		h.log.Errorf("Failed to delete report schedule: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, errCodeNotFound, "Report schedule not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/internal/analyzer"
+	"k8s-cost-optimizer/internal/store"
+)
+
+// simulatedChange is a change to one container in a cost simulation. Omitted fields
+// keep the container's current value, so a change can scale replicas alone.
+type simulatedChange struct {
+	PodName       string   `json:"pod_name"`
+	ContainerName string   `json:"container_name"`
+	CPURequest    *float64 `json:"cpu_request"`
+	CPULimit      *float64 `json:"cpu_limit"`
+	MemoryRequest *float64 `json:"memory_request"`
+	MemoryLimit   *float64 `json:"memory_limit"`
+	Replicas      *int     `json:"replicas"`
+}
+
+func (c simulatedChange) validate() error {
+	if c.PodName == "" || c.ContainerName == "" {
+		return fmt.Errorf("pod_name and container_name are required")
+	}
+	for name, value := range map[string]*float64{
+		"cpu_request": c.CPURequest, "cpu_limit": c.CPULimit,
+		"memory_request": c.MemoryRequest, "memory_limit": c.MemoryLimit,
+	} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s must not be negative, got %v", name, *value)
+		}
+	}
+	if c.Replicas != nil && *c.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got %d", *c.Replicas)
+	}
+	return nil
+}
+
+// changeCost is a change's share of the simulated cost, over the simulated period
+type changeCost struct {
+	PodName          string  `json:"pod_name"`
+	ContainerName    string  `json:"container_name"`
+	CurrentReplicas  int     `json:"current_replicas"`
+	Replicas         int     `json:"replicas"`
+	CurrentCost      float64 `json:"current_cost"`
+	ProjectedCost    float64 `json:"projected_cost"`
+	CostDifference   float64 `json:"cost_difference"`
+	CPUDifference    float64 `json:"cpu_difference"`
+	MemoryDifference float64 `json:"memory_difference"`
+}
+
+// simulateChange prices the container's replicas before and after the change, per
+// hour. Each replica costs its requests plus burstFactor of the headroom between each
+// request and a higher limit, for clouds that bill usage bursting above requests.
+func simulateChange(change simulatedChange, current store.ContainerResources, currentReplicas int,
+	prices *analyzer.ResourcePrices, burstFactor float64) changeCost {
+	next := current
+	replicas := currentReplicas
+	if change.CPURequest != nil {
+		next.CPURequest = *change.CPURequest
+	}
+	if change.CPULimit != nil {
+		next.CPULimit = *change.CPULimit
+	}
+	if change.MemoryRequest != nil {
+		next.MemoryRequest = *change.MemoryRequest
+	}
+	if change.MemoryLimit != nil {
+		next.MemoryLimit = *change.MemoryLimit
+	}
+	if change.Replicas != nil {
+		replicas = *change.Replicas
+	}
+
+	currentCPU := billedAmount(current.CPURequest, current.CPULimit, burstFactor) * prices.PerMillicoreHour * float64(currentReplicas)
+	currentMemory := billedAmount(current.MemoryRequest, current.MemoryLimit, burstFactor) * prices.PerByteHour * float64(currentReplicas)
+	nextCPU := billedAmount(next.CPURequest, next.CPULimit, burstFactor) * prices.PerMillicoreHour * float64(replicas)
+	nextMemory := billedAmount(next.MemoryRequest, next.MemoryLimit, burstFactor) * prices.PerByteHour * float64(replicas)
+
+	return changeCost{
+		PodName:          change.PodName,
+		ContainerName:    change.ContainerName,
+		CurrentReplicas:  currentReplicas,
+		Replicas:         replicas,
+		CurrentCost:      currentCPU + currentMemory,
+		ProjectedCost:    nextCPU + nextMemory,
+		CostDifference:   nextCPU + nextMemory - currentCPU - currentMemory,
+		CPUDifference:    nextCPU - currentCPU,
+		MemoryDifference: nextMemory - currentMemory,
+	}
+}
+
+// billedAmount is the request plus burstFactor of the headroom up to the limit. An
+// unset limit, or one at or below the request, adds nothing.
+func billedAmount(request, limit, burstFactor float64) float64 {
+	if limit <= request {
+		return request
+	}
+	return request + (limit-request)*burstFactor
+}
+
+// scale returns the cost over the period from the hourly cost
+func (c changeCost) scale(multiplier float64) changeCost {
+	c.CurrentCost *= multiplier
+	c.ProjectedCost *= multiplier
+	c.CostDifference *= multiplier
+	c.CPUDifference *= multiplier
+	c.MemoryDifference *= multiplier
+	return c
+}
+
+// replicaCounts returns the number of pods each pod's workload ran over the last two
+// metrics collections. Pods without a recorded owner count as one.
+func (h *Handler) replicaCounts(ctx context.Context, namespace, cluster string) map[string]int {
+	interval := h.metricsInterval
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+
+	owners, err := h.metrics.Owners(ctx, namespace, cluster, time.Now().Add(-2*interval))
+	if err != nil {
+		h.log.Warnf("Failed to load pod owners for %s, simulating pods as single replicas: %v", namespace, err)
+		return nil
+	}
+
+	perOwner := make(map[store.Owner]int)
+	for _, owner := range owners {
+		perOwner[owner]++
+	}
+	counts := make(map[string]int, len(owners))
+	for pod, owner := range owners {
+		counts[pod] = perOwner[owner]
+	}
+	return counts
+}
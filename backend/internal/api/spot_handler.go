@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetSpotRecommendations returns the namespace's workloads that could run on spot
+// capacity, with their estimated savings
+func (h *Handler) GetSpotRecommendations(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	prices := h.analyzer.Prices(r.Context())
+	candidates, err := h.consolidation.FindSpotCandidates(r.Context(), namespace, prices)
+	if err != nil {
+		h.log.Errorf("Spot analysis failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Analysis failed")
+		return
+	}
+
+	totalSavings := 0.0
+	for _, candidate := range candidates {
+		totalSavings += candidate.MonthlySavings
+	}
+
+	response := map[string]interface{}{
+		"namespace":       namespace,
+		"recommendations": candidates,
+		"count":           len(candidates),
+		"total_savings":   totalSavings,
+		"price_source":    prices.Source,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetStorageRecommendations returns the namespace's over-provisioned PVCs, to be
+// recreated at a smaller size, and the unused ones, with their monthly savings
+func (h *Handler) GetStorageRecommendations(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := h.liveNamespaceParam(w, r)
+	if !ok {
+		return
+	}
+
+	recommendations, err := h.analyzer.AnalyzePVCUsage(r.Context(), namespace)
+	if err != nil {
+		h.log.Errorf("Storage analysis failed: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeAnalysisFailed, "Analysis failed")
+		return
+	}
+
+	var totalSavings float64
+	for _, rec := range recommendations {
+		totalSavings += rec.MonthlySavings
+	}
+
+	response := map[string]interface{}{
+		"namespace":             namespace,
+		"recommendations":       recommendations,
+		"count":                 len(recommendations),
+		"total_monthly_savings": totalSavings,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
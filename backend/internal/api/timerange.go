@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxRangeSpan is the longest explicit start/end range a query may cover
+const maxRangeSpan = 365 * 24 * time.Hour
+
+// customPeriod is reported as the period of explicit start/end ranges
+const customPeriod = "custom"
+
+// namedPeriods are the shortcut periods accepted in place of start and end
+var namedPeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// parseTimeRange reads either the start and end RFC 3339 query parameters or a named
+// period, defaulting to the last 30 days. The two forms are mutually exclusive. For
+// explicit ranges the returned period is "custom".
+func parseTimeRange(r *http.Request) (period string, start, end time.Time, err error) {
+	query := r.URL.Query()
+	period = query.Get("period")
+	startParam, endParam := query.Get("start"), query.Get("end")
+
+	if startParam == "" && endParam == "" {
+		return namedPeriodRange(period)
+	}
+
+	if period != "" {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("period can't be combined with start and end")
+	}
+	if startParam == "" || endParam == "" {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("start and end must both be set")
+	}
+	if start, err = time.Parse(time.RFC3339, startParam); err != nil {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid start %q, must be an RFC 3339 time", startParam)
+	}
+	if end, err = time.Parse(time.RFC3339, endParam); err != nil {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid end %q, must be an RFC 3339 time", endParam)
+	}
+	if !start.Before(end) {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("start must be before end")
+	}
+	if end.Sub(start) > maxRangeSpan {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("range can't span more than %d days", int(maxRangeSpan.Hours()/24))
+	}
+	return customPeriod, start, end, nil
+}
+
+// namedPeriodRange returns the range of a named period ending now, defaulting to the
+// last 30 days
+func namedPeriodRange(period string) (string, time.Time, time.Time, error) {
+	if period == "" {
+		period = "30d"
+	}
+	window, ok := namedPeriods[period]
+	if !ok {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, must be 24h, 7d or 30d", period)
+	}
+	end := time.Now()
+	return period, end.Add(-window), end, nil
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"k8s-cost-optimizer/internal/analyzer"
+
+	"sigs.k8s.io/yaml"
+)
+
+// writeVPA responds with VerticalPodAutoscaler manifests for the recommendations as a
+// multi-document YAML stream that can be piped to kubectl apply. The update_mode query
+// parameter selects Off (the default), Initial or Auto.
+func (h *Handler) writeVPA(w http.ResponseWriter, r *http.Request, recommendations []analyzer.Recommendation) {
+	updateMode := r.URL.Query().Get("update_mode")
+	if updateMode == "" {
+		updateMode = analyzer.VPAUpdateModeOff
+	}
+
+	vpas, err := analyzer.GenerateVPA(recommendations, updateMode)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error())
+		return
+	}
+
+	var out []byte
+	for i, vpa := range vpas {
+		doc, err := yaml.Marshal(vpa)
+		if err != nil {
+			h.log.Errorf("Failed to encode VPA %s: %v", vpa.Metadata.Name, err)
+			writeError(w, http.StatusInternalServerError, errCodeInternal, "Failed to generate VPA manifests")
+			return
+		}
+		if i > 0 {
+			out = append(out, "---\n"...)
+		}
+		out = append(out, doc...)
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(out)
+}
@@ -2,32 +2,107 @@ package api
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"k8s-cost-optimizer/internal/websocket"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
 )
 
+// WebSocketPath is the route of the live cost stream
+const WebSocketPath = "/ws"
+
+// wsTokenProtocol is the subprotocol browsers offer alongside their token, as
+// new WebSocket(url, ["bearer", token]), since they can't set an Authorization header
+const wsTokenProtocol = "bearer"
+
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
-	hub *websocket.Hub
+	hub      *websocket.Hub
+	upgrader gorilla.Upgrader
+	auth     *AuthConfig
+	apiKeys  map[string]string
+	client   *websocket.ClientConfig
 }
 
-// NewWebSocketHandler creates a new WebSocket handler
-func NewWebSocketHandler(hub *websocket.Hub) *WebSocketHandler {
+// NewWebSocketHandler creates a new WebSocket handler that only accepts upgrades from
+// origins allowed by the CORS configuration and, when auth is enabled, from callers
+// with the same credentials the REST API accepts. Connections use the client config's
+// limits and timings; nil uses websocket.DefaultClientConfig.
+func NewWebSocketHandler(hub *websocket.Hub, cors *CORSConfig, auth *AuthConfig, client *websocket.ClientConfig) *WebSocketHandler {
+	if cors == nil {
+		cors = DefaultCORSConfig()
+	}
+	if auth == nil {
+		auth = DefaultAuthConfig()
+	}
+
+	upgrader := websocket.Upgrader
+	upgrader.CheckOrigin = cors.CheckOrigin
+	upgrader.Subprotocols = []string{wsTokenProtocol}
+
 	return &WebSocketHandler{
-		hub: hub,
+		hub:      hub,
+		upgrader: upgrader,
+		auth:     auth,
+		apiKeys:  parseAPIKeys(auth.APIKeys),
+		client:   client,
 	}
 }
 
-// ServeWebSocket handles WebSocket upgrade and client management
+// ServeWebSocket handles WebSocket upgrade and client management. The caller is
+// authenticated before the client is registered with the hub; on failure the
+// connection is closed with a policy violation close code, which browsers can read
+// where they can't read an HTTP status. The client may only subscribe to the
+// namespaces its token allows.
 func (h *WebSocketHandler) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := websocket.Upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
 
-	client := websocket.NewClient(h.hub, conn)
-	h.hub.register <- client
+	var allowedNamespaces []string
+	if h.auth.Enabled {
+		principal, err := h.authenticate(r)
+		if err == nil && !authorized(h.auth.RouteRoles, principal, r) {
+			err = errInsufficientRole
+		}
+		if err != nil {
+			logrus.Warnf("Rejected WebSocket connection from %s: %v", r.RemoteAddr, err)
+			closeMessage := gorilla.FormatCloseMessage(gorilla.ClosePolicyViolation, err.Error())
+			conn.WriteControl(gorilla.CloseMessage, closeMessage, time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
+		allowedNamespaces = principal.Namespaces
+	}
+
+	client := websocket.NewClient(h.hub, conn, allowedNamespaces, h.client)
+	h.hub.Register(client)
 
 	go client.WritePump()
 	go client.ReadPump()
-} 
\ No newline at end of file
+}
+
+// authenticate checks the credentials REST clients send, then a token passed as the
+// token query parameter or after the bearer subprotocol in Sec-WebSocket-Protocol
+func (h *WebSocketHandler) authenticate(r *http.Request) (*Principal, error) {
+	if r.Header.Get(h.auth.APIKeyHeader) != "" || r.Header.Get("Authorization") != "" {
+		return authenticate(h.auth, h.apiKeys, r)
+	}
+
+	if token := r.URL.Query().Get("token"); token != "" {
+		return authenticateToken(h.auth, h.apiKeys, token)
+	}
+
+	protocols := gorilla.Subprotocols(r)
+	for i, protocol := range protocols {
+		if strings.EqualFold(protocol, wsTokenProtocol) && i+1 < len(protocols) {
+			return authenticateToken(h.auth, h.apiKeys, protocols[i+1])
+		}
+	}
+
+	return nil, errMissingCredentials
+}
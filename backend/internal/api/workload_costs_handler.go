@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"k8s-cost-optimizer/internal/collectors"
+)
+
+// GetWorkloadCosts breaks a namespace's attributed node cost down by workload and
+// container over the period. The cluster's idle cost, which no container requests, is
+// returned as its own line alongside the cluster's total node spend, so the namespace's
+// share can be reconciled against what the nodes cost.
+func (h *Handler) GetWorkloadCosts(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := namespaceParam(w, r)
+	if !ok {
+		return
+	}
+	cluster := r.URL.Query().Get("cluster")
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "30d"
+	}
+	var window time.Duration
+	switch period {
+	case "24h":
+		window = 24 * time.Hour
+	case "7d":
+		window = 7 * 24 * time.Hour
+	case "30d":
+		window = 30 * 24 * time.Hour
+	default:
+		writeError(w, http.StatusBadRequest, errCodeInvalidPeriod, "Invalid period")
+		return
+	}
+	startTime := time.Now().Add(-window)
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT
+			workload_kind,
+			workload,
+			container_name,
+			SUM(cpu_cost) as cpu,
+			SUM(memory_cost) as memory
+		FROM workload_costs
+		WHERE namespace = $1 AND timestamp >= $2 AND ($3 = '' OR cluster = $3)
+		GROUP BY workload_kind, workload, container_name
+		ORDER BY SUM(cpu_cost + memory_cost) DESC
+	`, namespace, startTime, cluster)
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	type ContainerCost struct {
+		Kind      string  `json:"kind"`
+		Workload  string  `json:"workload"`
+		Container string  `json:"container"`
+		CPU       float64 `json:"cpu"`
+		Memory    float64 `json:"memory"`
+		Total     float64 `json:"total"`
+	}
+
+	containers := []ContainerCost{}
+	namespaceTotal := 0.0
+	for rows.Next() {
+		var cost ContainerCost
+		if err := rows.Scan(&cost.Kind, &cost.Workload, &cost.Container, &cost.CPU, &cost.Memory); err != nil {
+			h.log.Errorf("Failed to scan workload cost: %v", err)
+			continue
+		}
+		cost.Total = cost.CPU + cost.Memory
+		namespaceTotal += cost.Total
+		containers = append(containers, cost)
+	}
+
+	var idleTotal, nodeTotal float64
+	err = h.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(cpu_cost + memory_cost) FILTER (WHERE namespace = $1), 0),
+			COALESCE(SUM(cpu_cost + memory_cost), 0)
+		FROM workload_costs
+		WHERE timestamp >= $2 AND ($3 = '' OR cluster = $3)
+	`, collectors.IdleNamespace, startTime, cluster).Scan(&idleTotal, &nodeTotal)
+	if err != nil {
+		h.log.Errorf("Database error: %v", err)
+		writeError(w, http.StatusInternalServerError, errCodeDatabase, "Database error")
+		return
+	}
+
+	response := map[string]interface{}{
+		"namespace":  namespace,
+		"cluster":    cluster,
+		"period":     period,
+		"containers": containers,
+		"total":      namespaceTotal,
+		"idle": map[string]interface{}{
+			"total":    idleTotal,
+			"fraction": fraction(idleTotal, nodeTotal),
+		},
+		"node_total": nodeTotal,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// fraction returns part/whole, or 0 when whole is 0
+func fraction(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return part / whole
+}
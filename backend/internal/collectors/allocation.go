@@ -0,0 +1,182 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/cloudprovider"
+)
+
+// AllocationBasis is what a namespace's share of the cluster's compute cost is
+// proportional to
+type AllocationBasis string
+
+// Allocation bases. With no basis the cloud provider's own split is kept, and mock
+// costs are based on usage.
+//
+// The basis changes how compute cost is divided, never the total, so costs still
+// reconcile with the bill. Idle node capacity is therefore always paid for, and the
+// basis decides by whom:
+//
+//   - requests: namespaces pay for the capacity they reserve, used or not. Capacity no
+//     pod requests is spread in proportion to requests.
+//   - usage: namespaces pay for what they use. All idle capacity, including requested
+//     but unused capacity, is spread in proportion to usage, so busy namespaces carry
+//     over-provisioned ones.
+//   - max: namespaces pay for the larger of their requests and usage, per resource, so
+//     both reserving and bursting are charged. Capacity that is neither is spread in
+//     proportion to that.
+const (
+	AllocationRequests AllocationBasis = "requests"
+	AllocationUsage    AllocationBasis = "usage"
+	AllocationMax      AllocationBasis = "max"
+)
+
+// ParseAllocationBasis validates a configured basis. An empty basis keeps the
+// provider's allocation.
+func ParseAllocationBasis(basis string) (AllocationBasis, error) {
+	switch AllocationBasis(basis) {
+	case "", AllocationRequests, AllocationUsage, AllocationMax:
+		return AllocationBasis(basis), nil
+	default:
+		return "", fmt.Errorf("allocation basis must be requests, usage or max, got %q", basis)
+	}
+}
+
+// resourceQuantity is a namespace's average CPU (millicores) and memory (bytes) over an
+// allocation period
+type resourceQuantity struct {
+	CPU    float64
+	Memory float64
+}
+
+// weight prices the quantity in GiB-hour equivalents, using the same CPU to memory
+// price ratio that splits node prices
+func (q resourceQuantity) weight() float64 {
+	return q.CPU/1000*cloudprovider.CPUToMemoryPriceRatio + q.Memory/(1<<30)
+}
+
+// allocationQuantities returns each namespace's average CPU and memory between start
+// and end on the given basis. Quantities are summed per collection timestamp and
+// averaged over every timestamp in the period, so pods that only ran for part of it
+// count for that part.
+func (mc *MetricsCollector) allocationQuantities(ctx context.Context, basis AllocationBasis, start, end time.Time) (map[string]resourceQuantity, error) {
+	switch basis {
+	case AllocationRequests:
+		return mc.averageRequests(ctx, start, end)
+	case AllocationMax:
+		requests, err := mc.averageRequests(ctx, start, end)
+		if err != nil {
+			return nil, err
+		}
+		usage, err := mc.averageUsage(ctx, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for namespace, used := range usage {
+			requested := requests[namespace]
+			if used.CPU > requested.CPU {
+				requested.CPU = used.CPU
+			}
+			if used.Memory > requested.Memory {
+				requested.Memory = used.Memory
+			}
+			requests[namespace] = requested
+		}
+		return requests, nil
+	default:
+		return mc.averageUsage(ctx, start, end)
+	}
+}
+
+func (mc *MetricsCollector) averageUsage(ctx context.Context, start, end time.Time) (map[string]resourceQuantity, error) {
+	return mc.averageQuantities(ctx, `
+		WITH samples AS (
+			SELECT COUNT(DISTINCT timestamp) as n
+			FROM pod_metrics
+			WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+		)
+		SELECT
+			namespace,
+			COALESCE(SUM(cpu_millicores), 0) / MAX(samples.n),
+			COALESCE(SUM(memory_bytes), 0) / MAX(samples.n)
+		FROM pod_metrics, samples
+		WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+		GROUP BY namespace
+	`, start, end, mc.config.ClusterName)
+}
+
+func (mc *MetricsCollector) averageRequests(ctx context.Context, start, end time.Time) (map[string]resourceQuantity, error) {
+	return mc.averageQuantities(ctx, `
+		WITH samples AS (
+			SELECT COUNT(DISTINCT timestamp) as n
+			FROM resource_requests
+			WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+		)
+		SELECT
+			namespace,
+			COALESCE(SUM(cpu_request), 0) / MAX(samples.n),
+			COALESCE(SUM(memory_request), 0) / MAX(samples.n)
+		FROM resource_requests, samples
+		WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+		GROUP BY namespace
+	`, start, end, mc.config.ClusterName)
+}
+
+func (mc *MetricsCollector) averageQuantities(ctx context.Context, query string, args ...interface{}) (map[string]resourceQuantity, error) {
+	rows, err := mc.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying allocation quantities: %w", err)
+	}
+	defer rows.Close()
+
+	quantities := make(map[string]resourceQuantity)
+	for rows.Next() {
+		var namespace string
+		var quantity resourceQuantity
+		if err := rows.Scan(&namespace, &quantity.CPU, &quantity.Memory); err != nil {
+			return nil, fmt.Errorf("scanning allocation quantities: %w", err)
+		}
+		quantities[namespace] = quantity
+	}
+	return quantities, rows.Err()
+}
+
+// reallocateCompute splits the compute cost the provider attributed to namespaces
+// between them in proportion to their quantities on the configured basis. The total
+// is unchanged. Without a basis, or without any quantities to split by, the provider's
+// split is kept.
+func (mc *MetricsCollector) reallocateCompute(ctx context.Context, costs map[string]cloudprovider.NamespaceCost, start, end time.Time) {
+	if mc.config.AllocationBasis == "" {
+		return
+	}
+
+	quantities, err := mc.allocationQuantities(ctx, mc.config.AllocationBasis, start, end)
+	if err != nil {
+		mc.log.Warnf("Failed to load %s for cost allocation, keeping the provider's: %v", mc.config.AllocationBasis, err)
+		return
+	}
+
+	var pool, totalWeight float64
+	for _, cost := range costs {
+		pool += cost.Compute
+	}
+	for _, quantity := range quantities {
+		totalWeight += quantity.weight()
+	}
+	if totalWeight == 0 {
+		mc.log.Warnf("No %s recorded for cost allocation, keeping the provider's", mc.config.AllocationBasis)
+		return
+	}
+
+	for namespace, cost := range costs {
+		cost.Compute = 0
+		costs[namespace] = cost
+	}
+	for namespace, quantity := range quantities {
+		cost := costs[namespace]
+		cost.Compute = pool * quantity.weight() / totalWeight
+		costs[namespace] = cost
+	}
+}
@@ -0,0 +1,184 @@
+package collectors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// backfillChunk bounds each range query so a series stays well under Prometheus'
+// 11,000 points per query limit at the default step
+const backfillChunk = 24 * time.Hour
+
+type backfillKey struct {
+	namespace string
+	pod       string
+	container string
+	timestamp time.Time
+}
+
+type backfillSample struct {
+	cpu    float64
+	memory float64
+}
+
+// CollectPodMetricsFromPrometheus backfills pod_metrics from Prometheus range queries
+// over the lookback window, at the configured backfill step. Only the part of the
+// window before the earliest existing pod_metrics row is queried, so running it again
+// after a restart is cheap. The hourly rollups over the backfilled range are rebuilt
+// afterwards, so long-range queries and the analyzer see the history too.
+func (mc *MetricsCollector) CollectPodMetricsFromPrometheus(ctx context.Context, lookback time.Duration) error {
+	if mc.promClient == nil {
+		return fmt.Errorf("Prometheus client not available")
+	}
+	if mc.Lightweight() {
+		return fmt.Errorf("backfill needs Postgres")
+	}
+
+	end := time.Now()
+	start := end.Add(-lookback)
+
+	var earliest sql.NullTime
+	err := mc.db.QueryRowContext(ctx, `
+		SELECT MIN(timestamp) FROM pod_metrics WHERE cluster = $1 AND timestamp > $2
+	`, mc.config.ClusterName, start).Scan(&earliest)
+	if err != nil {
+		return fmt.Errorf("finding existing pod metrics: %w", err)
+	}
+	if earliest.Valid {
+		end = earliest.Time
+	}
+
+	step := mc.config.BackfillStep
+	if step <= 0 {
+		step = DefaultCollectorConfig().BackfillStep
+	}
+
+	var stored int
+	for chunkStart := start; chunkStart.Before(end); chunkStart = chunkStart.Add(backfillChunk) {
+		chunkEnd := chunkStart.Add(backfillChunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		n, err := mc.backfillRange(ctx, v1.Range{Start: chunkStart, End: chunkEnd, Step: step})
+		if err != nil {
+			return fmt.Errorf("backfilling %s to %s: %w",
+				chunkStart.Format(time.RFC3339), chunkEnd.Format(time.RFC3339), err)
+		}
+		stored += n
+	}
+
+	mc.log.Infof("Backfilled %d pod metric samples from Prometheus between %s and %s",
+		stored, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	if stored == 0 {
+		return nil
+	}
+
+	rebuilt, err := mc.RebuildRollups(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("rebuilding rollups after backfill: %w", err)
+	}
+	mc.log.Infof("Rebuilt %d pod metric rollups over the backfilled range", rebuilt)
+	return nil
+}
+
+// backfillRange range-queries CPU and memory per container and stores the merged
+// samples, returning how many rows were written
+func (mc *MetricsCollector) backfillRange(ctx context.Context, r v1.Range) (int, error) {
+	selector := fmt.Sprintf(`%s!="", %s!="POD"`, mc.config.ContainerLabel, mc.config.ContainerLabel)
+	groupBy := fmt.Sprintf("%s, %s, %s", mc.config.NamespaceLabel, mc.config.PodLabel, mc.config.ContainerLabel)
+
+	cpuQuery := fmt.Sprintf(`sum by (%s) (
+		rate(container_cpu_usage_seconds_total{%s}[5m]) * 1000
+	)`, groupBy, selector)
+	memQuery := fmt.Sprintf(`sum by (%s) (
+		container_memory_working_set_bytes{%s}
+	)`, groupBy, selector)
+
+	samples := make(map[backfillKey]*backfillSample)
+
+	if err := mc.mergeRange(ctx, cpuQuery, r, samples, func(s *backfillSample, v float64) { s.cpu = v }); err != nil {
+		return 0, fmt.Errorf("querying CPU usage: %w", err)
+	}
+	if err := mc.mergeRange(ctx, memQuery, r, samples, func(s *backfillSample, v float64) { s.memory = v }); err != nil {
+		return 0, fmt.Errorf("querying memory usage: %w", err)
+	}
+
+	tx, err := mc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO pod_metrics
+		(cluster, namespace, pod_name, container_name, cpu_millicores, memory_bytes, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp) DO NOTHING
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for key, sample := range samples {
+		_, err := stmt.ExecContext(ctx, mc.config.ClusterName, key.namespace, key.pod, key.container,
+			sample.cpu, sample.memory, key.timestamp)
+		if err != nil {
+			return 0, fmt.Errorf("storing sample for %s/%s/%s: %w", key.namespace, key.pod, key.container, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(samples), nil
+}
+
+// mergeRange runs a range query and folds each point into samples with set
+func (mc *MetricsCollector) mergeRange(ctx context.Context, query string, r v1.Range,
+	samples map[backfillKey]*backfillSample, set func(*backfillSample, float64)) error {
+	result, warnings, err := mc.promClient.QueryRange(ctx, query, r)
+	if err != nil {
+		return err
+	}
+	if len(warnings) > 0 {
+		mc.log.Warnf("Prometheus warnings: %v", warnings)
+	}
+
+	matrix, ok := result.(model.Matrix)
+	if !ok {
+		return fmt.Errorf("unexpected result type: %T", result)
+	}
+
+	for _, series := range matrix {
+		namespace := string(series.Metric[model.LabelName(mc.config.NamespaceLabel)])
+		pod := string(series.Metric[model.LabelName(mc.config.PodLabel)])
+		container := string(series.Metric[model.LabelName(mc.config.ContainerLabel)])
+		if namespace == "" || pod == "" || container == "" {
+			continue
+		}
+
+		for _, point := range series.Values {
+			key := backfillKey{
+				namespace: namespace,
+				pod:       pod,
+				container: container,
+				timestamp: point.Timestamp.Time(),
+			}
+			sample, ok := samples[key]
+			if !ok {
+				sample = &backfillSample{}
+				samples[key] = sample
+			}
+			set(sample, float64(point.Value))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxQueryParams is PostgreSQL's limit on bind parameters in a single statement
+const maxQueryParams = 65535
+
+// batchInsert is a multi-row INSERT split around its VALUES list, so the same
+// statement can be rendered for one row or for a whole chunk
+type batchInsert struct {
+	insert   string // INSERT INTO table (columns...)
+	conflict string // ON CONFLICT clause applied to every row
+	columns  int
+}
+
+// query renders the statement with placeholders for the given number of rows
+func (b batchInsert) query(rows int) string {
+	var sb strings.Builder
+	sb.WriteString(b.insert)
+	sb.WriteString(" VALUES ")
+
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for c := 0; c < b.columns; c++ {
+			if c > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", r*b.columns+c+1)
+		}
+		sb.WriteByte(')')
+	}
+
+	sb.WriteByte(' ')
+	sb.WriteString(b.conflict)
+	return sb.String()
+}
+
+// table returns the table the statement inserts into
+func (b batchInsert) table() string {
+	fields := strings.Fields(b.insert)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+// writeBatch inserts rows in chunks of the configured batch size, one multi-row
+// statement per chunk. If a chunk fails its rows are retried one at a time through
+// execWrite, so a single bad row only fails (and is queued for retry) on its own.
+// It returns the number of rows written.
+func (mc *MetricsCollector) writeBatch(ctx context.Context, stmt batchInsert, rows [][]interface{}) int {
+	batchSize := mc.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultCollectorConfig().BatchSize
+	}
+	if batchSize*stmt.columns > maxQueryParams {
+		batchSize = maxQueryParams / stmt.columns
+	}
+
+	ctx, span := tracing.StartQuery(ctx, "batch insert",
+		attribute.String("db.sql.table", stmt.table()), attribute.Int("rows", len(rows)))
+	defer span.End()
+
+	written := 0
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		chunk := rows[start:end]
+
+		args := make([]interface{}, 0, len(chunk)*stmt.columns)
+		for _, row := range chunk {
+			args = append(args, row...)
+		}
+
+		_, err := mc.db.ExecContext(ctx, stmt.query(len(chunk)), args...)
+		if err == nil {
+			written += len(chunk)
+			continue
+		}
+		mc.log.Warnf("Batch insert of %d rows failed, retrying rows individually: %v", len(chunk), err)
+
+		single := stmt.query(1)
+		for _, row := range chunk {
+			if err := mc.execWrite(ctx, single, row...); err != nil {
+				mc.log.Warnf("Failed to write row, queued for retry: %v", err)
+				continue
+			}
+			written++
+		}
+	}
+
+	span.SetAttributes(attribute.Int("rows_written", written))
+	return written
+}
@@ -0,0 +1,142 @@
+package collectors
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"k8s-cost-optimizer/pkg/resilience"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	writeBufferDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "collector_write_buffer_depth",
+			Help: "Number of failed metric/cost writes waiting to be retried",
+		},
+	)
+
+	writeBufferDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "collector_write_buffer_dropped_total",
+			Help: "Total number of failed writes dropped because the buffer was full or retries were exhausted",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(writeBufferDepth, writeBufferDropped)
+}
+
+// pendingWrite is a failed statement waiting to be retried
+type pendingWrite struct {
+	query       string
+	args        []interface{}
+	attempts    int
+	nextAttempt time.Time
+}
+
+// WriteBuffer is a bounded in-memory retry queue for failed database writes, so
+// transient DB blips don't leave gaps in the metric and cost time series
+type WriteBuffer struct {
+	mu       sync.Mutex
+	pending  []pendingWrite
+	capacity int
+	retry    *resilience.RetryConfig
+}
+
+// NewWriteBuffer creates a write buffer holding at most capacity writes
+func NewWriteBuffer(capacity int, retry *resilience.RetryConfig) *WriteBuffer {
+	if retry == nil {
+		retry = resilience.DefaultRetryConfig()
+	}
+
+	return &WriteBuffer{
+		capacity: capacity,
+		retry:    retry,
+	}
+}
+
+// Add queues a failed write. When the buffer is full the oldest write is dropped.
+func (wb *WriteBuffer) Add(query string, args ...interface{}) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if wb.capacity <= 0 {
+		writeBufferDropped.Inc()
+		return
+	}
+
+	if len(wb.pending) >= wb.capacity {
+		wb.pending = wb.pending[1:]
+		writeBufferDropped.Inc()
+	}
+
+	wb.pending = append(wb.pending, pendingWrite{
+		query:       query,
+		args:        args,
+		nextAttempt: time.Now(),
+	})
+	writeBufferDepth.Set(float64(len(wb.pending)))
+}
+
+// Flush retries every write whose backoff has elapsed. Writes that fail again are
+// re-queued with a longer backoff until MaxAttempts is reached, then dropped.
+// It returns the number of writes that succeeded.
+func (wb *WriteBuffer) Flush(ctx context.Context, db *sql.DB) int {
+	wb.mu.Lock()
+	due := wb.pending
+	wb.pending = nil
+	wb.mu.Unlock()
+
+	now := time.Now()
+	flushed := 0
+	var remaining []pendingWrite
+
+	for i, write := range due {
+		if ctx.Err() != nil {
+			remaining = append(remaining, due[i:]...)
+			break
+		}
+
+		if now.Before(write.nextAttempt) {
+			remaining = append(remaining, write)
+			continue
+		}
+
+		if _, err := db.ExecContext(ctx, write.query, write.args...); err != nil {
+			write.attempts++
+			if write.attempts >= wb.retry.MaxAttempts {
+				writeBufferDropped.Inc()
+				continue
+			}
+			write.nextAttempt = now.Add(wb.retry.Backoff(write.attempts - 1))
+			remaining = append(remaining, write)
+			continue
+		}
+
+		flushed++
+	}
+
+	wb.mu.Lock()
+	// Keep writes that failed while we were flushing, bounded by capacity
+	wb.pending = append(remaining, wb.pending...)
+	if overflow := len(wb.pending) - wb.capacity; overflow > 0 {
+		wb.pending = wb.pending[overflow:]
+		writeBufferDropped.Add(float64(overflow))
+	}
+	writeBufferDepth.Set(float64(len(wb.pending)))
+	wb.mu.Unlock()
+
+	return flushed
+}
+
+// Len returns the number of buffered writes
+func (wb *WriteBuffer) Len() int {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return len(wb.pending)
+}
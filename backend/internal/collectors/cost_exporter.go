@@ -0,0 +1,82 @@
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+
+	"k8s-cost-optimizer/pkg/cloudprovider"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultCostMetricPrefix prefixes the exported cost metric names
+const DefaultCostMetricPrefix = "k8s"
+
+// Cost types of the exported namespace cost series
+const (
+	costTypeCompute = "compute"
+	costTypeStorage = "storage"
+	costTypeNetwork = "network"
+	costTypeOther   = "other"
+)
+
+var metricPrefixPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// CostExporter publishes the namespace costs computed each cost collection cycle as
+// Prometheus gauges on /metrics, so cost dashboards can be built on the existing
+// Prometheus and Grafana stack. One exporter is shared by every cluster's collector.
+type CostExporter struct {
+	namespaceCost *prometheus.GaugeVec
+}
+
+// NewCostExporter creates an exporter for <prefix>_namespace_cost_total and registers
+// it with the registerer. An empty prefix leaves the name unprefixed.
+func NewCostExporter(prefix string, registerer prometheus.Registerer) (*CostExporter, error) {
+	name := "namespace_cost_total"
+	if prefix != "" {
+		if !metricPrefixPattern.MatchString(prefix) {
+			return nil, fmt.Errorf("invalid metric prefix %q", prefix)
+		}
+		name = prefix + "_" + name
+	}
+
+	exporter := &CostExporter{
+		namespaceCost: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: name,
+				Help: "Namespace cost over the last cost collection hour, by cost type",
+			},
+			[]string{"cluster", "namespace", "type"},
+		),
+	}
+	if err := registerer.Register(exporter.namespaceCost); err != nil {
+		return nil, fmt.Errorf("registering cost metrics: %w", err)
+	}
+	return exporter, nil
+}
+
+// update replaces the cluster's series with its latest namespace costs, dropping
+// namespaces that no longer have costs
+func (e *CostExporter) update(cluster string, costs map[string]cloudprovider.NamespaceCost) {
+	e.namespaceCost.DeletePartialMatch(prometheus.Labels{"cluster": cluster})
+
+	for namespace, cost := range costs {
+		e.namespaceCost.WithLabelValues(cluster, namespace, costTypeCompute).Set(cost.Compute)
+		e.namespaceCost.WithLabelValues(cluster, namespace, costTypeStorage).Set(cost.Storage)
+		e.namespaceCost.WithLabelValues(cluster, namespace, costTypeNetwork).Set(cost.Network)
+		e.namespaceCost.WithLabelValues(cluster, namespace, costTypeOther).Set(cost.Other)
+	}
+}
+
+// SetCostExporter publishes the costs computed by each CollectCosts run through the
+// exporter
+func (mc *MetricsCollector) SetCostExporter(exporter *CostExporter) {
+	mc.costExporter = exporter
+}
+
+// exportCosts publishes the cycle's namespace costs, if an exporter is set
+func (mc *MetricsCollector) exportCosts(costs map[string]cloudprovider.NamespaceCost) {
+	if mc.costExporter != nil {
+		mc.costExporter.update(mc.config.ClusterName, costs)
+	}
+}
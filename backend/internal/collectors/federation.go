@@ -0,0 +1,304 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s-cost-optimizer/pkg/resilience"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for the circuit breaker wrapping each Prometheus endpoint
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerTimeout   = time.Minute
+)
+
+// ErrPrometheusUnavailable is returned by queries while every Prometheus endpoint's
+// circuit breaker is open
+var ErrPrometheusUnavailable = errors.New("prometheus circuit breaker is open")
+
+// prometheusRetry retries failed queries within a collection cycle. A whole retried
+// query counts as a single breaker result.
+var prometheusRetry = &resilience.RetryConfig{
+	MaxAttempts:       3,
+	InitialDelay:      time.Second,
+	MaxDelay:          10 * time.Second,
+	BackoffMultiplier: 2.0,
+	Jitter:            true,
+}
+
+// PrometheusEndpoint is a Prometheus or Thanos query endpoint. Timeout bounds each
+// attempt of a query; zero leaves them bounded only by the collection cycle's context.
+type PrometheusEndpoint struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// PrometheusStatus is the circuit breaker state of a Prometheus endpoint: "closed",
+// "open" or "half_open"
+type PrometheusStatus struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+// prometheusEndpoint queries one endpoint, retrying failed queries with each attempt
+// bounded by its timeout, and short-circuited by its own breaker while it keeps failing
+type prometheusEndpoint struct {
+	url     string
+	api     v1.API
+	timeout time.Duration
+	breaker *resilience.CircuitBreaker
+}
+
+func (e *prometheusEndpoint) run(ctx context.Context,
+	query func(ctx context.Context) (model.Value, v1.Warnings, error)) (model.Value, v1.Warnings, error) {
+	var value model.Value
+	var warnings v1.Warnings
+	err := e.breaker.Execute(ctx, func() error {
+		return resilience.Retry(ctx, prometheusRetry, func() error {
+			attemptCtx := ctx
+			if e.timeout > 0 {
+				var cancel context.CancelFunc
+				attemptCtx, cancel = context.WithTimeout(ctx, e.timeout)
+				defer cancel()
+			}
+
+			var err error
+			value, warnings, err = query(attemptCtx)
+			return err
+		})
+	})
+	return value, warnings, err
+}
+
+// federatedPrometheus runs every instant and range query against each endpoint in
+// parallel and sums the results by label set, for sharded Prometheus or Thanos setups
+// where no single endpoint has all of a cluster's series. The endpoints must hold
+// disjoint series, or they would be counted twice. Queries that fail on some endpoints
+// return the others' results with a warning per failure; they only fail when every
+// endpoint does. Other API methods go to the first endpoint.
+type federatedPrometheus struct {
+	v1.API
+	endpoints []*prometheusEndpoint
+}
+
+// newPrometheusAPI returns the Prometheus API for the collector's endpoints, or for
+// PrometheusURL when none are configured
+func newPrometheusAPI(config *CollectorConfig) (v1.API, error) {
+	endpoints := config.PrometheusEndpoints
+	if len(endpoints) == 0 {
+		endpoints = []PrometheusEndpoint{{URL: config.PrometheusURL}}
+	}
+
+	federated := &federatedPrometheus{}
+	for _, endpoint := range endpoints {
+		client, err := api.NewClient(api.Config{Address: endpoint.URL})
+		if err != nil {
+			return nil, fmt.Errorf("creating Prometheus client for %s: %w", endpoint.URL, err)
+		}
+		federated.endpoints = append(federated.endpoints, &prometheusEndpoint{
+			url:     endpoint.URL,
+			api:     tracedPrometheus{v1.NewAPI(client)},
+			timeout: endpoint.Timeout,
+			breaker: resilience.NewCircuitBreaker(config.BreakerThreshold, config.BreakerTimeout),
+		})
+	}
+	federated.API = federated.endpoints[0].api
+
+	return federated, nil
+}
+
+func (p *federatedPrometheus) Query(ctx context.Context, query string, ts time.Time, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	return p.fanOut(ctx, func(ctx context.Context, promAPI v1.API) (model.Value, v1.Warnings, error) {
+		return promAPI.Query(ctx, query, ts, opts...)
+	})
+}
+
+func (p *federatedPrometheus) QueryRange(ctx context.Context, query string, r v1.Range, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	return p.fanOut(ctx, func(ctx context.Context, promAPI v1.API) (model.Value, v1.Warnings, error) {
+		return promAPI.QueryRange(ctx, query, r, opts...)
+	})
+}
+
+// available reports whether any endpoint's breaker would let a query through
+func (p *federatedPrometheus) available() bool {
+	for _, endpoint := range p.endpoints {
+		if endpoint.breaker.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// fanOut runs the query on every endpoint and merges the results
+func (p *federatedPrometheus) fanOut(ctx context.Context,
+	query func(ctx context.Context, promAPI v1.API) (model.Value, v1.Warnings, error)) (model.Value, v1.Warnings, error) {
+	if !p.available() {
+		return nil, nil, ErrPrometheusUnavailable
+	}
+	if len(p.endpoints) == 1 {
+		endpoint := p.endpoints[0]
+		return endpoint.run(ctx, func(ctx context.Context) (model.Value, v1.Warnings, error) {
+			return query(ctx, endpoint.api)
+		})
+	}
+
+	values := make([]model.Value, len(p.endpoints))
+	warnings := make([]v1.Warnings, len(p.endpoints))
+	errs := make([]error, len(p.endpoints))
+
+	var wg sync.WaitGroup
+	for i, endpoint := range p.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *prometheusEndpoint) {
+			defer wg.Done()
+			values[i], warnings[i], errs[i] = endpoint.run(ctx, func(ctx context.Context) (model.Value, v1.Warnings, error) {
+				return query(ctx, endpoint.api)
+			})
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	var merged model.Value
+	var allWarnings v1.Warnings
+	var failures []error
+	for i, endpoint := range p.endpoints {
+		allWarnings = append(allWarnings, warnings[i]...)
+		if errs[i] != nil {
+			failures = append(failures, fmt.Errorf("prometheus %s: %w", endpoint.url, errs[i]))
+			continue
+		}
+
+		var err error
+		if merged, err = mergeValues(merged, values[i]); err != nil {
+			return nil, allWarnings, fmt.Errorf("merging results from %s: %w", endpoint.url, err)
+		}
+	}
+
+	if len(failures) == len(p.endpoints) {
+		return nil, allWarnings, errors.Join(failures...)
+	}
+	for _, failure := range failures {
+		logrus.Warnf("Partial Prometheus results: %v", failure)
+		allWarnings = append(allWarnings, failure.Error())
+	}
+
+	return merged, allWarnings, nil
+}
+
+// PrometheusAvailable reports whether Prometheus queries can be made, i.e. whether any
+// endpoint's circuit breaker is closed or ready for a trial query
+func (mc *MetricsCollector) PrometheusAvailable() bool {
+	if federated, ok := mc.promClient.(*federatedPrometheus); ok {
+		return federated.available()
+	}
+	return mc.promClient != nil
+}
+
+// PrometheusStatus returns the breaker state of each Prometheus endpoint
+func (mc *MetricsCollector) PrometheusStatus() []PrometheusStatus {
+	federated, ok := mc.promClient.(*federatedPrometheus)
+	if !ok {
+		return nil
+	}
+
+	statuses := make([]PrometheusStatus, len(federated.endpoints))
+	for i, endpoint := range federated.endpoints {
+		statuses[i] = PrometheusStatus{URL: endpoint.url, State: endpoint.breaker.StateName()}
+	}
+	return statuses
+}
+
+// skipPrometheus reports whether a Prometheus-based collection should be skipped this
+// cycle because every endpoint's breaker is open, so the cycle doesn't wait on queries
+// that would fail
+func (mc *MetricsCollector) skipPrometheus(collection string) bool {
+	if mc.PrometheusAvailable() {
+		return false
+	}
+	mc.log.Warnf("Prometheus unavailable, skipping %s collection this cycle", collection)
+	return true
+}
+
+// mergeValues sums two query results. Vector samples and matrix points with the same
+// label set (and timestamp) are added together; other series are kept as they are.
+func mergeValues(a, b model.Value) (model.Value, error) {
+	if a == nil {
+		return b, nil
+	}
+	if b == nil {
+		return a, nil
+	}
+	if a.Type() != b.Type() {
+		return nil, fmt.Errorf("result types differ: %s and %s", a.Type(), b.Type())
+	}
+
+	switch a := a.(type) {
+	case model.Vector:
+		return mergeVectors(a, b.(model.Vector)), nil
+	case model.Matrix:
+		return mergeMatrices(a, b.(model.Matrix)), nil
+	case *model.Scalar:
+		return &model.Scalar{Value: a.Value + b.(*model.Scalar).Value, Timestamp: a.Timestamp}, nil
+	default:
+		return a, nil
+	}
+}
+
+func mergeVectors(a, b model.Vector) model.Vector {
+	index := make(map[model.Fingerprint]*model.Sample, len(a))
+	merged := make(model.Vector, 0, len(a)+len(b))
+	for _, vector := range []model.Vector{a, b} {
+		for _, sample := range vector {
+			fingerprint := sample.Metric.Fingerprint()
+			if existing, ok := index[fingerprint]; ok {
+				existing.Value += sample.Value
+				continue
+			}
+			copied := *sample
+			index[fingerprint] = &copied
+			merged = append(merged, &copied)
+		}
+	}
+	return merged
+}
+
+func mergeMatrices(a, b model.Matrix) model.Matrix {
+	index := make(map[model.Fingerprint]map[model.Time]model.SampleValue, len(a))
+	var merged model.Matrix
+	for _, matrix := range []model.Matrix{a, b} {
+		for _, stream := range matrix {
+			fingerprint := stream.Metric.Fingerprint()
+			points, ok := index[fingerprint]
+			if !ok {
+				points = make(map[model.Time]model.SampleValue, len(stream.Values))
+				index[fingerprint] = points
+				merged = append(merged, &model.SampleStream{Metric: stream.Metric})
+			}
+			for _, point := range stream.Values {
+				points[point.Timestamp] += point.Value
+			}
+		}
+	}
+
+	for _, stream := range merged {
+		points := index[stream.Metric.Fingerprint()]
+		stream.Values = make([]model.SamplePair, 0, len(points))
+		for timestamp, value := range points {
+			stream.Values = append(stream.Values, model.SamplePair{Timestamp: timestamp, Value: value})
+		}
+		sort.Slice(stream.Values, func(i, j int) bool {
+			return stream.Values[i].Timestamp < stream.Values[j].Timestamp
+		})
+	}
+	return merged
+}
@@ -0,0 +1,113 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// gpuResourceName is the extended resource advertised by the NVIDIA device plugin
+const gpuResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+// CollectGPUMetrics records per-container GPU utilization from the DCGM exporter.
+// DCGM_FI_DEV_GPU_UTIL is reported per device (0-100), so we store the average across
+// the container's devices along with the device count.
+func (mc *MetricsCollector) CollectGPUMetrics(ctx context.Context) (err error) {
+	defer observeRun(collectorGPU, time.Now(), &err)
+
+	if mc.promClient == nil {
+		return fmt.Errorf("Prometheus client not available")
+	}
+	if mc.skipPrometheus("GPU") {
+		return nil
+	}
+
+	labels := fmt.Sprintf("%s, %s, %s", mc.config.NamespaceLabel, mc.config.PodLabel, mc.config.ContainerLabel)
+	timestamp := time.Now()
+
+	utilResult, _, err := mc.promClient.Query(ctx, fmt.Sprintf(`avg by (%s) (DCGM_FI_DEV_GPU_UTIL)`, labels), timestamp)
+	if err != nil {
+		return fmt.Errorf("querying GPU utilization: %w", err)
+	}
+
+	countResult, _, err := mc.promClient.Query(ctx, fmt.Sprintf(`count by (%s) (DCGM_FI_DEV_GPU_UTIL)`, labels), timestamp)
+	if err != nil {
+		return fmt.Errorf("querying GPU device count: %w", err)
+	}
+
+	utilization, ok := utilResult.(model.Vector)
+	if !ok {
+		return fmt.Errorf("unexpected result type: %T", utilResult)
+	}
+
+	devices := make(map[string]float64)
+	if vector, ok := countResult.(model.Vector); ok {
+		for _, sample := range vector {
+			devices[sample.Metric.String()] = float64(sample.Value)
+		}
+	}
+
+	for _, sample := range utilization {
+		namespace := string(sample.Metric[model.LabelName(mc.config.NamespaceLabel)])
+		pod := string(sample.Metric[model.LabelName(mc.config.PodLabel)])
+		container := string(sample.Metric[model.LabelName(mc.config.ContainerLabel)])
+
+		// Devices not allocated to a pod have no pod labels
+		if namespace == "" || pod == "" || container == "" {
+			continue
+		}
+
+		deviceCount := devices[sample.Metric.String()]
+		if deviceCount == 0 {
+			deviceCount = 1
+		}
+
+		err := mc.execWrite(ctx, `
+			INSERT INTO gpu_metrics
+			(namespace, pod_name, container_name, gpu_utilization, gpu_devices, timestamp, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp)
+			DO UPDATE SET
+				gpu_utilization = $4,
+				gpu_devices = $5
+		`, namespace, pod, container, float64(sample.Value), deviceCount, timestamp, mc.config.ClusterName)
+		recordWrite(collectorGPU, err)
+
+		if err != nil {
+			mc.log.Warnf("Failed to store GPU metrics for %s/%s/%s: %v", namespace, pod, container, err)
+		}
+	}
+
+	return nil
+}
+
+// gpuRequestsInsert upserts containers' GPU requests and limits
+var gpuRequestsInsert = batchInsert{
+	insert: `INSERT INTO gpu_metrics
+		(cluster, namespace, pod_name, container_name, gpu_request, gpu_limit, timestamp)`,
+	conflict: `ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp)
+		DO UPDATE SET
+			gpu_request = EXCLUDED.gpu_request,
+			gpu_limit = EXCLUDED.gpu_limit`,
+	columns: 7,
+}
+
+// gpuRequestRow returns the gpuRequestsInsert row of a container's GPU request and
+// limit, and false for containers without GPUs. Extended resources can't be
+// overcommitted, so a missing request defaults to the limit.
+func gpuRequestRow(cluster, namespace, pod string, container *corev1.Container, timestamp time.Time) ([]interface{}, bool) {
+	gpuLimit := container.Resources.Limits[gpuResourceName]
+	gpuRequest, ok := container.Resources.Requests[gpuResourceName]
+	if !ok {
+		gpuRequest = gpuLimit
+	}
+
+	if gpuRequest.IsZero() && gpuLimit.IsZero() {
+		return nil, false
+	}
+
+	return []interface{}{cluster, namespace, pod, container.Name, gpuRequest.Value(), gpuLimit.Value(), timestamp}, true
+}
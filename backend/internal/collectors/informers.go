@@ -0,0 +1,82 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Interval at which the informer re-delivers every cached pod. Watches keep the
+// cache current between resyncs, so this only bounds drift from missed events.
+const informerResync = 10 * time.Minute
+
+// StartInformers starts a shared pod informer and waits for its cache to sync.
+// Until it returns successfully, collections fall back to listing pods from the
+// API server. Call StopInformers on shutdown.
+func (mc *MetricsCollector) StartInformers(ctx context.Context) error {
+	if mc.informers != nil {
+		return nil
+	}
+
+	factory := informers.NewSharedInformerFactory(mc.k8sClient, informerResync)
+	podInformer := factory.Core().V1().Pods()
+	lister := podInformer.Lister()
+
+	stopCh := make(chan struct{})
+	factory.Start(stopCh)
+
+	if !cache.WaitForCacheSync(ctx.Done(), podInformer.Informer().HasSynced) {
+		close(stopCh)
+		factory.Shutdown()
+		return fmt.Errorf("waiting for pod informer cache to sync: %w", ctx.Err())
+	}
+
+	mc.informers = factory
+	mc.stopInformers = stopCh
+	mc.podLister = lister
+	mc.log.Info("Pod informer cache synced")
+	return nil
+}
+
+// StopInformers stops the shared informers and waits for their goroutines to exit.
+// The lister keeps serving the last cached state, so a collection still in flight
+// during shutdown doesn't fail.
+func (mc *MetricsCollector) StopInformers() {
+	if mc.stopInformers == nil {
+		return
+	}
+
+	close(mc.stopInformers)
+	mc.stopInformers = nil
+	mc.informers.Shutdown()
+}
+
+// listPods returns every pod in the cluster, from the informer cache when it is
+// running and from the API server otherwise. Pods from the cache are shared and
+// must not be modified.
+func (mc *MetricsCollector) listPods(ctx context.Context) ([]*corev1.Pod, error) {
+	if mc.podLister != nil {
+		pods, err := mc.podLister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("listing pods from cache: %w", err)
+		}
+		return pods, nil
+	}
+
+	list, err := mc.k8sClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	pods := make([]*corev1.Pod, 0, len(list.Items))
+	for i := range list.Items {
+		pods = append(pods, &list.Items[i])
+	}
+	return pods, nil
+}
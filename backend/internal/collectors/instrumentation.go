@@ -0,0 +1,89 @@
+package collectors
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector labels used on the collection metrics
+const (
+	collectorNamespace    = "namespace"
+	collectorPod          = "pod"
+	collectorNode         = "node"
+	collectorRequests     = "requests"
+	collectorGPU          = "gpu"
+	collectorCost         = "cost"
+	collectorWorkloadCost = "workload_cost"
+	collectorNetwork      = "network"
+	collectorSignals      = "signals"
+)
+
+var (
+	collectorRunDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "collector_run_duration_seconds",
+			Help:    "Duration of collection runs in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"collector"},
+	)
+
+	collectorRowsWritten = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "collector_rows_written_total",
+			Help: "Total number of rows written to the database by collectors",
+		},
+		[]string{"collector"},
+	)
+
+	collectorErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "collector_errors_total",
+			Help: "Total number of failed collection runs and failed row writes",
+		},
+		[]string{"collector"},
+	)
+
+	collectorLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "collector_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful collection run, for alerting on stalls",
+		},
+		[]string{"collector"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(collectorRunDuration, collectorRowsWritten, collectorErrors, collectorLastSuccess)
+}
+
+// observeRun records a collection run's duration and outcome. Defer it at the start
+// of the run with a pointer to the run's named error result.
+func observeRun(collector string, start time.Time, err *error) {
+	collectorRunDuration.WithLabelValues(collector).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		collectorErrors.WithLabelValues(collector).Inc()
+		return
+	}
+	collectorLastSuccess.WithLabelValues(collector).SetToCurrentTime()
+}
+
+// recordWrites counts written and failed rows for a collector
+func recordWrites(collector string, written, failed int) {
+	if written > 0 {
+		collectorRowsWritten.WithLabelValues(collector).Add(float64(written))
+	}
+	if failed > 0 {
+		collectorErrors.WithLabelValues(collector).Add(float64(failed))
+	}
+}
+
+// recordWrite counts a single row write
+func recordWrite(collector string, err error) {
+	if err != nil {
+		recordWrites(collector, 0, 1)
+		return
+	}
+	recordWrites(collector, 1, 0)
+}
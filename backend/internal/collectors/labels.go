@@ -0,0 +1,52 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// workloadLabelsInsert stores the current pod labels of a workload
+var workloadLabelsInsert = batchInsert{
+	insert: `INSERT INTO workload_labels
+		(cluster, namespace, workload_kind, workload, labels, updated_at)`,
+	conflict: `ON CONFLICT (cluster, namespace, workload_kind, workload)
+		DO UPDATE SET labels = EXCLUDED.labels, updated_at = EXCLUDED.updated_at`,
+	columns: 6,
+}
+
+// storeWorkloadLabels records the pod labels of each workload, keyed the same way as
+// workload_costs so costs can be grouped by label. Replicas normally share their
+// template's labels, so the first pod seen for a workload is taken as representative.
+func (mc *MetricsCollector) storeWorkloadLabels(ctx context.Context, pods []*corev1.Pod, timestamp time.Time) {
+	type workloadKey struct{ namespace, kind, name string }
+	seen := make(map[workloadKey]bool)
+
+	var rows [][]interface{}
+	for _, pod := range pods {
+		kind, workload := PodController(pod)
+		key := workloadKey{pod.Namespace, kind, workload}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		labels := pod.Labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		encoded, err := json.Marshal(labels)
+		if err != nil {
+			mc.log.Warnf("Failed to encode labels for %s/%s: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+		rows = append(rows, []interface{}{
+			mc.config.ClusterName, pod.Namespace, kind, workload, string(encoded), timestamp,
+		})
+	}
+
+	written := mc.writeBatch(ctx, workloadLabelsInsert, rows)
+	recordWrites(collectorRequests, written, len(rows)-written)
+}
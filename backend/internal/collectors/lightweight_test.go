@@ -0,0 +1,83 @@
+package collectors
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s-cost-optimizer/internal/store"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestLightweightCollection checks that a collector without Postgres stores requests
+// and mock costs through the SQLite repositories, pricing the namespace's average
+// usage per collection
+func TestLightweightCollection(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.OpenSQLite(ctx, filepath.Join(t.TempDir(), "kost.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	objects := append(testPods(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-00"}})
+	mc, err := NewMetricsCollector(fake.NewSimpleClientset(objects...), nil, st.Repositories(),
+		DefaultCollectorConfig(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mc.Lightweight() {
+		t.Fatal("collector without a database isn't lightweight")
+	}
+
+	if err := mc.CollectResourceRequests(ctx); err != nil {
+		t.Fatalf("collecting resource requests: %v", err)
+	}
+	requests, err := st.NamespaceRequests(ctx, "team-00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests.Containers != 10 {
+		t.Errorf("stored %d containers for team-00, want 10", requests.Containers)
+	}
+
+	// Two collections: 100m + 200m, then 300m once the second pod is gone
+	now := time.Now()
+	first, second := now.Add(-30*time.Minute), now.Add(-20*time.Minute)
+	err = st.WriteUsage(ctx, []store.UsageSample{
+		{Cluster: "default", Namespace: "team-00", PodName: "a", ContainerName: "c", CPU: 100, Memory: 1e8, Timestamp: first},
+		{Cluster: "default", Namespace: "team-00", PodName: "b", ContainerName: "c", CPU: 200, Memory: 1e8, Timestamp: first},
+		{Cluster: "default", Namespace: "team-00", PodName: "a", ContainerName: "c", CPU: 300, Memory: 2e8, Timestamp: second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mc.CollectCosts(ctx, nil); err != nil {
+		t.Fatalf("collecting costs: %v", err)
+	}
+	costs, err := st.ClusterCosts(ctx, "default", now.Add(-time.Hour), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costs.Namespaces) != 1 || costs.Namespaces[0].Namespace != "team-00" {
+		t.Fatalf("stored costs for %+v, want team-00 only", costs.Namespaces)
+	}
+	want := 300*0.00001 + 2e8*0.00000001
+	if got := costs.Namespaces[0].Compute; math.Abs(got-want) > 1e-9 {
+		t.Errorf("compute cost = %v, want %v", got, want)
+	}
+}
+
+func TestLightweightCollectorRejectsAllocationBasis(t *testing.T) {
+	config := DefaultCollectorConfig()
+	config.AllocationBasis = AllocationRequests
+	if _, err := NewMetricsCollector(fake.NewSimpleClientset(), nil, store.Repositories{}, config, nil); err == nil {
+		t.Error("lightweight collector accepted an allocation basis")
+	}
+}
@@ -7,11 +7,19 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/prometheus/client_golang/api"
+	"k8s-cost-optimizer/internal/store"
+	"k8s-cost-optimizer/internal/websocket"
+	"k8s-cost-optimizer/pkg/cloudprovider"
+	"k8s-cost-optimizer/pkg/resilience"
+
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	"k8s.io/metrics/pkg/client/clientset/versioned"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -21,47 +29,209 @@ type MetricsCollector struct {
 	metricsClient versioned.Interface
 	promClient    v1.API
 	db            *sql.DB
+	metrics       store.MetricsRepository
+	costs         store.CostRepository
+	config        *CollectorConfig
+	buffer        *WriteBuffer
+	rollups       *RollupAggregator
+	hub           *websocket.Hub
+	costExporter  *CostExporter
 	log           *logrus.Logger
+
+	// Shared informer cache for pods, set once StartInformers has synced
+	informers     informers.SharedInformerFactory
+	podLister     corelisters.PodLister
+	stopInformers chan struct{}
 }
 
-func NewMetricsCollector(k8sClient kubernetes.Interface, db *sql.DB) *MetricsCollector {
-	// Initialize Prometheus client
-	promClient, err := api.NewClient(api.Config{
-		Address: "http://prometheus:9090",
-	})
-	if err != nil {
-		logrus.Warnf("Failed to initialize Prometheus client: %v", err)
+// CollectorConfig holds collector configuration
+type CollectorConfig struct {
+	// Name of the cluster this collector monitors, stored with every usage and cost
+	// row so several clusters can share one database
+	ClusterName string
+
+	// Address of the Prometheus server to query
+	PrometheusURL string
+
+	// Prometheus or Thanos query endpoints that each hold part of the cluster's series,
+	// for sharded or federated setups. When set they replace PrometheusURL, and each
+	// query's results are summed across them by label set.
+	PrometheusEndpoints []PrometheusEndpoint
+
+	// Consecutive failures after which a Prometheus endpoint's circuit breaker opens,
+	// and how long it stays open before a trial query
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+
+	// Prometheus label names used to group series. Some clusters relabel these
+	// (e.g. kubernetes_namespace or exported_namespace).
+	NamespaceLabel string
+	PodLabel       string
+	ContainerLabel string
+
+	// Maximum number of failed writes held for retry on the next cycle
+	RetryBufferSize int
+
+	// Resolution of samples backfilled from Prometheus. Matching the collection
+	// interval keeps backfilled and collected data equally dense.
+	BackfillStep time.Duration
+
+	// Rows per multi-row INSERT when storing pod metrics
+	BatchSize int
+
+	// Namespaces whose resource requests are written at once. Each holds a
+	// database connection while writing; 1 processes namespaces serially.
+	Concurrency int
+
+	// Egress prices network costs are computed with
+	NetworkPricing NetworkPricing
+
+	// What namespaces' compute costs are split by. Empty keeps the provider's split.
+	AllocationBasis AllocationBasis
+}
+
+// DefaultCollectorConfig returns the in-cluster Prometheus address and the standard
+// cAdvisor/kube-state-metrics label names
+func DefaultCollectorConfig() *CollectorConfig {
+	return &CollectorConfig{
+		ClusterName:      "default",
+		PrometheusURL:    "http://prometheus:9090",
+		BreakerThreshold: DefaultBreakerThreshold,
+		BreakerTimeout:   DefaultBreakerTimeout,
+		NamespaceLabel:   "namespace",
+		PodLabel:         "pod",
+		ContainerLabel:   "container",
+		RetryBufferSize:  10000,
+		BackfillStep:     5 * time.Minute,
+		BatchSize:        500,
+		Concurrency:      DefaultConcurrency,
+		NetworkPricing:   *DefaultNetworkPricing(),
 	}
+}
 
-	var promAPI v1.API
-	if promClient != nil {
-		promAPI = v1.NewAPI(promClient)
+// NewMetricsCollector creates a collector that writes to db and reads back stored
+// resources through the repositories. Without db the collector is lightweight: it
+// stores only pod usage, resource requests and namespace costs, through the
+// repositories, for databases such as SQLite that don't have the Postgres tables.
+func NewMetricsCollector(k8sClient kubernetes.Interface, db *sql.DB, repositories store.Repositories,
+	config *CollectorConfig, hub *websocket.Hub) (*MetricsCollector, error) {
+	if config == nil {
+		config = DefaultCollectorConfig()
+	}
+	if db == nil && config.AllocationBasis != "" {
+		return nil, fmt.Errorf("allocating costs by %s needs Postgres", config.AllocationBasis)
 	}
 
-	// Initialize metrics client
-	metricsClient, err := versioned.NewForConfig(k8sClient.RESTClient().Config())
+	// Initialize Prometheus client
+	promAPI, err := newPrometheusAPI(config)
 	if err != nil {
-		logrus.Warnf("Failed to initialize metrics client: %v", err)
+		return nil, err
 	}
 
 	return &MetricsCollector{
 		k8sClient:     k8sClient,
-		metricsClient: metricsClient,
 		promClient:    promAPI,
 		db:            db,
-		log:           logrus.New(),
+		metrics:       repositories.Metrics,
+		costs:         repositories.Costs,
+		config:        config,
+		buffer: NewWriteBuffer(config.RetryBufferSize, &resilience.RetryConfig{
+			MaxAttempts:       10,
+			InitialDelay:      30 * time.Second,
+			MaxDelay:          30 * time.Minute,
+			BackoffMultiplier: 2.0,
+			Jitter:            true,
+		}),
+		rollups: NewRollupAggregator(db, config.ClusterName),
+		hub:     hub,
+		log:     logrus.New(),
+	}, nil
+}
+
+// SetMetricsClient sets the Metrics Server client pod and node usage are read from.
+// Without one, CollectPodMetrics and CollectNodeMetrics fail.
+func (mc *MetricsCollector) SetMetricsClient(client versioned.Interface) {
+	mc.metricsClient = client
+}
+
+// Lightweight reports whether the collector runs without Postgres. Only
+// CollectPodMetrics, CollectResourceRequests and CollectCosts store anything then,
+// and Prometheus backfill isn't available.
+func (mc *MetricsCollector) Lightweight() bool {
+	return mc.db == nil
+}
+
+// ClusterName returns the name of the cluster the collector monitors
+func (mc *MetricsCollector) ClusterName() string {
+	return mc.config.ClusterName
+}
+
+// execWrite runs a write statement and queues it for retry if it fails
+func (mc *MetricsCollector) execWrite(ctx context.Context, query string, args ...interface{}) error {
+	_, err := mc.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		mc.buffer.Add(query, args...)
+	}
+	return err
+}
+
+// FlushFailedWrites retries writes that failed in previous collection cycles
+func (mc *MetricsCollector) FlushFailedWrites(ctx context.Context) {
+	if mc.buffer.Len() == 0 {
+		return
+	}
+
+	flushed := mc.buffer.Flush(ctx, mc.db)
+	if flushed > 0 {
+		mc.log.Infof("Flushed %d buffered writes, %d still pending", flushed, mc.buffer.Len())
+	}
+}
+
+// ValidateLabels checks that the configured namespace label yields series in Prometheus
+// and logs a warning if it doesn't, since every namespace query would then return empty
+func (mc *MetricsCollector) ValidateLabels(ctx context.Context) error {
+	if mc.promClient == nil {
+		return fmt.Errorf("Prometheus client not available")
 	}
+
+	query := fmt.Sprintf(`count by (%s) (container_cpu_usage_seconds_total)`, mc.config.NamespaceLabel)
+	result, _, err := mc.promClient.Query(ctx, query, time.Now())
+	if err != nil {
+		return fmt.Errorf("querying label %q: %w", mc.config.NamespaceLabel, err)
+	}
+
+	found := false
+	if vector, ok := result.(model.Vector); ok {
+		for _, sample := range vector {
+			if sample.Metric[model.LabelName(mc.config.NamespaceLabel)] != "" {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		mc.log.Warnf("Prometheus label %q yields no series for container_cpu_usage_seconds_total; "+
+			"namespace metrics will be empty. Check collector.labels.namespace", mc.config.NamespaceLabel)
+	}
+
+	return nil
 }
 
-func (mc *MetricsCollector) CollectNamespaceMetrics(ctx context.Context) error {
+func (mc *MetricsCollector) CollectNamespaceMetrics(ctx context.Context) (err error) {
+	defer observeRun(collectorNamespace, time.Now(), &err)
+
 	if mc.promClient == nil {
 		return fmt.Errorf("Prometheus client not available")
 	}
+	if mc.skipPrometheus("namespace metrics") {
+		return nil
+	}
 
 	// Query CPU usage by namespace
-	cpuQuery := `sum by (namespace) (
+	cpuQuery := fmt.Sprintf(`sum by (%s) (
 		rate(container_cpu_usage_seconds_total[5m]) * 1000
-	)`
+	)`, mc.config.NamespaceLabel)
 	
 	result, warnings, err := mc.promClient.Query(ctx, cpuQuery, time.Now())
 	if err != nil {
@@ -73,14 +243,14 @@ func (mc *MetricsCollector) CollectNamespaceMetrics(ctx context.Context) error {
 	}
 
 	// Process CPU results
-	if err := mc.processNamespaceMetrics(result, "cpu_millicores"); err != nil {
+	if err := mc.processNamespaceMetrics(ctx, result, "cpu_millicores"); err != nil {
 		return fmt.Errorf("processing CPU metrics: %w", err)
 	}
 
 	// Query memory usage by namespace
-	memQuery := `sum by (namespace) (
+	memQuery := fmt.Sprintf(`sum by (%s) (
 		container_memory_working_set_bytes
-	)`
+	)`, mc.config.NamespaceLabel)
 	
 	memResult, _, err := mc.promClient.Query(ctx, memQuery, time.Now())
 	if err != nil {
@@ -88,20 +258,20 @@ func (mc *MetricsCollector) CollectNamespaceMetrics(ctx context.Context) error {
 	}
 
 	// Process memory results
-	if err := mc.processNamespaceMetrics(memResult, "memory_bytes"); err != nil {
+	if err := mc.processNamespaceMetrics(ctx, memResult, "memory_bytes"); err != nil {
 		return fmt.Errorf("processing memory metrics: %w", err)
 	}
 
 	// Query storage usage by namespace
-	storageQuery := `sum by (namespace, persistentvolumeclaim) (
+	storageQuery := fmt.Sprintf(`sum by (%s, persistentvolumeclaim) (
 		kubelet_volume_stats_used_bytes
-	)`
+	)`, mc.config.NamespaceLabel)
 	
 	storageResult, _, err := mc.promClient.Query(ctx, storageQuery, time.Now())
 	if err != nil {
 		mc.log.Warnf("Failed to query storage metrics: %v", err)
 	} else {
-		if err := mc.processStorageMetrics(storageResult); err != nil {
+		if err := mc.processStorageMetrics(ctx, storageResult); err != nil {
 			mc.log.Warnf("Failed to process storage metrics: %v", err)
 		}
 	}
@@ -109,84 +279,128 @@ func (mc *MetricsCollector) CollectNamespaceMetrics(ctx context.Context) error {
 	return nil
 }
 
-func (mc *MetricsCollector) processNamespaceMetrics(result model.Value, metricType string) error {
-	matrix, ok := result.(model.Matrix)
+func (mc *MetricsCollector) processNamespaceMetrics(ctx context.Context, result model.Value, metricType string) error {
+	// Instant queries return a vector
+	vector, ok := result.(model.Vector)
 	if !ok {
 		return fmt.Errorf("unexpected result type: %T", result)
 	}
 
 	timestamp := time.Now()
 	
-	for _, sample := range matrix {
-		namespace := string(sample.Metric["namespace"])
+	for _, sample := range vector {
+		namespace := string(sample.Metric[model.LabelName(mc.config.NamespaceLabel)])
 		if namespace == "" {
 			continue
 		}
 
-		// Get the latest value
-		if len(sample.Values) == 0 {
-			continue
-		}
-		
-		value := float64(sample.Values[len(sample.Values)-1].Value)
+		value := float64(sample.Value)
 
 		// Store in database
-		_, err := mc.db.Exec(`
+		err := mc.execWrite(ctx, `
 			INSERT INTO namespace_metrics 
-			(namespace, metric_type, value, timestamp) 
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (namespace, metric_type, timestamp) 
+			(namespace, metric_type, value, timestamp, cluster) 
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (cluster, namespace, metric_type, timestamp) 
 			DO UPDATE SET value = $3
-		`, namespace, metricType, value, timestamp)
+		`, namespace, metricType, value, timestamp, mc.config.ClusterName)
+		recordWrite(collectorNamespace, err)
 		
 		if err != nil {
-			return fmt.Errorf("storing %s metrics for namespace %s: %w", metricType, namespace, err)
+			mc.log.Warnf("Failed to store %s metrics for namespace %s, queued for retry: %v", metricType, namespace, err)
+		}
+
+		// Push the new sample to dashboard clients subscribed to the namespace
+		if mc.hub != nil {
+			mc.hub.BroadcastToNamespace(namespace, websocket.Message{
+				Type:      "metrics_update",
+				Namespace: namespace,
+				Data: map[string]interface{}{
+					"metric_type": metricType,
+					"value":       value,
+				},
+				Timestamp: timestamp,
+			})
 		}
 	}
 
 	return nil
 }
 
-func (mc *MetricsCollector) processStorageMetrics(result model.Value) error {
-	matrix, ok := result.(model.Matrix)
+// processStorageMetrics stores each PVC's used bytes alongside the capacity it
+// requests, which the storage recommendations compare usage against
+func (mc *MetricsCollector) processStorageMetrics(ctx context.Context, result model.Value) error {
+	// Instant queries return a vector
+	vector, ok := result.(model.Vector)
 	if !ok {
 		return fmt.Errorf("unexpected result type: %T", result)
 	}
 
+	claims, err := mc.k8sClient.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		// Usage is still worth storing without the requested capacity
+		mc.log.Warnf("Failed to list PVCs: %v", err)
+		claims = &corev1.PersistentVolumeClaimList{}
+	}
+	requests := make(map[string]*corev1.PersistentVolumeClaim, len(claims.Items))
+	for i := range claims.Items {
+		requests[claims.Items[i].Namespace+"/"+claims.Items[i].Name] = &claims.Items[i]
+	}
+
 	timestamp := time.Now()
 	
-	for _, sample := range matrix {
-		namespace := string(sample.Metric["namespace"])
+	for _, sample := range vector {
+		namespace := string(sample.Metric[model.LabelName(mc.config.NamespaceLabel)])
 		pvc := string(sample.Metric["persistentvolumeclaim"])
 		
 		if namespace == "" || pvc == "" {
 			continue
 		}
 
-		if len(sample.Values) == 0 {
-			continue
+		value := float64(sample.Value)
+
+		var requestedBytes float64
+		var storageClass string
+		if claim, ok := requests[namespace+"/"+pvc]; ok {
+			requested := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+			requestedBytes = float64(requested.Value())
+			if claim.Spec.StorageClassName != nil {
+				storageClass = *claim.Spec.StorageClassName
+			}
 		}
-		
-		value := float64(sample.Values[len(sample.Values)-1].Value)
 
 		// Store storage metrics
-		_, err := mc.db.Exec(`
+		err := mc.execWrite(ctx, `
 			INSERT INTO storage_metrics 
-			(namespace, pvc_name, used_bytes, timestamp) 
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (namespace, pvc_name, timestamp) 
-			DO UPDATE SET used_bytes = $3
-		`, namespace, pvc, value, timestamp)
+			(namespace, pvc_name, used_bytes, requested_bytes, storage_class, timestamp, cluster) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (cluster, namespace, pvc_name, timestamp) 
+			DO UPDATE SET used_bytes = $3, requested_bytes = $4, storage_class = $5
+		`, namespace, pvc, value, requestedBytes, storageClass, timestamp, mc.config.ClusterName)
+		recordWrite(collectorNamespace, err)
 		
 		if err != nil {
-			return fmt.Errorf("storing storage metrics: %w", err)
+			mc.log.Warnf("Failed to store storage metrics for %s/%s, queued for retry: %v", namespace, pvc, err)
 		}
 	}
 
 	return nil
 }
 
-func (mc *MetricsCollector) CollectPodMetrics(ctx context.Context) error {
+// podMetricsInsert upserts a container's usage sample into pod_metrics
+var podMetricsInsert = batchInsert{
+	insert: `INSERT INTO pod_metrics
+		(cluster, namespace, pod_name, container_name, cpu_millicores, memory_bytes, timestamp)`,
+	conflict: `ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp)
+		DO UPDATE SET
+			cpu_millicores = EXCLUDED.cpu_millicores,
+			memory_bytes = EXCLUDED.memory_bytes`,
+	columns: 7,
+}
+
+func (mc *MetricsCollector) CollectPodMetrics(ctx context.Context) (err error) {
+	defer observeRun(collectorPod, time.Now(), &err)
+
 	if mc.metricsClient == nil {
 		return fmt.Errorf("metrics client not available")
 	}
@@ -201,35 +415,84 @@ func (mc *MetricsCollector) CollectPodMetrics(ctx context.Context) error {
 	}
 
 	timestamp := time.Now()
+	if mc.Lightweight() {
+		return mc.writeUsage(ctx, podMetricsList.Items, timestamp)
+	}
+
+	var metricRows, rollupRows [][]interface{}
 	
 	for _, podMetrics := range podMetricsList.Items {
 		for _, container := range podMetrics.Containers {
 			cpu := container.Usage.Cpu().MilliValue()
 			memory := container.Usage.Memory().Value()
 			
-			// Store detailed pod-level metrics
-			_, err = mc.db.Exec(`
-				INSERT INTO pod_metrics 
-				(namespace, pod_name, container_name, cpu_millicores, memory_bytes, timestamp)
-				VALUES ($1, $2, $3, $4, $5, $6)
-				ON CONFLICT (namespace, pod_name, container_name, timestamp) 
-				DO UPDATE SET 
-					cpu_millicores = $4,
-					memory_bytes = $5
-			`, podMetrics.Namespace, podMetrics.Name, container.Name, 
-			   cpu, memory, timestamp)
-			
+			metricRows = append(metricRows, []interface{}{
+				mc.config.ClusterName, podMetrics.Namespace, podMetrics.Name, container.Name, cpu, memory, timestamp,
+			})
+
+			// Update the incremental rollup the analyzer reads from
+			row, err := mc.rollups.Observe(ctx, podMetrics.Namespace, podMetrics.Name,
+				container.Name, timestamp, float64(cpu), float64(memory))
 			if err != nil {
-				mc.log.Warnf("Failed to store pod metrics for %s/%s/%s: %v", 
+				mc.log.Warnf("Failed to update rollup for %s/%s/%s: %v",
 					podMetrics.Namespace, podMetrics.Name, container.Name, err)
+				continue
 			}
+			rollupRows = append(rollupRows, row)
 		}
 	}
 
+	// Store detailed pod-level metrics and rollups in multi-row batches
+	written := mc.writeBatch(ctx, podMetricsInsert, metricRows)
+	recordWrites(collectorPod, written, len(metricRows)-written)
+	if written < len(metricRows) {
+		mc.log.Warnf("Stored %d of %d pod metrics", written, len(metricRows))
+	}
+	written = mc.writeBatch(ctx, rollupInsert, rollupRows)
+	recordWrites(collectorPod, written, len(rollupRows)-written)
+	if written < len(rollupRows) {
+		mc.log.Warnf("Stored %d of %d pod metric rollups", written, len(rollupRows))
+	}
+
+	mc.rollups.Prune(timestamp.Add(-rollupBucket))
+
+	// GPU utilization comes from the DCGM exporter rather than the Metrics Server
+	if err := mc.CollectGPUMetrics(ctx); err != nil {
+		mc.log.Warnf("Failed to collect GPU metrics: %v", err)
+	}
+
 	return nil
 }
 
-func (mc *MetricsCollector) CollectNodeMetrics(ctx context.Context) error {
+// writeUsage stores pod usage through the metrics repository, without the rollups and
+// GPU utilization only Postgres keeps
+func (mc *MetricsCollector) writeUsage(ctx context.Context, pods []metricsv1beta1.PodMetrics, timestamp time.Time) error {
+	var samples []store.UsageSample
+	for _, podMetrics := range pods {
+		for _, container := range podMetrics.Containers {
+			samples = append(samples, store.UsageSample{
+				Cluster:       mc.config.ClusterName,
+				Namespace:     podMetrics.Namespace,
+				PodName:       podMetrics.Name,
+				ContainerName: container.Name,
+				CPU:           float64(container.Usage.Cpu().MilliValue()),
+				Memory:        float64(container.Usage.Memory().Value()),
+				Timestamp:     timestamp,
+			})
+		}
+	}
+
+	if err := mc.metrics.WriteUsage(ctx, samples); err != nil {
+		recordWrites(collectorPod, 0, len(samples))
+		return fmt.Errorf("storing pod metrics: %w", err)
+	}
+	recordWrites(collectorPod, len(samples), 0)
+	return nil
+}
+
+func (mc *MetricsCollector) CollectNodeMetrics(ctx context.Context) (err error) {
+	defer observeRun(collectorNode, time.Now(), &err)
+
 	if mc.metricsClient == nil {
 		return fmt.Errorf("metrics client not available")
 	}
@@ -250,15 +513,16 @@ func (mc *MetricsCollector) CollectNodeMetrics(ctx context.Context) error {
 		memory := nodeMetrics.Usage.Memory().Value()
 		
 		// Store node metrics
-		_, err = mc.db.Exec(`
+		err = mc.execWrite(ctx, `
 			INSERT INTO node_metrics 
-			(node_name, cpu_millicores, memory_bytes, timestamp)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (node_name, timestamp) 
+			(node_name, cpu_millicores, memory_bytes, timestamp, cluster)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (cluster, node_name, timestamp) 
 			DO UPDATE SET 
 				cpu_millicores = $2,
 				memory_bytes = $3
-		`, nodeMetrics.Name, cpu, memory, timestamp)
+		`, nodeMetrics.Name, cpu, memory, timestamp, mc.config.ClusterName)
+		recordWrite(collectorNode, err)
 		
 		if err != nil {
 			mc.log.Warnf("Failed to store node metrics for %s: %v", nodeMetrics.Name, err)
@@ -268,59 +532,204 @@ func (mc *MetricsCollector) CollectNodeMetrics(ctx context.Context) error {
 	return nil
 }
 
-func (mc *MetricsCollector) CollectResourceRequests(ctx context.Context) error {
-	// Get all namespaces
-	namespaces, err := mc.k8sClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+// resourceRequestsInsert upserts containers' requests and limits with the workload
+// that owns their pod
+var resourceRequestsInsert = batchInsert{
+	insert: `INSERT INTO resource_requests
+		(cluster, namespace, pod_name, container_name, cpu_request, cpu_limit, memory_request, memory_limit,
+		 timestamp, owner_kind, owner_name)`,
+	conflict: `ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp)
+		DO UPDATE SET
+			cpu_request = EXCLUDED.cpu_request,
+			cpu_limit = EXCLUDED.cpu_limit,
+			memory_request = EXCLUDED.memory_request,
+			memory_limit = EXCLUDED.memory_limit,
+			owner_kind = EXCLUDED.owner_kind,
+			owner_name = EXCLUDED.owner_name`,
+	columns: 11,
+}
+
+// CollectResourceRequests stores every container's requests and limits. Namespaces
+// are written in parallel, up to the configured concurrency, each in its own batches.
+// Rows that fail are queued for retry and reported in the returned error.
+func (mc *MetricsCollector) CollectResourceRequests(ctx context.Context) (err error) {
+	defer observeRun(collectorRequests, time.Now(), &err)
+
+	// Served from the informer cache once it has synced, so this no longer
+	// lists every namespace's pods from the API server each cycle
+	pods, err := mc.listPods(ctx)
 	if err != nil {
-		return fmt.Errorf("listing namespaces: %w", err)
+		return err
 	}
 
 	timestamp := time.Now()
 
-	for _, namespace := range namespaces.Items {
-		// Get all pods in the namespace
-		pods, err := mc.k8sClient.CoreV1().Pods(namespace.Name).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			mc.log.Warnf("Failed to list pods in namespace %s: %v", namespace.Name, err)
-			continue
+	byNamespace := make(map[string][]*corev1.Pod)
+	var namespaces []string
+	for _, pod := range pods {
+		if _, ok := byNamespace[pod.Namespace]; !ok {
+			namespaces = append(namespaces, pod.Namespace)
+		}
+		byNamespace[pod.Namespace] = append(byNamespace[pod.Namespace], pod)
+	}
+
+	err = forEachNamespace(ctx, mc.config.Concurrency, namespaces, func(ctx context.Context, namespace string) error {
+		if mc.Lightweight() {
+			return mc.writeResources(ctx, byNamespace[namespace], timestamp)
 		}
+		return mc.storeResourceRequests(ctx, byNamespace[namespace], timestamp)
+	})
+
+	if !mc.Lightweight() {
+		mc.storeWorkloadLabels(ctx, pods, timestamp)
+	}
+
+	return err
+}
 
-		for _, pod := range pods.Items {
-			for _, container := range pod.Spec.Containers {
-				cpuRequest := container.Resources.Requests.Cpu().MilliValue()
-				cpuLimit := container.Resources.Limits.Cpu().MilliValue()
-				memoryRequest := container.Resources.Requests.Memory().Value()
-				memoryLimit := container.Resources.Limits.Memory().Value()
-
-				// Store resource requests/limits
-				_, err = mc.db.Exec(`
-					INSERT INTO resource_requests 
-					(namespace, pod_name, container_name, cpu_request, cpu_limit, memory_request, memory_limit, timestamp)
-					VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-					ON CONFLICT (namespace, pod_name, container_name, timestamp) 
-					DO UPDATE SET 
-						cpu_request = $4,
-						cpu_limit = $5,
-						memory_request = $6,
-						memory_limit = $7
-				`, namespace.Name, pod.Name, container.Name, 
-				   cpuRequest, cpuLimit, memoryRequest, memoryLimit, timestamp)
-				
-				if err != nil {
-					mc.log.Warnf("Failed to store resource requests for %s/%s/%s: %v", 
-						namespace.Name, pod.Name, container.Name, err)
-				}
+// storeResourceRequests writes the requests and limits, and GPU requests, of the
+// pods' containers
+func (mc *MetricsCollector) storeResourceRequests(ctx context.Context, pods []*corev1.Pod, timestamp time.Time) error {
+	var requestRows, gpuRows [][]interface{}
+
+	for _, pod := range pods {
+		// Stored so the analyzer can size DaemonSet and StatefulSet templates as a whole
+		ownerKind, ownerName := PodController(pod)
+
+		for i := range pod.Spec.Containers {
+			container := &pod.Spec.Containers[i]
+			requestRows = append(requestRows, []interface{}{
+				mc.config.ClusterName, pod.Namespace, pod.Name, container.Name,
+				container.Resources.Requests.Cpu().MilliValue(),
+				container.Resources.Limits.Cpu().MilliValue(),
+				container.Resources.Requests.Memory().Value(),
+				container.Resources.Limits.Memory().Value(),
+				timestamp, ownerKind, ownerName,
+			})
+
+			if row, ok := gpuRequestRow(mc.config.ClusterName, pod.Namespace, pod.Name, container, timestamp); ok {
+				gpuRows = append(gpuRows, row)
 			}
 		}
 	}
 
+	rows := len(requestRows) + len(gpuRows)
+	written := mc.writeBatch(ctx, resourceRequestsInsert, requestRows)
+	written += mc.writeBatch(ctx, gpuRequestsInsert, gpuRows)
+	recordWrites(collectorRequests, written, rows-written)
+
+	if written < rows {
+		return fmt.Errorf("stored %d of %d resource request rows", written, rows)
+	}
 	return nil
 }
 
-func (mc *MetricsCollector) CollectCosts(ctx context.Context, costProvider interface{}) error {
-	// This method will be implemented to collect costs from cloud providers
-	// For now, we'll use mock data
-	return mc.collectMockCosts(ctx)
+// writeResources stores the requests and limits of the pods' containers through the
+// metrics repository. GPU requests are only kept in Postgres.
+func (mc *MetricsCollector) writeResources(ctx context.Context, pods []*corev1.Pod, timestamp time.Time) error {
+	var resources []store.ContainerResources
+	for _, pod := range pods {
+		ownerKind, ownerName := PodController(pod)
+
+		for i := range pod.Spec.Containers {
+			container := &pod.Spec.Containers[i]
+			resources = append(resources, store.ContainerResources{
+				Cluster:       mc.config.ClusterName,
+				Namespace:     pod.Namespace,
+				PodName:       pod.Name,
+				ContainerName: container.Name,
+				CPURequest:    float64(container.Resources.Requests.Cpu().MilliValue()),
+				CPULimit:      float64(container.Resources.Limits.Cpu().MilliValue()),
+				MemoryRequest: float64(container.Resources.Requests.Memory().Value()),
+				MemoryLimit:   float64(container.Resources.Limits.Memory().Value()),
+				Owner:         store.Owner{Kind: ownerKind, Name: ownerName},
+				Timestamp:     timestamp,
+			})
+		}
+	}
+
+	if err := mc.metrics.WriteResources(ctx, resources); err != nil {
+		recordWrites(collectorRequests, 0, len(resources))
+		return fmt.Errorf("storing resource requests: %w", err)
+	}
+	recordWrites(collectorRequests, len(resources), 0)
+	return nil
+}
+
+// CollectCosts fetches the per-namespace cost breakdown for the previous hour from the
+// cloud provider and stores it in namespace_costs. Rows are keyed by the end of the hour,
+// so re-running a collection for the same hour overwrites rather than duplicates.
+func (mc *MetricsCollector) CollectCosts(ctx context.Context, costProvider cloudprovider.Provider) (err error) {
+	defer observeRun(collectorCost, time.Now(), &err)
+
+	if _, ok := costProvider.(*cloudprovider.MockCostProvider); ok || costProvider == nil {
+		return mc.collectMockCosts(ctx)
+	}
+
+	end := time.Now().Truncate(time.Hour)
+	start := end.Add(-time.Hour)
+
+	breakdown, err := costProvider.GetDetailedCosts(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("fetching detailed costs: %w", err)
+	}
+
+	// Fill in network costs the provider doesn't attribute to namespaces from egress metrics
+	networkCosts, err := mc.networkCosts(ctx, start, end)
+	if err != nil {
+		mc.log.Warnf("Failed to compute network costs: %v", err)
+	}
+
+	costs := make(map[string]cloudprovider.NamespaceCost, len(breakdown.Namespaces))
+	for namespace, cost := range breakdown.Namespaces {
+		if cost.Network == 0 {
+			cost.Network = networkCosts[namespace]
+		}
+		costs[namespace] = cost
+	}
+
+	// Split compute between namespaces on the configured allocation basis
+	mc.reallocateCompute(ctx, costs, start, end)
+
+	for namespace, cost := range costs {
+		err := mc.storeNamespaceCost(ctx, namespace, cost.Compute, cost.Storage, cost.Network, cost.Other, end)
+		if err != nil {
+			mc.log.Warnf("Failed to store costs for namespace %s: %v", namespace, err)
+		}
+	}
+	mc.exportCosts(costs)
+
+	return nil
+}
+
+func (mc *MetricsCollector) storeNamespaceCost(ctx context.Context, namespace string,
+	computeCost, storageCost, networkCost, otherCost float64, timestamp time.Time) error {
+	if mc.Lightweight() {
+		err := mc.costs.WriteCosts(ctx, []store.NamespaceCost{{
+			Cluster:   mc.config.ClusterName,
+			Namespace: namespace,
+			Compute:   computeCost,
+			Storage:   storageCost,
+			Network:   networkCost,
+			Other:     otherCost,
+		}}, timestamp)
+		recordWrite(collectorCost, err)
+		return err
+	}
+
+	err := mc.execWrite(ctx, `
+		INSERT INTO namespace_costs 
+		(namespace, compute_cost, storage_cost, network_cost, other_cost, timestamp, cluster)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (cluster, namespace, timestamp) 
+		DO UPDATE SET 
+			compute_cost = $2,
+			storage_cost = $3,
+			network_cost = $4,
+			other_cost = $5
+	`, namespace, computeCost, storageCost, networkCost, otherCost, timestamp, mc.config.ClusterName)
+	recordWrite(collectorCost, err)
+	return err
 }
 
 func (mc *MetricsCollector) collectMockCosts(ctx context.Context) error {
@@ -332,76 +741,115 @@ func (mc *MetricsCollector) collectMockCosts(ctx context.Context) error {
 
 	timestamp := time.Now()
 
+	networkCosts, err := mc.networkCosts(ctx, timestamp.Add(-time.Hour), timestamp)
+	if err != nil {
+		mc.log.Warnf("Failed to compute network costs: %v", err)
+	}
+
+	// Mock prices apply to the configured allocation basis, or to the namespace's
+	// average usage from Prometheus without one
+	var quantities map[string]resourceQuantity
+	if mc.config.AllocationBasis != "" {
+		quantities, err = mc.allocationQuantities(ctx, mc.config.AllocationBasis, timestamp.Add(-time.Hour), timestamp)
+		if err != nil {
+			return fmt.Errorf("loading %s for cost allocation: %w", mc.config.AllocationBasis, err)
+		}
+	}
+
+	costs := make(map[string]cloudprovider.NamespaceCost, len(namespaces.Items))
 	for _, namespace := range namespaces.Items {
 		// Calculate mock costs based on resource usage
 		var computeCost, storageCost, networkCost, otherCost float64
 
 		// Query recent resource usage
 		var cpuUsage, memoryUsage float64
-		err = mc.db.QueryRow(`
-			SELECT AVG(value) FROM namespace_metrics 
-			WHERE namespace = $1 AND metric_type = 'cpu_millicores' 
-			AND timestamp > NOW() - INTERVAL '1 hour'
-		`, namespace.Name).Scan(&cpuUsage)
-		if err != nil && err != sql.ErrNoRows {
-			mc.log.Warnf("Failed to get CPU usage for %s: %v", namespace.Name, err)
-		}
+		if quantities != nil {
+			cpuUsage, memoryUsage = quantities[namespace.Name].CPU, quantities[namespace.Name].Memory
+		} else if mc.Lightweight() {
+			usage, err := mc.namespaceUsage(ctx, namespace.Name, timestamp.Add(-time.Hour))
+			if err != nil {
+				mc.log.Warnf("Failed to get usage for %s: %v", namespace.Name, err)
+			}
+			cpuUsage, memoryUsage = usage.CPU, usage.Memory
+		} else {
+			err = mc.db.QueryRow(`
+				SELECT AVG(value) FROM namespace_metrics 
+				WHERE cluster = $2 AND namespace = $1 AND metric_type = 'cpu_millicores' 
+				AND timestamp > NOW() - INTERVAL '1 hour'
+			`, namespace.Name, mc.config.ClusterName).Scan(&cpuUsage)
+			if err != nil && err != sql.ErrNoRows {
+				mc.log.Warnf("Failed to get CPU usage for %s: %v", namespace.Name, err)
+			}
 
-		err = mc.db.QueryRow(`
-			SELECT AVG(value) FROM namespace_metrics 
-			WHERE namespace = $1 AND metric_type = 'memory_bytes' 
-			AND timestamp > NOW() - INTERVAL '1 hour'
-		`, namespace.Name).Scan(&memoryUsage)
-		if err != nil && err != sql.ErrNoRows {
-			mc.log.Warnf("Failed to get memory usage for %s: %v", namespace.Name, err)
+			err = mc.db.QueryRow(`
+				SELECT AVG(value) FROM namespace_metrics 
+				WHERE cluster = $2 AND namespace = $1 AND metric_type = 'memory_bytes' 
+				AND timestamp > NOW() - INTERVAL '1 hour'
+			`, namespace.Name, mc.config.ClusterName).Scan(&memoryUsage)
+			if err != nil && err != sql.ErrNoRows {
+				mc.log.Warnf("Failed to get memory usage for %s: %v", namespace.Name, err)
+			}
 		}
 
 		// Calculate mock costs (simplified pricing model)
 		computeCost = (cpuUsage * 0.00001) + (memoryUsage * 0.00000001) // $0.00001 per millicore, $0.00000001 per byte
 		storageCost = computeCost * 0.2  // 20% of compute cost
-		networkCost = computeCost * 0.1  // 10% of compute cost
+		networkCost = networkCosts[namespace.Name] // Egress over the last hour
 		otherCost = computeCost * 0.05   // 5% of compute cost
 
+		costs[namespace.Name] = cloudprovider.NamespaceCost{
+			Compute: computeCost,
+			Storage: storageCost,
+			Network: networkCost,
+			Other:   otherCost,
+		}
+
 		// Store costs
-		_, err = mc.db.Exec(`
-			INSERT INTO namespace_costs 
-			(namespace, compute_cost, storage_cost, network_cost, other_cost, timestamp)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			ON CONFLICT (namespace, timestamp) 
-			DO UPDATE SET 
-				compute_cost = $2,
-				storage_cost = $3,
-				network_cost = $4,
-				other_cost = $5
-		`, namespace.Name, computeCost, storageCost, networkCost, otherCost, timestamp)
-		
+		err = mc.storeNamespaceCost(ctx, namespace.Name, computeCost, storageCost, networkCost, otherCost, timestamp)
 		if err != nil {
 			mc.log.Warnf("Failed to store costs for namespace %s: %v", namespace.Name, err)
 		}
 	}
+	mc.exportCosts(costs)
 
 	return nil
 }
 
-// Helper method to get current resource allocation
-func (mc *MetricsCollector) GetCurrentAllocation(namespace, podName, containerName string) (map[string]float64, error) {
-	var cpuRequest, cpuLimit, memoryRequest, memoryLimit float64
+// namespaceUsage averages the namespace's total stored pod usage over the collections
+// since the given time, as namespace_metrics holds it from Prometheus
+func (mc *MetricsCollector) namespaceUsage(ctx context.Context, namespace string, since time.Time) (resourceQuantity, error) {
+	samples, err := mc.metrics.Usage(ctx, namespace, mc.config.ClusterName, since)
+	if err != nil {
+		return resourceQuantity{}, err
+	}
 
-	err := mc.db.QueryRow(`
-		SELECT cpu_request, cpu_limit, memory_request, memory_limit 
-		FROM resource_requests 
-		WHERE namespace = $1 AND pod_name = $2 AND container_name = $3
-		ORDER BY timestamp DESC LIMIT 1
-	`, namespace, podName, containerName).Scan(&cpuRequest, &cpuLimit, &memoryRequest, &memoryLimit)
+	var total resourceQuantity
+	collections := make(map[int64]bool)
+	for _, sample := range samples {
+		total.CPU += sample.CPU
+		total.Memory += sample.Memory
+		collections[sample.Timestamp.UnixMilli()] = true
+	}
+	if len(collections) == 0 {
+		return total, nil
+	}
+	return resourceQuantity{
+		CPU:    total.CPU / float64(len(collections)),
+		Memory: total.Memory / float64(len(collections)),
+	}, nil
+}
 
+// GetCurrentAllocation returns the container's most recently collected requests and limits
+func (mc *MetricsCollector) GetCurrentAllocation(ctx context.Context, namespace, podName, containerName string) (map[string]float64, error) {
+	current, err := mc.metrics.CurrentResources(ctx, namespace, mc.config.ClusterName, podName, containerName)
 	if err != nil {
 		return nil, fmt.Errorf("getting current allocation: %w", err)
 	}
 
 	return map[string]float64{
-		"cpu_request":    cpuRequest,
-		"cpu_limit":      cpuLimit,
-		"memory_request": memoryRequest,
-		"memory_limit":   memoryLimit,
+		"cpu_request":    current.CPURequest,
+		"cpu_limit":      current.CPULimit,
+		"memory_request": current.MemoryRequest,
+		"memory_limit":   current.MemoryLimit,
 	}, nil
 } 
\ No newline at end of file
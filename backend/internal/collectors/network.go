@@ -0,0 +1,211 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Destinations egress is priced by. Traffic within a zone or region is free on the
+// major clouds; traffic between zones and out to the internet is billed per GB.
+const (
+	EgressInternet    = "internet"
+	EgressCrossZone   = "cross_zone"
+	EgressIntraRegion = "intra_region"
+)
+
+// Directions stored in network_metrics
+const (
+	networkTransmit = "transmit"
+	networkReceive  = "receive"
+)
+
+// NetworkPricing prices namespace egress per GB by destination
+type NetworkPricing struct {
+	InternetPerGB  float64
+	CrossZonePerGB float64
+
+	// Label on container_network_transmit_bytes_total that classifies traffic by
+	// destination, with the values internet, cross_zone and intra_region. cAdvisor
+	// doesn't record destinations, so this needs a CNI or exporter that does.
+	DestinationLabel string
+
+	// Destination assumed for traffic without a DestinationLabel value
+	UnclassifiedAs string
+}
+
+// DefaultNetworkPricing returns typical public cloud egress prices. Without
+// destination labels, traffic is priced as cross-zone: most pod traffic stays in the
+// cluster, so pricing it all as internet egress would overstate network cost.
+func DefaultNetworkPricing() *NetworkPricing {
+	return &NetworkPricing{
+		InternetPerGB:  0.09,
+		CrossZonePerGB: 0.01,
+		UnclassifiedAs: EgressCrossZone,
+	}
+}
+
+// Validate checks that prices aren't negative and UnclassifiedAs is a destination
+func (p *NetworkPricing) Validate() error {
+	if p.InternetPerGB < 0 || p.CrossZonePerGB < 0 {
+		return fmt.Errorf("network prices can't be negative")
+	}
+	switch p.UnclassifiedAs {
+	case EgressInternet, EgressCrossZone, EgressIntraRegion:
+		return nil
+	default:
+		return fmt.Errorf("unclassified network traffic must be priced as %s, %s or %s, not %q",
+			EgressInternet, EgressCrossZone, EgressIntraRegion, p.UnclassifiedAs)
+	}
+}
+
+// destination maps a destination label value to the destination it is priced as
+func (p *NetworkPricing) destination(value string) string {
+	switch value {
+	case EgressInternet, EgressCrossZone, EgressIntraRegion:
+		return value
+	default:
+		return p.UnclassifiedAs
+	}
+}
+
+// perGB returns the egress price per GB to the destination
+func (p *NetworkPricing) perGB(destination string) float64 {
+	switch destination {
+	case EgressInternet:
+		return p.InternetPerGB
+	case EgressCrossZone:
+		return p.CrossZonePerGB
+	default:
+		return 0
+	}
+}
+
+// CollectNetworkMetrics records the bytes each pod transmitted and received since the
+// last collection, with transmitted bytes split by destination when the metrics expose
+// it. Received bytes are stored for idle detection and visibility; ingress isn't billed.
+func (mc *MetricsCollector) CollectNetworkMetrics(ctx context.Context) (err error) {
+	defer observeRun(collectorNetwork, time.Now(), &err)
+
+	if mc.promClient == nil {
+		return fmt.Errorf("Prometheus client not available")
+	}
+	if mc.skipPrometheus("network") {
+		return nil
+	}
+
+	timestamp := time.Now()
+	// Bytes sent since the previous collection, one interval ago
+	window := model.Duration(mc.config.BackfillStep)
+	if window <= 0 {
+		window = model.Duration(5 * time.Minute)
+	}
+	pricing := mc.config.NetworkPricing
+
+	groupBy := mc.config.NamespaceLabel + ", " + mc.config.PodLabel
+	transmitBy := groupBy
+	if pricing.DestinationLabel != "" {
+		transmitBy += ", " + pricing.DestinationLabel
+	}
+	transmitQuery := fmt.Sprintf(`sum by (%s) (increase(container_network_transmit_bytes_total[%s]))`,
+		transmitBy, window)
+	receiveQuery := fmt.Sprintf(`sum by (%s) (increase(container_network_receive_bytes_total[%s]))`,
+		groupBy, window)
+
+	transmitResult, _, err := mc.promClient.Query(ctx, transmitQuery, timestamp)
+	if err != nil {
+		return fmt.Errorf("querying transmitted bytes: %w", err)
+	}
+	receiveResult, _, err := mc.promClient.Query(ctx, receiveQuery, timestamp)
+	if err != nil {
+		return fmt.Errorf("querying received bytes: %w", err)
+	}
+
+	transmitted, ok := transmitResult.(model.Vector)
+	if !ok {
+		return fmt.Errorf("unexpected result type: %T", transmitResult)
+	}
+	received, ok := receiveResult.(model.Vector)
+	if !ok {
+		return fmt.Errorf("unexpected result type: %T", receiveResult)
+	}
+
+	// Several label values can map to the same destination
+	type networkKey struct {
+		namespace   string
+		pod         string
+		direction   string
+		destination string
+	}
+	totals := make(map[networkKey]float64)
+
+	for _, sample := range transmitted {
+		namespace := string(sample.Metric[model.LabelName(mc.config.NamespaceLabel)])
+		if namespace == "" {
+			continue
+		}
+		destination := pricing.UnclassifiedAs
+		if pricing.DestinationLabel != "" {
+			destination = pricing.destination(string(sample.Metric[model.LabelName(pricing.DestinationLabel)]))
+		}
+		pod := string(sample.Metric[model.LabelName(mc.config.PodLabel)])
+		totals[networkKey{namespace, pod, networkTransmit, destination}] += float64(sample.Value)
+	}
+	for _, sample := range received {
+		namespace := string(sample.Metric[model.LabelName(mc.config.NamespaceLabel)])
+		if namespace == "" {
+			continue
+		}
+		pod := string(sample.Metric[model.LabelName(mc.config.PodLabel)])
+		totals[networkKey{namespace, pod, networkReceive, ""}] += float64(sample.Value)
+	}
+
+	for key, bytes := range totals {
+		err := mc.execWrite(ctx, `
+			INSERT INTO network_metrics
+			(cluster, namespace, pod_name, direction, destination, bytes, timestamp)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (cluster, namespace, pod_name, direction, destination, timestamp)
+			DO UPDATE SET bytes = $6
+		`, mc.config.ClusterName, key.namespace, key.pod, key.direction, key.destination, bytes, timestamp)
+		recordWrite(collectorNetwork, err)
+
+		if err != nil {
+			mc.log.Warnf("Failed to store network metrics for %s/%s, queued for retry: %v", key.namespace, key.pod, err)
+		}
+	}
+
+	return nil
+}
+
+// networkCosts returns each namespace's egress cost between start and end from the
+// collected network_metrics. A lightweight collector doesn't collect network metrics,
+// so it has none.
+func (mc *MetricsCollector) networkCosts(ctx context.Context, start, end time.Time) (map[string]float64, error) {
+	if mc.Lightweight() {
+		return nil, nil
+	}
+	rows, err := mc.db.QueryContext(ctx, `
+		SELECT namespace, destination, SUM(bytes)
+		FROM network_metrics
+		WHERE cluster = $1 AND direction = $2 AND timestamp > $3 AND timestamp <= $4
+		GROUP BY namespace, destination
+	`, mc.config.ClusterName, networkTransmit, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("querying network usage: %w", err)
+	}
+	defer rows.Close()
+
+	costs := make(map[string]float64)
+	for rows.Next() {
+		var namespace, destination string
+		var bytes float64
+		if err := rows.Scan(&namespace, &destination, &bytes); err != nil {
+			return nil, fmt.Errorf("scanning network usage: %w", err)
+		}
+		costs[namespace] += bytes / 1e9 * mc.config.NetworkPricing.perGB(destination)
+	}
+	return costs, rows.Err()
+}
@@ -0,0 +1,60 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultConcurrency is how many namespaces a collection cycle processes at once
+const DefaultConcurrency = 4
+
+// forEachNamespace calls fn for every namespace on at most concurrency goroutines,
+// each taking the next namespace when it finishes one. The errors fn returns are
+// collected and joined, so one failing namespace doesn't stop the others. Namespaces
+// not yet started when ctx is cancelled are skipped.
+func forEachNamespace(ctx context.Context, concurrency int, namespaces []string,
+	fn func(ctx context.Context, namespace string) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(namespaces) {
+		concurrency = len(namespaces)
+	}
+
+	work := make(chan string)
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for namespace := range work {
+				if err := fn(ctx, namespace); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("namespace %s: %w", namespace, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, namespace := range namespaces {
+		select {
+		case work <- namespace:
+		case <-ctx.Done():
+			mu.Lock()
+			errs = append(errs, ctx.Err())
+			mu.Unlock()
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
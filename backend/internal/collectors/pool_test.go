@@ -0,0 +1,201 @@
+package collectors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s-cost-optimizer/internal/store"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// recordingConnector is a database that accepts every statement and records the rows
+// each batch insert writes, by table
+type recordingConnector struct {
+	mu   sync.Mutex
+	rows map[string][]string
+}
+
+func (c *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver { return recordingDriver{} }
+
+type recordingDriver struct{}
+
+func (recordingDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("open through the connector")
+}
+
+type recordingConn struct{ c *recordingConnector }
+
+func (recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (recordingConn) Close() error                        { return nil }
+func (recordingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+// batchColumns are the columns per row of the batch inserts CollectResourceRequests runs
+var batchColumns = map[string]int{
+	resourceRequestsInsert.table(): resourceRequestsInsert.columns,
+	gpuRequestsInsert.table():      gpuRequestsInsert.columns,
+	workloadLabelsInsert.table():   workloadLabelsInsert.columns,
+}
+
+func (r recordingConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	fields := strings.Fields(query)
+	if len(fields) < 3 || fields[0] != "INSERT" {
+		return nil, fmt.Errorf("unexpected statement %q", query)
+	}
+	table := fields[2]
+	columns, ok := batchColumns[table]
+	if !ok || len(args)%columns != 0 {
+		return nil, fmt.Errorf("unexpected insert into %s with %d arguments", table, len(args))
+	}
+
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+	for start := 0; start < len(args); start += columns {
+		values := make([]string, columns)
+		for i, arg := range args[start : start+columns] {
+			// Each run has its own collection time
+			if _, ok := arg.Value.(time.Time); ok {
+				values[i] = "<timestamp>"
+				continue
+			}
+			values[i] = fmt.Sprint(arg.Value)
+		}
+		r.c.rows[table] = append(r.c.rows[table], strings.Join(values, "|"))
+	}
+	return driver.RowsAffected(len(args) / columns), nil
+}
+
+// testPods returns pods in many namespaces with a mix of owners, labels, limits and
+// GPU requests
+func testPods() []runtime.Object {
+	var pods []runtime.Object
+	for n := 0; n < 30; n++ {
+		namespace := fmt.Sprintf("team-%02d", n)
+		for p := 0; p < 5; p++ {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace,
+					Name:      fmt.Sprintf("web-6d4cf56db6-%d", p),
+					Labels:    map[string]string{"app": "web", "pod-template-hash": "6d4cf56db6"},
+				},
+			}
+			if p%2 == 0 {
+				controller := true
+				pod.OwnerReferences = []metav1.OwnerReference{{
+					Kind: "ReplicaSet", Name: "web-6d4cf56db6", Controller: &controller,
+				}}
+			}
+
+			for c := 0; c < 2; c++ {
+				container := corev1.Container{
+					Name: fmt.Sprintf("c%d", c),
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    *resource.NewMilliQuantity(int64(100*(n+1)+p), resource.DecimalSI),
+							corev1.ResourceMemory: *resource.NewQuantity(int64(64<<20)*int64(c+1), resource.BinarySI),
+						},
+					},
+				}
+				if c == 1 {
+					container.Resources.Limits = corev1.ResourceList{
+						corev1.ResourceCPU: *resource.NewMilliQuantity(int64(500+n), resource.DecimalSI),
+						gpuResourceName:    *resource.NewQuantity(int64(p%2), resource.DecimalSI),
+					}
+				}
+				pod.Spec.Containers = append(pod.Spec.Containers, container)
+			}
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// collectResourceRequests runs CollectResourceRequests at the given concurrency and
+// returns the rows it wrote to each table, sorted
+func collectResourceRequests(t *testing.T, concurrency int) map[string][]string {
+	t.Helper()
+
+	recorder := &recordingConnector{rows: make(map[string][]string)}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+
+	config := DefaultCollectorConfig()
+	config.Concurrency = concurrency
+	config.BatchSize = 3 // Several batches per namespace
+
+	mc, err := NewMetricsCollector(fake.NewSimpleClientset(testPods()...), db, store.Repositories{}, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.CollectResourceRequests(context.Background()); err != nil {
+		t.Fatalf("collecting at concurrency %d: %v", concurrency, err)
+	}
+
+	for _, rows := range recorder.rows {
+		sort.Strings(rows)
+	}
+	return recorder.rows
+}
+
+func TestParallelCollectionMatchesSerial(t *testing.T) {
+	serial := collectResourceRequests(t, 1)
+
+	if got, want := len(serial[resourceRequestsInsert.table()]), 30*5*2; got != want {
+		t.Fatalf("serial collection wrote %d resource request rows, want %d", got, want)
+	}
+	if len(serial[gpuRequestsInsert.table()]) == 0 || len(serial[workloadLabelsInsert.table()]) == 0 {
+		t.Fatalf("serial collection wrote no GPU requests or workload labels: %v", serial)
+	}
+
+	for _, concurrency := range []int{2, 8, 64} {
+		parallel := collectResourceRequests(t, concurrency)
+		for table := range batchColumns {
+			if !reflect.DeepEqual(serial[table], parallel[table]) {
+				t.Errorf("concurrency %d wrote different %s rows than serial collection:\nserial:   %v\nparallel: %v",
+					concurrency, table, serial[table], parallel[table])
+			}
+		}
+	}
+}
+
+func TestForEachNamespaceJoinsErrors(t *testing.T) {
+	namespaces := []string{"a", "b", "c", "d"}
+	var mu sync.Mutex
+	visited := map[string]int{}
+
+	err := forEachNamespace(context.Background(), 3, namespaces, func(_ context.Context, namespace string) error {
+		mu.Lock()
+		visited[namespace]++
+		mu.Unlock()
+		if namespace == "b" || namespace == "d" {
+			return errors.New("write failed")
+		}
+		return nil
+	})
+
+	for _, namespace := range namespaces {
+		if visited[namespace] != 1 {
+			t.Errorf("namespace %s processed %d times, want once", namespace, visited[namespace])
+		}
+	}
+	if err == nil || !strings.Contains(err.Error(), "namespace b") || !strings.Contains(err.Error(), "namespace d") {
+		t.Errorf("error = %v, want failures of namespaces b and d", err)
+	}
+}
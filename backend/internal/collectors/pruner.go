@@ -0,0 +1,94 @@
+package collectors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// prunableTables maps each time-series table the collectors write to its time column
+var prunableTables = map[string]string{
+	"namespace_metrics":  "timestamp",
+	"pod_metrics":        "timestamp",
+	"pod_metrics_rollup": "bucket",
+	"node_metrics":       "timestamp",
+	"storage_metrics":    "timestamp",
+	"resource_requests":  "timestamp",
+	"gpu_metrics":        "timestamp",
+	"network_metrics":    "timestamp",
+	"container_signals":  "timestamp",
+	"namespace_costs":    "timestamp",
+	"workload_costs":     "timestamp",
+}
+
+// Pruner deletes collected data once it is older than its table's retention
+type Pruner struct {
+	db        *sql.DB
+	retention map[string]time.Duration
+	log       *logrus.Logger
+}
+
+// NewPruner creates a pruner with per-table retention overrides, e.g. keeping
+// namespace_costs for a year while raw pod_metrics only need the analysis window.
+// Tables without an override use the retention passed to PruneOldData.
+func NewPruner(db *sql.DB, tableRetention map[string]time.Duration) (*Pruner, error) {
+	for table, retention := range tableRetention {
+		if _, ok := prunableTables[table]; !ok {
+			return nil, fmt.Errorf("unknown table %q in retention settings", table)
+		}
+		if retention <= 0 {
+			return nil, fmt.Errorf("retention for %s must be positive, got %v", table, retention)
+		}
+	}
+
+	return &Pruner{
+		db:        db,
+		retention: tableRetention,
+		log:       logrus.New(),
+	}, nil
+}
+
+// PruneOldData deletes rows older than each table's retention, falling back to the
+// given default. A failure on one table is logged and the remaining tables are still
+// pruned; the first error is returned.
+func (p *Pruner) PruneOldData(ctx context.Context, retention time.Duration) error {
+	if retention <= 0 {
+		return fmt.Errorf("retention must be positive, got %v", retention)
+	}
+
+	tables := make([]string, 0, len(prunableTables))
+	for table := range prunableTables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var firstErr error
+	for _, table := range tables {
+		maxAge := retention
+		if override, ok := p.retention[table]; ok {
+			maxAge = override
+		}
+		cutoff := time.Now().Add(-maxAge)
+
+		// Table and column names come from prunableTables, never from user input
+		result, err := p.db.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE %s < $1", table, prunableTables[table]), cutoff)
+		if err != nil {
+			p.log.Errorf("Failed to prune %s: %v", table, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("pruning %s: %w", table, err)
+			}
+			continue
+		}
+
+		if deleted, err := result.RowsAffected(); err == nil && deleted > 0 {
+			p.log.Infof("Pruned %d rows older than %v from %s", deleted, maxAge, table)
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,109 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/cloudprovider"
+)
+
+// reconcileWindow is the period reconciled against the provider's cluster total, the
+// same 30 days GetClusterCosts reports
+const reconcileWindow = 30 * 24 * time.Hour
+
+// ErrNoBillingData is returned when reconciling without a cloud provider that reports
+// what the cluster actually cost
+var ErrNoBillingData = errors.New("no cloud provider billing data to reconcile against")
+
+// Reconciliation is the result of scaling a cluster's stored namespace costs to the
+// provider's authoritative total for the same period
+type Reconciliation struct {
+	Cluster        string    `json:"cluster"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	EstimatedTotal float64   `json:"estimated_total"`
+	ActualTotal    float64   `json:"actual_total"`
+	Factor         float64   `json:"reconciliation_factor"`
+	Namespaces     int       `json:"namespaces"`
+	ReconciledAt   time.Time `json:"reconciled_at"`
+}
+
+// ReconcileCosts scales the cluster's namespace_costs over the last 30 days so they sum
+// to the provider's cluster total, e.g. to include idle node cost and discounts the
+// per-namespace estimates miss. Each row keeps the factor it was scaled by, so
+// reconciling again rescales the original estimates instead of compounding. Costs
+// collected after a reconciliation are unscaled until the next one.
+func (mc *MetricsCollector) ReconcileCosts(ctx context.Context, costProvider cloudprovider.Provider) (*Reconciliation, error) {
+	if _, ok := costProvider.(*cloudprovider.MockCostProvider); ok || costProvider == nil {
+		return nil, ErrNoBillingData
+	}
+
+	end := time.Now().Truncate(time.Hour)
+	start := end.Add(-reconcileWindow)
+
+	clusterCosts, err := costProvider.GetClusterCosts(ctx, mc.config.ClusterName)
+	if err != nil {
+		return nil, fmt.Errorf("fetching cluster costs: %w", err)
+	}
+
+	tx, err := mc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &Reconciliation{
+		Cluster:      mc.config.ClusterName,
+		Start:        start,
+		End:          end,
+		ActualTotal:  clusterCosts.Total,
+		ReconciledAt: time.Now(),
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor), 0),
+			COUNT(DISTINCT namespace)
+		FROM namespace_costs
+		WHERE cluster = $1 AND timestamp > $2 AND timestamp <= $3
+	`, result.Cluster, start, end).Scan(&result.EstimatedTotal, &result.Namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("summing estimated costs: %w", err)
+	}
+	if result.EstimatedTotal <= 0 {
+		return nil, fmt.Errorf("no costs stored for cluster %s since %s", result.Cluster, start.Format(time.RFC3339))
+	}
+	result.Factor = result.ActualTotal / result.EstimatedTotal
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE namespace_costs SET
+			compute_cost = compute_cost / reconciliation_factor * $1,
+			storage_cost = storage_cost / reconciliation_factor * $1,
+			network_cost = network_cost / reconciliation_factor * $1,
+			other_cost = other_cost / reconciliation_factor * $1,
+			reconciliation_factor = $1
+		WHERE cluster = $2 AND timestamp > $3 AND timestamp <= $4
+	`, result.Factor, result.Cluster, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("scaling namespace costs: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO cost_reconciliations
+		(cluster, period_start, period_end, estimated_total, actual_total, reconciliation_factor, reconciled_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, result.Cluster, start, end, result.EstimatedTotal, result.ActualTotal, result.Factor, result.ReconciledAt)
+	if err != nil {
+		return nil, fmt.Errorf("recording reconciliation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	mc.log.Infof("Reconciled %d namespaces in cluster %s: estimated %.2f, actual %.2f, factor %.4f",
+		result.Namespaces, result.Cluster, result.EstimatedTotal, result.ActualTotal, result.Factor)
+	return result, nil
+}
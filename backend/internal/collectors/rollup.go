@@ -0,0 +1,309 @@
+package collectors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tdigest"
+)
+
+// rollupBucket is the granularity of the pod_metrics_rollup table
+const rollupBucket = time.Hour
+
+// rollupColumns are the pod_metrics_rollup columns written by the aggregator
+const rollupColumns = `INSERT INTO pod_metrics_rollup
+		(cluster, namespace, pod_name, container_name, bucket, sample_count,
+		 cpu_sum, cpu_sum_sq, cpu_min, cpu_max, cpu_digest,
+		 memory_sum, memory_sum_sq, memory_min, memory_max, memory_digest)`
+
+// rollupInsert upserts a container's hourly rollup row. The digests are replaced
+// rather than merged since the aggregator already folded the new sample into them.
+var rollupInsert = batchInsert{
+	insert: rollupColumns,
+	conflict: `ON CONFLICT (cluster, namespace, pod_name, container_name, bucket)
+		DO UPDATE SET
+			sample_count = pod_metrics_rollup.sample_count + EXCLUDED.sample_count,
+			cpu_sum = pod_metrics_rollup.cpu_sum + EXCLUDED.cpu_sum,
+			cpu_sum_sq = pod_metrics_rollup.cpu_sum_sq + EXCLUDED.cpu_sum_sq,
+			cpu_min = LEAST(pod_metrics_rollup.cpu_min, EXCLUDED.cpu_min),
+			cpu_max = GREATEST(pod_metrics_rollup.cpu_max, EXCLUDED.cpu_max),
+			cpu_digest = EXCLUDED.cpu_digest,
+			memory_sum = pod_metrics_rollup.memory_sum + EXCLUDED.memory_sum,
+			memory_sum_sq = pod_metrics_rollup.memory_sum_sq + EXCLUDED.memory_sum_sq,
+			memory_min = LEAST(pod_metrics_rollup.memory_min, EXCLUDED.memory_min),
+			memory_max = GREATEST(pod_metrics_rollup.memory_max, EXCLUDED.memory_max),
+			memory_digest = EXCLUDED.memory_digest`,
+	columns: 16,
+}
+
+// rollupReplace overwrites a rollup row with one recomputed from raw pod_metrics
+var rollupReplace = batchInsert{
+	insert: rollupColumns,
+	conflict: `ON CONFLICT (cluster, namespace, pod_name, container_name, bucket)
+		DO UPDATE SET
+			sample_count = EXCLUDED.sample_count,
+			cpu_sum = EXCLUDED.cpu_sum,
+			cpu_sum_sq = EXCLUDED.cpu_sum_sq,
+			cpu_min = EXCLUDED.cpu_min,
+			cpu_max = EXCLUDED.cpu_max,
+			cpu_digest = EXCLUDED.cpu_digest,
+			memory_sum = EXCLUDED.memory_sum,
+			memory_sum_sq = EXCLUDED.memory_sum_sq,
+			memory_min = EXCLUDED.memory_min,
+			memory_max = EXCLUDED.memory_max,
+			memory_digest = EXCLUDED.memory_digest`,
+	columns: 16,
+}
+
+type rollupKey struct {
+	namespace string
+	pod       string
+	container string
+}
+
+// rollupState holds the in-progress digests for a container's current bucket
+type rollupState struct {
+	bucket time.Time
+	cpu    *tdigest.TDigest
+	memory *tdigest.TDigest
+}
+
+// RollupAggregator maintains per-container hourly statistics incrementally as samples
+// are collected. Digests for the current bucket are kept in memory and loaded from
+// the database on a cache miss, e.g. after a restart.
+type RollupAggregator struct {
+	db      *sql.DB
+	cluster string
+	mu      sync.Mutex
+	states  map[rollupKey]*rollupState
+}
+
+func NewRollupAggregator(db *sql.DB, cluster string) *RollupAggregator {
+	return &RollupAggregator{
+		db:      db,
+		cluster: cluster,
+		states:  make(map[rollupKey]*rollupState),
+	}
+}
+
+// Observe folds a sample into the container's current bucket and returns the
+// rollupInsert row that persists the updated rollup
+func (ra *RollupAggregator) Observe(ctx context.Context, namespace, pod, container string,
+	timestamp time.Time, cpu, memory float64) ([]interface{}, error) {
+	bucket := timestamp.Truncate(rollupBucket)
+	key := rollupKey{namespace: namespace, pod: pod, container: container}
+
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	state, ok := ra.states[key]
+	if !ok || !state.bucket.Equal(bucket) {
+		var err error
+		state, err = ra.loadState(ctx, key, bucket)
+		if err != nil {
+			return nil, err
+		}
+		ra.states[key] = state
+	}
+
+	state.cpu.Add(cpu)
+	state.memory.Add(memory)
+
+	cpuDigest, err := state.cpu.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("encoding CPU digest: %w", err)
+	}
+	memoryDigest, err := state.memory.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("encoding memory digest: %w", err)
+	}
+
+	return []interface{}{
+		ra.cluster, namespace, pod, container, bucket, 1,
+		cpu, cpu * cpu, cpu, cpu, string(cpuDigest),
+		memory, memory * memory, memory, memory, string(memoryDigest),
+	}, nil
+}
+
+// Prune drops in-memory state for buckets older than the given bucket, so state for
+// deleted pods doesn't accumulate
+func (ra *RollupAggregator) Prune(before time.Time) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	for key, state := range ra.states {
+		if state.bucket.Before(before.Truncate(rollupBucket)) {
+			delete(ra.states, key)
+		}
+	}
+}
+
+// forget drops in-memory state for the bucket, so the next sample reloads the
+// digests from the database
+func (ra *RollupAggregator) forget(bucket time.Time) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	for key, state := range ra.states {
+		if state.bucket.Equal(bucket) {
+			delete(ra.states, key)
+		}
+	}
+}
+
+func (ra *RollupAggregator) loadState(ctx context.Context, key rollupKey, bucket time.Time) (*rollupState, error) {
+	state := &rollupState{
+		bucket: bucket,
+		cpu:    tdigest.New(tdigest.DefaultCompression),
+		memory: tdigest.New(tdigest.DefaultCompression),
+	}
+
+	var cpuDigest, memoryDigest []byte
+	err := ra.db.QueryRowContext(ctx, `
+		SELECT cpu_digest, memory_digest
+		FROM pod_metrics_rollup
+		WHERE cluster = $1 AND namespace = $2 AND pod_name = $3 AND container_name = $4 AND bucket = $5
+	`, ra.cluster, key.namespace, key.pod, key.container, bucket).Scan(&cpuDigest, &memoryDigest)
+
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading rollup for %s/%s/%s: %w", key.namespace, key.pod, key.container, err)
+	}
+
+	if len(cpuDigest) > 0 {
+		if state.cpu, err = tdigest.Decode(cpuDigest); err != nil {
+			return nil, err
+		}
+	}
+	if len(memoryDigest) > 0 {
+		if state.memory, err = tdigest.Decode(memoryDigest); err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+// rollupRow accumulates one container's raw samples within a bucket
+type rollupRow struct {
+	count                   int
+	cpuSum, cpuSumSq        float64
+	cpuMin, cpuMax          float64
+	memorySum, memorySumSq  float64
+	memoryMin, memoryMax    float64
+	cpuDigest, memoryDigest *tdigest.TDigest
+}
+
+func (r *rollupRow) add(cpu, memory float64) {
+	if r.count == 0 {
+		r.cpuMin, r.cpuMax, r.memoryMin, r.memoryMax = cpu, cpu, memory, memory
+	}
+	r.count++
+	r.cpuSum += cpu
+	r.cpuSumSq += cpu * cpu
+	r.cpuMin = math.Min(r.cpuMin, cpu)
+	r.cpuMax = math.Max(r.cpuMax, cpu)
+	r.memorySum += memory
+	r.memorySumSq += memory * memory
+	r.memoryMin = math.Min(r.memoryMin, memory)
+	r.memoryMax = math.Max(r.memoryMax, memory)
+	r.cpuDigest.Add(cpu)
+	r.memoryDigest.Add(memory)
+}
+
+// RebuildRollups recomputes this cluster's hourly rollups between from and to from the
+// raw pod_metrics, replacing whatever the buckets held. It is for filling rollups
+// after pod_metrics are backfilled, or repairing them. Buckets are rebuilt one at a
+// time to bound memory; the bucket in progress is left to the aggregator. It returns
+// the number of rollup rows written.
+func (mc *MetricsCollector) RebuildRollups(ctx context.Context, from, to time.Time) (int, error) {
+	if !from.Before(to) {
+		return 0, fmt.Errorf("rebuild start %s must be before its end %s",
+			from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if current := time.Now().Truncate(rollupBucket); to.After(current) {
+		to = current
+	}
+
+	written := 0
+	for bucket := from.Truncate(rollupBucket); bucket.Before(to); bucket = bucket.Add(rollupBucket) {
+		rows, err := mc.rebuildBucket(ctx, bucket)
+		if err != nil {
+			return written, fmt.Errorf("rebuilding rollups for %s: %w", bucket.Format(time.RFC3339), err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		n := mc.writeBatch(ctx, rollupReplace, rows)
+		recordWrites(collectorPod, n, len(rows)-n)
+		written += n
+		mc.rollups.forget(bucket)
+	}
+
+	return written, nil
+}
+
+// rebuildBucket computes the rollup rows for one bucket from raw pod_metrics
+func (mc *MetricsCollector) rebuildBucket(ctx context.Context, bucket time.Time) ([][]interface{}, error) {
+	rows, err := mc.db.QueryContext(ctx, `
+		SELECT namespace, pod_name, container_name, cpu_millicores, memory_bytes
+		FROM pod_metrics
+		WHERE cluster = $1 AND timestamp >= $2 AND timestamp < $3
+			AND cpu_millicores IS NOT NULL AND memory_bytes IS NOT NULL
+	`, mc.config.ClusterName, bucket, bucket.Add(rollupBucket))
+	if err != nil {
+		return nil, fmt.Errorf("querying pod metrics: %w", err)
+	}
+	defer rows.Close()
+
+	containers := make(map[rollupKey]*rollupRow)
+	var order []rollupKey
+	for rows.Next() {
+		var key rollupKey
+		var cpu, memory float64
+		if err := rows.Scan(&key.namespace, &key.pod, &key.container, &cpu, &memory); err != nil {
+			return nil, fmt.Errorf("scanning pod metrics: %w", err)
+		}
+
+		row, ok := containers[key]
+		if !ok {
+			row = &rollupRow{
+				cpuDigest:    tdigest.New(tdigest.DefaultCompression),
+				memoryDigest: tdigest.New(tdigest.DefaultCompression),
+			}
+			containers[key] = row
+			order = append(order, key)
+		}
+		row.add(cpu, memory)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading pod metrics: %w", err)
+	}
+
+	result := make([][]interface{}, 0, len(order))
+	for _, key := range order {
+		row := containers[key]
+		cpuDigest, err := row.cpuDigest.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("encoding CPU digest: %w", err)
+		}
+		memoryDigest, err := row.memoryDigest.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("encoding memory digest: %w", err)
+		}
+
+		result = append(result, []interface{}{
+			mc.config.ClusterName, key.namespace, key.pod, key.container, bucket, row.count,
+			row.cpuSum, row.cpuSumSq, row.cpuMin, row.cpuMax, string(cpuDigest),
+			row.memorySum, row.memorySumSq, row.memoryMin, row.memoryMax, string(memoryDigest),
+		})
+	}
+	return result, nil
+}
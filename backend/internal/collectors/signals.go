@@ -0,0 +1,146 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// containerSignalsInsert upserts containers' restarts, OOM kills and CPU throttling
+var containerSignalsInsert = batchInsert{
+	insert: `INSERT INTO container_signals
+		(cluster, namespace, pod_name, container_name, restarts, oom_killed, throttled_ratio, timestamp)`,
+	conflict: `ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp)
+		DO UPDATE SET
+			restarts = EXCLUDED.restarts,
+			oom_killed = EXCLUDED.oom_killed,
+			throttled_ratio = EXCLUDED.throttled_ratio`,
+	columns: 8,
+}
+
+// containerSignal is what a container's restarts and throttling looked like over one
+// collection interval
+type containerSignal struct {
+	restarts  float64
+	oomKilled bool
+	throttled *float64 // nil for containers without a CPU limit, which can't be throttled
+}
+
+// CollectContainerSignals records each container's restarts, whether its last
+// termination was an OOM kill, and the share of its CFS periods that were throttled
+// since the last collection. The analyzer uses them to hold back recommendations
+// that would shrink a container which is already OOMKilled or throttled.
+func (mc *MetricsCollector) CollectContainerSignals(ctx context.Context) (err error) {
+	defer observeRun(collectorSignals, time.Now(), &err)
+
+	if mc.promClient == nil {
+		return fmt.Errorf("Prometheus client not available")
+	}
+	if mc.skipPrometheus("container signal") {
+		return nil
+	}
+
+	labels := fmt.Sprintf("%s, %s, %s", mc.config.NamespaceLabel, mc.config.PodLabel, mc.config.ContainerLabel)
+	timestamp := time.Now()
+	// Restarts and throttling since the previous collection, one interval ago
+	window := model.Duration(mc.config.BackfillStep)
+	if window <= 0 {
+		window = model.Duration(5 * time.Minute)
+	}
+
+	restartQuery := fmt.Sprintf(`sum by (%s) (increase(kube_pod_container_status_restarts_total[%s]))`,
+		labels, window)
+	oomQuery := fmt.Sprintf(`max by (%s) (kube_pod_container_status_last_terminated_reason{reason="OOMKilled"})`,
+		labels)
+	throttleQuery := fmt.Sprintf(`sum by (%[1]s) (increase(container_cpu_cfs_throttled_periods_total[%[2]s]))
+		/ sum by (%[1]s) (increase(container_cpu_cfs_periods_total[%[2]s]))`, labels, window)
+
+	restarts, err := mc.queryVector(ctx, restartQuery, timestamp)
+	if err != nil {
+		return fmt.Errorf("querying container restarts: %w", err)
+	}
+	oomKilled, err := mc.queryVector(ctx, oomQuery, timestamp)
+	if err != nil {
+		return fmt.Errorf("querying OOM kills: %w", err)
+	}
+	throttled, err := mc.queryVector(ctx, throttleQuery, timestamp)
+	if err != nil {
+		return fmt.Errorf("querying CPU throttling: %w", err)
+	}
+
+	type containerKey struct {
+		namespace, pod, container string
+	}
+	signals := make(map[containerKey]*containerSignal)
+	signal := func(sample *model.Sample) *containerSignal {
+		key := containerKey{
+			string(sample.Metric[model.LabelName(mc.config.NamespaceLabel)]),
+			string(sample.Metric[model.LabelName(mc.config.PodLabel)]),
+			string(sample.Metric[model.LabelName(mc.config.ContainerLabel)]),
+		}
+		// cAdvisor reports pod-level cgroups without a container
+		if key.namespace == "" || key.pod == "" || key.container == "" {
+			return nil
+		}
+		if signals[key] == nil {
+			signals[key] = &containerSignal{}
+		}
+		return signals[key]
+	}
+
+	for _, sample := range restarts {
+		if s := signal(sample); s != nil {
+			s.restarts = float64(sample.Value)
+		}
+	}
+	for _, sample := range oomKilled {
+		if s := signal(sample); s != nil {
+			s.oomKilled = sample.Value > 0
+		}
+	}
+	for _, sample := range throttled {
+		// Containers that ran no CFS periods divide by zero
+		ratio := float64(sample.Value)
+		if math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+			continue
+		}
+		if s := signal(sample); s != nil {
+			s.throttled = &ratio
+		}
+	}
+
+	rows := make([][]interface{}, 0, len(signals))
+	for key, s := range signals {
+		var throttledRatio interface{}
+		if s.throttled != nil {
+			throttledRatio = *s.throttled
+		}
+		rows = append(rows, []interface{}{
+			mc.config.ClusterName, key.namespace, key.pod, key.container, s.restarts, s.oomKilled, throttledRatio, timestamp,
+		})
+	}
+
+	written := mc.writeBatch(ctx, containerSignalsInsert, rows)
+	recordWrites(collectorSignals, written, len(rows)-written)
+	if written < len(rows) {
+		mc.log.Warnf("Stored %d of %d container signals", written, len(rows))
+	}
+
+	return nil
+}
+
+// queryVector runs an instant query that returns a vector
+func (mc *MetricsCollector) queryVector(ctx context.Context, query string, ts time.Time) (model.Vector, error) {
+	result, _, err := mc.promClient.Query(ctx, query, ts)
+	if err != nil {
+		return nil, err
+	}
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type: %T", result)
+	}
+	return vector, nil
+}
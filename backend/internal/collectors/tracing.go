@@ -0,0 +1,43 @@
+package collectors
+
+import (
+	"context"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedPrometheus wraps the Prometheus API with a client span around each instant
+// and range query, recording the PromQL so slow queries can be found in traces
+type tracedPrometheus struct {
+	v1.API
+}
+
+func (p tracedPrometheus) Query(ctx context.Context, query string, ts time.Time, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "prometheus query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("prometheus.query", query)))
+
+	value, warnings, err := p.API.Query(ctx, query, ts, opts...)
+	tracing.End(span, err)
+	return value, warnings, err
+}
+
+func (p tracedPrometheus) QueryRange(ctx context.Context, query string, r v1.Range, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "prometheus query_range",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("prometheus.query", query),
+			attribute.String("prometheus.step", r.Step.String()),
+			attribute.Float64("prometheus.range_hours", r.End.Sub(r.Start).Hours()),
+		))
+
+	value, warnings, err := p.API.QueryRange(ctx, query, r, opts...)
+	tracing.End(span, err)
+	return value, warnings, err
+}
@@ -0,0 +1,186 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s-cost-optimizer/pkg/cloudprovider"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IdleNamespace is the namespace under which the unrequested share of each node's cost
+// is stored, so attributed and idle costs add up to the node spend
+const IdleNamespace = "__idle__"
+
+// workloadCostInsert stores the cost attributed to one container for one hour
+var workloadCostInsert = batchInsert{
+	insert: `INSERT INTO workload_costs
+		(cluster, namespace, workload_kind, workload, container_name, cpu_cost, memory_cost, timestamp)`,
+	conflict: `ON CONFLICT (cluster, namespace, workload_kind, workload, container_name, timestamp)
+		DO UPDATE SET cpu_cost = EXCLUDED.cpu_cost, memory_cost = EXCLUDED.memory_cost`,
+	columns: 8,
+}
+
+// workloadCostKey is the workload_costs key within one cluster and hour. Replicas of
+// a workload share it.
+type workloadCostKey struct {
+	namespace, kind, workload, container string
+}
+
+// workloadCostSums adds up the cost of every replica of each workload container, in the
+// order the keys were first seen, so each key is written once per batch. Postgres
+// rejects an upsert that touches the same row twice.
+type workloadCostSums struct {
+	keys  []workloadCostKey
+	costs map[workloadCostKey][2]float64 // CPU and memory cost
+}
+
+func (s *workloadCostSums) add(key workloadCostKey, cpuCost, memoryCost float64) {
+	if s.costs == nil {
+		s.costs = make(map[workloadCostKey][2]float64)
+	}
+	cost, seen := s.costs[key]
+	if !seen {
+		s.keys = append(s.keys, key)
+	}
+	s.costs[key] = [2]float64{cost[0] + cpuCost, cost[1] + memoryCost}
+}
+
+// rows renders the sums as workloadCostInsert rows
+func (s *workloadCostSums) rows(cluster string, timestamp time.Time) [][]interface{} {
+	rows := make([][]interface{}, 0, len(s.keys))
+	for _, key := range s.keys {
+		cost := s.costs[key]
+		rows = append(rows, []interface{}{
+			cluster, key.namespace, key.kind, key.workload, key.container, cost[0], cost[1], timestamp,
+		})
+	}
+	return rows
+}
+
+// CollectWorkloadCosts attributes each node's hourly price to the containers scheduled
+// on it in proportion to their requests. The price is split between CPU and memory by
+// CPUToMemoryPriceRatio over the node's allocatable capacity, and each container pays
+// for the share of that capacity it requests. Whatever no container requests is stored
+// against the node under IdleNamespace. Replicas of a workload are summed into one row.
+// Rows are keyed by the hour, so re-running a collection within the hour overwrites
+// rather than duplicates.
+func (mc *MetricsCollector) CollectWorkloadCosts(ctx context.Context, costProvider cloudprovider.Provider) (err error) {
+	defer observeRun(collectorWorkloadCost, time.Now(), &err)
+
+	if costProvider == nil {
+		return fmt.Errorf("no cloud provider configured")
+	}
+
+	nodeCosts, err := costProvider.GetNodeCosts(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching node costs: %w", err)
+	}
+
+	nodes, err := mc.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	pods, err := mc.listPods(ctx)
+	if err != nil {
+		return err
+	}
+	podsByNode := make(map[string][]*corev1.Pod)
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+	}
+
+	timestamp := time.Now().Truncate(time.Hour)
+	var sums workloadCostSums
+
+	for _, node := range nodes.Items {
+		hourly, ok := nodeCosts[node.Name]
+		if !ok || hourly <= 0 {
+			continue
+		}
+
+		allocCores := float64(node.Status.Allocatable.Cpu().MilliValue()) / 1000
+		allocGiB := float64(node.Status.Allocatable.Memory().Value()) / (1 << 30)
+		if allocCores+allocGiB == 0 {
+			continue
+		}
+
+		// hourly = cores * ratio * perGiB + GiB * perGiB
+		cpuWeight := allocCores * cloudprovider.CPUToMemoryPriceRatio
+		perGiBHour := hourly / (cpuWeight + allocGiB)
+		perCoreHour := perGiBHour * cloudprovider.CPUToMemoryPriceRatio
+
+		attributed := 0.0
+		for _, pod := range podsByNode[node.Name] {
+			kind, workload := PodController(pod)
+			for _, container := range pod.Spec.Containers {
+				cores := float64(container.Resources.Requests.Cpu().MilliValue()) / 1000
+				gib := float64(container.Resources.Requests.Memory().Value()) / (1 << 30)
+				cpuCost := cores * perCoreHour
+				memoryCost := gib * perGiBHour
+				if cpuCost+memoryCost == 0 {
+					continue
+				}
+
+				attributed += cpuCost + memoryCost
+				sums.add(workloadCostKey{pod.Namespace, kind, workload, container.Name}, cpuCost, memoryCost)
+			}
+		}
+
+		// Overcommitted nodes have no idle share rather than a negative one
+		if idle := hourly - attributed; idle > 0 {
+			sums.add(workloadCostKey{IdleNamespace, "Node", node.Name, ""},
+				idle*cpuWeight/(cpuWeight+allocGiB), idle*allocGiB/(cpuWeight+allocGiB))
+		}
+	}
+
+	rows := sums.rows(mc.config.ClusterName, timestamp)
+	written := mc.writeBatch(ctx, workloadCostInsert, rows)
+	recordWrites(collectorWorkloadCost, written, len(rows)-written)
+	return nil
+}
+
+// PodController returns the kind and name of the workload that created the pod,
+// following ReplicaSets to their Deployment and Jobs to their CronJob by name. Bare
+// pods are their own workload.
+func PodController(pod *corev1.Pod) (string, string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "Pod", pod.Name
+	}
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		if hash := pod.Labels["pod-template-hash"]; hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	case "Job":
+		if name, ok := cronJobName(owner.Name); ok {
+			return "CronJob", name
+		}
+	}
+	return owner.Kind, owner.Name
+}
+
+// cronJobName recovers the CronJob name from a Job it created, named
+// <cronjob>-<scheduled time in minutes>
+func cronJobName(jobName string) (string, bool) {
+	i := strings.LastIndex(jobName, "-")
+	if i <= 0 || len(jobName)-i-1 < 8 {
+		return "", false
+	}
+	for _, c := range jobName[i+1:] {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return jobName[:i], true
+}
@@ -0,0 +1,458 @@
+// Package costimport seeds namespace_costs with daily namespace costs from another
+// tool, such as a Kubecost or cloud billing export
+package costimport
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Supported file formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// dateLayout is the format of the date column
+const dateLayout = "2006-01-02"
+
+// maxCost is the largest cost namespace_costs' DECIMAL(10, 4) columns hold
+const maxCost = 999999.9999
+
+// Columns of an import file. A row gives either its total cost, recorded as compute,
+// or its cost broken down by component.
+const (
+	columnCluster   = "cluster"
+	columnNamespace = "namespace"
+	columnDate      = "date"
+	columnCost      = "cost"
+	columnCompute   = "compute_cost"
+	columnStorage   = "storage_cost"
+	columnNetwork   = "network_cost"
+	columnOther     = "other_cost"
+)
+
+var knownColumns = map[string]bool{
+	columnCluster:   true,
+	columnNamespace: true,
+	columnDate:      true,
+	columnCost:      true,
+	columnCompute:   true,
+	columnStorage:   true,
+	columnNetwork:   true,
+	columnOther:     true,
+}
+
+var componentColumns = []string{columnCompute, columnStorage, columnNetwork, columnOther}
+
+// Row is one namespace's cost for one day
+type Row struct {
+	Line      int
+	Cluster   string
+	Namespace string
+	Day       time.Time
+	Compute   float64
+	Storage   float64
+	Network   float64
+	Other     float64
+}
+
+// RowError reports why a row of the file was rejected
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// InvalidRowsError is returned when any row of the file is malformed. Nothing is
+// imported from a file with invalid rows.
+type InvalidRowsError struct {
+	Rows []RowError
+}
+
+func (e *InvalidRowsError) Error() string {
+	messages := make([]string, len(e.Rows))
+	for i, row := range e.Rows {
+		messages[i] = row.Error()
+	}
+	return fmt.Sprintf("%d invalid rows: %s", len(e.Rows), strings.Join(messages, "; "))
+}
+
+// Result counts the rows an import wrote, or would write on a dry run
+type Result struct {
+	Rows     int  `json:"rows"`
+	Inserted int  `json:"inserted"`
+	Updated  int  `json:"updated"` // Days that already had costs stored
+	DryRun   bool `json:"dry_run"`
+}
+
+// Parse reads the rows of a CSV file with a header line, or of a JSON array of
+// objects keyed by column name. Rows without a cluster are assigned defaultCluster.
+// The whole file is validated, and an *InvalidRowsError lists every invalid row.
+func Parse(r io.Reader, format, defaultCluster string) ([]Row, error) {
+	var records []record
+	var err error
+	switch format {
+	case FormatCSV:
+		records, err = readCSV(r)
+	case FormatJSON:
+		records, err = readJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %q, must be %s or %s", format, FormatCSV, FormatJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	var invalid []RowError
+	seen := make(map[string]int)
+	for _, rec := range records {
+		row, err := rec.row(defaultCluster)
+		if err != nil {
+			invalid = append(invalid, RowError{Line: rec.line, Message: err.Error()})
+			continue
+		}
+
+		key := row.Cluster + "/" + row.Namespace + "/" + row.Day.Format(dateLayout)
+		if line, ok := seen[key]; ok {
+			invalid = append(invalid, RowError{Line: rec.line,
+				Message: fmt.Sprintf("duplicates line %d for namespace %s on %s", line, row.Namespace, row.Day.Format(dateLayout))})
+			continue
+		}
+		seen[key] = rec.line
+		rows = append(rows, row)
+	}
+
+	if len(invalid) > 0 {
+		return nil, &InvalidRowsError{Rows: invalid}
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the file has no rows")
+	}
+	return rows, nil
+}
+
+// record is a row's values by column, before validation, or why they couldn't be read
+type record struct {
+	line   int
+	values map[string]string
+	err    error
+}
+
+func (rec record) row(defaultCluster string) (Row, error) {
+	if rec.err != nil {
+		return Row{}, rec.err
+	}
+	for column := range rec.values {
+		if !knownColumns[column] {
+			return Row{}, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	row := Row{
+		Line:      rec.line,
+		Cluster:   strings.TrimSpace(rec.values[columnCluster]),
+		Namespace: strings.TrimSpace(rec.values[columnNamespace]),
+	}
+	if row.Cluster == "" {
+		row.Cluster = defaultCluster
+	}
+
+	if row.Namespace == "" {
+		return Row{}, errors.New("namespace is required")
+	}
+	if errs := validation.IsDNS1123Label(row.Namespace); len(errs) > 0 {
+		return Row{}, fmt.Errorf("invalid namespace %q: %s", row.Namespace, strings.Join(errs, "; "))
+	}
+
+	date := strings.TrimSpace(rec.values[columnDate])
+	if date == "" {
+		return Row{}, errors.New("date is required")
+	}
+	day, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return Row{}, fmt.Errorf("invalid date %q, must be YYYY-MM-DD", date)
+	}
+	if day.After(time.Now()) {
+		return Row{}, fmt.Errorf("date %s is in the future", date)
+	}
+	row.Day = day
+
+	total, hasTotal, err := rec.cost(columnCost)
+	if err != nil {
+		return Row{}, err
+	}
+
+	components := []*float64{&row.Compute, &row.Storage, &row.Network, &row.Other}
+	hasComponents := false
+	for i, column := range componentColumns {
+		value, ok, err := rec.cost(column)
+		if err != nil {
+			return Row{}, err
+		}
+		*components[i] = value
+		hasComponents = hasComponents || ok
+	}
+
+	switch {
+	case hasTotal && hasComponents:
+		return Row{}, fmt.Errorf("%s can't be combined with the component cost columns", columnCost)
+	case hasTotal:
+		row.Compute = total
+	case !hasComponents:
+		return Row{}, fmt.Errorf("%s or a component cost column is required", columnCost)
+	}
+
+	return row, nil
+}
+
+// cost parses the column's cost, reporting whether it was set
+func (rec record) cost(column string) (float64, bool, error) {
+	value := strings.TrimSpace(rec.values[column])
+	if value == "" {
+		return 0, false, nil
+	}
+
+	cost, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(cost) || math.IsInf(cost, 0) {
+		return 0, false, fmt.Errorf("invalid %s %q", column, value)
+	}
+	if cost < 0 {
+		return 0, false, fmt.Errorf("%s can't be negative", column)
+	}
+	if cost > maxCost {
+		return 0, false, fmt.Errorf("%s %s exceeds %v", column, value, maxCost)
+	}
+	return cost, true, nil
+}
+
+func readCSV(r io.Reader) ([]record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	present := make(map[string]bool)
+	for i, name := range header {
+		columns[i] = strings.ToLower(strings.TrimSpace(name))
+		if !knownColumns[columns[i]] {
+			return nil, fmt.Errorf("line 1: unknown column %q", name)
+		}
+		if present[columns[i]] {
+			return nil, fmt.Errorf("line 1: duplicate column %q", name)
+		}
+		present[columns[i]] = true
+	}
+	for _, required := range []string{columnNamespace, columnDate} {
+		if !present[required] {
+			return nil, fmt.Errorf("line 1: missing required column %q", required)
+		}
+	}
+
+	var records []record
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// A row with the wrong number of fields is reported and skipped; other parse
+		// errors, such as an unterminated quote, leave the reader unable to continue
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			records = append(records, record{line: parseErr.StartLine,
+				err: fmt.Errorf("expected %d fields, got %d", len(columns), len(fields))})
+			continue
+		}
+		if errors.As(err, &parseErr) {
+			return nil, &InvalidRowsError{Rows: []RowError{{Line: parseErr.StartLine, Message: parseErr.Err.Error()}}}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading file: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		values := make(map[string]string, len(columns))
+		for i, column := range columns {
+			values[column] = fields[i]
+		}
+		records = append(records, record{line: line, values: values})
+	}
+	return records, nil
+}
+
+func readJSON(r io.Reader) ([]record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	lineAt := func(offset int64) int {
+		if offset > int64(len(data)) {
+			offset = int64(len(data))
+		}
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+	malformedJSON := func(err error, line int) error {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line = lineAt(syntaxErr.Offset)
+		}
+		return &InvalidRowsError{Rows: []RowError{{Line: line, Message: "malformed JSON"}}}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if delim, ok := token.(json.Delim); err != nil || !ok || delim != '[' {
+		return nil, errors.New("line 1: expected a JSON array of rows")
+	}
+
+	var records []record
+	for decoder.More() {
+		// The offset before decoding is the end of the previous value, so skip the
+		// separating comma and whitespace to find the line the row starts on
+		offset := decoder.InputOffset()
+		for offset < int64(len(data)) && bytes.IndexByte([]byte(", \t\r\n"), data[offset]) >= 0 {
+			offset++
+		}
+		line := lineAt(offset)
+
+		// A value that isn't an object is still read in full, so the rows after it can
+		// be checked too
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				records = append(records, record{line: line, err: errors.New("expected an object")})
+				continue
+			}
+			return nil, malformedJSON(err, lineAt(decoder.InputOffset()))
+		}
+
+		values := make(map[string]string, len(object))
+		var invalid []string
+		for key, value := range object {
+			column := strings.ToLower(key)
+			switch v := value.(type) {
+			case string:
+				values[column] = v
+			case json.Number:
+				values[column] = v.String()
+			case nil:
+			default:
+				invalid = append(invalid, key)
+			}
+		}
+		if len(invalid) > 0 {
+			sort.Strings(invalid)
+			records = append(records, record{line: line,
+				err: fmt.Errorf("%s must be a string or number", strings.Join(invalid, ", "))})
+			continue
+		}
+		records = append(records, record{line: line, values: values})
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, malformedJSON(err, lineAt(decoder.InputOffset()))
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the JSON array")
+	}
+	return records, nil
+}
+
+// Import upserts the rows into namespace_costs in a single transaction. Each row is
+// stored at the start of its day, replacing any costs already collected for that
+// namespace on that day, so collected and imported costs aren't counted twice. A dry
+// run counts the rows without writing them.
+func Import(ctx context.Context, db *sql.DB, rows []Row, dryRun bool) (*Result, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &Result{Rows: len(rows), DryRun: dryRun}
+	for _, row := range rows {
+		next := row.Day.AddDate(0, 0, 1)
+
+		var exists bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM namespace_costs
+				WHERE cluster = $1 AND namespace = $2 AND timestamp >= $3 AND timestamp < $4
+			)
+		`, row.Cluster, row.Namespace, row.Day, next).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: checking existing costs: %w", row.Line, err)
+		}
+		if exists {
+			result.Updated++
+		} else {
+			result.Inserted++
+		}
+
+		if dryRun {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM namespace_costs
+			WHERE cluster = $1 AND namespace = $2 AND timestamp > $3 AND timestamp < $4
+		`, row.Cluster, row.Namespace, row.Day, next)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: replacing collected costs: %w", row.Line, err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO namespace_costs
+			(cluster, namespace, compute_cost, storage_cost, network_cost, other_cost, timestamp)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (cluster, namespace, timestamp)
+			DO UPDATE SET
+				compute_cost = EXCLUDED.compute_cost,
+				storage_cost = EXCLUDED.storage_cost,
+				network_cost = EXCLUDED.network_cost,
+				other_cost = EXCLUDED.other_cost,
+				reconciliation_factor = 1
+		`, row.Cluster, row.Namespace, row.Compute, row.Storage, row.Network, row.Other, row.Day)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: storing costs: %w", row.Line, err)
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// conflictTarget is the column list an upsert's ON CONFLICT clause names. Postgres
+// rejects the insert outright unless a unique index covers exactly those columns.
+type conflictTarget struct {
+	Table   string
+	Columns []string
+	Partial bool // The clause has a WHERE, matched by a partial index
+}
+
+func (t conflictTarget) String() string {
+	return fmt.Sprintf("%s(%s)", t.Table, strings.Join(t.Columns, ", "))
+}
+
+// conflictTargets lists the ON CONFLICT clauses used by the collectors and analyzer.
+// A new upsert must add its target here and a unique index for it in a migration.
+var conflictTargets = []conflictTarget{
+	{Table: "namespace_metrics", Columns: []string{"cluster", "namespace", "metric_type", "timestamp"}},
+	{Table: "pod_metrics", Columns: []string{"cluster", "namespace", "pod_name", "container_name", "timestamp"}},
+	{Table: "node_metrics", Columns: []string{"cluster", "node_name", "timestamp"}},
+	{Table: "storage_metrics", Columns: []string{"cluster", "namespace", "pvc_name", "timestamp"}},
+	{Table: "resource_requests", Columns: []string{"cluster", "namespace", "pod_name", "container_name", "timestamp"}},
+	{Table: "namespace_costs", Columns: []string{"cluster", "namespace", "timestamp"}},
+	{Table: "pod_metrics_rollup", Columns: []string{"cluster", "namespace", "pod_name", "container_name", "bucket"}},
+	{Table: "gpu_metrics", Columns: []string{"cluster", "namespace", "pod_name", "container_name", "timestamp"}},
+	{Table: "budgets", Columns: []string{"namespace"}},
+	{Table: "workload_costs", Columns: []string{"cluster", "namespace", "workload_kind", "workload", "container_name", "timestamp"}},
+	{Table: "workload_labels", Columns: []string{"cluster", "namespace", "workload_kind", "workload"}},
+	{Table: "network_metrics", Columns: []string{"cluster", "namespace", "pod_name", "direction", "destination", "timestamp"}},
+	{Table: "recommendations", Columns: []string{"namespace", "pod_name", "container_name", "resource_type"}, Partial: true},
+	{Table: "recommendation_outcomes", Columns: []string{"action_id"}},
+	{Table: "container_signals", Columns: []string{"cluster", "namespace", "pod_name", "container_name", "timestamp"}},
+}
+
+// CheckConflictTargets verifies a unique index exists for every ON CONFLICT clause.
+// Without one each upsert to the table fails, which the collectors only log, so
+// the server should refuse to start rather than silently drop data. It reads the Postgres
+// catalog, as the server only runs on Postgres.
+func CheckConflictTargets(ctx context.Context, db *sql.DB) error {
+	var missing []string
+	for _, target := range conflictTargets {
+		var found bool
+		err := db.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1
+				FROM pg_index i,
+					LATERAL (
+						SELECT array_agg(a.attname::text) AS names
+						FROM pg_attribute a
+						WHERE a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+					) k
+				WHERE i.indrelid = to_regclass($1)
+					AND i.indisunique AND i.indisvalid AND i.indexprs IS NULL
+					AND (i.indpred IS NOT NULL) = $3
+					AND k.names @> $2 AND k.names <@ $2
+			)
+		`, target.Table, pq.Array(target.Columns), target.Partial).Scan(&found)
+		if err != nil {
+			return fmt.Errorf("checking unique index on %s: %w", target, err)
+		}
+		if !found {
+			missing = append(missing, target.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no unique index for the ON CONFLICT targets %s; run the database migrations",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package database holds the schema migrations, embedded in the binary, and runs them
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// Migrations are numbered NNNN_description.up.sql and applied in order. Each file runs
+// as a single multi-statement query, so statements TimescaleDB won't run in one, such
+// as creating a continuous aggregate, need a file of their own.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// MigrationStatus is the schema version before and after migrating
+type MigrationStatus struct {
+	From    uint
+	To      uint
+	Applied bool // Whether any migration ran
+}
+
+// Migrate applies any migrations the database hasn't run yet. It takes a Postgres
+// advisory lock, so replicas starting together apply them once. A migration that
+// failed part way leaves the schema marked dirty, and Migrate refuses to continue
+// until it has been fixed by hand and the version forced with the migrate CLI.
+func Migrate(ctx context.Context, db *sql.DB) (*MigrationStatus, error) {
+	source, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+
+	// Migrate on a connection of its own; closing the driver would close the pool
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("creating migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		return nil, fmt.Errorf("creating migrator: %w", err)
+	}
+
+	status := &MigrationStatus{}
+	status.From, err = version(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return nil, fmt.Errorf("migrating from version %d: %w", status.From, err)
+	}
+
+	status.To, err = version(m)
+	if err != nil {
+		return nil, err
+	}
+	status.Applied = status.To != status.From
+	return status, nil
+}
+
+// version returns the current schema version, 0 for an unmigrated database
+func version(m *migrate.Migrate) (uint, error) {
+	v, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema version %d is dirty: a migration failed part way and must be fixed by hand", v)
+	}
+	return v, nil
+}
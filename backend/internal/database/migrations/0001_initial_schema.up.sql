@@ -129,67 +129,6 @@ SELECT add_retention_policy('storage_metrics', INTERVAL '90 days', if_not_exists
 SELECT add_retention_policy('resource_requests', INTERVAL '90 days', if_not_exists => TRUE);
 SELECT add_retention_policy('namespace_costs', INTERVAL '90 days', if_not_exists => TRUE);
 
--- Continuous aggregates for faster queries
-CREATE MATERIALIZED VIEW IF NOT EXISTS hourly_namespace_metrics
-WITH (timescaledb.continuous) AS
-SELECT
-    namespace,
-    metric_type,
-    time_bucket('1 hour', timestamp) AS hour,
-    AVG(value) as avg_value,
-    MAX(value) as max_value,
-    MIN(value) as min_value,
-    COUNT(*) as data_points
-FROM namespace_metrics
-GROUP BY namespace, metric_type, hour;
-
-SELECT add_continuous_aggregate_policy('hourly_namespace_metrics',
-    start_offset => INTERVAL '3 hours',
-    end_offset => INTERVAL '1 hour',
-    schedule_interval => INTERVAL '1 hour',
-    if_not_exists => TRUE);
-
--- Daily cost aggregates
-CREATE MATERIALIZED VIEW IF NOT EXISTS daily_namespace_costs
-WITH (timescaledb.continuous) AS
-SELECT
-    namespace,
-    time_bucket('1 day', timestamp) AS day,
-    SUM(compute_cost) as total_compute,
-    SUM(storage_cost) as total_storage,
-    SUM(network_cost) as total_network,
-    SUM(other_cost) as total_other,
-    SUM(compute_cost + storage_cost + network_cost + other_cost) as total_cost
-FROM namespace_costs
-GROUP BY namespace, day;
-
-SELECT add_continuous_aggregate_policy('daily_namespace_costs',
-    start_offset => INTERVAL '3 days',
-    end_offset => INTERVAL '1 day',
-    schedule_interval => INTERVAL '1 day',
-    if_not_exists => TRUE);
-
--- Pod utilization aggregates
-CREATE MATERIALIZED VIEW IF NOT EXISTS hourly_pod_utilization
-WITH (timescaledb.continuous) AS
-SELECT
-    namespace,
-    pod_name,
-    container_name,
-    time_bucket('1 hour', timestamp) AS hour,
-    AVG(cpu_millicores) as avg_cpu,
-    MAX(cpu_millicores) as max_cpu,
-    AVG(memory_bytes) as avg_memory,
-    MAX(memory_bytes) as max_memory
-FROM pod_metrics
-GROUP BY namespace, pod_name, container_name, hour;
-
-SELECT add_continuous_aggregate_policy('hourly_pod_utilization',
-    start_offset => INTERVAL '3 hours',
-    end_offset => INTERVAL '1 hour',
-    schedule_interval => INTERVAL '1 hour',
-    if_not_exists => TRUE);
-
 -- Create views for easier querying
 CREATE OR REPLACE VIEW current_resource_usage AS
 SELECT 
@@ -275,4 +214,4 @@ SELECT
         ELSE 'LOW'
     END as optimization_potential
 FROM current_resource_usage
-WHERE memory_utilization_percent < 70; 
\ No newline at end of file
+WHERE memory_utilization_percent < 70;
@@ -0,0 +1,14 @@
+-- Hourly namespace metric aggregates. A continuous aggregate can't be created in a
+-- multi-statement migration, so each has a file of its own; policies are in 0005.
+CREATE MATERIALIZED VIEW IF NOT EXISTS hourly_namespace_metrics
+WITH (timescaledb.continuous) AS
+SELECT
+    namespace,
+    metric_type,
+    time_bucket('1 hour', timestamp) AS hour,
+    AVG(value) as avg_value,
+    MAX(value) as max_value,
+    MIN(value) as min_value,
+    COUNT(*) as data_points
+FROM namespace_metrics
+GROUP BY namespace, metric_type, hour;
@@ -0,0 +1,13 @@
+-- Daily cost aggregates, in a file of their own like 0002
+CREATE MATERIALIZED VIEW IF NOT EXISTS daily_namespace_costs
+WITH (timescaledb.continuous) AS
+SELECT
+    namespace,
+    time_bucket('1 day', timestamp) AS day,
+    SUM(compute_cost) as total_compute,
+    SUM(storage_cost) as total_storage,
+    SUM(network_cost) as total_network,
+    SUM(other_cost) as total_other,
+    SUM(compute_cost + storage_cost + network_cost + other_cost) as total_cost
+FROM namespace_costs
+GROUP BY namespace, day;
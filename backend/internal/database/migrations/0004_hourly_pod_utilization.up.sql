@@ -0,0 +1,14 @@
+-- Pod utilization aggregates, in a file of their own like 0002
+CREATE MATERIALIZED VIEW IF NOT EXISTS hourly_pod_utilization
+WITH (timescaledb.continuous) AS
+SELECT
+    namespace,
+    pod_name,
+    container_name,
+    time_bucket('1 hour', timestamp) AS hour,
+    AVG(cpu_millicores) as avg_cpu,
+    MAX(cpu_millicores) as max_cpu,
+    AVG(memory_bytes) as avg_memory,
+    MAX(memory_bytes) as max_memory
+FROM pod_metrics
+GROUP BY namespace, pod_name, container_name, hour;
@@ -0,0 +1,23 @@
+-- Incremental per-container usage rollups (hourly buckets). Each row keeps the
+-- count, sum, sum of squares, max, and a serialized t-digest so the analyzer can
+-- compute mean, stddev, and percentiles without scanning raw pod_metrics.
+CREATE TABLE IF NOT EXISTS pod_metrics_rollup (
+    namespace VARCHAR(255) NOT NULL,
+    pod_name VARCHAR(255) NOT NULL,
+    container_name VARCHAR(255) NOT NULL,
+    bucket TIMESTAMPTZ NOT NULL,
+    sample_count BIGINT NOT NULL DEFAULT 0,
+    cpu_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+    cpu_sum_sq DOUBLE PRECISION NOT NULL DEFAULT 0,
+    cpu_max DOUBLE PRECISION NOT NULL DEFAULT 0,
+    cpu_digest JSONB,
+    memory_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+    memory_sum_sq DOUBLE PRECISION NOT NULL DEFAULT 0,
+    memory_max DOUBLE PRECISION NOT NULL DEFAULT 0,
+    memory_digest JSONB,
+    PRIMARY KEY (namespace, pod_name, container_name, bucket)
+);
+
+SELECT create_hypertable('pod_metrics_rollup', 'bucket', if_not_exists => TRUE);
+CREATE INDEX IF NOT EXISTS idx_pod_metrics_rollup_namespace ON pod_metrics_rollup(namespace, bucket DESC);
+SELECT add_retention_policy('pod_metrics_rollup', INTERVAL '90 days', if_not_exists => TRUE);
@@ -0,0 +1,16 @@
+-- GPU requests and DCGM utilization per container
+CREATE TABLE IF NOT EXISTS gpu_metrics (
+    namespace VARCHAR(255) NOT NULL,
+    pod_name VARCHAR(255) NOT NULL,
+    container_name VARCHAR(255) NOT NULL,
+    gpu_request DOUBLE PRECISION,
+    gpu_limit DOUBLE PRECISION,
+    gpu_devices DOUBLE PRECISION,
+    gpu_utilization DOUBLE PRECISION,
+    timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (namespace, pod_name, container_name, timestamp)
+);
+
+SELECT create_hypertable('gpu_metrics', 'timestamp', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_gpu_metrics_namespace ON gpu_metrics(namespace, timestamp DESC);
@@ -0,0 +1,36 @@
+-- Multi-cluster support: tag usage and cost rows with the cluster they came from.
+-- Existing rows belong to the single cluster the deployment was monitoring.
+ALTER TABLE pod_metrics ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE pod_metrics_rollup ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE namespace_costs ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+
+-- Include the cluster in the primary keys so clusters with the same namespaces don't collide
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'pod_metrics' AND constraint_name = 'pod_metrics_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE pod_metrics DROP CONSTRAINT pod_metrics_pkey;
+        ALTER TABLE pod_metrics ADD PRIMARY KEY (cluster, namespace, pod_name, container_name, timestamp);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'pod_metrics_rollup' AND constraint_name = 'pod_metrics_rollup_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE pod_metrics_rollup DROP CONSTRAINT pod_metrics_rollup_pkey;
+        ALTER TABLE pod_metrics_rollup ADD PRIMARY KEY (cluster, namespace, pod_name, container_name, bucket);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'namespace_costs' AND constraint_name = 'namespace_costs_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE namespace_costs DROP CONSTRAINT namespace_costs_pkey;
+        ALTER TABLE namespace_costs ADD PRIMARY KEY (cluster, namespace, timestamp);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_pod_metrics_cluster ON pod_metrics(cluster, namespace, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_namespace_costs_cluster ON namespace_costs(cluster, namespace, timestamp DESC);
@@ -0,0 +1,7 @@
+-- Monthly spending limits per namespace
+CREATE TABLE IF NOT EXISTS budgets (
+    namespace VARCHAR(255) PRIMARY KEY,
+    monthly_limit DOUBLE PRECISION NOT NULL CHECK (monthly_limit > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
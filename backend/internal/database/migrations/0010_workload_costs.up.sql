@@ -0,0 +1,27 @@
+-- Hourly node cost attributed to containers by their requests, keyed by the workload
+-- that owns them. Each node's unrequested cost is stored under the __idle__ namespace.
+CREATE TABLE IF NOT EXISTS workload_costs (
+    cluster VARCHAR(255) NOT NULL DEFAULT 'default',
+    namespace VARCHAR(255) NOT NULL,
+    workload_kind VARCHAR(63) NOT NULL,
+    workload VARCHAR(255) NOT NULL,
+    container_name VARCHAR(255) NOT NULL,
+    cpu_cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+    memory_cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+    timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (cluster, namespace, workload_kind, workload, container_name, timestamp)
+);
+
+SELECT create_hypertable('workload_costs', 'timestamp', if_not_exists => TRUE);
+CREATE INDEX IF NOT EXISTS idx_workload_costs_namespace ON workload_costs(namespace, timestamp DESC);
+
+-- Latest pod labels per workload, for grouping workload_costs by label
+CREATE TABLE IF NOT EXISTS workload_labels (
+    cluster VARCHAR(255) NOT NULL DEFAULT 'default',
+    namespace VARCHAR(255) NOT NULL,
+    workload_kind VARCHAR(63) NOT NULL,
+    workload VARCHAR(255) NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (cluster, namespace, workload_kind, workload)
+);
@@ -0,0 +1,13 @@
+-- Keep one unapplied recommendation per container and resource, which re-analysis
+-- updates in place. Applied recommendations are kept as history.
+UPDATE recommendations SET applied = FALSE WHERE applied IS NULL;
+ALTER TABLE recommendations ALTER COLUMN applied SET NOT NULL;
+DELETE FROM recommendations older USING recommendations newer
+WHERE NOT older.applied AND NOT newer.applied
+    AND older.namespace = newer.namespace
+    AND older.pod_name = newer.pod_name
+    AND older.container_name = newer.container_name
+    AND older.resource_type = newer.resource_type
+    AND older.id < newer.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recommendations_open
+    ON recommendations(namespace, pod_name, container_name, resource_type) WHERE NOT applied;
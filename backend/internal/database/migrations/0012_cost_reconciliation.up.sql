@@ -0,0 +1,17 @@
+-- Factor each namespace cost row was scaled by to match the provider's cluster total,
+-- so the unreconciled estimate is the stored cost divided by it
+ALTER TABLE namespace_costs ADD COLUMN IF NOT EXISTS reconciliation_factor DOUBLE PRECISION NOT NULL DEFAULT 1;
+
+-- History of cost reconciliations against the provider's cluster totals
+CREATE TABLE IF NOT EXISTS cost_reconciliations (
+    id SERIAL PRIMARY KEY,
+    cluster VARCHAR(255) NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    estimated_total DOUBLE PRECISION NOT NULL,
+    actual_total DOUBLE PRECISION NOT NULL,
+    reconciliation_factor DOUBLE PRECISION NOT NULL,
+    reconciled_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_cost_reconciliations_cluster ON cost_reconciliations(cluster, reconciled_at DESC);
@@ -0,0 +1,3 @@
+-- Capacity each PVC requests, for storage rightsizing against its used bytes
+ALTER TABLE storage_metrics ADD COLUMN IF NOT EXISTS requested_bytes DOUBLE PRECISION;
+ALTER TABLE storage_metrics ADD COLUMN IF NOT EXISTS storage_class VARCHAR(255);
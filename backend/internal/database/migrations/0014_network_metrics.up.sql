@@ -0,0 +1,13 @@
+-- Bytes each namespace sent and received per collection interval. Transmitted bytes
+-- are split by destination (internet, cross_zone, intra_region) to price egress.
+CREATE TABLE IF NOT EXISTS network_metrics (
+    cluster VARCHAR(255) NOT NULL DEFAULT 'default',
+    namespace VARCHAR(255) NOT NULL,
+    direction VARCHAR(16) NOT NULL,
+    destination VARCHAR(16) NOT NULL DEFAULT '',
+    bytes DOUBLE PRECISION NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (cluster, namespace, direction, destination, timestamp)
+);
+
+SELECT create_hypertable('network_metrics', 'timestamp', if_not_exists => TRUE);
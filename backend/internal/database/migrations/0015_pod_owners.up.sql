@@ -0,0 +1,5 @@
+-- Workload that owns each pod, so DaemonSet and StatefulSet replicas can be sized
+-- with one template change
+ALTER TABLE resource_requests ADD COLUMN IF NOT EXISTS owner_kind VARCHAR(63);
+ALTER TABLE resource_requests ADD COLUMN IF NOT EXISTS owner_name VARCHAR(255);
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS owner_kind VARCHAR(63);
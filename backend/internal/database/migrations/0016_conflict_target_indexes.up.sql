@@ -0,0 +1,42 @@
+-- Every upsert names its conflict target, and Postgres rejects an ON CONFLICT clause
+-- that no unique index matches. The primary keys above provide them, but databases
+-- created before the schema was migrated may be missing some; add any that are. This
+-- fails if a table already holds duplicate rows, which must then be removed by hand.
+DO $$
+DECLARE
+    target RECORD;
+BEGIN
+    FOR target IN
+        SELECT * FROM (VALUES
+            ('namespace_metrics', ARRAY['namespace', 'metric_type', 'timestamp']),
+            ('pod_metrics', ARRAY['cluster', 'namespace', 'pod_name', 'container_name', 'timestamp']),
+            ('node_metrics', ARRAY['node_name', 'timestamp']),
+            ('storage_metrics', ARRAY['namespace', 'pvc_name', 'timestamp']),
+            ('resource_requests', ARRAY['namespace', 'pod_name', 'container_name', 'timestamp']),
+            ('namespace_costs', ARRAY['cluster', 'namespace', 'timestamp']),
+            ('pod_metrics_rollup', ARRAY['cluster', 'namespace', 'pod_name', 'container_name', 'bucket']),
+            ('gpu_metrics', ARRAY['namespace', 'pod_name', 'container_name', 'timestamp']),
+            ('budgets', ARRAY['namespace']),
+            ('workload_costs', ARRAY['cluster', 'namespace', 'workload_kind', 'workload', 'container_name', 'timestamp']),
+            ('workload_labels', ARRAY['cluster', 'namespace', 'workload_kind', 'workload']),
+            ('network_metrics', ARRAY['cluster', 'namespace', 'direction', 'destination', 'timestamp'])
+        ) AS targets(table_name, columns)
+    LOOP
+        IF NOT EXISTS (
+            SELECT 1
+            FROM pg_index i,
+                LATERAL (
+                    SELECT array_agg(a.attname::text) AS names
+                    FROM pg_attribute a
+                    WHERE a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+                ) k
+            WHERE i.indrelid = target.table_name::regclass
+                AND i.indisunique AND i.indpred IS NULL AND i.indexprs IS NULL
+                AND k.names @> target.columns AND k.names <@ target.columns
+        ) THEN
+            EXECUTE format('CREATE UNIQUE INDEX %I ON %I (%s)',
+                target.table_name || '_conflict_key', target.table_name,
+                (SELECT string_agg(quote_ident(c), ', ') FROM unnest(target.columns) c));
+        END IF;
+    END LOOP;
+END $$;
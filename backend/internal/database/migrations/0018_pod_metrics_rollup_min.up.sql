@@ -0,0 +1,5 @@
+-- Hourly minimums, so long-range queries can report min/avg/max/p95 per container
+-- from the rollup alone. Buckets written before this migration have no minimum
+-- until RebuildRollups recomputes them.
+ALTER TABLE pod_metrics_rollup ADD COLUMN IF NOT EXISTS cpu_min DOUBLE PRECISION;
+ALTER TABLE pod_metrics_rollup ADD COLUMN IF NOT EXISTS memory_min DOUBLE PRECISION;
@@ -0,0 +1,32 @@
+-- What an apply action changed, so its outcome can be evaluated once the workload has
+-- run with the new values. Actions recorded before this migration aren't evaluated.
+ALTER TABLE recommendation_actions ADD COLUMN IF NOT EXISTS recommendation_id INTEGER;
+ALTER TABLE recommendation_actions ADD COLUMN IF NOT EXISTS owner_kind VARCHAR(63);
+ALTER TABLE recommendation_actions ADD COLUMN IF NOT EXISTS owner_name VARCHAR(255);
+ALTER TABLE recommendation_actions ADD COLUMN IF NOT EXISTS recommended_request DOUBLE PRECISION;
+ALTER TABLE recommendation_actions ADD COLUMN IF NOT EXISTS recommended_limit DOUBLE PRECISION;
+
+-- Whether an applied recommendation held up: usage and OOM kills over the evaluation
+-- window after it was applied, and the verdict
+CREATE TABLE IF NOT EXISTS recommendation_outcomes (
+    action_id INTEGER PRIMARY KEY REFERENCES recommendation_actions(id) ON DELETE CASCADE,
+    namespace VARCHAR(255) NOT NULL,
+    owner_kind VARCHAR(63) NOT NULL,
+    owner_name VARCHAR(255) NOT NULL,
+    container_name VARCHAR(255) NOT NULL,
+    resource_type VARCHAR(20) NOT NULL,
+    recommended_request DOUBLE PRECISION,
+    recommended_limit DOUBLE PRECISION,
+    p95_usage DOUBLE PRECISION,
+    max_usage DOUBLE PRECISION,
+    samples INTEGER NOT NULL DEFAULT 0,
+    restarts INTEGER NOT NULL DEFAULT 0,
+    oom_kills INTEGER NOT NULL DEFAULT 0,
+    outcome VARCHAR(20) NOT NULL, -- 'safe', 'too_tight', 'too_loose', 'no_data'
+    applied_at TIMESTAMPTZ NOT NULL,
+    evaluated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recommendation_outcomes_namespace ON recommendation_outcomes(namespace, evaluated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_recommendation_actions_pending ON recommendation_actions(applied_at)
+    WHERE action = 'apply' AND owner_name IS NOT NULL;
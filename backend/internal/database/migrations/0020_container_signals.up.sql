@@ -0,0 +1,22 @@
+-- Restarts, OOM kills and CPU throttling per container and collection interval.
+-- oom_killed is set while the container's last termination was an OOM kill, so an
+-- interval with restarts and oom_killed saw the container OOMKilled.
+CREATE TABLE IF NOT EXISTS container_signals (
+    cluster VARCHAR(255) NOT NULL DEFAULT 'default',
+    namespace VARCHAR(255) NOT NULL,
+    pod_name VARCHAR(255) NOT NULL,
+    container_name VARCHAR(255) NOT NULL,
+    restarts DOUBLE PRECISION NOT NULL DEFAULT 0,
+    oom_killed BOOLEAN NOT NULL DEFAULT FALSE,
+    throttled_ratio DOUBLE PRECISION, -- Share of CFS periods throttled; NULL without a CPU limit
+    timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (cluster, namespace, pod_name, container_name, timestamp)
+);
+
+SELECT create_hypertable('container_signals', 'timestamp', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_container_signals_namespace ON container_signals(namespace, timestamp DESC);
+
+-- Whether a recommendation was held back by an OOM kill or CPU throttling
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS oom_observed BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS throttle_observed BOOLEAN NOT NULL DEFAULT FALSE;
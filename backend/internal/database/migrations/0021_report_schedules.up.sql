@@ -0,0 +1,19 @@
+-- Reports generated on a cron schedule and emailed or posted to a webhook. An empty
+-- namespaces list sends one cluster-wide report.
+CREATE TABLE IF NOT EXISTS report_schedules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    cron VARCHAR(255) NOT NULL,
+    namespaces TEXT[] NOT NULL DEFAULT '{}',
+    format VARCHAR(10) NOT NULL, -- 'csv' or 'pdf'
+    delivery VARCHAR(20) NOT NULL, -- 'email' or 'webhook'
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_schedules_due ON report_schedules(next_run_at) WHERE enabled;
@@ -0,0 +1,55 @@
+-- Tag the remaining per-cluster tables with the cluster they came from, as 0008 did
+-- for pod metrics and costs, so additional clusters' rows don't overwrite each other.
+-- Existing rows belong to the single cluster the deployment was monitoring.
+ALTER TABLE namespace_metrics ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE resource_requests ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE node_metrics ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE storage_metrics ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+
+-- 0016 may have added unique indexes on the old keys, which would still make two
+-- clusters' rows collide
+DROP INDEX IF EXISTS namespace_metrics_conflict_key;
+DROP INDEX IF EXISTS resource_requests_conflict_key;
+DROP INDEX IF EXISTS node_metrics_conflict_key;
+DROP INDEX IF EXISTS storage_metrics_conflict_key;
+
+-- Include the cluster in the primary keys the upserts conflict on
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'namespace_metrics' AND constraint_name = 'namespace_metrics_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE namespace_metrics DROP CONSTRAINT IF EXISTS namespace_metrics_pkey;
+        ALTER TABLE namespace_metrics ADD PRIMARY KEY (cluster, namespace, metric_type, timestamp);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'resource_requests' AND constraint_name = 'resource_requests_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE resource_requests DROP CONSTRAINT IF EXISTS resource_requests_pkey;
+        ALTER TABLE resource_requests ADD PRIMARY KEY (cluster, namespace, pod_name, container_name, timestamp);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'node_metrics' AND constraint_name = 'node_metrics_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE node_metrics DROP CONSTRAINT IF EXISTS node_metrics_pkey;
+        ALTER TABLE node_metrics ADD PRIMARY KEY (cluster, node_name, timestamp);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'storage_metrics' AND constraint_name = 'storage_metrics_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE storage_metrics DROP CONSTRAINT IF EXISTS storage_metrics_pkey;
+        ALTER TABLE storage_metrics ADD PRIMARY KEY (cluster, namespace, pvc_name, timestamp);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_namespace_metrics_cluster ON namespace_metrics(cluster, namespace, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_resource_requests_cluster ON resource_requests(cluster, namespace, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_node_metrics_cluster ON node_metrics(cluster, node_name, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_storage_metrics_cluster ON storage_metrics(cluster, namespace, timestamp DESC);
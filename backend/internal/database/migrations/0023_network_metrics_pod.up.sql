@@ -0,0 +1,21 @@
+-- Record network traffic per pod, so idle detection can tell a quiet pod from one
+-- that serves traffic on little CPU. Existing rows are namespace totals, kept with an
+-- empty pod name; namespace sums are unchanged.
+ALTER TABLE network_metrics ADD COLUMN IF NOT EXISTS pod_name VARCHAR(255) NOT NULL DEFAULT '';
+
+-- 0016 may have added a unique index on the old key, which would make a namespace's
+-- pods collide
+DROP INDEX IF EXISTS network_metrics_conflict_key;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'network_metrics' AND constraint_name = 'network_metrics_pkey' AND column_name = 'pod_name'
+    ) THEN
+        ALTER TABLE network_metrics DROP CONSTRAINT IF EXISTS network_metrics_pkey;
+        ALTER TABLE network_metrics ADD PRIMARY KEY (cluster, namespace, pod_name, direction, destination, timestamp);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_network_metrics_pod ON network_metrics(namespace, pod_name, timestamp DESC);
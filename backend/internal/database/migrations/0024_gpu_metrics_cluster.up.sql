@@ -0,0 +1,20 @@
+-- Tag GPU metrics with their cluster, as 0022 did for the other metrics tables, so
+-- additional clusters' GPU rows don't overwrite each other. Existing rows belong to
+-- the single cluster the deployment was monitoring.
+ALTER TABLE gpu_metrics ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default';
+
+-- 0016 may have added a unique index on the old key
+DROP INDEX IF EXISTS gpu_metrics_conflict_key;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.key_column_usage
+        WHERE table_name = 'gpu_metrics' AND constraint_name = 'gpu_metrics_pkey' AND column_name = 'cluster'
+    ) THEN
+        ALTER TABLE gpu_metrics DROP CONSTRAINT IF EXISTS gpu_metrics_pkey;
+        ALTER TABLE gpu_metrics ADD PRIMARY KEY (cluster, namespace, pod_name, container_name, timestamp);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_gpu_metrics_cluster ON gpu_metrics(cluster, namespace, timestamp DESC);
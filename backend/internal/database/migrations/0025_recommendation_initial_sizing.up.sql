@@ -0,0 +1,3 @@
+-- What sizing a container that had no request adds to the monthly bill. It is kept
+-- apart from potential_savings so it isn't netted against other containers' savings.
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS initial_sizing_cost DECIMAL(10, 4) NOT NULL DEFAULT 0;
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// BudgetRepository stores namespaces' monthly budgets
+type BudgetRepository interface {
+	// SaveBudget creates or replaces the namespace's monthly budget
+	SaveBudget(ctx context.Context, namespace string, monthlyLimit float64) (*Budget, error)
+
+	// Budget returns the namespace's budget, or nil if it has none
+	Budget(ctx context.Context, namespace string) (*Budget, error)
+
+	// Budgets returns every budget, ordered by namespace
+	Budgets(ctx context.Context) ([]Budget, error)
+
+	// DeleteBudget removes the namespace's budget, reporting whether it existed
+	DeleteBudget(ctx context.Context, namespace string) (bool, error)
+}
+
+// Budget is a namespace's monthly spending limit
+type Budget struct {
+	Namespace    string    `json:"namespace"`
+	MonthlyLimit float64   `json:"monthly_limit"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (p *Postgres) SaveBudget(ctx context.Context, namespace string, monthlyLimit float64) (*Budget, error) {
+	budget := &Budget{Namespace: namespace, MonthlyLimit: monthlyLimit}
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO budgets (namespace, monthly_limit, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (namespace) DO UPDATE SET monthly_limit = $2, updated_at = NOW()
+		RETURNING created_at, updated_at
+	`, namespace, monthlyLimit).Scan(&budget.CreatedAt, &budget.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+func (p *Postgres) Budget(ctx context.Context, namespace string) (*Budget, error) {
+	budget := &Budget{Namespace: namespace}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT monthly_limit, created_at, updated_at FROM budgets WHERE namespace = $1
+	`, namespace).Scan(&budget.MonthlyLimit, &budget.CreatedAt, &budget.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+func (p *Postgres) Budgets(ctx context.Context) ([]Budget, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT namespace, monthly_limit, created_at, updated_at FROM budgets ORDER BY namespace
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+	for rows.Next() {
+		var budget Budget
+		if err := rows.Scan(&budget.Namespace, &budget.MonthlyLimit, &budget.CreatedAt, &budget.UpdatedAt); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
+}
+
+func (p *Postgres) DeleteBudget(ctx context.Context, namespace string) (bool, error) {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM budgets WHERE namespace = $1`, namespace)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
@@ -0,0 +1,435 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CostRepository stores and reads the namespace costs the collector computes
+type CostRepository interface {
+	// WriteCosts stores namespaces' costs for the period ending at the timestamp,
+	// replacing the costs already stored for it. Total and Estimated are derived and
+	// not stored.
+	WriteCosts(ctx context.Context, costs []NamespaceCost, timestamp time.Time) error
+
+	// CostNamespaces returns the namespaces with costs stored since the given time
+	CostNamespaces(ctx context.Context, since time.Time) ([]string, error)
+
+	// LastCostTime returns when the cluster's costs were last stored, or nil if they
+	// never were
+	LastCostTime(ctx context.Context, cluster string) (*time.Time, error)
+
+	// DailyCosts sums the namespace's costs per day between start and end, newest
+	// first. An empty cluster sums the namespace's costs across all clusters.
+	DailyCosts(ctx context.Context, cluster, namespace string, start, end time.Time) ([]DailyCost, error)
+
+	// CurrentCost sums the namespace's costs stored since the given time, or zero
+	// without any
+	CurrentCost(ctx context.Context, namespace string, since time.Time) (float64, error)
+
+	// ClusterCosts sums each namespace's costs stored since the given time, returning
+	// a page of namespaces by total cost, highest first, with totals across all pages.
+	// An empty cluster lists the namespaces of every cluster.
+	ClusterCosts(ctx context.Context, cluster string, since time.Time, limit, offset int) (*ClusterCosts, error)
+
+	// CostBreakdown sums the namespace's costs by resource between start and end. An
+	// empty cluster sums across all clusters.
+	CostBreakdown(ctx context.Context, cluster, namespace string, start, end time.Time) (CostBreakdown, error)
+}
+
+// DailyCost is a namespace's cost for one day. Estimated is the total before
+// reconciliation with the provider's bill scaled it.
+type DailyCost struct {
+	Day       time.Time
+	Compute   float64
+	Storage   float64
+	Network   float64
+	Other     float64
+	Total     float64
+	Estimated float64
+}
+
+// NamespaceCost is a namespace's cost in one cluster over a period. Estimated is the
+// total before reconciliation with the provider's bill scaled it.
+type NamespaceCost struct {
+	Cluster   string
+	Namespace string
+	Compute   float64
+	Storage   float64
+	Network   float64
+	Other     float64
+	Total     float64
+	Estimated float64
+}
+
+// ClusterCosts is a page of namespace costs. Count, Total and Estimated cover every
+// namespace, not just the page.
+type ClusterCosts struct {
+	Namespaces []NamespaceCost
+	Count      int
+	Total      float64
+	Estimated  float64
+}
+
+// CostBreakdown is a cost split by resource
+type CostBreakdown struct {
+	Compute float64
+	Storage float64
+	Network float64
+	Other   float64
+}
+
+func (p *Postgres) WriteCosts(ctx context.Context, costs []NamespaceCost, timestamp time.Time) error {
+	ctx, span := tracing.StartQuery(ctx, "write costs", attribute.Int("rows", len(costs)))
+	defer span.End()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO namespace_costs
+		(cluster, namespace, compute_cost, storage_cost, network_cost, other_cost, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (cluster, namespace, timestamp)
+		DO UPDATE SET
+			compute_cost = EXCLUDED.compute_cost,
+			storage_cost = EXCLUDED.storage_cost,
+			network_cost = EXCLUDED.network_cost,
+			other_cost = EXCLUDED.other_cost
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, cost := range costs {
+		if _, err := stmt.ExecContext(ctx, cost.Cluster, cost.Namespace, cost.Compute, cost.Storage,
+			cost.Network, cost.Other, timestamp); err != nil {
+			return fmt.Errorf("storing costs for %s: %w", cost.Namespace, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) CostNamespaces(ctx context.Context, since time.Time) ([]string, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT DISTINCT namespace FROM namespace_costs WHERE timestamp > $1
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+	return scanNamespaces(rows)
+}
+
+// scanNamespaces reads a column of namespace names
+func scanNamespaces(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var namespaces []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, fmt.Errorf("scanning namespaces: %w", err)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, rows.Err()
+}
+
+func (p *Postgres) LastCostTime(ctx context.Context, cluster string) (*time.Time, error) {
+	return p.lastTime(ctx, "namespace_costs", cluster)
+}
+
+func (p *Postgres) DailyCosts(ctx context.Context, cluster, namespace string, start, end time.Time) ([]DailyCost, error) {
+	ctx, span := tracing.StartQuery(ctx, "load daily costs", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT
+			DATE_TRUNC('day', timestamp) as day,
+			SUM(compute_cost) as compute,
+			SUM(storage_cost) as storage,
+			SUM(network_cost) as network,
+			SUM(other_cost) as other,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total,
+			SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor) as estimated
+		FROM namespace_costs
+		WHERE
+			namespace = $1
+			AND timestamp BETWEEN $2 AND $3
+			AND ($4 = '' OR cluster = $4)
+		GROUP BY day
+		ORDER BY day DESC
+	`, namespace, start, end, cluster)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var costs []DailyCost
+	for rows.Next() {
+		var cost DailyCost
+		if err := rows.Scan(&cost.Day, &cost.Compute, &cost.Storage,
+			&cost.Network, &cost.Other, &cost.Total, &cost.Estimated); err != nil {
+			continue
+		}
+		costs = append(costs, cost)
+	}
+	return costs, rows.Err()
+}
+
+func (p *Postgres) CurrentCost(ctx context.Context, namespace string, since time.Time) (float64, error) {
+	var total sql.NullFloat64
+	err := p.db.QueryRowContext(ctx, `
+		SELECT SUM(compute_cost + storage_cost + network_cost + other_cost)
+		FROM namespace_costs
+		WHERE namespace = $1 AND timestamp >= $2
+	`, namespace, since).Scan(&total)
+	return total.Float64, err
+}
+
+// ClusterCosts skips namespaces that fail to scan rather than failing the page
+func (p *Postgres) ClusterCosts(ctx context.Context, cluster string, since time.Time, limit, offset int) (*ClusterCosts, error) {
+	ctx, span := tracing.StartQuery(ctx, "load cluster costs", attribute.String("cluster", cluster))
+	defer span.End()
+
+	costs := &ClusterCosts{}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(DISTINCT (cluster, namespace)),
+			COALESCE(SUM(compute_cost + storage_cost + network_cost + other_cost), 0),
+			COALESCE(SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor), 0)
+		FROM namespace_costs
+		WHERE timestamp > $1
+			AND ($2 = '' OR cluster = $2)
+	`, since, cluster).Scan(&costs.Count, &costs.Total, &costs.Estimated)
+	if err != nil {
+		return nil, fmt.Errorf("querying cluster cost totals: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT
+			cluster,
+			namespace,
+			SUM(compute_cost) as compute,
+			SUM(storage_cost) as storage,
+			SUM(network_cost) as network,
+			SUM(other_cost) as other,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total,
+			SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor) as estimated
+		FROM namespace_costs
+		WHERE timestamp > $4
+			AND ($3 = '' OR cluster = $3)
+		GROUP BY cluster, namespace
+		ORDER BY total DESC, namespace, cluster
+		LIMIT $1 OFFSET $2
+	`, limit, offset, cluster, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying cluster costs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cost NamespaceCost
+		if err := rows.Scan(&cost.Cluster, &cost.Namespace, &cost.Compute, &cost.Storage,
+			&cost.Network, &cost.Other, &cost.Total, &cost.Estimated); err != nil {
+			continue
+		}
+		costs.Namespaces = append(costs.Namespaces, cost)
+	}
+	return costs, rows.Err()
+}
+
+func (p *Postgres) CostBreakdown(ctx context.Context, cluster, namespace string, start, end time.Time) (CostBreakdown, error) {
+	var breakdown CostBreakdown
+	err := p.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(compute_cost), 0) as compute,
+			COALESCE(SUM(storage_cost), 0) as storage,
+			COALESCE(SUM(network_cost), 0) as network,
+			COALESCE(SUM(other_cost), 0) as other
+		FROM namespace_costs
+		WHERE namespace = $1 AND timestamp BETWEEN $2 AND $3
+			AND ($4 = '' OR cluster = $4)
+	`, namespace, start, end, cluster).Scan(&breakdown.Compute, &breakdown.Storage, &breakdown.Network, &breakdown.Other)
+	return breakdown, err
+}
+
+func (s *SQLite) WriteCosts(ctx context.Context, costs []NamespaceCost, timestamp time.Time) error {
+	ctx, span := tracing.StartQuery(ctx, "write costs", attribute.Int("rows", len(costs)))
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO namespace_costs
+		(cluster, namespace, compute_cost, storage_cost, network_cost, other_cost, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cluster, namespace, timestamp)
+		DO UPDATE SET
+			compute_cost = excluded.compute_cost,
+			storage_cost = excluded.storage_cost,
+			network_cost = excluded.network_cost,
+			other_cost = excluded.other_cost
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, cost := range costs {
+		if _, err := stmt.ExecContext(ctx, cost.Cluster, cost.Namespace, cost.Compute, cost.Storage,
+			cost.Network, cost.Other, timestamp.UnixMilli()); err != nil {
+			return fmt.Errorf("storing costs for %s: %w", cost.Namespace, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) CostNamespaces(ctx context.Context, since time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT namespace FROM namespace_costs WHERE timestamp > ?
+	`, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+	return scanNamespaces(rows)
+}
+
+func (s *SQLite) LastCostTime(ctx context.Context, cluster string) (*time.Time, error) {
+	return s.lastTime(ctx, "namespace_costs", cluster)
+}
+
+// DailyCosts groups by UTC day, as DATE_TRUNC does in a UTC session
+func (s *SQLite) DailyCosts(ctx context.Context, cluster, namespace string, start, end time.Time) ([]DailyCost, error) {
+	ctx, span := tracing.StartQuery(ctx, "load daily costs", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			timestamp / 86400000 * 86400000 as day,
+			SUM(compute_cost) as compute,
+			SUM(storage_cost) as storage,
+			SUM(network_cost) as network,
+			SUM(other_cost) as other,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total,
+			SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor) as estimated
+		FROM namespace_costs
+		WHERE
+			namespace = ?
+			AND timestamp BETWEEN ? AND ?
+			AND (? = '' OR cluster = ?)
+		GROUP BY day
+		ORDER BY day DESC
+	`, namespace, start.UnixMilli(), end.UnixMilli(), cluster, cluster)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var costs []DailyCost
+	for rows.Next() {
+		var cost DailyCost
+		var day int64
+		if err := rows.Scan(&day, &cost.Compute, &cost.Storage,
+			&cost.Network, &cost.Other, &cost.Total, &cost.Estimated); err != nil {
+			return nil, fmt.Errorf("scanning daily costs: %w", err)
+		}
+		cost.Day = time.UnixMilli(day).UTC()
+		costs = append(costs, cost)
+	}
+	return costs, rows.Err()
+}
+
+func (s *SQLite) CurrentCost(ctx context.Context, namespace string, since time.Time) (float64, error) {
+	var total sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT SUM(compute_cost + storage_cost + network_cost + other_cost)
+		FROM namespace_costs
+		WHERE namespace = ? AND timestamp >= ?
+	`, namespace, since.UnixMilli()).Scan(&total)
+	return total.Float64, err
+}
+
+// ClusterCosts counts namespaces over a grouped subquery; SQLite can't count distinct
+// (cluster, namespace) pairs directly
+func (s *SQLite) ClusterCosts(ctx context.Context, cluster string, since time.Time, limit, offset int) (*ClusterCosts, error) {
+	ctx, span := tracing.StartQuery(ctx, "load cluster costs", attribute.String("cluster", cluster))
+	defer span.End()
+
+	costs := &ClusterCosts{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(total), 0), COALESCE(SUM(estimated), 0)
+		FROM (
+			SELECT
+				SUM(compute_cost + storage_cost + network_cost + other_cost) as total,
+				SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor) as estimated
+			FROM namespace_costs
+			WHERE timestamp > ? AND (? = '' OR cluster = ?)
+			GROUP BY cluster, namespace
+		)
+	`, since.UnixMilli(), cluster, cluster).Scan(&costs.Count, &costs.Total, &costs.Estimated)
+	if err != nil {
+		return nil, fmt.Errorf("querying cluster cost totals: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			cluster,
+			namespace,
+			SUM(compute_cost) as compute,
+			SUM(storage_cost) as storage,
+			SUM(network_cost) as network,
+			SUM(other_cost) as other,
+			SUM(compute_cost + storage_cost + network_cost + other_cost) as total,
+			SUM((compute_cost + storage_cost + network_cost + other_cost) / reconciliation_factor) as estimated
+		FROM namespace_costs
+		WHERE timestamp > ? AND (? = '' OR cluster = ?)
+		GROUP BY cluster, namespace
+		ORDER BY total DESC, namespace, cluster
+		LIMIT ? OFFSET ?
+	`, since.UnixMilli(), cluster, cluster, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("querying cluster costs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cost NamespaceCost
+		if err := rows.Scan(&cost.Cluster, &cost.Namespace, &cost.Compute, &cost.Storage,
+			&cost.Network, &cost.Other, &cost.Total, &cost.Estimated); err != nil {
+			return nil, fmt.Errorf("scanning cluster costs: %w", err)
+		}
+		costs.Namespaces = append(costs.Namespaces, cost)
+	}
+	return costs, rows.Err()
+}
+
+func (s *SQLite) CostBreakdown(ctx context.Context, cluster, namespace string, start, end time.Time) (CostBreakdown, error) {
+	var breakdown CostBreakdown
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(compute_cost), 0) as compute,
+			COALESCE(SUM(storage_cost), 0) as storage,
+			COALESCE(SUM(network_cost), 0) as network,
+			COALESCE(SUM(other_cost), 0) as other
+		FROM namespace_costs
+		WHERE namespace = ? AND timestamp BETWEEN ? AND ?
+			AND (? = '' OR cluster = ?)
+	`, namespace, start.UnixMilli(), end.UnixMilli(), cluster, cluster).Scan(
+		&breakdown.Compute, &breakdown.Storage, &breakdown.Network, &breakdown.Other)
+	return breakdown, err
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GPURepository reads the GPU utilization and requests the collector stores from DCGM
+type GPURepository interface {
+	// GPUUsageStats returns GPU usage statistics, in busy-GPU equivalents (utilization
+	// x devices), for each container in the namespace with at least minSamples
+	// utilization samples since the given time. An empty cluster covers all clusters.
+	GPUUsageStats(ctx context.Context, namespace, cluster string, since time.Time, minSamples int) ([]GPUStats, error)
+
+	// GPURequest returns the container's most recently stored GPU request and limit.
+	// An empty cluster takes the latest from any cluster.
+	GPURequest(ctx context.Context, namespace, cluster, podName, containerName string) (request, limit float64, err error)
+}
+
+// GPUStats is a container's GPU usage statistics
+type GPUStats struct {
+	PodName       string
+	ContainerName string
+	Samples       int
+	Usage         Stats
+}
+
+func (p *Postgres) GPUUsageStats(ctx context.Context, namespace, cluster string, since time.Time, minSamples int) ([]GPUStats, error) {
+	ctx, span := tracing.StartQuery(ctx, "load GPU stats", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT
+			pod_name,
+			container_name,
+			PERCENTILE_CONT(0.50) WITHIN GROUP (ORDER BY gpu_utilization / 100 * gpu_devices) as p50,
+			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY gpu_utilization / 100 * gpu_devices) as p95,
+			PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY gpu_utilization / 100 * gpu_devices) as p99,
+			MAX(gpu_utilization / 100 * gpu_devices) as max,
+			AVG(gpu_utilization / 100 * gpu_devices) as avg,
+			COALESCE(STDDEV(gpu_utilization / 100 * gpu_devices), 0) as stddev,
+			COUNT(*) as data_points
+		FROM gpu_metrics
+		WHERE
+			namespace = $1
+			AND gpu_utilization IS NOT NULL
+			AND timestamp > $2
+			AND ($4 = '' OR cluster = $4)
+		GROUP BY pod_name, container_name
+		HAVING COUNT(*) >= $3
+	`, namespace, since, minSamples, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying GPU metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []GPUStats
+	for rows.Next() {
+		var stat GPUStats
+		if err := rows.Scan(&stat.PodName, &stat.ContainerName, &stat.Usage.P50, &stat.Usage.P95, &stat.Usage.P99,
+			&stat.Usage.Max, &stat.Usage.Avg, &stat.Usage.StdDev, &stat.Samples); err != nil {
+			return nil, fmt.Errorf("scanning GPU metrics: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+func (p *Postgres) GPURequest(ctx context.Context, namespace, cluster, podName, containerName string) (float64, float64, error) {
+	var request, limit float64
+	err := p.db.QueryRowContext(ctx, `
+		SELECT gpu_request, gpu_limit
+		FROM gpu_metrics
+		WHERE namespace = $1 AND pod_name = $2 AND container_name = $3
+			AND gpu_request IS NOT NULL
+			AND ($4 = '' OR cluster = $4)
+		ORDER BY timestamp DESC LIMIT 1
+	`, namespace, podName, containerName, cluster).Scan(&request, &limit)
+	return request, limit, err
+}
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OutcomeRepository stores how applied recommendations held up, and reads the apply
+// actions and usage they are evaluated from
+type OutcomeRepository interface {
+	// PendingOutcomes returns the CPU and memory apply actions taken at or before the
+	// given time that haven't been evaluated, oldest first. Actions recorded without
+	// the workload they changed aren't returned.
+	PendingOutcomes(ctx context.Context, appliedBefore time.Time) ([]RecommendationOutcome, error)
+
+	// WorkloadPods returns the pods recorded with the workload as their owner between
+	// start and end
+	WorkloadPods(ctx context.Context, namespace string, owner Owner, start, end time.Time) ([]string, error)
+
+	// LoadOutcomeUsage fills in the outcome's samples, P95 and peak usage of its
+	// container and resource in the cluster's pods from the apply until end
+	LoadOutcomeUsage(ctx context.Context, outcome *RecommendationOutcome, cluster string, pods []string, end time.Time) error
+
+	// SaveOutcome stores an evaluated outcome. An action's outcome is saved once.
+	SaveOutcome(ctx context.Context, outcome *RecommendationOutcome) error
+
+	// Outcomes lists evaluated outcomes, newest first, with the total matching the
+	// filter across all pages
+	Outcomes(ctx context.Context, filter OutcomeFilter) ([]RecommendationOutcome, int, error)
+
+	// OutcomeCounts counts evaluated outcomes by resource type and outcome, for the
+	// namespace or every namespace when empty
+	OutcomeCounts(ctx context.Context, namespace string) ([]OutcomeCount, error)
+}
+
+// RecommendationOutcome is how an applied recommendation held up over the window after
+// it was applied, across the pods of the workload it changed
+type RecommendationOutcome struct {
+	ActionID           int64     `json:"action_id"`
+	Namespace          string    `json:"namespace"`
+	OwnerKind          string    `json:"owner_kind"`
+	OwnerName          string    `json:"owner_name"`
+	ContainerName      string    `json:"container_name"`
+	ResourceType       string    `json:"resource_type"`
+	RecommendedRequest float64   `json:"recommended_request"`
+	RecommendedLimit   float64   `json:"recommended_limit"`
+	P95Usage           float64   `json:"p95_usage"`
+	MaxUsage           float64   `json:"max_usage"`
+	Samples            int       `json:"samples"`
+	Restarts           int       `json:"restarts"`
+	OOMKills           int       `json:"oom_kills"`
+	Outcome            string    `json:"outcome"`
+	AppliedAt          time.Time `json:"applied_at"`
+	EvaluatedAt        time.Time `json:"evaluated_at"`
+}
+
+// OutcomeFilter narrows and pages Outcomes. Empty fields match everything;
+// ResourceType is matched case-insensitively.
+type OutcomeFilter struct {
+	Namespace    string
+	ResourceType string
+	Outcome      string
+	Limit        int
+	Offset       int
+}
+
+// OutcomeCount is the number of evaluated outcomes of a resource type with an outcome
+type OutcomeCount struct {
+	ResourceType string
+	Outcome      string
+	Count        int
+}
+
+func (p *Postgres) PendingOutcomes(ctx context.Context, appliedBefore time.Time) ([]RecommendationOutcome, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT
+			a.id, a.namespace, a.owner_kind, a.owner_name, a.container_name, a.resource_type,
+			COALESCE(a.recommended_request, 0), COALESCE(a.recommended_limit, 0), a.applied_at
+		FROM recommendation_actions a
+		LEFT JOIN recommendation_outcomes o ON o.action_id = a.id
+		WHERE a.action = 'apply'
+			AND a.owner_name IS NOT NULL
+			AND a.resource_type IN ('CPU', 'Memory')
+			AND a.applied_at <= $1
+			AND o.action_id IS NULL
+		ORDER BY a.applied_at
+	`, appliedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("querying pending recommendation outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []RecommendationOutcome
+	for rows.Next() {
+		var outcome RecommendationOutcome
+		if err := rows.Scan(&outcome.ActionID, &outcome.Namespace, &outcome.OwnerKind, &outcome.OwnerName,
+			&outcome.ContainerName, &outcome.ResourceType, &outcome.RecommendedRequest,
+			&outcome.RecommendedLimit, &outcome.AppliedAt); err != nil {
+			return nil, fmt.Errorf("scanning pending recommendation outcomes: %w", err)
+		}
+		pending = append(pending, outcome)
+	}
+	return pending, rows.Err()
+}
+
+func (p *Postgres) WorkloadPods(ctx context.Context, namespace string, owner Owner, start, end time.Time) ([]string, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT DISTINCT pod_name
+		FROM resource_requests
+		WHERE namespace = $1 AND owner_kind = $2 AND owner_name = $3
+			AND timestamp > $4 AND timestamp <= $5
+	`, namespace, owner.Kind, owner.Name, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("querying workload pods: %w", err)
+	}
+	defer rows.Close()
+
+	var pods []string
+	for rows.Next() {
+		var pod string
+		if err := rows.Scan(&pod); err != nil {
+			return nil, fmt.Errorf("scanning workload pods: %w", err)
+		}
+		pods = append(pods, pod)
+	}
+	return pods, rows.Err()
+}
+
+func (p *Postgres) LoadOutcomeUsage(ctx context.Context, outcome *RecommendationOutcome, cluster string, pods []string, end time.Time) error {
+	ctx, span := tracing.StartQuery(ctx, "load outcome usage", attribute.Int64("action_id", outcome.ActionID))
+	defer span.End()
+
+	err := p.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(value),
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY value), 0),
+			COALESCE(MAX(value), 0)
+		FROM (
+			SELECT CASE WHEN $6 = 'CPU' THEN cpu_millicores ELSE memory_bytes END as value
+			FROM pod_metrics
+			WHERE namespace = $1 AND pod_name = ANY($2) AND container_name = $3
+				AND timestamp > $4 AND timestamp <= $5 AND cluster = $7
+		) applied_usage
+	`, outcome.Namespace, pq.Array(pods), outcome.ContainerName, outcome.AppliedAt, end,
+		outcome.ResourceType, cluster).Scan(&outcome.Samples, &outcome.P95Usage, &outcome.MaxUsage)
+	if err != nil {
+		return fmt.Errorf("querying usage after apply: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) SaveOutcome(ctx context.Context, outcome *RecommendationOutcome) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO recommendation_outcomes
+		(action_id, namespace, owner_kind, owner_name, container_name, resource_type,
+		 recommended_request, recommended_limit, p95_usage, max_usage,
+		 samples, restarts, oom_kills, outcome, applied_at, evaluated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (action_id) DO NOTHING
+	`, outcome.ActionID, outcome.Namespace, outcome.OwnerKind, outcome.OwnerName, outcome.ContainerName,
+		outcome.ResourceType, outcome.RecommendedRequest, outcome.RecommendedLimit, outcome.P95Usage,
+		outcome.MaxUsage, outcome.Samples, outcome.Restarts, outcome.OOMKills, outcome.Outcome,
+		outcome.AppliedAt, outcome.EvaluatedAt)
+	return err
+}
+
+func (p *Postgres) Outcomes(ctx context.Context, filter OutcomeFilter) ([]RecommendationOutcome, int, error) {
+	ctx, span := tracing.StartQuery(ctx, "load recommendation outcomes")
+	defer span.End()
+
+	var total int
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM recommendation_outcomes
+		WHERE ($1 = '' OR namespace = $1)
+			AND ($2 = '' OR UPPER(resource_type) = UPPER($2))
+			AND ($3 = '' OR outcome = $3)
+	`, filter.Namespace, filter.ResourceType, filter.Outcome).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting recommendation outcomes: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT
+			action_id, namespace, owner_kind, owner_name, container_name, resource_type,
+			COALESCE(recommended_request, 0), COALESCE(recommended_limit, 0),
+			COALESCE(p95_usage, 0), COALESCE(max_usage, 0),
+			samples, restarts, oom_kills, outcome, applied_at, evaluated_at
+		FROM recommendation_outcomes
+		WHERE ($1 = '' OR namespace = $1)
+			AND ($2 = '' OR UPPER(resource_type) = UPPER($2))
+			AND ($3 = '' OR outcome = $3)
+		ORDER BY evaluated_at DESC, action_id DESC
+		LIMIT $4 OFFSET $5
+	`, filter.Namespace, filter.ResourceType, filter.Outcome, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying recommendation outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	var outcomes []RecommendationOutcome
+	for rows.Next() {
+		var outcome RecommendationOutcome
+		if err := rows.Scan(&outcome.ActionID, &outcome.Namespace, &outcome.OwnerKind, &outcome.OwnerName,
+			&outcome.ContainerName, &outcome.ResourceType, &outcome.RecommendedRequest,
+			&outcome.RecommendedLimit, &outcome.P95Usage, &outcome.MaxUsage, &outcome.Samples,
+			&outcome.Restarts, &outcome.OOMKills, &outcome.Outcome, &outcome.AppliedAt,
+			&outcome.EvaluatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scanning recommendation outcomes: %w", err)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, total, rows.Err()
+}
+
+func (p *Postgres) OutcomeCounts(ctx context.Context, namespace string) ([]OutcomeCount, error) {
+	ctx, span := tracing.StartQuery(ctx, "load recommendation outcome stats")
+	defer span.End()
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT resource_type, outcome, COUNT(*)
+		FROM recommendation_outcomes
+		WHERE $1 = '' OR namespace = $1
+		GROUP BY resource_type, outcome
+	`, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("querying recommendation outcome stats: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []OutcomeCount
+	for rows.Next() {
+		var count OutcomeCount
+		if err := rows.Scan(&count.ResourceType, &count.Outcome, &count.Count); err != nil {
+			return nil, fmt.Errorf("scanning recommendation outcome stats: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,355 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Postgres stores usage in the pod_metrics and resource_requests tables the
+// migrations create
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres uses an open database the migrations have been run on. Close closes it.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// OpenPostgres connects to the database at the DSN
+func OpenPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("pinging database: %w", err)
+	}
+	return NewPostgres(db), nil
+}
+
+func (p *Postgres) WriteUsage(ctx context.Context, samples []UsageSample) error {
+	ctx, span := tracing.StartQuery(ctx, "write usage", attribute.Int("rows", len(samples)))
+	defer span.End()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO pod_metrics
+		(cluster, namespace, pod_name, container_name, cpu_millicores, memory_bytes, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp) DO NOTHING
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range samples {
+		if _, err := stmt.ExecContext(ctx, s.Cluster, s.Namespace, s.PodName, s.ContainerName,
+			s.CPU, s.Memory, s.Timestamp); err != nil {
+			return fmt.Errorf("storing usage for %s/%s/%s: %w", s.Namespace, s.PodName, s.ContainerName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) WriteResources(ctx context.Context, resources []ContainerResources) error {
+	ctx, span := tracing.StartQuery(ctx, "write resources", attribute.Int("rows", len(resources)))
+	defer span.End()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO resource_requests
+		(cluster, namespace, pod_name, container_name, cpu_request, cpu_limit, memory_request, memory_limit,
+		 timestamp, owner_kind, owner_name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp)
+		DO UPDATE SET
+			cpu_request = EXCLUDED.cpu_request,
+			cpu_limit = EXCLUDED.cpu_limit,
+			memory_request = EXCLUDED.memory_request,
+			memory_limit = EXCLUDED.memory_limit,
+			owner_kind = EXCLUDED.owner_kind,
+			owner_name = EXCLUDED.owner_name
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range resources {
+		if _, err := stmt.ExecContext(ctx, r.Cluster, r.Namespace, r.PodName, r.ContainerName,
+			r.CPURequest, r.CPULimit, r.MemoryRequest, r.MemoryLimit, r.Timestamp,
+			r.Owner.Kind, r.Owner.Name); err != nil {
+			return fmt.Errorf("storing resources for %s/%s/%s: %w", r.Namespace, r.PodName, r.ContainerName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) Usage(ctx context.Context, namespace, cluster string, since time.Time) ([]UsageSample, error) {
+	ctx, span := tracing.StartQuery(ctx, "load usage", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT cluster, pod_name, container_name, cpu_millicores, memory_bytes, timestamp
+		FROM pod_metrics
+		WHERE namespace = $1 AND timestamp > $2 AND ($3 = '' OR cluster = $3)
+		ORDER BY pod_name, container_name, timestamp
+	`, namespace, since, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying usage: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []UsageSample
+	for rows.Next() {
+		s := UsageSample{Namespace: namespace}
+		if err := rows.Scan(&s.Cluster, &s.PodName, &s.ContainerName, &s.CPU, &s.Memory, &s.Timestamp); err != nil {
+			return nil, fmt.Errorf("scanning usage: %w", err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// usageStatsQuery computes each container's usage statistics. Its parameters are the
+// namespace, the minimum samples, the cluster (empty for all) and the window start. It
+// reads only columns carried by the idx_pod_metrics_analysis covering index, so the
+// scan stays index-only; TestUsageStatsUsesAnalysisIndex checks the plan.
+const usageStatsQuery = `
+		SELECT
+			pm.pod_name,
+			pm.container_name,
+			PERCENTILE_CONT(0.50) WITHIN GROUP (ORDER BY pm.cpu_millicores) as p50_cpu,
+			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY pm.cpu_millicores) as p95_cpu,
+			PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY pm.cpu_millicores) as p99_cpu,
+			MAX(pm.cpu_millicores) as max_cpu,
+			AVG(pm.cpu_millicores) as avg_cpu,
+			STDDEV(pm.cpu_millicores) as stddev_cpu,
+			COUNT(*) as data_points,
+			PERCENTILE_CONT(0.50) WITHIN GROUP (ORDER BY pm.memory_bytes) as p50_mem,
+			PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY pm.memory_bytes) as p95_mem,
+			PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY pm.memory_bytes) as p99_mem,
+			MAX(pm.memory_bytes) as max_mem,
+			AVG(pm.memory_bytes) as avg_mem,
+			STDDEV(pm.memory_bytes) as stddev_mem
+		FROM pod_metrics pm
+		WHERE
+			pm.namespace = $1
+			AND pm.timestamp > $4
+			AND ($3 = '' OR pm.cluster = $3)
+		GROUP BY pm.pod_name, pm.container_name
+		HAVING COUNT(*) >= $2
+`
+
+// UsageStats computes the statistics in SQL with usageStatsQuery
+func (p *Postgres) UsageStats(ctx context.Context, namespace, cluster string, since time.Time, minSamples int) ([]ContainerStats, error) {
+	ctx, span := tracing.StartQuery(ctx, "load raw stats", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := p.db.QueryContext(ctx, usageStatsQuery, namespace, minSamples, cluster, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []ContainerStats
+	for rows.Next() {
+		var stat ContainerStats
+		var cpuStdDev, memoryStdDev sql.NullFloat64
+		if err := rows.Scan(&stat.PodName, &stat.ContainerName,
+			&stat.CPU.P50, &stat.CPU.P95, &stat.CPU.P99, &stat.CPU.Max, &stat.CPU.Avg, &cpuStdDev, &stat.Samples,
+			&stat.Memory.P50, &stat.Memory.P95, &stat.Memory.P99, &stat.Memory.Max, &stat.Memory.Avg, &memoryStdDev); err != nil {
+			return nil, fmt.Errorf("scanning metrics for %s/%s: %w", stat.PodName, stat.ContainerName, err)
+		}
+		// STDDEV is NULL for a single sample
+		stat.CPU.StdDev = cpuStdDev.Float64
+		stat.Memory.StdDev = memoryStdDev.Float64
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+func (p *Postgres) CurrentResources(ctx context.Context, namespace, cluster, podName, containerName string) (*ContainerResources, error) {
+	ctx, span := tracing.StartQuery(ctx, "get current resources",
+		attribute.String("namespace", namespace), attribute.String("pod", podName), attribute.String("container", containerName))
+	defer span.End()
+
+	r := &ContainerResources{Namespace: namespace, PodName: podName, ContainerName: containerName}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT cluster, cpu_request, cpu_limit, memory_request, memory_limit,
+			COALESCE(owner_kind, 'Pod'), COALESCE(owner_name, pod_name), timestamp
+		FROM resource_requests
+		WHERE namespace = $1 AND pod_name = $2 AND container_name = $3 AND ($4 = '' OR cluster = $4)
+		ORDER BY timestamp DESC LIMIT 1
+	`, namespace, podName, containerName, cluster).Scan(&r.Cluster, &r.CPURequest, &r.CPULimit, &r.MemoryRequest, &r.MemoryLimit,
+		&r.Owner.Kind, &r.Owner.Name, &r.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("getting current resources: %w", err)
+	}
+	return r, nil
+}
+
+func (p *Postgres) Owners(ctx context.Context, namespace, cluster string, since time.Time) (map[string]Owner, error) {
+	ctx, span := tracing.StartQuery(ctx, "load pod owners", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (pod_name)
+			pod_name, COALESCE(owner_kind, 'Pod'), COALESCE(owner_name, pod_name)
+		FROM resource_requests
+		WHERE namespace = $1 AND timestamp > $2 AND ($3 = '' OR cluster = $3)
+		ORDER BY pod_name, timestamp DESC
+	`, namespace, since, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying pod owners: %w", err)
+	}
+	defer rows.Close()
+
+	owners := make(map[string]Owner)
+	for rows.Next() {
+		var podName string
+		var owner Owner
+		if err := rows.Scan(&podName, &owner.Kind, &owner.Name); err != nil {
+			return nil, fmt.Errorf("scanning pod owners: %w", err)
+		}
+		owners[podName] = owner
+	}
+	return owners, rows.Err()
+}
+
+// ResourceUsage joins each container's usage to only its latest resource_requests row
+func (p *Postgres) ResourceUsage(ctx context.Context, namespace, cluster string, since time.Time) ([]ContainerUsage, error) {
+	ctx, span := tracing.StartQuery(ctx, "load resource usage", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := p.db.QueryContext(ctx, `
+		WITH usage AS (
+			SELECT
+				pm.cluster,
+				pm.pod_name,
+				pm.container_name,
+				AVG(pm.cpu_millicores) as avg_cpu,
+				MAX(pm.cpu_millicores) as max_cpu,
+				AVG(pm.memory_bytes) as avg_memory,
+				MAX(pm.memory_bytes) as max_memory
+			FROM pod_metrics pm
+			WHERE pm.namespace = $1
+				AND pm.timestamp > $3
+				AND ($2 = '' OR pm.cluster = $2)
+			GROUP BY pm.cluster, pm.pod_name, pm.container_name
+		)
+		SELECT
+			u.cluster,
+			u.pod_name,
+			u.container_name,
+			u.avg_cpu,
+			u.max_cpu,
+			u.avg_memory,
+			u.max_memory,
+			COALESCE(rr.cpu_request, 0),
+			COALESCE(rr.cpu_limit, 0),
+			COALESCE(rr.memory_request, 0),
+			COALESCE(rr.memory_limit, 0)
+		FROM usage u
+		LEFT JOIN LATERAL (
+			SELECT cpu_request, cpu_limit, memory_request, memory_limit
+			FROM resource_requests
+			WHERE cluster = u.cluster AND namespace = $1
+				AND pod_name = u.pod_name AND container_name = u.container_name
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) rr ON true
+		ORDER BY u.cluster, u.pod_name, u.container_name
+	`, namespace, cluster, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying resource usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []ContainerUsage
+	for rows.Next() {
+		var u ContainerUsage
+		if err := rows.Scan(&u.Cluster, &u.PodName, &u.ContainerName,
+			&u.AvgCPU, &u.MaxCPU, &u.AvgMemory, &u.MaxMemory,
+			&u.CPURequest, &u.CPULimit, &u.MemoryRequest, &u.MemoryLimit); err != nil {
+			return nil, fmt.Errorf("scanning resource usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+func (p *Postgres) NamespaceRequests(ctx context.Context, namespace string) (NamespaceRequests, error) {
+	var r NamespaceRequests
+	err := p.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(cpu_request), 0),
+			COALESCE(SUM(cpu_limit), 0),
+			COALESCE(SUM(memory_request), 0),
+			COALESCE(SUM(memory_limit), 0),
+			COUNT(*) FILTER (WHERE cpu_limit > 0),
+			COUNT(*) FILTER (WHERE memory_limit > 0)
+		FROM resource_requests
+		WHERE namespace = $1
+			AND timestamp = (SELECT MAX(timestamp) FROM resource_requests WHERE namespace = $1)
+	`, namespace).Scan(&r.Containers, &r.CPURequest, &r.CPULimit,
+		&r.MemoryRequest, &r.MemoryLimit, &r.CPULimited, &r.MemoryLimited)
+	if err != nil {
+		return r, fmt.Errorf("querying current requests: %w", err)
+	}
+	return r, nil
+}
+
+func (p *Postgres) LastUsageTime(ctx context.Context, cluster string) (*time.Time, error) {
+	return p.lastTime(ctx, "pod_metrics", cluster)
+}
+
+// lastTime returns the newest timestamp stored in the table for the cluster, or nil if
+// it has none
+func (p *Postgres) lastTime(ctx context.Context, table, cluster string) (*time.Time, error) {
+	var latest sql.NullTime
+	err := p.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT MAX(timestamp) FROM %s WHERE cluster = $1`, table), cluster).Scan(&latest)
+	if err != nil || !latest.Valid {
+		return nil, err
+	}
+	return &latest.Time, nil
+}
+
+// Repositories returns the Postgres store as every repository
+func (p *Postgres) Repositories() Repositories {
+	return Repositories{
+		Metrics:         p,
+		Costs:           p,
+		Recommendations: p,
+		GPU:             p,
+		Budgets:         p,
+		Outcomes:        p,
+		ReportSchedules: p,
+	}
+}
+
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
@@ -0,0 +1,471 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RecommendationRepository stores recommendations so they can be applied by ID and
+// listed as history
+type RecommendationRepository interface {
+	// SaveRecommendation stores the recommendation and sets its ID. A container has at
+	// most one unapplied recommendation per resource, which saving again updates in
+	// place; once applied it is kept as history and the next save starts a new one.
+	SaveRecommendation(ctx context.Context, rec *Recommendation) error
+
+	// Recommendation loads a stored recommendation by ID
+	Recommendation(ctx context.Context, id int64) (*Recommendation, error)
+
+	// RecommendationHistory returns a page of the namespace's stored recommendations,
+	// newest first, along with the number matching the filter across all pages
+	RecommendationHistory(ctx context.Context, namespace string, filter RecommendationFilter) ([]Recommendation, int, error)
+
+	// MarkApplied records that a stored recommendation has been applied to the cluster
+	MarkApplied(ctx context.Context, id int64) error
+
+	// OpenRecommendationIDs returns the IDs of the namespace's unapplied recommendations
+	OpenRecommendationIDs(ctx context.Context, namespace string) (map[RecommendationKey]int64, error)
+
+	// RecordAction stores an action taken on a recommendation for auditing
+	RecordAction(ctx context.Context, action RecommendationAction) error
+}
+
+// RecommendationAction is an action taken on a recommendation, e.g. applying it
+type RecommendationAction struct {
+	RecommendationID int64 // Zero for a recommendation that wasn't stored
+	Namespace        string
+	PodName          string
+	ContainerName    string
+	ResourceType     string
+	Action           string
+	TakenAt          time.Time
+
+	// The workload an applied change was made to, empty for other actions. The outcome
+	// evaluator later checks its usage against the recommended request and limit.
+	OwnerKind          string
+	OwnerName          string
+	RecommendedRequest float64
+	RecommendedLimit   float64
+}
+
+// RecommendationKey identifies the resource of a container a recommendation is for. A
+// key has at most one unapplied recommendation.
+type RecommendationKey struct {
+	PodName       string
+	ContainerName string
+	ResourceType  string
+}
+
+// Recommendation is a recommended request and limit for one resource of a container
+type Recommendation struct {
+	ID                 int64 // Set for recommendations loaded from the recommendations table
+	Namespace          string
+	PodName            string
+	ContainerName      string
+	ResourceType       string
+	CurrentRequest     float64
+	CurrentLimit       float64
+	RecommendedRequest float64
+	RecommendedLimit   float64
+	P50Usage           float64
+	P95Usage           float64
+	P99Usage           float64
+	MaxUsage           float64
+	PotentialSavings   float64 // Monthly; zero for a container without a request
+	InitialSizingCost  float64 // Monthly cost of giving a container without a request one
+	Confidence         float64
+	Reasoning          string
+	RiskLevel          string
+	LastUpdated        time.Time
+	Applied            bool       // Set for recommendations loaded from the recommendations table
+	AppliedAt          *time.Time // When an applied recommendation was applied
+	OwnerKind          string     // Kind of the workload that owns the pod, e.g. Deployment or DaemonSet
+	OwnerName          string
+	Replicas           int    // Pods a DaemonSet or StatefulSet recommendation covers; 1 otherwise
+	CurrentQoS         string // QoS class the current request and limit give the container
+	TargetQoS          string // QoS class the recommended request and limit give it
+	OOMObserved        bool   // The container was OOMKilled in the analysis window, so memory isn't reduced
+	ThrottleObserved   bool   // The container was CPU throttled in the analysis window, so CPU isn't reduced
+}
+
+// IsQoSTransition reports whether applying the recommendation moves the container to
+// the given QoS class from another
+func (r Recommendation) IsQoSTransition(target string) bool {
+	return r.TargetQoS == target && r.CurrentQoS != target
+}
+
+// Key returns the container resource the recommendation is for
+func (r Recommendation) Key() RecommendationKey {
+	return RecommendationKey{PodName: r.PodName, ContainerName: r.ContainerName, ResourceType: r.ResourceType}
+}
+
+// RecommendationFilter narrows and pages RecommendationHistory. Empty ResourceType
+// and RiskLevel match everything; both are matched case-insensitively.
+type RecommendationFilter struct {
+	ResourceType string
+	RiskLevel    string
+	Limit        int
+	Offset       int
+}
+
+func (p *Postgres) SaveRecommendation(ctx context.Context, rec *Recommendation) error {
+	return p.db.QueryRowContext(ctx, `
+		INSERT INTO recommendations
+		(namespace, pod_name, container_name, resource_type,
+		 current_request, current_limit, recommended_request, recommended_limit,
+		 p50_usage, p95_usage, p99_usage, max_usage,
+		 potential_savings, confidence, reasoning, risk_level, created_at, owner_kind,
+		 oom_observed, throttle_observed, initial_sizing_cost)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		ON CONFLICT (namespace, pod_name, container_name, resource_type) WHERE NOT applied
+		DO UPDATE SET
+			current_request = EXCLUDED.current_request,
+			current_limit = EXCLUDED.current_limit,
+			recommended_request = EXCLUDED.recommended_request,
+			recommended_limit = EXCLUDED.recommended_limit,
+			p50_usage = EXCLUDED.p50_usage,
+			p95_usage = EXCLUDED.p95_usage,
+			p99_usage = EXCLUDED.p99_usage,
+			max_usage = EXCLUDED.max_usage,
+			potential_savings = EXCLUDED.potential_savings,
+			confidence = EXCLUDED.confidence,
+			reasoning = EXCLUDED.reasoning,
+			risk_level = EXCLUDED.risk_level,
+			created_at = EXCLUDED.created_at,
+			owner_kind = EXCLUDED.owner_kind,
+			oom_observed = EXCLUDED.oom_observed,
+			throttle_observed = EXCLUDED.throttle_observed,
+			initial_sizing_cost = EXCLUDED.initial_sizing_cost
+		RETURNING id
+	`, rec.Namespace, rec.PodName, rec.ContainerName, rec.ResourceType,
+		rec.CurrentRequest, rec.CurrentLimit, rec.RecommendedRequest, rec.RecommendedLimit,
+		rec.P50Usage, rec.P95Usage, rec.P99Usage, rec.MaxUsage,
+		rec.PotentialSavings, rec.Confidence, rec.Reasoning, rec.RiskLevel, rec.LastUpdated, rec.OwnerKind,
+		rec.OOMObserved, rec.ThrottleObserved, rec.InitialSizingCost).Scan(&rec.ID)
+}
+
+func (p *Postgres) Recommendation(ctx context.Context, id int64) (*Recommendation, error) {
+	rec := &Recommendation{ID: id}
+
+	err := p.db.QueryRowContext(ctx, `
+		SELECT
+			namespace, pod_name, container_name, resource_type,
+			current_request, current_limit, recommended_request, recommended_limit,
+			p50_usage, p95_usage, p99_usage, max_usage,
+			potential_savings, confidence, reasoning, risk_level, created_at, COALESCE(owner_kind, ''),
+			oom_observed, throttle_observed, initial_sizing_cost
+		FROM recommendations
+		WHERE id = $1
+	`, id).Scan(
+		&rec.Namespace, &rec.PodName, &rec.ContainerName, &rec.ResourceType,
+		&rec.CurrentRequest, &rec.CurrentLimit, &rec.RecommendedRequest, &rec.RecommendedLimit,
+		&rec.P50Usage, &rec.P95Usage, &rec.P99Usage, &rec.MaxUsage,
+		&rec.PotentialSavings, &rec.Confidence, &rec.Reasoning, &rec.RiskLevel, &rec.LastUpdated, &rec.OwnerKind,
+		&rec.OOMObserved, &rec.ThrottleObserved, &rec.InitialSizingCost,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("recommendation %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting recommendation %d: %w", id, err)
+	}
+
+	return rec, nil
+}
+
+// RecommendationHistory skips rows that fail to scan rather than failing the page
+func (p *Postgres) RecommendationHistory(ctx context.Context, namespace string, filter RecommendationFilter) ([]Recommendation, int, error) {
+	var total int
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM recommendations
+		WHERE namespace = $1
+			AND ($2 = '' OR UPPER(resource_type) = UPPER($2))
+			AND ($3 = '' OR UPPER(risk_level) = UPPER($3))
+	`, namespace, filter.ResourceType, filter.RiskLevel).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting recommendation history: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT
+			id, namespace, pod_name, container_name, resource_type,
+			current_request, current_limit, recommended_request, recommended_limit,
+			p50_usage, p95_usage, p99_usage, max_usage,
+			potential_savings, confidence, reasoning, risk_level, created_at,
+			COALESCE(applied, FALSE), applied_at, COALESCE(owner_kind, ''),
+			oom_observed, throttle_observed, initial_sizing_cost
+		FROM recommendations
+		WHERE namespace = $1
+			AND ($2 = '' OR UPPER(resource_type) = UPPER($2))
+			AND ($3 = '' OR UPPER(risk_level) = UPPER($3))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`, namespace, filter.ResourceType, filter.RiskLevel, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying recommendation history: %w", err)
+	}
+	defer rows.Close()
+
+	var recommendations []Recommendation
+	for rows.Next() {
+		var rec Recommendation
+		var appliedAt sql.NullTime
+
+		err := rows.Scan(
+			&rec.ID, &rec.Namespace, &rec.PodName, &rec.ContainerName, &rec.ResourceType,
+			&rec.CurrentRequest, &rec.CurrentLimit, &rec.RecommendedRequest, &rec.RecommendedLimit,
+			&rec.P50Usage, &rec.P95Usage, &rec.P99Usage, &rec.MaxUsage,
+			&rec.PotentialSavings, &rec.Confidence, &rec.Reasoning, &rec.RiskLevel, &rec.LastUpdated,
+			&rec.Applied, &appliedAt, &rec.OwnerKind,
+			&rec.OOMObserved, &rec.ThrottleObserved, &rec.InitialSizingCost,
+		)
+		if err != nil {
+			continue
+		}
+
+		if appliedAt.Valid {
+			rec.AppliedAt = &appliedAt.Time
+		}
+		recommendations = append(recommendations, rec)
+	}
+
+	return recommendations, total, rows.Err()
+}
+
+func (p *Postgres) MarkApplied(ctx context.Context, id int64) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE recommendations SET applied = TRUE, applied_at = NOW() WHERE id = $1
+	`, id)
+	return err
+}
+
+func (p *Postgres) OpenRecommendationIDs(ctx context.Context, namespace string) (map[RecommendationKey]int64, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, pod_name, container_name, resource_type
+		FROM recommendations
+		WHERE namespace = $1 AND NOT applied
+	`, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("querying open recommendations: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[RecommendationKey]int64)
+	for rows.Next() {
+		var id int64
+		var key RecommendationKey
+		if err := rows.Scan(&id, &key.PodName, &key.ContainerName, &key.ResourceType); err != nil {
+			return nil, fmt.Errorf("scanning open recommendation: %w", err)
+		}
+		ids[key] = id
+	}
+	return ids, rows.Err()
+}
+
+func (p *Postgres) RecordAction(ctx context.Context, action RecommendationAction) error {
+	var recommendationID sql.NullInt64
+	if action.RecommendationID != 0 {
+		recommendationID = sql.NullInt64{Int64: action.RecommendationID, Valid: true}
+	}
+	var ownerKind, ownerName sql.NullString
+	if action.OwnerName != "" {
+		ownerKind = sql.NullString{String: action.OwnerKind, Valid: true}
+		ownerName = sql.NullString{String: action.OwnerName, Valid: true}
+	}
+
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO recommendation_actions
+		(namespace, pod_name, container_name, resource_type, action, applied_at,
+		 recommendation_id, owner_kind, owner_name, recommended_request, recommended_limit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, action.Namespace, action.PodName, action.ContainerName, action.ResourceType, action.Action, action.TakenAt,
+		recommendationID, ownerKind, ownerName, action.RecommendedRequest, action.RecommendedLimit)
+	return err
+}
+
+func (s *SQLite) SaveRecommendation(ctx context.Context, rec *Recommendation) error {
+	return s.db.QueryRowContext(ctx, `
+		INSERT INTO recommendations
+		(namespace, pod_name, container_name, resource_type,
+		 current_request, current_limit, recommended_request, recommended_limit,
+		 p50_usage, p95_usage, p99_usage, max_usage,
+		 potential_savings, confidence, reasoning, risk_level, created_at, owner_kind,
+		 oom_observed, throttle_observed, initial_sizing_cost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (namespace, pod_name, container_name, resource_type) WHERE NOT applied
+		DO UPDATE SET
+			current_request = excluded.current_request,
+			current_limit = excluded.current_limit,
+			recommended_request = excluded.recommended_request,
+			recommended_limit = excluded.recommended_limit,
+			p50_usage = excluded.p50_usage,
+			p95_usage = excluded.p95_usage,
+			p99_usage = excluded.p99_usage,
+			max_usage = excluded.max_usage,
+			potential_savings = excluded.potential_savings,
+			confidence = excluded.confidence,
+			reasoning = excluded.reasoning,
+			risk_level = excluded.risk_level,
+			created_at = excluded.created_at,
+			owner_kind = excluded.owner_kind,
+			oom_observed = excluded.oom_observed,
+			throttle_observed = excluded.throttle_observed,
+			initial_sizing_cost = excluded.initial_sizing_cost
+		RETURNING id
+	`, rec.Namespace, rec.PodName, rec.ContainerName, rec.ResourceType,
+		rec.CurrentRequest, rec.CurrentLimit, rec.RecommendedRequest, rec.RecommendedLimit,
+		rec.P50Usage, rec.P95Usage, rec.P99Usage, rec.MaxUsage,
+		rec.PotentialSavings, rec.Confidence, rec.Reasoning, rec.RiskLevel, rec.LastUpdated.UnixMilli(), rec.OwnerKind,
+		rec.OOMObserved, rec.ThrottleObserved, rec.InitialSizingCost).Scan(&rec.ID)
+}
+
+func (s *SQLite) Recommendation(ctx context.Context, id int64) (*Recommendation, error) {
+	rec := &Recommendation{ID: id}
+	var createdAt int64
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			namespace, pod_name, container_name, resource_type,
+			current_request, current_limit, recommended_request, recommended_limit,
+			p50_usage, p95_usage, p99_usage, max_usage,
+			potential_savings, confidence, reasoning, risk_level, created_at, COALESCE(owner_kind, ''),
+			oom_observed, throttle_observed, initial_sizing_cost
+		FROM recommendations
+		WHERE id = ?
+	`, id).Scan(
+		&rec.Namespace, &rec.PodName, &rec.ContainerName, &rec.ResourceType,
+		&rec.CurrentRequest, &rec.CurrentLimit, &rec.RecommendedRequest, &rec.RecommendedLimit,
+		&rec.P50Usage, &rec.P95Usage, &rec.P99Usage, &rec.MaxUsage,
+		&rec.PotentialSavings, &rec.Confidence, &rec.Reasoning, &rec.RiskLevel, &createdAt, &rec.OwnerKind,
+		&rec.OOMObserved, &rec.ThrottleObserved, &rec.InitialSizingCost,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("recommendation %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting recommendation %d: %w", id, err)
+	}
+
+	rec.LastUpdated = time.UnixMilli(createdAt)
+	return rec, nil
+}
+
+func (s *SQLite) RecommendationHistory(ctx context.Context, namespace string, filter RecommendationFilter) ([]Recommendation, int, error) {
+	var total int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM recommendations
+		WHERE namespace = ?
+			AND (? = '' OR UPPER(resource_type) = UPPER(?))
+			AND (? = '' OR UPPER(risk_level) = UPPER(?))
+	`, namespace, filter.ResourceType, filter.ResourceType, filter.RiskLevel, filter.RiskLevel).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting recommendation history: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			id, namespace, pod_name, container_name, resource_type,
+			current_request, current_limit, recommended_request, recommended_limit,
+			p50_usage, p95_usage, p99_usage, max_usage,
+			potential_savings, confidence, reasoning, risk_level, created_at,
+			applied, applied_at, COALESCE(owner_kind, ''),
+			oom_observed, throttle_observed, initial_sizing_cost
+		FROM recommendations
+		WHERE namespace = ?
+			AND (? = '' OR UPPER(resource_type) = UPPER(?))
+			AND (? = '' OR UPPER(risk_level) = UPPER(?))
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, namespace, filter.ResourceType, filter.ResourceType, filter.RiskLevel, filter.RiskLevel,
+		filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying recommendation history: %w", err)
+	}
+	defer rows.Close()
+
+	var recommendations []Recommendation
+	for rows.Next() {
+		var rec Recommendation
+		var createdAt int64
+		var appliedAt sql.NullInt64
+
+		err := rows.Scan(
+			&rec.ID, &rec.Namespace, &rec.PodName, &rec.ContainerName, &rec.ResourceType,
+			&rec.CurrentRequest, &rec.CurrentLimit, &rec.RecommendedRequest, &rec.RecommendedLimit,
+			&rec.P50Usage, &rec.P95Usage, &rec.P99Usage, &rec.MaxUsage,
+			&rec.PotentialSavings, &rec.Confidence, &rec.Reasoning, &rec.RiskLevel, &createdAt,
+			&rec.Applied, &appliedAt, &rec.OwnerKind,
+			&rec.OOMObserved, &rec.ThrottleObserved, &rec.InitialSizingCost,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning recommendation history: %w", err)
+		}
+
+		rec.LastUpdated = time.UnixMilli(createdAt)
+		if appliedAt.Valid {
+			applied := time.UnixMilli(appliedAt.Int64)
+			rec.AppliedAt = &applied
+		}
+		recommendations = append(recommendations, rec)
+	}
+
+	return recommendations, total, rows.Err()
+}
+
+func (s *SQLite) MarkApplied(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE recommendations SET applied = 1, applied_at = ? WHERE id = ?
+	`, time.Now().UnixMilli(), id)
+	return err
+}
+
+func (s *SQLite) OpenRecommendationIDs(ctx context.Context, namespace string) (map[RecommendationKey]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, pod_name, container_name, resource_type
+		FROM recommendations
+		WHERE namespace = ? AND NOT applied
+	`, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("querying open recommendations: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[RecommendationKey]int64)
+	for rows.Next() {
+		var id int64
+		var key RecommendationKey
+		if err := rows.Scan(&id, &key.PodName, &key.ContainerName, &key.ResourceType); err != nil {
+			return nil, fmt.Errorf("scanning open recommendation: %w", err)
+		}
+		ids[key] = id
+	}
+	return ids, rows.Err()
+}
+
+func (s *SQLite) RecordAction(ctx context.Context, action RecommendationAction) error {
+	var recommendationID sql.NullInt64
+	if action.RecommendationID != 0 {
+		recommendationID = sql.NullInt64{Int64: action.RecommendationID, Valid: true}
+	}
+	var ownerKind, ownerName sql.NullString
+	if action.OwnerName != "" {
+		ownerKind = sql.NullString{String: action.OwnerKind, Valid: true}
+		ownerName = sql.NullString{String: action.OwnerName, Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO recommendation_actions
+		(namespace, pod_name, container_name, resource_type, action, applied_at,
+		 recommendation_id, owner_kind, owner_name, recommended_request, recommended_limit)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, action.Namespace, action.PodName, action.ContainerName, action.ResourceType, action.Action,
+		action.TakenAt.UnixMilli(), recommendationID, ownerKind, ownerName,
+		action.RecommendedRequest, action.RecommendedLimit)
+	return err
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotFound is returned for a record that doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// Report schedule deliveries
+const (
+	DeliveryEmail   = "email"
+	DeliveryWebhook = "webhook"
+)
+
+// ReportScheduleRepository stores the schedules reports are sent on
+type ReportScheduleRepository interface {
+	// CreateReportSchedule stores the schedule and sets its ID and timestamps
+	CreateReportSchedule(ctx context.Context, schedule *ReportSchedule) error
+
+	// ReportSchedules lists every schedule by ID
+	ReportSchedules(ctx context.Context) ([]ReportSchedule, error)
+
+	// ReportSchedule loads a schedule by ID, or returns ErrNotFound
+	ReportSchedule(ctx context.Context, id int64) (*ReportSchedule, error)
+
+	// UpdateReportSchedule replaces the schedule's settings and next run, or returns
+	// ErrNotFound. Its run history is kept.
+	UpdateReportSchedule(ctx context.Context, schedule *ReportSchedule) error
+
+	// DeleteReportSchedule removes a schedule, reporting whether it existed
+	DeleteReportSchedule(ctx context.Context, id int64) (bool, error)
+
+	// DueReportSchedules returns the enabled schedules whose next run is at or before now
+	DueReportSchedules(ctx context.Context, now time.Time) ([]ReportSchedule, error)
+
+	// ClaimReportSchedule moves a due schedule's next run from due to next. It reports
+	// false when the next run has already moved, e.g. because another server claimed
+	// the run, so each run is sent once.
+	ClaimReportSchedule(ctx context.Context, id int64, due, next time.Time) (bool, error)
+
+	// RecordReportScheduleRun records when the schedule last ran and why it failed, if
+	// it did
+	RecordReportScheduleRun(ctx context.Context, id int64, ranAt time.Time, runErr error) error
+}
+
+// ReportSchedule sends reports in a format to recipients on a cron schedule
+type ReportSchedule struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+
+	// Standard five-field cron expression, in UTC unless prefixed with CRON_TZ=<zone>
+	Cron string `json:"cron"`
+
+	// Namespaces each get a report; none sends one cluster-wide report
+	Namespaces []string `json:"namespaces"`
+	Format     string   `json:"format"`
+
+	// DeliveryEmail sends to Recipients; DeliveryWebhook posts to the configured webhook
+	Delivery   string   `json:"delivery"`
+	Recipients []string `json:"recipients"`
+
+	Enabled   bool       `json:"enabled"`
+	NextRunAt time.Time  `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// reportScheduleColumns are the columns scanReportSchedule reads, in order
+const reportScheduleColumns = `id, name, cron, namespaces, format, delivery, recipients, enabled,
+	next_run_at, last_run_at, COALESCE(last_error, ''), created_at, updated_at`
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanReportSchedule(row rowScanner) (*ReportSchedule, error) {
+	var schedule ReportSchedule
+	var lastRunAt sql.NullTime
+	err := row.Scan(&schedule.ID, &schedule.Name, &schedule.Cron, pq.Array(&schedule.Namespaces),
+		&schedule.Format, &schedule.Delivery, pq.Array(&schedule.Recipients), &schedule.Enabled,
+		&schedule.NextRunAt, &lastRunAt, &schedule.LastError, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+	return &schedule, nil
+}
+
+func (p *Postgres) CreateReportSchedule(ctx context.Context, schedule *ReportSchedule) error {
+	return p.db.QueryRowContext(ctx, `
+		INSERT INTO report_schedules
+		(name, cron, namespaces, format, delivery, recipients, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`, schedule.Name, schedule.Cron, pq.Array(schedule.Namespaces), schedule.Format, schedule.Delivery,
+		pq.Array(schedule.Recipients), schedule.Enabled, schedule.NextRunAt,
+	).Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+}
+
+func (p *Postgres) ReportSchedules(ctx context.Context) ([]ReportSchedule, error) {
+	return p.queryReportSchedules(ctx, `SELECT `+reportScheduleColumns+` FROM report_schedules ORDER BY id`)
+}
+
+func (p *Postgres) ReportSchedule(ctx context.Context, id int64) (*ReportSchedule, error) {
+	schedule, err := scanReportSchedule(p.db.QueryRowContext(ctx,
+		`SELECT `+reportScheduleColumns+` FROM report_schedules WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("report schedule %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting report schedule %d: %w", id, err)
+	}
+	return schedule, nil
+}
+
+func (p *Postgres) UpdateReportSchedule(ctx context.Context, schedule *ReportSchedule) error {
+	err := p.db.QueryRowContext(ctx, `
+		UPDATE report_schedules SET
+			name = $2, cron = $3, namespaces = $4, format = $5, delivery = $6, recipients = $7,
+			enabled = $8, next_run_at = $9, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, schedule.ID, schedule.Name, schedule.Cron, pq.Array(schedule.Namespaces), schedule.Format,
+		schedule.Delivery, pq.Array(schedule.Recipients), schedule.Enabled, schedule.NextRunAt,
+	).Scan(&schedule.CreatedAt, &schedule.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("report schedule %d: %w", schedule.ID, ErrNotFound)
+	}
+	return err
+}
+
+func (p *Postgres) DeleteReportSchedule(ctx context.Context, id int64) (bool, error) {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM report_schedules WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+func (p *Postgres) DueReportSchedules(ctx context.Context, now time.Time) ([]ReportSchedule, error) {
+	return p.queryReportSchedules(ctx, `
+		SELECT `+reportScheduleColumns+`
+		FROM report_schedules
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at
+	`, now)
+}
+
+func (p *Postgres) ClaimReportSchedule(ctx context.Context, id int64, due, next time.Time) (bool, error) {
+	result, err := p.db.ExecContext(ctx, `
+		UPDATE report_schedules SET next_run_at = $3 WHERE id = $1 AND next_run_at = $2
+	`, id, due, next)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	return claimed > 0, err
+}
+
+func (p *Postgres) RecordReportScheduleRun(ctx context.Context, id int64, ranAt time.Time, runErr error) error {
+	var lastError sql.NullString
+	if runErr != nil {
+		lastError = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE report_schedules SET last_run_at = $2, last_error = $3 WHERE id = $1
+	`, id, ranAt, lastError)
+	return err
+}
+
+func (p *Postgres) queryReportSchedules(ctx context.Context, query string, args ...interface{}) ([]ReportSchedule, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying report schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []ReportSchedule{}
+	for rows.Next() {
+		schedule, err := scanReportSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning report schedule: %w", err)
+		}
+		schedules = append(schedules, *schedule)
+	}
+	return schedules, rows.Err()
+}
@@ -0,0 +1,475 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema mirrors the Postgres tables the store uses. Timestamps are Unix
+// milliseconds, since SQLite has no timestamp type.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS pod_metrics (
+    cluster TEXT NOT NULL DEFAULT 'default',
+    namespace TEXT NOT NULL,
+    pod_name TEXT NOT NULL,
+    container_name TEXT NOT NULL,
+    cpu_millicores REAL NOT NULL,
+    memory_bytes REAL NOT NULL,
+    timestamp INTEGER NOT NULL,
+    PRIMARY KEY (cluster, namespace, pod_name, container_name, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pod_metrics_namespace ON pod_metrics(namespace, timestamp);
+
+CREATE TABLE IF NOT EXISTS resource_requests (
+    cluster TEXT NOT NULL DEFAULT 'default',
+    namespace TEXT NOT NULL,
+    pod_name TEXT NOT NULL,
+    container_name TEXT NOT NULL,
+    cpu_request REAL NOT NULL DEFAULT 0,
+    cpu_limit REAL NOT NULL DEFAULT 0,
+    memory_request REAL NOT NULL DEFAULT 0,
+    memory_limit REAL NOT NULL DEFAULT 0,
+    owner_kind TEXT,
+    owner_name TEXT,
+    timestamp INTEGER NOT NULL,
+    PRIMARY KEY (cluster, namespace, pod_name, container_name, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_requests_namespace ON resource_requests(namespace, timestamp);
+
+CREATE TABLE IF NOT EXISTS namespace_costs (
+    cluster TEXT NOT NULL DEFAULT 'default',
+    namespace TEXT NOT NULL,
+    compute_cost REAL NOT NULL DEFAULT 0,
+    storage_cost REAL NOT NULL DEFAULT 0,
+    network_cost REAL NOT NULL DEFAULT 0,
+    other_cost REAL NOT NULL DEFAULT 0,
+    reconciliation_factor REAL NOT NULL DEFAULT 1,
+    timestamp INTEGER NOT NULL,
+    PRIMARY KEY (cluster, namespace, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_namespace_costs_namespace ON namespace_costs(namespace, timestamp);
+
+CREATE TABLE IF NOT EXISTS recommendations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    namespace TEXT NOT NULL,
+    pod_name TEXT NOT NULL,
+    container_name TEXT NOT NULL,
+    resource_type TEXT NOT NULL,
+    current_request REAL,
+    current_limit REAL,
+    recommended_request REAL,
+    recommended_limit REAL,
+    p50_usage REAL,
+    p95_usage REAL,
+    p99_usage REAL,
+    max_usage REAL,
+    potential_savings REAL,
+    confidence REAL,
+    reasoning TEXT,
+    risk_level TEXT,
+    applied INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL,
+    applied_at INTEGER,
+    owner_kind TEXT,
+    oom_observed INTEGER NOT NULL DEFAULT 0,
+    throttle_observed INTEGER NOT NULL DEFAULT 0,
+    initial_sizing_cost REAL NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_recommendations_namespace ON recommendations(namespace, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recommendations_open
+    ON recommendations(namespace, pod_name, container_name, resource_type) WHERE NOT applied;
+
+CREATE TABLE IF NOT EXISTS recommendation_actions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    namespace TEXT NOT NULL,
+    pod_name TEXT NOT NULL,
+    container_name TEXT NOT NULL,
+    resource_type TEXT NOT NULL,
+    action TEXT NOT NULL,
+    applied_at INTEGER NOT NULL,
+    recommendation_id INTEGER,
+    owner_kind TEXT,
+    owner_name TEXT,
+    recommended_request REAL,
+    recommended_limit REAL
+);
+
+CREATE INDEX IF NOT EXISTS idx_recommendation_actions_namespace ON recommendation_actions(namespace, applied_at);
+`
+
+// SQLite stores usage in a single file, for running without a database server.
+// Percentiles are computed in Go, as SQLite has no PERCENTILE_CONT.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates the database file at path and creates its tables
+func OpenSQLite(ctx context.Context, path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating schema: %w", err)
+	}
+	if err := addResourcesCluster(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("upgrading schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// addResourcesCluster rebuilds a resource_requests table created before it had a
+// cluster column, since SQLite can't change a table's primary key. Existing rows are
+// kept under the default cluster.
+func addResourcesCluster(ctx context.Context, db *sql.DB) error {
+	var columns int
+	if err := db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('resource_requests') WHERE name = 'cluster'`).Scan(&columns); err != nil {
+		return err
+	}
+	if columns > 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`ALTER TABLE resource_requests RENAME TO resource_requests_old`,
+		`DROP INDEX idx_resource_requests_namespace`,
+		sqliteSchema,
+		`INSERT INTO resource_requests
+		 (namespace, pod_name, container_name, cpu_request, cpu_limit, memory_request, memory_limit,
+		  owner_kind, owner_name, timestamp)
+		 SELECT namespace, pod_name, container_name, cpu_request, cpu_limit, memory_request, memory_limit,
+		  owner_kind, owner_name, timestamp
+		 FROM resource_requests_old`,
+		`DROP TABLE resource_requests_old`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) WriteUsage(ctx context.Context, samples []UsageSample) error {
+	ctx, span := tracing.StartQuery(ctx, "write usage", attribute.Int("rows", len(samples)))
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO pod_metrics
+		(cluster, namespace, pod_name, container_name, cpu_millicores, memory_bytes, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp) DO NOTHING
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, sample := range samples {
+		if _, err := stmt.ExecContext(ctx, sample.Cluster, sample.Namespace, sample.PodName, sample.ContainerName,
+			sample.CPU, sample.Memory, sample.Timestamp.UnixMilli()); err != nil {
+			return fmt.Errorf("storing usage for %s/%s/%s: %w",
+				sample.Namespace, sample.PodName, sample.ContainerName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) WriteResources(ctx context.Context, resources []ContainerResources) error {
+	ctx, span := tracing.StartQuery(ctx, "write resources", attribute.Int("rows", len(resources)))
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO resource_requests
+		(cluster, namespace, pod_name, container_name, cpu_request, cpu_limit, memory_request, memory_limit,
+		 timestamp, owner_kind, owner_name)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cluster, namespace, pod_name, container_name, timestamp)
+		DO UPDATE SET
+			cpu_request = excluded.cpu_request,
+			cpu_limit = excluded.cpu_limit,
+			memory_request = excluded.memory_request,
+			memory_limit = excluded.memory_limit,
+			owner_kind = excluded.owner_kind,
+			owner_name = excluded.owner_name
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range resources {
+		if _, err := stmt.ExecContext(ctx, r.Cluster, r.Namespace, r.PodName, r.ContainerName,
+			r.CPURequest, r.CPULimit, r.MemoryRequest, r.MemoryLimit, r.Timestamp.UnixMilli(),
+			r.Owner.Kind, r.Owner.Name); err != nil {
+			return fmt.Errorf("storing resources for %s/%s/%s: %w", r.Namespace, r.PodName, r.ContainerName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) Usage(ctx context.Context, namespace, cluster string, since time.Time) ([]UsageSample, error) {
+	ctx, span := tracing.StartQuery(ctx, "load usage", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT cluster, pod_name, container_name, cpu_millicores, memory_bytes, timestamp
+		FROM pod_metrics
+		WHERE namespace = ? AND timestamp > ? AND (? = '' OR cluster = ?)
+		ORDER BY pod_name, container_name, timestamp
+	`, namespace, since.UnixMilli(), cluster, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying usage: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []UsageSample
+	for rows.Next() {
+		sample := UsageSample{Namespace: namespace}
+		var timestamp int64
+		if err := rows.Scan(&sample.Cluster, &sample.PodName, &sample.ContainerName,
+			&sample.CPU, &sample.Memory, &timestamp); err != nil {
+			return nil, fmt.Errorf("scanning usage: %w", err)
+		}
+		sample.Timestamp = time.UnixMilli(timestamp)
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// UsageStats reads the namespace's samples ordered by container and summarizes each
+// container's in turn, so only one container's samples are held at a time
+func (s *SQLite) UsageStats(ctx context.Context, namespace, cluster string, since time.Time, minSamples int) ([]ContainerStats, error) {
+	ctx, span := tracing.StartQuery(ctx, "load raw stats", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT pod_name, container_name, cpu_millicores, memory_bytes
+		FROM pod_metrics
+		WHERE namespace = ? AND timestamp > ? AND (? = '' OR cluster = ?)
+		ORDER BY pod_name, container_name
+	`, namespace, since.UnixMilli(), cluster, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []ContainerStats
+	var current ContainerStats
+	var cpu, memory []float64
+	flush := func() {
+		if len(cpu) >= minSamples && len(cpu) > 0 {
+			current.Samples = len(cpu)
+			current.CPU = Summarize(cpu)
+			current.Memory = Summarize(memory)
+			stats = append(stats, current)
+		}
+		cpu, memory = cpu[:0], memory[:0]
+	}
+
+	for rows.Next() {
+		var podName, containerName string
+		var cpuValue, memoryValue float64
+		if err := rows.Scan(&podName, &containerName, &cpuValue, &memoryValue); err != nil {
+			return nil, fmt.Errorf("scanning metrics: %w", err)
+		}
+		if podName != current.PodName || containerName != current.ContainerName {
+			flush()
+			current = ContainerStats{PodName: podName, ContainerName: containerName}
+		}
+		cpu = append(cpu, cpuValue)
+		memory = append(memory, memoryValue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return stats, nil
+}
+
+func (s *SQLite) CurrentResources(ctx context.Context, namespace, cluster, podName, containerName string) (*ContainerResources, error) {
+	ctx, span := tracing.StartQuery(ctx, "get current resources",
+		attribute.String("namespace", namespace), attribute.String("pod", podName), attribute.String("container", containerName))
+	defer span.End()
+
+	r := &ContainerResources{Namespace: namespace, PodName: podName, ContainerName: containerName}
+	var timestamp int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT cluster, cpu_request, cpu_limit, memory_request, memory_limit,
+			COALESCE(owner_kind, 'Pod'), COALESCE(owner_name, pod_name), timestamp
+		FROM resource_requests
+		WHERE namespace = ? AND pod_name = ? AND container_name = ? AND (? = '' OR cluster = ?)
+		ORDER BY timestamp DESC LIMIT 1
+	`, namespace, podName, containerName, cluster, cluster).Scan(&r.Cluster, &r.CPURequest, &r.CPULimit, &r.MemoryRequest, &r.MemoryLimit,
+		&r.Owner.Kind, &r.Owner.Name, &timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("getting current resources: %w", err)
+	}
+	r.Timestamp = time.UnixMilli(timestamp)
+	return r, nil
+}
+
+// Owners keeps each pod's newest row; SQLite has no DISTINCT ON
+func (s *SQLite) Owners(ctx context.Context, namespace, cluster string, since time.Time) (map[string]Owner, error) {
+	ctx, span := tracing.StartQuery(ctx, "load pod owners", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT pod_name, COALESCE(owner_kind, 'Pod'), COALESCE(owner_name, pod_name)
+		FROM resource_requests
+		WHERE namespace = ? AND timestamp > ? AND (? = '' OR cluster = ?)
+		ORDER BY pod_name, timestamp DESC
+	`, namespace, since.UnixMilli(), cluster, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("querying pod owners: %w", err)
+	}
+	defer rows.Close()
+
+	owners := make(map[string]Owner)
+	for rows.Next() {
+		var podName string
+		var owner Owner
+		if err := rows.Scan(&podName, &owner.Kind, &owner.Name); err != nil {
+			return nil, fmt.Errorf("scanning pod owners: %w", err)
+		}
+		if _, seen := owners[podName]; !seen {
+			owners[podName] = owner
+		}
+	}
+	return owners, rows.Err()
+}
+
+// ResourceUsage joins each container's usage to its newest resource_requests row;
+// SQLite has no LATERAL join
+func (s *SQLite) ResourceUsage(ctx context.Context, namespace, cluster string, since time.Time) ([]ContainerUsage, error) {
+	ctx, span := tracing.StartQuery(ctx, "load resource usage", attribute.String("namespace", namespace))
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			u.cluster, u.pod_name, u.container_name,
+			u.avg_cpu, u.max_cpu, u.avg_memory, u.max_memory,
+			COALESCE(rr.cpu_request, 0), COALESCE(rr.cpu_limit, 0),
+			COALESCE(rr.memory_request, 0), COALESCE(rr.memory_limit, 0)
+		FROM (
+			SELECT
+				cluster, pod_name, container_name,
+				AVG(cpu_millicores) as avg_cpu,
+				MAX(cpu_millicores) as max_cpu,
+				AVG(memory_bytes) as avg_memory,
+				MAX(memory_bytes) as max_memory
+			FROM pod_metrics
+			WHERE namespace = ? AND timestamp > ? AND (? = '' OR cluster = ?)
+			GROUP BY cluster, pod_name, container_name
+		) u
+		LEFT JOIN resource_requests rr
+			ON rr.cluster = u.cluster AND rr.namespace = ?
+			AND rr.pod_name = u.pod_name AND rr.container_name = u.container_name
+			AND rr.timestamp = (
+				SELECT MAX(timestamp) FROM resource_requests
+				WHERE cluster = u.cluster AND namespace = ?
+					AND pod_name = u.pod_name AND container_name = u.container_name
+			)
+		ORDER BY u.cluster, u.pod_name, u.container_name
+	`, namespace, since.UnixMilli(), cluster, cluster, namespace, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("querying resource usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []ContainerUsage
+	for rows.Next() {
+		var u ContainerUsage
+		if err := rows.Scan(&u.Cluster, &u.PodName, &u.ContainerName,
+			&u.AvgCPU, &u.MaxCPU, &u.AvgMemory, &u.MaxMemory,
+			&u.CPURequest, &u.CPULimit, &u.MemoryRequest, &u.MemoryLimit); err != nil {
+			return nil, fmt.Errorf("scanning resource usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+func (s *SQLite) NamespaceRequests(ctx context.Context, namespace string) (NamespaceRequests, error) {
+	var r NamespaceRequests
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(cpu_request), 0),
+			COALESCE(SUM(cpu_limit), 0),
+			COALESCE(SUM(memory_request), 0),
+			COALESCE(SUM(memory_limit), 0),
+			COUNT(*) FILTER (WHERE cpu_limit > 0),
+			COUNT(*) FILTER (WHERE memory_limit > 0)
+		FROM resource_requests
+		WHERE namespace = ?
+			AND timestamp = (SELECT MAX(timestamp) FROM resource_requests WHERE namespace = ?)
+	`, namespace, namespace).Scan(&r.Containers, &r.CPURequest, &r.CPULimit,
+		&r.MemoryRequest, &r.MemoryLimit, &r.CPULimited, &r.MemoryLimited)
+	if err != nil {
+		return r, fmt.Errorf("querying current requests: %w", err)
+	}
+	return r, nil
+}
+
+func (s *SQLite) LastUsageTime(ctx context.Context, cluster string) (*time.Time, error) {
+	return s.lastTime(ctx, "pod_metrics", cluster)
+}
+
+// lastTime returns the newest timestamp stored in the table for the cluster, or nil if
+// it has none
+func (s *SQLite) lastTime(ctx context.Context, table, cluster string) (*time.Time, error) {
+	var latest sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT MAX(timestamp) FROM %s WHERE cluster = ?`, table), cluster).Scan(&latest)
+	if err != nil || !latest.Valid {
+		return nil, err
+	}
+	t := time.UnixMilli(latest.Int64)
+	return &t, nil
+}
+
+// Repositories returns the SQLite store as the metrics, cost and recommendation
+// repositories. GPU metrics, budgets, outcomes and report schedules need Postgres.
+func (s *SQLite) Repositories() Repositories {
+	return Repositories{Metrics: s, Costs: s, Recommendations: s}
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestSQLite(t *testing.T) *SQLite {
+	t.Helper()
+	s, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "kost.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteCosts(t *testing.T) {
+	ctx := context.Background()
+	s := openTestSQLite(t)
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	write := func(timestamp time.Time, costs ...NamespaceCost) {
+		t.Helper()
+		if err := s.WriteCosts(ctx, costs, timestamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(day.Add(10*time.Hour),
+		NamespaceCost{Cluster: "prod", Namespace: "web", Compute: 99},
+		NamespaceCost{Cluster: "prod", Namespace: "batch", Compute: 1, Storage: 1})
+	// Collecting the same hour again replaces its costs
+	write(day.Add(10*time.Hour), NamespaceCost{Cluster: "prod", Namespace: "web", Compute: 4, Storage: 2, Network: 1, Other: 1})
+	write(day.Add(34*time.Hour), NamespaceCost{Cluster: "prod", Namespace: "web", Compute: 2})
+	write(day.Add(11*time.Hour), NamespaceCost{Cluster: "dev", Namespace: "web", Compute: 10})
+
+	// Reconciliation doubled the first hour's costs
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE namespace_costs SET reconciliation_factor = 2 WHERE cluster = 'prod' AND namespace = 'web' AND timestamp = ?
+	`, day.Add(10*time.Hour).UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+
+	daily, err := s.DailyCosts(ctx, "prod", "web", day, day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 2 {
+		t.Fatalf("got %d days, want 2: %+v", len(daily), daily)
+	}
+	if !daily[0].Day.Equal(day.Add(24*time.Hour)) || daily[0].Total != 2 {
+		t.Errorf("newest day = %v with total %v, want %v with 2", daily[0].Day, daily[0].Total, day.Add(24*time.Hour))
+	}
+	if !daily[1].Day.Equal(day) || daily[1].Total != 8 || daily[1].Estimated != 4 || daily[1].Network != 1 {
+		t.Errorf("first day = %+v, want total 8, estimated 4 and network 1 on %v", daily[1], day)
+	}
+
+	all, err := s.DailyCosts(ctx, "", "web", day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Total != 18 {
+		t.Errorf("costs across clusters = %+v, want one day totalling 18", all)
+	}
+
+	page, err := s.ClusterCosts(ctx, "", day, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 3 || page.Total != 22 || page.Estimated != 18 {
+		t.Errorf("totals = %d namespaces, %v, estimated %v; want 3, 22 and 18", page.Count, page.Total, page.Estimated)
+	}
+	if len(page.Namespaces) != 1 || page.Namespaces[0].Cluster != "prod" || page.Namespaces[0].Total != 10 {
+		t.Errorf("second page = %+v, want prod/web totalling 10", page.Namespaces)
+	}
+
+	breakdown, err := s.CostBreakdown(ctx, "prod", "web", day, day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if breakdown != (CostBreakdown{Compute: 6, Storage: 2, Network: 1, Other: 1}) {
+		t.Errorf("breakdown = %+v", breakdown)
+	}
+
+	current, err := s.CurrentCost(ctx, "web", day.Add(11*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 12 {
+		t.Errorf("current cost = %v, want 12", current)
+	}
+
+	namespaces, err := s.CostNamespaces(ctx, day.Add(10*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaces) != 1 || namespaces[0] != "web" {
+		t.Errorf("namespaces with recent costs = %v, want [web]", namespaces)
+	}
+
+	latest, err := s.LastCostTime(ctx, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || !latest.Equal(day.Add(34*time.Hour)) {
+		t.Errorf("last cost time = %v, want %v", latest, day.Add(34*time.Hour))
+	}
+	if latest, err := s.LastCostTime(ctx, "staging"); err != nil || latest != nil {
+		t.Errorf("last cost time of a cluster without costs = %v, %v; want nil", latest, err)
+	}
+}
+
+func TestSQLiteRecommendations(t *testing.T) {
+	ctx := context.Background()
+	s := openTestSQLite(t)
+
+	created := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	rec := &Recommendation{
+		Namespace: "web", PodName: "api-0", ContainerName: "app", ResourceType: "CPU",
+		CurrentRequest: 1000, RecommendedRequest: 400, RiskLevel: "low", Reasoning: "overprovisioned",
+		LastUpdated: created, OwnerKind: "StatefulSet", OOMObserved: true,
+	}
+	if err := s.SaveRecommendation(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	first := rec.ID
+
+	// An unapplied recommendation is updated in place
+	rec.RecommendedRequest = 500
+	if err := s.SaveRecommendation(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != first {
+		t.Fatalf("saving again got ID %d, want %d", rec.ID, first)
+	}
+
+	loaded, err := s.Recommendation(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.RecommendedRequest != 500 || !loaded.OOMObserved || loaded.ThrottleObserved ||
+		loaded.OwnerKind != "StatefulSet" || !loaded.LastUpdated.Equal(created) {
+		t.Errorf("loaded %+v", loaded)
+	}
+
+	open, err := s.OpenRecommendationIDs(ctx, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open[rec.Key()] != first || len(open) != 1 {
+		t.Errorf("open recommendations = %v, want %v: %d", open, rec.Key(), first)
+	}
+
+	// Once applied it is kept as history and the next save starts a new one
+	if err := s.MarkApplied(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	rec.LastUpdated = created.Add(time.Hour)
+	if err := s.SaveRecommendation(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID == first {
+		t.Fatal("saving after applying updated the applied recommendation")
+	}
+
+	history, total, err := s.RecommendationHistory(ctx, "web", RecommendationFilter{ResourceType: "cpu", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(history) != 2 {
+		t.Fatalf("history = %d of %d, want 2 of 2", len(history), total)
+	}
+	if history[0].ID != rec.ID || history[0].Applied || history[0].AppliedAt != nil {
+		t.Errorf("newest = %+v, want the open recommendation", history[0])
+	}
+	if history[1].ID != first || !history[1].Applied || history[1].AppliedAt == nil {
+		t.Errorf("oldest = %+v, want the applied recommendation", history[1])
+	}
+
+	if _, total, err := s.RecommendationHistory(ctx, "web", RecommendationFilter{RiskLevel: "high", Limit: 10}); err != nil || total != 0 {
+		t.Errorf("high risk history = %d, %v; want none", total, err)
+	}
+
+	err = s.RecordAction(ctx, RecommendationAction{
+		RecommendationID: first, Namespace: "web", PodName: "api-0", ContainerName: "app",
+		ResourceType: "CPU", Action: "apply", TakenAt: created, OwnerKind: "StatefulSet", OwnerName: "api",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
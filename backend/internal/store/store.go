@@ -0,0 +1,220 @@
+// Package store keeps the SQL behind typed repositories: MetricsRepository for the
+// per-container usage rightsizing is computed from, CostRepository for namespace
+// costs and RecommendationRepository for stored recommendations, along with GPU,
+// budget, outcome and report schedule repositories. Postgres (TimescaleDB) implements
+// all of them. SQLite implements the metrics, cost and recommendation repositories,
+// computing in Go what Postgres computes in SQL, for the kost CLI and for running the
+// server without a database server; features built on the other repositories need
+// Postgres.
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Store is a MetricsRepository over a database connection it owns
+type Store interface {
+	MetricsRepository
+
+	// Repositories returns the repositories the database implements
+	Repositories() Repositories
+
+	Close() error
+}
+
+// MetricsRepository reads and writes the usage data the rightsizing analyzer needs
+type MetricsRepository interface {
+	// WriteUsage stores usage samples. A sample already stored for the same
+	// container and timestamp is kept.
+	WriteUsage(ctx context.Context, samples []UsageSample) error
+
+	// WriteResources stores containers' requests, limits and owners as of a timestamp
+	WriteResources(ctx context.Context, resources []ContainerResources) error
+
+	// Usage returns the namespace's usage samples since the given time, ordered by pod,
+	// container and timestamp. An empty cluster covers all clusters.
+	Usage(ctx context.Context, namespace, cluster string, since time.Time) ([]UsageSample, error)
+
+	// UsageStats returns usage statistics for each container in the namespace with at
+	// least minSamples samples since the given time. An empty cluster covers all
+	// clusters.
+	UsageStats(ctx context.Context, namespace, cluster string, since time.Time, minSamples int) ([]ContainerStats, error)
+
+	// CurrentResources returns the container's most recently stored requests and
+	// limits. An empty cluster takes the latest from any cluster.
+	CurrentResources(ctx context.Context, namespace, cluster, podName, containerName string) (*ContainerResources, error)
+
+	// Owners returns the latest recorded owner of each pod in the namespace seen since
+	// the given time. Pods recorded without an owner are their own owner. An empty
+	// cluster covers all clusters.
+	Owners(ctx context.Context, namespace, cluster string, since time.Time) (map[string]Owner, error)
+
+	// ResourceUsage returns each container's average and peak usage since the given
+	// time, with its latest requests and limits, ordered by cluster, pod and container.
+	// An empty cluster covers all clusters.
+	ResourceUsage(ctx context.Context, namespace, cluster string, since time.Time) ([]ContainerUsage, error)
+
+	// NamespaceRequests totals the requests and limits in the namespace's latest
+	// resource snapshot
+	NamespaceRequests(ctx context.Context, namespace string) (NamespaceRequests, error)
+
+	// LastUsageTime returns when the cluster's usage was last stored, or nil if it
+	// never was
+	LastUsageTime(ctx context.Context, cluster string) (*time.Time, error)
+}
+
+// Repositories are the repositories over one database. Those the database doesn't
+// implement are nil.
+type Repositories struct {
+	Metrics         MetricsRepository
+	Costs           CostRepository
+	Recommendations RecommendationRepository
+	GPU             GPURepository
+	Budgets         BudgetRepository
+	Outcomes        OutcomeRepository
+	ReportSchedules ReportScheduleRepository
+}
+
+// UsageSample is a container's CPU (millicores) and memory (bytes) usage at a time
+type UsageSample struct {
+	Cluster       string
+	Namespace     string
+	PodName       string
+	ContainerName string
+	CPU           float64
+	Memory        float64
+	Timestamp     time.Time
+}
+
+// ContainerResources is a container's requests and limits, CPU in millicores and
+// memory in bytes, and the workload owning its pod
+type ContainerResources struct {
+	Cluster       string
+	Namespace     string
+	PodName       string
+	ContainerName string
+	CPURequest    float64
+	CPULimit      float64
+	MemoryRequest float64
+	MemoryLimit   float64
+	Owner         Owner
+	Timestamp     time.Time
+}
+
+// ContainerUsage is a container's average and peak usage over a period, CPU in
+// millicores and memory in bytes, with its latest requests and limits. Requests and
+// limits are zero for a container without stored resources.
+type ContainerUsage struct {
+	Cluster       string
+	PodName       string
+	ContainerName string
+	AvgCPU        float64
+	MaxCPU        float64
+	AvgMemory     float64
+	MaxMemory     float64
+	CPURequest    float64
+	CPULimit      float64
+	MemoryRequest float64
+	MemoryLimit   float64
+}
+
+// NamespaceRequests totals a namespace's container requests and limits
+type NamespaceRequests struct {
+	Containers                 int
+	CPURequest, CPULimit       float64
+	MemoryRequest, MemoryLimit float64
+	CPULimited, MemoryLimited  int // Containers that set the limit
+}
+
+// Owner is the workload controlling a pod, e.g. a Deployment
+type Owner struct {
+	Kind string
+	Name string
+}
+
+// Stats summarizes a container's usage of one resource
+type Stats struct {
+	P50    float64
+	P95    float64
+	P99    float64
+	Max    float64
+	Avg    float64
+	StdDev float64
+}
+
+// ContainerStats is a container's CPU and memory usage statistics
+type ContainerStats struct {
+	PodName       string
+	ContainerName string
+	Samples       int
+	CPU           Stats
+	Memory        Stats
+}
+
+// Open connects to the database with the named driver. SQLite's schema is created
+// if needed; Postgres' comes from the migrations.
+func Open(ctx context.Context, driver, dsn string) (Store, error) {
+	switch driver {
+	case DriverPostgres, "":
+		return OpenPostgres(ctx, dsn)
+	case DriverSQLite:
+		return OpenSQLite(ctx, dsn)
+	default:
+		return nil, fmt.Errorf("database driver must be %s or %s, got %q", DriverPostgres, DriverSQLite, driver)
+	}
+}
+
+// Summarize computes the statistics Postgres returns for usage samples: continuous
+// percentiles as PERCENTILE_CONT interpolates them, and the sample standard deviation
+// as STDDEV does
+func Summarize(samples []float64) Stats {
+	if len(samples) == 0 {
+		return Stats{}
+	}
+
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	avg := sum / float64(len(sorted))
+
+	var stddev float64
+	if len(sorted) > 1 {
+		var squares float64
+		for _, v := range sorted {
+			squares += (v - avg) * (v - avg)
+		}
+		stddev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+
+	return Stats{
+		P50:    percentileCont(sorted, 0.50),
+		P95:    percentileCont(sorted, 0.95),
+		P99:    percentileCont(sorted, 0.99),
+		Max:    sorted[len(sorted)-1],
+		Avg:    avg,
+		StdDev: stddev,
+	}
+}
+
+// percentileCont interpolates the p-th percentile of sorted values linearly between
+// the closest ranks
+func percentileCont(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
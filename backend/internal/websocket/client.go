@@ -2,8 +2,8 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 
@@ -17,6 +17,14 @@ type Client struct {
 	send                  chan []byte
 	subscribedNamespaces  map[string]bool
 	mutex                 sync.RWMutex
+
+	// Set by the hub, under its lock, when it closes send
+	closed bool
+
+	// Namespaces the client may subscribe to; nil allows all
+	allowedNamespaces map[string]bool
+
+	config *ClientConfig
 }
 
 // Message represents a WebSocket message
@@ -27,23 +35,80 @@ type Message struct {
 	Timestamp time.Time   `json:"timestamp"`
 }
 
-// Upgrader for WebSocket connections
-var upgrader = websocket.Upgrader{
+// ClientConfig holds per-connection limits and keepalive timings.
+//
+// The server pings every PingPeriod and each pong extends the read deadline by
+// ReadTimeout, so PingPeriod must be shorter than ReadTimeout, with enough margin for
+// the pong's round trip; otherwise healthy idle clients time out between pings.
+type ClientConfig struct {
+	// Largest message accepted from the client, in bytes. Subscribe messages grow with
+	// the namespace name, and oversized messages close the connection.
+	ReadLimit int64
+
+	// How long to wait for any message or pong before dropping the client
+	ReadTimeout time.Duration
+
+	// How often to ping the client to keep the connection and read deadline alive
+	PingPeriod time.Duration
+
+	// How long a single write, including pings, may take
+	WriteTimeout time.Duration
+}
+
+// DefaultClientConfig returns limits and timings suited to the dashboard
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		ReadLimit:    4096,
+		ReadTimeout:  60 * time.Second,
+		PingPeriod:   54 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+// Validate checks that the limits are positive and that pings arrive within the read
+// deadline
+func (c *ClientConfig) Validate() error {
+	if c.ReadLimit <= 0 {
+		return fmt.Errorf("read limit must be positive, got %d", c.ReadLimit)
+	}
+	if c.ReadTimeout <= 0 || c.PingPeriod <= 0 || c.WriteTimeout <= 0 {
+		return fmt.Errorf("read timeout, ping period and write timeout must be positive")
+	}
+	if c.PingPeriod >= c.ReadTimeout {
+		return fmt.Errorf("ping period (%v) must be less than the read timeout (%v)", c.PingPeriod, c.ReadTimeout)
+	}
+	return nil
+}
+
+// Upgrader for WebSocket connections. Without a CheckOrigin it only accepts same-origin
+// upgrades; the API handler sets one from the CORS configuration.
+var Upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
 }
 
-// NewClient creates a new WebSocket client
-func NewClient(hub *Hub, conn *websocket.Conn) *Client {
-	return &Client{
+// NewClient creates a new WebSocket client that may subscribe to the allowed
+// namespaces, or to any namespace when allowedNamespaces is nil. A nil config uses
+// DefaultClientConfig.
+func NewClient(hub *Hub, conn *websocket.Conn, allowedNamespaces []string, config *ClientConfig) *Client {
+	if config == nil {
+		config = DefaultClientConfig()
+	}
+
+	client := &Client{
 		hub:                  hub,
 		conn:                 conn,
 		send:                 make(chan []byte, 256),
 		subscribedNamespaces: make(map[string]bool),
+		config:               config,
 	}
+	if allowedNamespaces != nil {
+		client.allowedNamespaces = make(map[string]bool, len(allowedNamespaces))
+		for _, namespace := range allowedNamespaces {
+			client.allowedNamespaces[namespace] = true
+		}
+	}
+	return client
 }
 
 // ReadPump handles reading messages from the WebSocket
@@ -53,10 +118,10 @@ func (c *Client) ReadPump() {
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(512)
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.conn.SetReadLimit(c.config.ReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout))
 		return nil
 	})
 
@@ -75,7 +140,7 @@ func (c *Client) ReadPump() {
 
 // WritePump handles writing messages to the WebSocket
 func (c *Client) WritePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	ticker := time.NewTicker(c.config.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -84,7 +149,7 @@ func (c *Client) WritePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
 			if !ok {
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
@@ -100,7 +165,7 @@ func (c *Client) WritePump() {
 				return
 			}
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -126,50 +191,65 @@ func (c *Client) handleMessage(message []byte) {
 	}
 }
 
-// subscribeToNamespace subscribes the client to a namespace
+// subscribeToNamespace subscribes the client to a namespace it is authorized for
 func (c *Client) subscribeToNamespace(namespace string) {
+	if c.allowedNamespaces != nil && !c.allowedNamespaces[namespace] {
+		c.reply(Message{
+			Type:      "error",
+			Namespace: namespace,
+			Data:      "Not authorized to subscribe to " + namespace,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
 	c.subscribedNamespaces[namespace] = true
+	c.mutex.Unlock()
 
-	response := Message{
+	c.reply(Message{
 		Type:      "subscribed",
 		Namespace: namespace,
 		Data:      "Successfully subscribed to " + namespace,
 		Timestamp: time.Now(),
-	}
-
-	data, _ := json.Marshal(response)
-	c.send <- data
+	})
 }
 
 // unsubscribeFromNamespace unsubscribes the client from a namespace
 func (c *Client) unsubscribeFromNamespace(namespace string) {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
 	delete(c.subscribedNamespaces, namespace)
+	c.mutex.Unlock()
 
-	response := Message{
+	c.reply(Message{
 		Type:      "unsubscribed",
 		Namespace: namespace,
 		Data:      "Successfully unsubscribed from " + namespace,
 		Timestamp: time.Now(),
-	}
-
-	data, _ := json.Marshal(response)
-	c.send <- data
+	})
 }
 
 // sendPong sends a pong response
 func (c *Client) sendPong() {
-	response := Message{
+	c.reply(Message{
 		Type:      "pong",
 		Data:      "pong",
 		Timestamp: time.Now(),
-	}
+	})
+}
 
-	data, _ := json.Marshal(response)
-	c.send <- data
+// reply queues a response to the client's own message. It never blocks, since the
+// client's mutex and the hub's lock must stay free for broadcasts; a reply to a client
+// that isn't reading fast enough, or has been removed, is dropped.
+func (c *Client) reply(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+	if !c.hub.queue(c, data) {
+		log.Printf("Dropped %s reply to a WebSocket client that isn't reading", msg.Type)
+	}
 }
 
 // IsSubscribedTo checks if the client is subscribed to a namespace
@@ -0,0 +1,175 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestClient returns the server side of a real connection, registered with the hub
+// but without its pumps running, so the test controls what drains its send buffer
+func newTestClient(t *testing.T, hub *Hub) *Client {
+	t.Helper()
+
+	clients := make(chan *Client, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrading: %v", err)
+			return
+		}
+		clients <- NewClient(hub, conn, nil, nil)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client := <-clients
+	hub.Register(client)
+	return client
+}
+
+func message(t *testing.T, msgType, namespace string) []byte {
+	t.Helper()
+	data, err := json.Marshal(Message{Type: msgType, Namespace: namespace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// within fails the test if fn doesn't return within the timeout
+func within(t *testing.T, timeout time.Duration, what string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("%s blocked for more than %v", what, timeout)
+	}
+}
+
+func TestRepliesDontBlockOnFullSendBuffer(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	client := newTestClient(t, hub)
+
+	for i := 0; i < cap(client.send); i++ {
+		client.send <- []byte("backlog")
+	}
+
+	within(t, time.Second, "subscribe with a full send buffer", func() {
+		client.handleMessage(message(t, "subscribe", "team-a"))
+		client.handleMessage(message(t, "ping", ""))
+		client.handleMessage(message(t, "unsubscribe", "team-a"))
+	})
+
+	// A client the hub is waiting on must not hold its subscriptions locked
+	client.handleMessage(message(t, "subscribe", "team-a"))
+	within(t, time.Second, "checking the subscription of a client with a full send buffer", func() {
+		if !client.IsSubscribedTo("team-a") {
+			t.Error("client isn't subscribed to team-a")
+		}
+	})
+}
+
+func TestRepliesAfterRemovalDontPanic(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	client := newTestClient(t, hub)
+
+	hub.unregister <- client
+	// Wait for the hub to close the client's send channel
+	for range client.send {
+	}
+
+	for _, msgType := range []string{"subscribe", "unsubscribe", "ping"} {
+		client.handleMessage(message(t, msgType, "team-a"))
+	}
+}
+
+// TestConcurrentSubscribeBroadcastUnregister is meant for go test -race: clients
+// subscribe, unsubscribe and ping while the hub broadcasts to them and removes them
+func TestConcurrentSubscribeBroadcastUnregister(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	const clientCount, rounds = 8, 50
+	clients := make([]*Client, clientCount)
+	for i := range clients {
+		clients[i] = newTestClient(t, hub)
+	}
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		namespace := fmt.Sprintf("team-%d", i%3)
+
+		// Stand in for WritePump, draining until the hub closes the channel. Every
+		// other client drains slowly enough to fall behind broadcasts.
+		slow := i%2 == 1
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			for range client.send {
+				if slow {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}(client)
+
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				client.handleMessage(message(t, "subscribe", namespace))
+				client.handleMessage(message(t, "ping", ""))
+				client.handleMessage(message(t, "unsubscribe", namespace))
+			}
+		}(client)
+	}
+
+	var broadcasts sync.WaitGroup
+	broadcasts.Add(2)
+	go func() {
+		defer broadcasts.Done()
+		for r := 0; r < rounds; r++ {
+			hub.Broadcast(Message{Type: "cluster_update", Data: r})
+		}
+	}()
+	go func() {
+		defer broadcasts.Done()
+		for r := 0; r < rounds; r++ {
+			hub.BroadcastToNamespace(fmt.Sprintf("team-%d", r%3), Message{Type: "cost_update", Data: r})
+		}
+	}()
+
+	// Remove clients while the broadcasts are in flight
+	for _, client := range clients[:clientCount/2] {
+		hub.unregister <- client
+	}
+
+	within(t, 30*time.Second, "broadcasting", broadcasts.Wait)
+	for _, client := range clients[clientCount/2:] {
+		hub.unregister <- client
+	}
+	within(t, 30*time.Second, "draining clients", wg.Wait)
+
+	if count := hub.GetClientCount(); count != 0 {
+		t.Errorf("%d clients still registered", count)
+	}
+}
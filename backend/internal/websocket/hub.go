@@ -6,9 +6,24 @@ import (
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// slowClientWait bounds how long a broadcast waits, in total, for clients whose send
+// buffer is full to drain it before they are disconnected
+const slowClientWait = 250 * time.Millisecond
+
+var droppedClients = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "websocket_dropped_clients_total",
+		Help: "Total number of WebSocket clients disconnected because they couldn't keep up with broadcasts",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(droppedClients)
+}
+
 // Hub manages WebSocket connections
 type Hub struct {
 	clients    map[*Client]bool
@@ -39,29 +54,22 @@ func (h *Hub) Run() {
 			log.Printf("Client connected: %s", client.conn.RemoteAddr())
 
 		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
-			h.mutex.Unlock()
-			log.Printf("Client disconnected: %s", client.conn.RemoteAddr())
+			h.removeClient(client)
 
 		case message := <-h.broadcast:
-			h.mutex.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+			// Run is the unregister receiver, so it removes dropped clients directly
+			for _, client := range h.deliver(message, nil) {
+				h.removeClient(client)
 			}
-			h.mutex.RUnlock()
 		}
 	}
 }
 
+// Register adds a client to the hub
+func (h *Hub) Register(client *Client) {
+	h.register <- client
+}
+
 // Broadcast sends a message to all connected clients
 func (h *Hub) Broadcast(message interface{}) {
 	data, err := json.Marshal(message)
@@ -74,24 +82,101 @@ func (h *Hub) Broadcast(message interface{}) {
 
 // BroadcastToNamespace sends a message to clients subscribed to a specific namespace
 func (h *Hub) BroadcastToNamespace(namespace string, message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	dropped := h.deliver(data, func(client *Client) bool {
+		return client.IsSubscribedTo(namespace)
+	})
+	for _, client := range dropped {
+		h.unregister <- client
+	}
+}
+
+// deliver queues the message for every client accepted by filter, or every client if
+// filter is nil. Clients with a full send buffer get up to slowClientWait in total to
+// make room; those that still can't take the message are returned to be disconnected.
+// The read lock is held throughout, so no client's send channel is closed mid-send.
+func (h *Hub) deliver(message []byte, filter func(*Client) bool) []*Client {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
+	var slow []*Client
 	for client := range h.clients {
-		if client.subscribedNamespaces[namespace] {
-			data, err := json.Marshal(message)
-			if err != nil {
-				log.Printf("Error marshaling message: %v", err)
-				continue
-			}
+		if filter != nil && !filter(client) {
+			continue
+		}
+		select {
+		case client.send <- message:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	if len(slow) == 0 {
+		return nil
+	}
+
+	var dropped []*Client
+	deadline := time.NewTimer(slowClientWait)
+	defer deadline.Stop()
+	expired := false
+	for _, client := range slow {
+		if !expired {
 			select {
-			case client.send <- data:
-			default:
-				close(client.send)
-				delete(h.clients, client)
+			case client.send <- message:
+				continue
+			case <-deadline.C:
+				expired = true
 			}
 		}
+		// Past the deadline, only clients that have since made room get the message
+		select {
+		case client.send <- message:
+		default:
+			dropped = append(dropped, client)
+		}
+	}
+
+	if len(dropped) > 0 {
+		droppedClients.Add(float64(len(dropped)))
+		log.Printf("Disconnecting %d WebSocket clients that couldn't keep up", len(dropped))
+	}
+	return dropped
+}
+
+// queue sends the message to one client without waiting, reporting whether it was
+// queued. The read lock keeps removeClient from closing the send channel mid-send.
+func (h *Hub) queue(client *Client, message []byte) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if client.closed {
+		return false
+	}
+	select {
+	case client.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// removeClient removes a client from the hub and closes its send channel, which makes
+// WritePump send a close frame and close the connection, in turn ending ReadPump.
+// Clients can be removed both as slow and by their own ReadPump, so removing a client
+// that is already gone does nothing.
+func (h *Hub) removeClient(client *Client) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.clients[client]; !ok {
+		return
 	}
+	delete(h.clients, client)
+	client.closed = true
+	close(client.send)
+	log.Printf("Client disconnected: %s", client.conn.RemoteAddr())
 }
 
 // GetClientCount returns the number of connected clients
@@ -1,20 +1,64 @@
 package cache
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"sync/atomic"
 	"time"
 
+	"k8s-cost-optimizer/pkg/resilience"
+	"k8s-cost-optimizer/pkg/tracing"
+
 	"github.com/redis/go-redis/v9"
 	"github.com/allegro/bigcache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+)
+
+// compressedPrefix marks gzip-compressed values. JSON can't start with a NUL byte, so
+// entries written before compression was enabled are still read as-is.
+var compressedPrefix = []byte{0x00, 'g', 'z'}
+
+var (
+	cacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Total number of cache lookups by result (l2_hit, l1_hit, miss)",
+		},
+		[]string{"result"},
+	)
+
+	cacheHitRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_hit_ratio",
+			Help: "Cache hit ratio since startup by level (l1, l2, overall)",
+		},
+		[]string{"level"},
+	)
 )
 
+func init() {
+	prometheus.MustRegister(cacheLookups, cacheHitRatio)
+}
+
 // CacheManager implements multi-level caching
 type CacheManager struct {
 	l1Cache *redis.Client  // Redis for hot data
 	l2Cache *bigcache.BigCache  // In-memory for very hot data
 	config  *CacheConfig
+	loads   singleflight.Group // Coalesces concurrent GetOrLoad misses per key
+	breaker *resilience.CircuitBreaker // Skips Redis while it keeps failing
+
+	// Lookup outcomes since startup
+	l2Hits atomic.Int64
+	l1Hits atomic.Int64
+	misses atomic.Int64
 }
 
 // CacheConfig holds cache configuration
@@ -23,6 +67,15 @@ type CacheConfig struct {
 	L2TTL     time.Duration
 	MaxSize   int
 	L2MaxSize int
+
+	// Gzip values of at least CompressionThreshold bytes before storing them
+	Compression          bool
+	CompressionThreshold int
+
+	// After RedisBreakerThreshold consecutive Redis failures, Redis is skipped for
+	// RedisBreakerTimeout and the cache only uses memory
+	RedisBreakerThreshold int
+	RedisBreakerTimeout   time.Duration
 }
 
 // DefaultCacheConfig returns sensible default cache configuration
@@ -32,6 +85,11 @@ func DefaultCacheConfig() *CacheConfig {
 		L2TTL:     5 * time.Minute,
 		MaxSize:   1000,
 		L2MaxSize: 100 * 1024 * 1024, // 100MB
+
+		CompressionThreshold: 1024,
+
+		RedisBreakerThreshold: 5,
+		RedisBreakerTimeout:   30 * time.Second,
 	}
 }
 
@@ -50,24 +108,172 @@ func NewCacheManager(redisClient *redis.Client, config *CacheConfig) (*CacheMana
 		l1Cache: redisClient,
 		l2Cache: l2Cache,
 		config:  config,
+		breaker: resilience.NewCircuitBreaker(config.RedisBreakerThreshold, config.RedisBreakerTimeout),
 	}, nil
 }
 
+// l1 runs a Redis call through the breaker. A missing key is returned as redis.Nil but
+// doesn't count as a failure.
+func (cm *CacheManager) l1(ctx context.Context, call func() error) error {
+	var result error
+	err := cm.breaker.Execute(ctx, func() error {
+		result = call()
+		if errors.Is(result, redis.Nil) {
+			return nil
+		}
+		return result
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// RedisState returns the state of the breaker in front of Redis: "closed", or "open"
+// or "half_open" while Redis is failing and lookups fall through to the loader
+func (cm *CacheManager) RedisState() string {
+	return cm.breaker.StateName()
+}
+
+// Redis runs a call against the cache's Redis client through the same breaker, so
+// other users of Redis, like rate limiting, fail fast instead of waiting out the
+// client timeout while it is down. A missing key is returned as redis.Nil.
+func (cm *CacheManager) Redis(ctx context.Context, call func(client *redis.Client) error) error {
+	return cm.l1(ctx, func() error {
+		return call(cm.l1Cache)
+	})
+}
+
+// Lookup outcomes, as recorded in cache_lookups_total
+const (
+	lookupL2Hit = "l2_hit"
+	lookupL1Hit = "l1_hit"
+	lookupMiss  = "miss"
+)
+
 // Get retrieves a value from the cache
 func (cm *CacheManager) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "cache get", attribute.String("cache.key", key))
+	defer span.End()
+
+	data, result := cm.lookup(ctx, key)
+	cm.recordLookup(result)
+	span.SetAttributes(attribute.String("cache.result", result))
+	if result == lookupMiss {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	return decompress(data)
+}
+
+// lookup reads the stored value of key from memory, then Redis, without recording the
+// outcome. An unavailable Redis is treated as a miss.
+func (cm *CacheManager) lookup(ctx context.Context, key string) ([]byte, string) {
 	// L2: In-memory cache (fastest)
 	if data, err := cm.l2Cache.Get(key); err == nil {
-		return data, nil
+		return data, lookupL2Hit
 	}
 
 	// L1: Redis cache
-	if data, err := cm.l1Cache.Get(ctx, key).Result(); err == nil {
+	var data string
+	err := cm.l1(ctx, func() error {
+		var err error
+		data, err = cm.l1Cache.Get(ctx, key).Result()
+		return err
+	})
+	if err == nil {
 		// Store in L2 cache for future fast access
 		cm.l2Cache.Set(key, []byte(data))
-		return []byte(data), nil
+		return []byte(data), lookupL1Hit
 	}
 
-	return nil, fmt.Errorf("key not found: %s", key)
+	return nil, lookupMiss
+}
+
+// recordLookup counts a lookup outcome and refreshes the hit ratio gauges
+func (cm *CacheManager) recordLookup(result string) {
+	switch result {
+	case lookupL2Hit:
+		cm.l2Hits.Add(1)
+	case lookupL1Hit:
+		cm.l1Hits.Add(1)
+	default:
+		cm.misses.Add(1)
+	}
+	cacheLookups.WithLabelValues(result).Inc()
+
+	ratios := cm.hitRatios()
+	cacheHitRatio.WithLabelValues("l1").Set(ratios["l1_hit_ratio"])
+	cacheHitRatio.WithLabelValues("l2").Set(ratios["l2_hit_ratio"])
+	cacheHitRatio.WithLabelValues("overall").Set(ratios["hit_ratio"])
+}
+
+// hitRatios returns the L2 hit ratio over all lookups, the L1 hit ratio over lookups
+// that missed L2 and reached Redis, and the overall hit ratio
+func (cm *CacheManager) hitRatios() map[string]float64 {
+	l2Hits := float64(cm.l2Hits.Load())
+	l1Hits := float64(cm.l1Hits.Load())
+	misses := float64(cm.misses.Load())
+
+	ratios := map[string]float64{"l1_hit_ratio": 0, "l2_hit_ratio": 0, "hit_ratio": 0}
+	if total := l2Hits + l1Hits + misses; total > 0 {
+		ratios["l2_hit_ratio"] = l2Hits / total
+		ratios["hit_ratio"] = (l2Hits + l1Hits) / total
+	}
+	if l1Lookups := l1Hits + misses; l1Lookups > 0 {
+		ratios["l1_hit_ratio"] = l1Hits / l1Lookups
+	}
+	return ratios
+}
+
+// GetOrLoad returns the cached value for key, or runs loader and caches its result.
+// Concurrent misses for the same key share a single loader call, so an expiring hot
+// key doesn't send every waiting request to the database. Loader errors are returned
+// to all waiters and not cached. Caching the loaded value is best effort.
+//
+// The loader runs on behalf of every waiter, so it shouldn't depend on a context that
+// is cancelled when only the first caller goes away.
+func (cm *CacheManager) GetOrLoad(ctx context.Context, key string, loader func() ([]byte, error)) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "cache get or load", attribute.String("cache.key", key))
+	defer span.End()
+
+	// Each call records one lookup: its first, or if that missed, the outcome of the
+	// flight that served it
+	data, result := cm.lookup(ctx, key)
+	if result != lookupMiss {
+		cm.recordLookup(result)
+		span.SetAttributes(attribute.String("cache.result", result))
+		return decompress(data)
+	}
+
+	type loaded struct {
+		data   []byte
+		result string
+	}
+	value, err, _ := cm.loads.Do(key, func() (interface{}, error) {
+		// A previous flight may have filled the cache while this caller was missing
+		if data, result := cm.lookup(ctx, key); result != lookupMiss {
+			data, err := decompress(data)
+			return loaded{data, result}, err
+		}
+
+		data, err := loader()
+		if err != nil {
+			return nil, err
+		}
+
+		cm.Set(ctx, key, data)
+		return loaded{data, lookupMiss}, nil
+	})
+	if err != nil {
+		cm.recordLookup(lookupMiss)
+		span.SetAttributes(attribute.String("cache.result", lookupMiss))
+		return nil, err
+	}
+
+	flight := value.(loaded)
+	cm.recordLookup(flight.result)
+	span.SetAttributes(attribute.String("cache.result", flight.result))
+	return flight.data, nil
 }
 
 // GetObject retrieves and deserializes an object from cache
@@ -80,17 +286,29 @@ func (cm *CacheManager) GetObject(ctx context.Context, key string, dest interfac
 	return json.Unmarshal(data, dest)
 }
 
-// Set stores a value in the cache
+// Set stores a value in the cache. Writing to Redis is best effort: while it is
+// unavailable values are only kept in memory.
 func (cm *CacheManager) Set(ctx context.Context, key string, value []byte) error {
-	// Store in both L1 and L2 caches
-	err1 := cm.l1Cache.Set(ctx, key, value, cm.config.L1TTL).Err()
-	err2 := cm.l2Cache.Set(key, value)
+	ctx, span := tracing.Start(ctx, "cache set", attribute.String("cache.key", key))
+	defer span.End()
 
-	if err1 != nil {
-		return fmt.Errorf("failed to set L1 cache: %w", err1)
+	if cm.config.Compression && len(value) >= cm.config.CompressionThreshold {
+		compressed, err := compress(value)
+		if err != nil {
+			return fmt.Errorf("failed to compress value: %w", err)
+		}
+		value = compressed
 	}
-	if err2 != nil {
-		return fmt.Errorf("failed to set L2 cache: %w", err2)
+
+	// Store in both L1 and L2 caches
+	if err := cm.l1(ctx, func() error {
+		return cm.l1Cache.Set(ctx, key, value, cm.config.L1TTL).Err()
+	}); err != nil {
+		span.SetAttributes(attribute.String("cache.l1_error", err.Error()))
+	}
+
+	if err := cm.l2Cache.Set(key, value); err != nil {
+		return fmt.Errorf("failed to set L2 cache: %w", err)
 	}
 
 	return nil
@@ -108,7 +326,9 @@ func (cm *CacheManager) SetObject(ctx context.Context, key string, value interfa
 
 // Delete removes a key from both cache levels
 func (cm *CacheManager) Delete(ctx context.Context, key string) error {
-	err1 := cm.l1Cache.Del(ctx, key).Err()
+	err1 := cm.l1(ctx, func() error {
+		return cm.l1Cache.Del(ctx, key).Err()
+	})
 	err2 := cm.l2Cache.Delete(key)
 
 	if err1 != nil {
@@ -124,7 +344,9 @@ func (cm *CacheManager) Delete(ctx context.Context, key string) error {
 // Clear clears all cache levels
 func (cm *CacheManager) Clear(ctx context.Context) error {
 	// Clear L1 cache
-	err1 := cm.l1Cache.FlushAll(ctx).Err()
+	err1 := cm.l1(ctx, func() error {
+		return cm.l1Cache.FlushAll(ctx).Err()
+	})
 	
 	// Clear L2 cache
 	err2 := cm.l2Cache.Reset()
@@ -141,10 +363,24 @@ func (cm *CacheManager) Clear(ctx context.Context) error {
 
 // GetStats returns cache statistics
 func (cm *CacheManager) GetStats(ctx context.Context) map[string]interface{} {
-	l1Stats := cm.l1Cache.Info(ctx, "memory").Val()
+	var l1Stats string
+	cm.l1(ctx, func() error {
+		var err error
+		l1Stats, err = cm.l1Cache.Info(ctx, "memory").Result()
+		return err
+	})
 	l2Stats := cm.l2Cache.Stats()
 
+	ratios := cm.hitRatios()
+
 	return map[string]interface{}{
+		"l1_hits":      cm.l1Hits.Load(),
+		"l2_hits":      cm.l2Hits.Load(),
+		"misses":       cm.misses.Load(),
+		"l1_hit_ratio": ratios["l1_hit_ratio"],
+		"l2_hit_ratio": ratios["l2_hit_ratio"],
+		"hit_ratio":    ratios["hit_ratio"],
+		"l1_state":     cm.RedisState(),
 		"l1_stats": l1Stats,
 		"l2_stats": map[string]interface{}{
 			"hits":   l2Stats.Hits,
@@ -152,4 +388,35 @@ func (cm *CacheManager) GetStats(ctx context.Context) map[string]interface{} {
 			"size":   l2Stats.Size,
 		},
 	}
-} 
\ No newline at end of file
+} 
+// compress gzips the value and adds compressedPrefix
+func compress(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(compressedPrefix)
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(value); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompress returns the original value of a compressed entry, and uncompressed
+// entries unchanged
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, compressedPrefix) {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data[len(compressedPrefix):]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress cached value: %w", err)
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestCache returns a cache whose Redis is unreachable, so lookups that miss memory
+// fall through to the loader
+func newTestCache(t *testing.T) *CacheManager {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	cm, err := NewCacheManager(client, DefaultCacheConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+func checkLookups(t *testing.T, cm *CacheManager, l2Hits, l1Hits, misses int64) {
+	t.Helper()
+	if got := cm.l2Hits.Load(); got != l2Hits {
+		t.Errorf("l2 hits = %d, want %d", got, l2Hits)
+	}
+	if got := cm.l1Hits.Load(); got != l1Hits {
+		t.Errorf("l1 hits = %d, want %d", got, l1Hits)
+	}
+	if got := cm.misses.Load(); got != misses {
+		t.Errorf("misses = %d, want %d", got, misses)
+	}
+}
+
+func TestGetOrLoadRecordsOneLookupPerCall(t *testing.T) {
+	cm := newTestCache(t)
+	ctx := context.Background()
+	loader := func() ([]byte, error) { return []byte(`{"cost":1}`), nil }
+
+	if _, err := cm.GetOrLoad(ctx, "costs", loader); err != nil {
+		t.Fatal(err)
+	}
+	checkLookups(t, cm, 0, 0, 1)
+
+	data, err := cm.GetOrLoad(ctx, "costs", loader)
+	if err != nil || string(data) != `{"cost":1}` {
+		t.Fatalf("GetOrLoad = %q, %v", data, err)
+	}
+	checkLookups(t, cm, 1, 0, 1)
+
+	if ratio := cm.hitRatios()["hit_ratio"]; ratio != 0.5 {
+		t.Errorf("hit ratio = %v, want 0.5", ratio)
+	}
+}
+
+func TestGetOrLoadRecordsFailedLoadAsMiss(t *testing.T) {
+	cm := newTestCache(t)
+	errLoad := errors.New("database down")
+
+	if _, err := cm.GetOrLoad(context.Background(), "costs", func() ([]byte, error) { return nil, errLoad }); err != errLoad {
+		t.Fatalf("GetOrLoad error = %v, want %v", err, errLoad)
+	}
+	checkLookups(t, cm, 0, 0, 1)
+}
+
+func TestGetOrLoadCoalescedCallersRecordOnce(t *testing.T) {
+	cm := newTestCache(t)
+	release := make(chan struct{})
+	var loads atomic.Int32
+
+	const callers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cm.GetOrLoad(context.Background(), "costs", func() ([]byte, error) {
+				loads.Add(1)
+				<-release
+				return []byte("value"), nil
+			})
+		}()
+	}
+
+	// Let every caller miss and join the flight before it completes
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Errorf("loader ran %d times, want once", loads.Load())
+	}
+	if total := cm.l2Hits.Load() + cm.l1Hits.Load() + cm.misses.Load(); total != callers {
+		t.Errorf("recorded %d lookups for %d calls", total, callers)
+	}
+}
+
+func TestRedisSkippedWhileBreakerOpen(t *testing.T) {
+	cm := newTestCache(t)
+	ctx := context.Background()
+
+	var calls int
+	ping := func(client *redis.Client) error {
+		calls++
+		return client.Ping(ctx).Err()
+	}
+	for i := 0; i < cm.config.RedisBreakerThreshold; i++ {
+		if err := cm.Redis(ctx, ping); err == nil {
+			t.Fatal("Ping of an unreachable Redis succeeded")
+		}
+	}
+	if state := cm.RedisState(); state != "open" {
+		t.Fatalf("RedisState = %q after %d failures, want open", state, calls)
+	}
+
+	if err := cm.Redis(ctx, ping); err == nil {
+		t.Error("Redis succeeded while the breaker is open")
+	}
+	if calls != cm.config.RedisBreakerThreshold {
+		t.Errorf("Redis ran %d calls, want %d before the breaker opened", calls, cm.config.RedisBreakerThreshold)
+	}
+}
@@ -0,0 +1,395 @@
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	bigquery "google.golang.org/api/bigquery/v2"
+)
+
+// Labels GKE puts on billing export rows. The namespace label requires GKE cost
+// allocation to be enabled on the cluster.
+const (
+	gkeClusterLabel   = "goog-k8s-cluster-name"
+	gkeNamespaceLabel = "k8s-namespace"
+	gceMachineLabel   = "compute.googleapis.com/machine_spec"
+)
+
+const (
+	// Billing export rows usually land within a few hours of usage, so node prices are
+	// taken from the day ending this long ago to avoid averaging in missing hours
+	gcpBillingDelay = 6 * time.Hour
+
+	// Window of billing data averaged into hourly node prices
+	gcpNodeCostWindow = 24 * time.Hour
+
+	// Period covered by GetClusterCosts
+	gcpClusterCostWindow = 30 * 24 * time.Hour
+
+	// How long a single poll for query results waits server-side
+	gcpQueryPollTimeout = 10 * time.Second
+)
+
+// billingTablePattern matches project.dataset.table, where the project may be
+// domain-scoped. The table name can't be passed as a query parameter, so anything
+// else is rejected before it reaches the SQL.
+var billingTablePattern = regexp.MustCompile(`^[a-zA-Z0-9_.:-]+\.[a-zA-Z0-9_]+\.[a-zA-Z0-9_]+$`)
+
+// GCPConfig locates the Cloud Billing export in BigQuery
+type GCPConfig struct {
+	// Project the billing queries run in and are billed to. Defaults to the billing
+	// table's project.
+	ProjectID string
+
+	// Detailed usage cost export table, as project.dataset.table. Only the detailed
+	// export has the resource names that tie costs to nodes.
+	BillingTable string
+}
+
+// GCPCostProvider reads GKE costs from the Cloud Billing detailed export in BigQuery.
+// Rows are attributed to the cluster by GKE's cluster label, to nodes by the VM
+// resource name, which matches the node name, and to namespaces by the namespace label
+// GKE cost allocation adds. Credentials come from Application Default Credentials.
+type GCPCostProvider struct {
+	bq          *bigquery.Service
+	config      GCPConfig
+	region      string
+	clusterName string
+}
+
+// NewGCPCostProvider creates a provider for the cluster's costs in the given region. An
+// empty region covers every region the cluster's resources are billed in.
+func NewGCPCostProvider(region, clusterName string, config *GCPConfig) (*GCPCostProvider, error) {
+	if config == nil || config.BillingTable == "" {
+		return nil, fmt.Errorf("gcp provider requires the billing export table")
+	}
+	if !billingTablePattern.MatchString(config.BillingTable) {
+		return nil, fmt.Errorf("invalid billing table %q, expected project.dataset.table", config.BillingTable)
+	}
+
+	cfg := *config
+	if cfg.ProjectID == "" {
+		parts := strings.Split(config.BillingTable, ".")
+		cfg.ProjectID = strings.Join(parts[:len(parts)-2], ".")
+	}
+
+	// Uses Application Default Credentials: GKE workload identity, a service account key
+	// in GOOGLE_APPLICATION_CREDENTIALS or gcloud user credentials
+	bq, err := bigquery.NewService(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("creating BigQuery client: %w", err)
+	}
+
+	return &GCPCostProvider{
+		bq:          bq,
+		config:      cfg,
+		region:      region,
+		clusterName: clusterName,
+	}, nil
+}
+
+// GetNodeCosts returns each node's hourly cost, averaged over the last complete day of
+// billing data
+func (g *GCPCostProvider) GetNodeCosts(ctx context.Context) (map[string]float64, error) {
+	end := time.Now().Add(-gcpBillingDelay).Truncate(time.Hour)
+	start := end.Add(-gcpNodeCostWindow)
+
+	rows, err := g.query(ctx, `
+		SELECT resource.name, SUM(`+gcpNetCost+`)
+		FROM `+g.table()+`
+		WHERE `+gcpClusterFilter+`
+			AND service.description = 'Compute Engine'
+			AND resource.name IS NOT NULL
+		GROUP BY 1
+	`, g.params(g.clusterName, start, end))
+	if err != nil {
+		return nil, fmt.Errorf("querying node costs: %w", err)
+	}
+
+	hours := gcpNodeCostWindow.Hours()
+	costs := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		costs[row[0]] = parseBillingFloat(row[1]) / hours
+	}
+	return costs, nil
+}
+
+// GetDetailedCosts returns the cluster's costs between start and end by namespace.
+// Costs GKE couldn't attribute to a namespace count toward the total only.
+func (g *GCPCostProvider) GetDetailedCosts(ctx context.Context, start, end time.Time) (*CostBreakdown, error) {
+	namespaces, total, err := g.namespaceCosts(ctx, g.clusterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CostBreakdown{
+		Namespaces: namespaces,
+		Total:      total,
+		Period:     billingPeriod(end.Sub(start)),
+	}, nil
+}
+
+// GetClusterCosts returns the named cluster's costs over the last 30 days by node and
+// namespace
+func (g *GCPCostProvider) GetClusterCosts(ctx context.Context, clusterName string) (*ClusterCosts, error) {
+	end := time.Now().Truncate(time.Hour)
+	start := end.Add(-gcpClusterCostWindow)
+
+	namespaces, total, err := g.namespaceCosts(ctx, clusterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := g.query(ctx, `
+		SELECT
+			resource.name,
+			MAX((SELECT l.value FROM UNNEST(system_labels) l WHERE l.key = '`+gceMachineLabel+`')),
+			MAX(location.region),
+			sku.description,
+			COUNT(DISTINCT usage_start_time),
+			SUM(`+gcpNetCost+`)
+		FROM `+g.table()+`
+		WHERE `+gcpClusterFilter+`
+			AND service.description = 'Compute Engine'
+			AND resource.name IS NOT NULL
+		GROUP BY resource.name, sku.description
+	`, g.params(clusterName, start, end))
+	if err != nil {
+		return nil, fmt.Errorf("querying node costs: %w", err)
+	}
+
+	nodes := make(map[string]NodeCost)
+	nodeHours := make(map[string]float64)
+	for _, row := range rows {
+		name, sku := row[0], row[3]
+		cost := parseBillingFloat(row[5])
+
+		node := nodes[name]
+		if node.Lifecycle == "" {
+			node.Lifecycle = LifecycleOnDemand
+		}
+		if row[1] != "" {
+			node.InstanceType = row[1]
+		}
+		if row[2] != "" {
+			node.Region = row[2]
+		}
+		if isSpotSKU(sku) {
+			node.Lifecycle = LifecycleSpot
+		}
+		node.MonthlyCost += cost
+		switch billingCategory("Compute Engine", sku) {
+		case "storage":
+			node.Components.Storage += cost
+		case "network":
+			node.Components.Network += cost
+		case "other":
+			node.Components.Other += cost
+		default:
+			node.Components.Compute += cost
+		}
+		nodes[name] = node
+
+		if hours := parseBillingFloat(row[4]); hours > nodeHours[name] {
+			nodeHours[name] = hours
+		}
+	}
+
+	// Scale each node's cost to a full month from the hours it actually ran
+	for name, node := range nodes {
+		if hours := nodeHours[name]; hours > 0 {
+			node.HourlyCost = node.MonthlyCost / hours
+			scale := 24 * 30 / hours
+			node.MonthlyCost *= scale
+			node.Components.Compute *= scale
+			node.Components.Storage *= scale
+			node.Components.Network *= scale
+			node.Components.Other *= scale
+		}
+		nodes[name] = node
+	}
+
+	return &ClusterCosts{
+		ClusterName: clusterName,
+		Total:       total,
+		Nodes:       nodes,
+		Namespaces:  namespaces,
+		Period:      billingPeriod(gcpClusterCostWindow),
+	}, nil
+}
+
+// namespaceCosts sums the cluster's costs between start and end by namespace label and
+// category, returning them with the cluster total
+func (g *GCPCostProvider) namespaceCosts(ctx context.Context, clusterName string, start, end time.Time) (map[string]NamespaceCost, float64, error) {
+	rows, err := g.query(ctx, `
+		SELECT
+			IFNULL((SELECT l.value FROM UNNEST(labels) l WHERE l.key = '`+gkeNamespaceLabel+`'), ''),
+			service.description,
+			sku.description,
+			SUM(`+gcpNetCost+`)
+		FROM `+g.table()+`
+		WHERE `+gcpClusterFilter+`
+		GROUP BY 1, 2, 3
+	`, g.params(clusterName, start, end))
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying namespace costs: %w", err)
+	}
+
+	namespaces := make(map[string]NamespaceCost)
+	total := 0.0
+	for _, row := range rows {
+		namespace := row[0]
+		cost := parseBillingFloat(row[3])
+		total += cost
+		if namespace == "" {
+			continue
+		}
+
+		nc := namespaces[namespace]
+		nc.Total += cost
+		switch billingCategory(row[1], row[2]) {
+		case "compute":
+			nc.Compute += cost
+		case "storage":
+			nc.Storage += cost
+		case "network":
+			nc.Network += cost
+		default:
+			nc.Other += cost
+		}
+		namespaces[namespace] = nc
+	}
+
+	return namespaces, total, nil
+}
+
+// gcpNetCost is a row's cost after credits such as sustained and committed use discounts
+const gcpNetCost = `cost + IFNULL((SELECT SUM(c.amount) FROM UNNEST(credits) c), 0)`
+
+// gcpClusterFilter restricts rows to the period, the cluster and, when set, the region
+const gcpClusterFilter = `usage_start_time >= @start AND usage_start_time < @end
+			AND EXISTS (SELECT 1 FROM UNNEST(labels) l WHERE l.key = '` + gkeClusterLabel + `' AND l.value = @cluster)
+			AND (@region = '' OR location.region = @region)`
+
+func (g *GCPCostProvider) table() string {
+	return "`" + g.config.BillingTable + "`"
+}
+
+func (g *GCPCostProvider) params(clusterName string, start, end time.Time) []*bigquery.QueryParameter {
+	return []*bigquery.QueryParameter{
+		queryParam("cluster", "STRING", clusterName),
+		queryParam("region", "STRING", g.region),
+		queryParam("start", "TIMESTAMP", start.UTC().Format("2006-01-02 15:04:05.999999-07:00")),
+		queryParam("end", "TIMESTAMP", end.UTC().Format("2006-01-02 15:04:05.999999-07:00")),
+	}
+}
+
+func queryParam(name, typ, value string) *bigquery.QueryParameter {
+	return &bigquery.QueryParameter{
+		Name:           name,
+		ParameterType:  &bigquery.QueryParameterType{Type: typ},
+		ParameterValue: &bigquery.QueryParameterValue{Value: value},
+	}
+}
+
+// query runs a standard SQL query and returns every row as strings, waiting for the
+// job to finish and following result pages. NULL cells are returned as empty strings.
+func (g *GCPCostProvider) query(ctx context.Context, sql string, params []*bigquery.QueryParameter) (_ [][]string, err error) {
+	ctx, span := tracing.Start(ctx, "bigquery query", attribute.String("gcp.project_id", g.config.ProjectID))
+	defer func() { tracing.End(span, err) }()
+
+	useLegacySQL := false
+	resp, err := g.bq.Jobs.Query(g.config.ProjectID, &bigquery.QueryRequest{
+		Query:           sql,
+		UseLegacySql:    &useLegacySQL,
+		ParameterMode:   "NAMED",
+		QueryParameters: params,
+		TimeoutMs:       gcpQueryPollTimeout.Milliseconds(),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := resp.Rows
+	complete, pageToken := resp.JobComplete, resp.PageToken
+	job := resp.JobReference
+
+	for !complete || pageToken != "" {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		call := g.bq.Jobs.GetQueryResults(job.ProjectId, job.JobId).
+			Location(job.Location).
+			TimeoutMs(gcpQueryPollTimeout.Milliseconds()).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		results, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+		if !results.JobComplete {
+			continue
+		}
+
+		complete = true
+		rows = append(rows, results.Rows...)
+		pageToken = results.PageToken
+	}
+
+	values := make([][]string, len(rows))
+	for i, row := range rows {
+		values[i] = make([]string, len(row.F))
+		for j, cell := range row.F {
+			if cell.V != nil {
+				values[i][j] = fmt.Sprint(cell.V)
+			}
+		}
+	}
+	return values, nil
+}
+
+// billingCategory maps a billing row's service and SKU to the cost components
+func billingCategory(service, sku string) string {
+	sku = strings.ToLower(sku)
+	switch {
+	case strings.Contains(sku, "egress") || strings.Contains(sku, "network") || service == "Networking":
+		return "network"
+	case strings.Contains(sku, "storage") || strings.Contains(sku, "pd capacity") ||
+		strings.Contains(sku, "snapshot") || service == "Cloud Storage":
+		return "storage"
+	case service == "Compute Engine" || service == "Kubernetes Engine":
+		return "compute"
+	default:
+		return "other"
+	}
+}
+
+// isSpotSKU reports whether the SKU bills spot or preemptible capacity
+func isSpotSKU(sku string) bool {
+	sku = strings.ToLower(sku)
+	return strings.Contains(sku, "spot") || strings.Contains(sku, "preemptible")
+}
+
+func parseBillingFloat(s string) float64 {
+	value, _ := strconv.ParseFloat(s, 64)
+	return value
+}
+
+// billingPeriod formats a duration the way the other providers label periods, e.g. 30d
+func billingPeriod(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%dh", int(d/time.Hour))
+}
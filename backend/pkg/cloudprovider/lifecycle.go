@@ -0,0 +1,37 @@
+package cloudprovider
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Node purchase options
+const (
+	LifecycleOnDemand = "on-demand"
+	LifecycleSpot     = "spot"
+	LifecycleReserved = "reserved"
+)
+
+// spotNodeLabels are the labels, and the values marking spot capacity, that cloud
+// providers and node provisioners put on nodes
+var spotNodeLabels = map[string]string{
+	"eks.amazonaws.com/capacityType":        "spot",
+	"karpenter.sh/capacity-type":            "spot",
+	"node.kubernetes.io/lifecycle":          "spot",
+	"cloud.google.com/gke-spot":             "true",
+	"cloud.google.com/gke-preemptible":      "true",
+	"kubernetes.azure.com/scalesetpriority": "spot",
+}
+
+// NodeLifecycle reports whether a node runs on spot/preemptible capacity based on its
+// labels. Reserved capacity isn't visible on the node, so every other node is on-demand;
+// providers that know about reservations set NodeCost.Lifecycle themselves.
+func NodeLifecycle(node *corev1.Node) string {
+	for label, spotValue := range spotNodeLabels {
+		if strings.EqualFold(node.Labels[label], spotValue) {
+			return LifecycleSpot
+		}
+	}
+	return LifecycleOnDemand
+}
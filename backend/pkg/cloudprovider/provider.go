@@ -5,6 +5,11 @@ import (
 	"time"
 )
 
+// CPUToMemoryPriceRatio is the price of one vCPU-hour relative to one GiB-hour, used to
+// split a node's price between CPU and memory. Published on-demand pricing for general
+// purpose instances puts this at roughly 7-9.
+const CPUToMemoryPriceRatio = 7.5
+
 // Provider interface for cloud cost management
 type Provider interface {
 	GetNodeCosts(ctx context.Context) (map[string]float64, error)
@@ -41,6 +46,7 @@ type ClusterCosts struct {
 type NodeCost struct {
 	InstanceType string  `json:"instance_type"`
 	Region       string  `json:"region"`
+	Lifecycle    string  `json:"lifecycle"` // LifecycleOnDemand, LifecycleSpot or LifecycleReserved
 	HourlyCost   float64 `json:"hourly_cost"`
 	MonthlyCost  float64 `json:"monthly_cost"`
 	Components   struct {
@@ -111,6 +117,7 @@ func (m *MockCostProvider) GetClusterCosts(ctx context.Context, clusterName stri
 			"node-1": {
 				InstanceType: "t3.medium",
 				Region:       "us-west-2",
+				Lifecycle:    LifecycleOnDemand,
 				HourlyCost:   0.50,
 				MonthlyCost:  360.0,
 				Components: struct {
@@ -128,6 +135,7 @@ func (m *MockCostProvider) GetClusterCosts(ctx context.Context, clusterName stri
 			"node-2": {
 				InstanceType: "t3.large",
 				Region:       "us-west-2",
+				Lifecycle:    LifecycleSpot,
 				HourlyCost:   0.75,
 				MonthlyCost:  540.0,
 				Components: struct {
@@ -0,0 +1,99 @@
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-cost-optimizer/pkg/resilience"
+	"k8s-cost-optimizer/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ResilientProvider wraps a Provider so billing API calls are retried with backoff
+// and short-circuited while the API keeps failing. A whole retried call counts as a
+// single breaker result, so an open breaker fails fast without retrying.
+type ResilientProvider struct {
+	provider Provider
+	breaker  *resilience.CircuitBreaker
+	retry    *resilience.RetryConfig
+}
+
+// NewResilientProvider wraps the provider with the given breaker and retry settings.
+// A nil retry config uses resilience.DefaultRetryConfig.
+func NewResilientProvider(provider Provider, breaker *resilience.CircuitBreaker, retry *resilience.RetryConfig) *ResilientProvider {
+	if retry == nil {
+		retry = resilience.DefaultRetryConfig()
+	}
+
+	return &ResilientProvider{
+		provider: provider,
+		breaker:  breaker,
+		retry:    retry,
+	}
+}
+
+func (rp *ResilientProvider) GetNodeCosts(ctx context.Context) (map[string]float64, error) {
+	ctx, span := rp.startSpan(ctx, "GetNodeCosts")
+
+	var costs map[string]float64
+	err := rp.breaker.Execute(ctx, func() error {
+		var err error
+		costs, err = resilience.RetryWithResult(ctx, rp.retry, func() (map[string]float64, error) {
+			return rp.provider.GetNodeCosts(ctx)
+		})
+		return err
+	})
+	tracing.End(span, err)
+	return costs, err
+}
+
+func (rp *ResilientProvider) GetDetailedCosts(ctx context.Context, start, end time.Time) (*CostBreakdown, error) {
+	ctx, span := rp.startSpan(ctx, "GetDetailedCosts")
+
+	var breakdown *CostBreakdown
+	err := rp.breaker.Execute(ctx, func() error {
+		var err error
+		breakdown, err = resilience.RetryWithResult(ctx, rp.retry, func() (*CostBreakdown, error) {
+			return rp.provider.GetDetailedCosts(ctx, start, end)
+		})
+		return err
+	})
+	tracing.End(span, err)
+	return breakdown, err
+}
+
+func (rp *ResilientProvider) GetClusterCosts(ctx context.Context, clusterName string) (*ClusterCosts, error) {
+	ctx, span := rp.startSpan(ctx, "GetClusterCosts")
+
+	var costs *ClusterCosts
+	err := rp.breaker.Execute(ctx, func() error {
+		var err error
+		costs, err = resilience.RetryWithResult(ctx, rp.retry, func() (*ClusterCosts, error) {
+			return rp.provider.GetClusterCosts(ctx, clusterName)
+		})
+		return err
+	})
+	tracing.End(span, err)
+	return costs, err
+}
+
+// startSpan starts a span around a provider call. Retries and the breaker run inside
+// it, and the provider receives its context, so billing API calls join the caller's trace.
+func (rp *ResilientProvider) startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "cloudprovider "+method,
+		attribute.String("cloud.provider", fmt.Sprintf("%T", rp.provider)),
+		attribute.String("breaker.state", rp.BreakerState()))
+}
+
+// BreakerState returns "closed", "open" or "half_open"
+func (rp *ResilientProvider) BreakerState() string {
+	return rp.breaker.StateName()
+}
+
+// Unwrap returns the underlying provider
+func (rp *ResilientProvider) Unwrap() Provider {
+	return rp.provider
+}
@@ -0,0 +1,240 @@
+package cloudprovider
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// staticClusterCostWindow is the period covered by StaticPriceProvider.GetClusterCosts
+const staticClusterCostWindow = 30 * 24 * time.Hour
+
+// StaticPrices is a pricing table for clusters without a billing API, such as bare
+// metal or on-prem
+type StaticPrices struct {
+	PerVCPUHour        float64
+	PerGiBHour         float64
+	PerGiBStorageMonth float64
+}
+
+// DefaultStaticPrices returns prices close to public cloud on-demand rates
+func DefaultStaticPrices() *StaticPrices {
+	return &StaticPrices{
+		PerVCPUHour:        0.0316,
+		PerGiBHour:         0.0042,
+		PerGiBStorageMonth: 0.10,
+	}
+}
+
+// Validate checks that no price is negative and that compute isn't free
+func (p *StaticPrices) Validate() error {
+	if p.PerVCPUHour < 0 || p.PerGiBHour < 0 || p.PerGiBStorageMonth < 0 {
+		return fmt.Errorf("static prices can't be negative")
+	}
+	if p.PerVCPUHour == 0 && p.PerGiBHour == 0 {
+		return fmt.Errorf("static prices need a vCPU or memory price")
+	}
+	return nil
+}
+
+// StaticPriceProvider prices the cluster from a fixed pricing table. Nodes cost their
+// allocatable CPU and memory at the table's rates. Namespaces are charged for their
+// average CPU and memory usage, as collected in pod_metrics, and for the storage their
+// PVCs use, as collected in storage_metrics.
+type StaticPriceProvider struct {
+	k8sClient   kubernetes.Interface
+	db          *sql.DB
+	clusterName string
+	prices      StaticPrices
+}
+
+// NewStaticPriceProvider creates a provider for the cluster's costs at the given
+// prices. Nil prices use DefaultStaticPrices.
+func NewStaticPriceProvider(k8sClient kubernetes.Interface, db *sql.DB, clusterName string, prices *StaticPrices) (*StaticPriceProvider, error) {
+	if prices == nil {
+		prices = DefaultStaticPrices()
+	}
+	if err := prices.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &StaticPriceProvider{
+		k8sClient:   k8sClient,
+		db:          db,
+		clusterName: clusterName,
+		prices:      *prices,
+	}, nil
+}
+
+// GetNodeCosts returns each node's hourly cost from its allocatable CPU and memory
+func (s *StaticPriceProvider) GetNodeCosts(ctx context.Context) (map[string]float64, error) {
+	nodes, err := s.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	costs := make(map[string]float64, len(nodes.Items))
+	for i := range nodes.Items {
+		costs[nodes.Items[i].Name] = s.nodeHourlyCost(&nodes.Items[i])
+	}
+	return costs, nil
+}
+
+// GetDetailedCosts returns each namespace's cost between start and end for its average
+// CPU, memory and storage usage over the period
+func (s *StaticPriceProvider) GetDetailedCosts(ctx context.Context, start, end time.Time) (*CostBreakdown, error) {
+	namespaces, err := s.namespaceCosts(ctx, s.clusterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0.0
+	for _, cost := range namespaces {
+		total += cost.Total
+	}
+
+	return &CostBreakdown{
+		Namespaces: namespaces,
+		Total:      total,
+		Period:     billingPeriod(end.Sub(start)),
+	}, nil
+}
+
+// GetClusterCosts returns the cost of the current nodes over a 30 day month, and what
+// each namespace's usage cost over the last 30 days
+func (s *StaticPriceProvider) GetClusterCosts(ctx context.Context, clusterName string) (*ClusterCosts, error) {
+	nodes, err := s.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	end := time.Now()
+	namespaces, err := s.namespaceCosts(ctx, clusterName, end.Add(-staticClusterCostWindow), end)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := &ClusterCosts{
+		ClusterName: clusterName,
+		Nodes:       make(map[string]NodeCost, len(nodes.Items)),
+		Namespaces:  namespaces,
+		Period:      billingPeriod(staticClusterCostWindow),
+	}
+
+	hours := staticClusterCostWindow.Hours()
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		hourly := s.nodeHourlyCost(node)
+
+		nodeCost := NodeCost{
+			InstanceType: node.Labels[corev1.LabelInstanceTypeStable],
+			Region:       node.Labels[corev1.LabelTopologyRegion],
+			Lifecycle:    NodeLifecycle(node),
+			HourlyCost:   hourly,
+			MonthlyCost:  hourly * hours,
+		}
+		nodeCost.Components.Compute = nodeCost.MonthlyCost
+		costs.Nodes[node.Name] = nodeCost
+		costs.Total += nodeCost.MonthlyCost
+	}
+
+	// Storage isn't part of the node price, so it adds to the cluster total
+	for _, cost := range namespaces {
+		costs.Total += cost.Storage
+	}
+
+	return costs, nil
+}
+
+func (s *StaticPriceProvider) nodeHourlyCost(node *corev1.Node) float64 {
+	cores := float64(node.Status.Allocatable.Cpu().MilliValue()) / 1000
+	gib := float64(node.Status.Allocatable.Memory().Value()) / (1 << 30)
+	return cores*s.prices.PerVCPUHour + gib*s.prices.PerGiBHour
+}
+
+// namespaceCosts prices each namespace's usage between start and end. Usage is summed
+// per collection timestamp and averaged over every timestamp collected in the period,
+// so pods that only ran for part of it are charged for that part.
+func (s *StaticPriceProvider) namespaceCosts(ctx context.Context, clusterName string, start, end time.Time) (map[string]NamespaceCost, error) {
+	hours := end.Sub(start).Hours()
+	namespaces := make(map[string]NamespaceCost)
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH samples AS (
+			SELECT COUNT(DISTINCT timestamp) as n
+			FROM pod_metrics
+			WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+		)
+		SELECT
+			namespace,
+			COALESCE(SUM(cpu_millicores), 0) / MAX(samples.n),
+			COALESCE(SUM(memory_bytes), 0) / MAX(samples.n)
+		FROM pod_metrics, samples
+		WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+		GROUP BY namespace
+	`, start, end, clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("querying namespace usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var namespace string
+		var millicores, bytes float64
+		if err := rows.Scan(&namespace, &millicores, &bytes); err != nil {
+			return nil, fmt.Errorf("scanning namespace usage: %w", err)
+		}
+
+		cost := namespaces[namespace]
+		cost.Compute = (millicores/1000*s.prices.PerVCPUHour + bytes/(1<<30)*s.prices.PerGiBHour) * hours
+		namespaces[namespace] = cost
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading namespace usage: %w", err)
+	}
+
+	if s.prices.PerGiBStorageMonth > 0 {
+		storageRows, err := s.db.QueryContext(ctx, `
+			WITH samples AS (
+				SELECT COUNT(DISTINCT timestamp) as n
+				FROM storage_metrics
+				WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+			)
+			SELECT namespace, COALESCE(SUM(used_bytes), 0) / MAX(samples.n)
+			FROM storage_metrics, samples
+			WHERE timestamp >= $1 AND timestamp < $2 AND cluster = $3
+			GROUP BY namespace
+		`, start, end, clusterName)
+		if err != nil {
+			return nil, fmt.Errorf("querying namespace storage: %w", err)
+		}
+		defer storageRows.Close()
+
+		perGiBHour := s.prices.PerGiBStorageMonth / (24 * 30)
+		for storageRows.Next() {
+			var namespace string
+			var bytes float64
+			if err := storageRows.Scan(&namespace, &bytes); err != nil {
+				return nil, fmt.Errorf("scanning namespace storage: %w", err)
+			}
+
+			cost := namespaces[namespace]
+			cost.Storage = bytes / (1 << 30) * perGiBHour * hours
+			namespaces[namespace] = cost
+		}
+		if err := storageRows.Err(); err != nil {
+			return nil, fmt.Errorf("reading namespace storage: %w", err)
+		}
+	}
+
+	for namespace, cost := range namespaces {
+		cost.Total = cost.Compute + cost.Storage
+		namespaces[namespace] = cost
+	}
+	return namespaces, nil
+}
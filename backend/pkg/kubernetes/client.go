@@ -7,37 +7,114 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
-// NewClient creates a new Kubernetes client
-func NewClient() (kubernetes.Interface, error) {
-	var config *rest.Config
-	var err error
+// ClientConfig holds client-side rate limits for the API server
+type ClientConfig struct {
+	// Sustained queries per second and burst allowed before client-go throttles.
+	// client-go's own defaults (5/10) are too low to list pods on large clusters.
+	QPS   float32
+	Burst int
+}
+
+// DefaultClientConfig returns rate limits suited to listing pods across a large cluster
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		QPS:   50,
+		Burst: 100,
+	}
+}
 
-	// Try in-cluster config first
-	config, err = rest.InClusterConfig()
+// NewClient creates a new Kubernetes client. A nil config uses DefaultClientConfig.
+func NewClient(clientConfig *ClientConfig) (kubernetes.Interface, error) {
+	config, err := defaultRESTConfig(clientConfig)
 	if err != nil {
-		// Fall back to kubeconfig file
-		kubeconfig := os.Getenv("KUBECONFIG")
-		if kubeconfig == "" {
-			home := os.Getenv("HOME")
-			if home == "" {
-				home = os.Getenv("USERPROFILE") // Windows
-			}
-			kubeconfig = filepath.Join(home, ".kube", "config")
-		}
+		return nil, err
+	}
+
+	// Create the clientset
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientset, nil
+}
+
+// NewClientForContext creates a client for the named kubeconfig context, so one
+// process can collect from several clusters. An empty name uses the current context.
+func NewClientForContext(contextName string, clientConfig *ClientConfig) (kubernetes.Interface, error) {
+	config, err := contextRESTConfig(contextName, clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// NewMetricsClient creates a Metrics Server client for the cluster NewClient connects to
+func NewMetricsClient(clientConfig *ClientConfig) (versioned.Interface, error) {
+	config, err := defaultRESTConfig(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return versioned.NewForConfig(config)
+}
 
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+// NewMetricsClientForContext creates a Metrics Server client for the named kubeconfig
+// context
+func NewMetricsClientForContext(contextName string, clientConfig *ClientConfig) (versioned.Interface, error) {
+	config, err := contextRESTConfig(contextName, clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return versioned.NewForConfig(config)
+}
+
+// defaultRESTConfig uses the in-cluster config, falling back to the kubeconfig file
+func defaultRESTConfig(clientConfig *ClientConfig) (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath())
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// Create the clientset
-	clientset, err := kubernetes.NewForConfig(config)
+	applyRateLimits(config, clientConfig)
+	return config, nil
+}
+
+func contextRESTConfig(contextName string, clientConfig *ClientConfig) (*rest.Config, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath()},
+		&clientcmd.ConfigOverrides{CurrentContext: contextName},
+	).ClientConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	return clientset, nil
+	applyRateLimits(config, clientConfig)
+	return config, nil
+}
+
+func applyRateLimits(config *rest.Config, clientConfig *ClientConfig) {
+	if clientConfig == nil {
+		clientConfig = DefaultClientConfig()
+	}
+	config.QPS = clientConfig.QPS
+	config.Burst = clientConfig.Burst
+}
+
+// kubeconfigPath returns $KUBECONFIG or the default ~/.kube/config
+func kubeconfigPath() string {
+	kubeconfig := os.Getenv("KUBECONFIG")
+	if kubeconfig == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			home = os.Getenv("USERPROFILE") // Windows
+		}
+		kubeconfig = filepath.Join(home, ".kube", "config")
+	}
+	return kubeconfig
 }
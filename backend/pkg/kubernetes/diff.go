@@ -0,0 +1,261 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// StrategicMergePatchType is the content type for patches produced by DiffWorkloadResources
+const StrategicMergePatchType = "application/strategic-merge-patch+json"
+
+// ResourceUpdate is a recommended request and limit for one resource of a container
+type ResourceUpdate struct {
+	Container    string
+	ResourceType string
+	Request      float64
+	Limit        float64
+}
+
+// ContainerDiff shows a container's resources block before and after the update
+type ContainerDiff struct {
+	Container   string `json:"container"`
+	Current     string `json:"current"`
+	Recommended string `json:"recommended"`
+	Diff        string `json:"diff"`
+}
+
+// WorkloadDiff is a reviewable description of the resource changes to one workload.
+// Patch is a strategic merge patch for the workload, empty for bare pods, whose
+// resources can't be changed in place.
+type WorkloadDiff struct {
+	Kind       string          `json:"kind"`
+	Name       string          `json:"name"`
+	Namespace  string          `json:"namespace"`
+	Containers []ContainerDiff `json:"containers"`
+	Patch      string          `json:"patch,omitempty"`
+	PatchType  string          `json:"patch_type,omitempty"`
+	Command    string          `json:"command,omitempty"`
+	Note       string          `json:"note,omitempty"`
+}
+
+// ResolveOwner returns the workload whose template defines the pod's resources: its
+// Deployment, StatefulSet or DaemonSet, or the pod itself when it has no controller
+func ResolveOwner(ctx context.Context, client kubernetes.Interface, namespace, podName string) (string, string, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("getting pod %s/%s: %w", namespace, podName, err)
+	}
+	if metav1.GetControllerOf(pod) == nil {
+		return "Pod", podName, nil
+	}
+	return workloadOf(ctx, client, pod)
+}
+
+// DiffWorkloadResources renders the updates against the workload's current pod spec
+// without changing anything. Updates for several containers of the workload are
+// combined into a single patch.
+func DiffWorkloadResources(ctx context.Context, client kubernetes.Interface, namespace, kind, name string,
+	updates []ResourceUpdate) (*WorkloadDiff, error) {
+	spec, err := podSpecOf(ctx, client, namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	byContainer := make(map[string][]ResourceUpdate)
+	for _, update := range updates {
+		byContainer[update.Container] = append(byContainer[update.Container], update)
+	}
+
+	result := &WorkloadDiff{Kind: kind, Name: name, Namespace: namespace}
+	var patchContainers []map[string]interface{}
+
+	// Follow the spec's container order so the output is stable
+	for _, container := range spec.Containers {
+		containerUpdates, ok := byContainer[container.Name]
+		if !ok {
+			continue
+		}
+		delete(byContainer, container.Name)
+
+		recommended := container.Resources.DeepCopy()
+		requests := corev1.ResourceList{}
+		limits := corev1.ResourceList{}
+		for _, update := range containerUpdates {
+			resourceName, requestQty, err := ResourceQuantity(update.ResourceType, update.Request)
+			if err != nil {
+				return nil, err
+			}
+			_, limitQty, _ := ResourceQuantity(update.ResourceType, update.Limit)
+			requests[resourceName] = requestQty
+			limits[resourceName] = limitQty
+		}
+		if recommended.Requests == nil {
+			recommended.Requests = corev1.ResourceList{}
+		}
+		if recommended.Limits == nil {
+			recommended.Limits = corev1.ResourceList{}
+		}
+		for resourceName, qty := range requests {
+			recommended.Requests[resourceName] = qty
+		}
+		for resourceName, qty := range limits {
+			recommended.Limits[resourceName] = qty
+		}
+
+		current, err := resourcesYAML(container.Resources)
+		if err != nil {
+			return nil, err
+		}
+		proposed, err := resourcesYAML(*recommended)
+		if err != nil {
+			return nil, err
+		}
+
+		label := fmt.Sprintf("%s/%s/%s", strings.ToLower(kind), name, container.Name)
+		result.Containers = append(result.Containers, ContainerDiff{
+			Container:   container.Name,
+			Current:     current,
+			Recommended: proposed,
+			Diff:        unifiedDiff(label+" (current)", label+" (recommended)", current, proposed),
+		})
+
+		patchContainers = append(patchContainers, map[string]interface{}{
+			"name": container.Name,
+			"resources": map[string]corev1.ResourceList{
+				"requests": requests,
+				"limits":   limits,
+			},
+		})
+	}
+
+	if len(byContainer) > 0 {
+		var missing []string
+		for containerName := range byContainer {
+			missing = append(missing, containerName)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("containers %s not found in %s %s/%s", strings.Join(missing, ", "), kind, namespace, name)
+	}
+
+	if kind == "Pod" {
+		result.Note = "Pod resources can't be changed in place. Recreate the pod with the recommended " +
+			"resources, or run it under a controller so future changes can be patched."
+		return result, nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": patchContainers,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding patch: %w", err)
+	}
+	result.Patch = string(patch)
+	result.PatchType = StrategicMergePatchType
+	result.Command = fmt.Sprintf("kubectl patch %s %s -n %s --type strategic -p '%s'",
+		strings.ToLower(kind), name, namespace, patch)
+
+	return result, nil
+}
+
+// podSpecOf returns the pod spec that defines the workload's container resources
+func podSpecOf(ctx context.Context, client kubernetes.Interface, namespace, kind, name string) (*corev1.PodSpec, error) {
+	switch kind {
+	case "Deployment":
+		obj, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting deployment %s/%s: %w", namespace, name, err)
+		}
+		return &obj.Spec.Template.Spec, nil
+	case "StatefulSet":
+		obj, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting statefulset %s/%s: %w", namespace, name, err)
+		}
+		return &obj.Spec.Template.Spec, nil
+	case "DaemonSet":
+		obj, err := client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting daemonset %s/%s: %w", namespace, name, err)
+		}
+		return &obj.Spec.Template.Spec, nil
+	case "Pod":
+		obj, err := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting pod %s/%s: %w", namespace, name, err)
+		}
+		return &obj.Spec, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %s", kind)
+	}
+}
+
+// resourcesYAML renders a container's resources block as it appears in a manifest
+func resourcesYAML(resources corev1.ResourceRequirements) (string, error) {
+	out, err := yaml.Marshal(struct {
+		Resources corev1.ResourceRequirements `json:"resources"`
+	}{resources})
+	if err != nil {
+		return "", fmt.Errorf("encoding resources: %w", err)
+	}
+	return string(out), nil
+}
+
+// unifiedDiff returns a single-hunk unified diff between two short texts. Resource
+// blocks are a handful of lines, so a full LCS table is cheap and every line is kept
+// as context.
+func unifiedDiff(fromName, toName, from, to string) string {
+	a := strings.Split(strings.TrimSuffix(from, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(to, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n@@ -1,%d +1,%d @@\n", fromName, toName, len(a), len(b))
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString(" " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			sb.WriteString("+" + b[j] + "\n")
+			j++
+		default:
+			sb.WriteString("-" + a[i] + "\n")
+			i++
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// EventSourceComponent is the component name reported as the source of emitted events
+const EventSourceComponent = "k8s-cost-optimizer"
+
+// Event reasons emitted by the optimizer
+const (
+	ReasonRecommendationApplied = "RecommendationApplied"
+)
+
+// EventEmitter records Kubernetes Events on objects changed by the optimizer
+// so that operators see them in `kubectl get events`
+type EventEmitter struct {
+	client      kubernetes.Interface
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+	enabled     bool
+}
+
+// NewEventEmitter creates a new event emitter. When disabled, all calls are no-ops.
+func NewEventEmitter(client kubernetes.Interface, enabled bool) *EventEmitter {
+	emitter := &EventEmitter{
+		client:  client,
+		enabled: enabled,
+	}
+
+	if !enabled {
+		return emitter
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: client.CoreV1().Events(""),
+	})
+
+	emitter.broadcaster = broadcaster
+	emitter.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+		Component: EventSourceComponent,
+	})
+
+	return emitter
+}
+
+// Enabled reports whether event emission is turned on
+func (e *EventEmitter) Enabled() bool {
+	return e != nil && e.enabled
+}
+
+// RecommendationApplied emits a Normal event on the target pod describing the
+// applied resource change and its estimated monthly savings
+func (e *EventEmitter) RecommendationApplied(ctx context.Context, namespace, podName, containerName, resourceType string,
+	currentRequest, recommendedRequest, monthlySavings float64) error {
+	if !e.Enabled() {
+		return nil
+	}
+
+	// Look up the pod so the event is attached to the live object (name + UID)
+	pod, err := e.client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting pod %s/%s: %w", namespace, podName, err)
+	}
+
+	e.recorder.Eventf(pod, corev1.EventTypeNormal, ReasonRecommendationApplied,
+		"Applied %s recommendation to container %s: request %.0f -> %.0f (estimated savings $%.2f/month)",
+		resourceType, containerName, currentRequest, recommendedRequest, monthlySavings)
+
+	return nil
+}
+
+// Shutdown stops the underlying event broadcaster
+func (e *EventEmitter) Shutdown() {
+	if e != nil && e.broadcaster != nil {
+		e.broadcaster.Shutdown()
+	}
+}
@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ApplyResourceQuota creates the ResourceQuota or updates the hard limits of an existing one
+func ApplyResourceQuota(ctx context.Context, client kubernetes.Interface, quota *corev1.ResourceQuota) (*corev1.ResourceQuota, error) {
+	quotas := client.CoreV1().ResourceQuotas(quota.Namespace)
+
+	existing, err := quotas.Get(ctx, quota.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		created, err := quotas.Create(ctx, quota, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("creating resource quota %s/%s: %w", quota.Namespace, quota.Name, err)
+		}
+		return created, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting resource quota %s/%s: %w", quota.Namespace, quota.Name, err)
+	}
+
+	existing.Spec.Hard = quota.Spec.Hard
+	for key, value := range quota.Annotations {
+		if existing.Annotations == nil {
+			existing.Annotations = map[string]string{}
+		}
+		existing.Annotations[key] = value
+	}
+
+	updated, err := quotas.Update(ctx, existing, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("updating resource quota %s/%s: %w", quota.Namespace, quota.Name, err)
+	}
+	return updated, nil
+}
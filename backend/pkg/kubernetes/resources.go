@@ -0,0 +1,180 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GPUResourceName is the extended resource advertised by the NVIDIA device plugin
+const GPUResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+// ResourceChange describes a container resource update on the workload owning a pod
+type ResourceChange struct {
+	Kind           string `json:"kind"`
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	Container      string `json:"container"`
+	Resource       string `json:"resource"`
+	CurrentRequest string `json:"current_request"`
+	CurrentLimit   string `json:"current_limit"`
+	NewRequest     string `json:"new_request"`
+	NewLimit       string `json:"new_limit"`
+	DryRun         bool   `json:"dry_run"`
+}
+
+// ResourceQuantity converts a recommendation value into a Kubernetes quantity.
+// CPU is in millicores, memory in bytes and GPU in whole devices.
+func ResourceQuantity(resourceType string, value float64) (corev1.ResourceName, resource.Quantity, error) {
+	switch resourceType {
+	case "CPU":
+		return corev1.ResourceCPU, *resource.NewMilliQuantity(int64(value), resource.DecimalSI), nil
+	case "Memory":
+		return corev1.ResourceMemory, *resource.NewQuantity(int64(value), resource.BinarySI), nil
+	case "GPU":
+		return GPUResourceName, *resource.NewQuantity(int64(value), resource.DecimalSI), nil
+	default:
+		return "", resource.Quantity{}, fmt.Errorf("unsupported resource type %q", resourceType)
+	}
+}
+
+// ApplyContainerResources sets the request and limit of one resource on a container.
+// Pod resources are immutable, so the pod template of the owning Deployment, StatefulSet
+// or DaemonSet is updated instead, which rolls the pods. With dryRun the update is
+// validated by the API server without being persisted.
+func ApplyContainerResources(ctx context.Context, client kubernetes.Interface, namespace, podName, containerName,
+	resourceType string, request, limit float64, dryRun bool) (*ResourceChange, error) {
+	resourceName, requestQty, err := ResourceQuantity(resourceType, request)
+	if err != nil {
+		return nil, err
+	}
+	_, limitQty, _ := ResourceQuantity(resourceType, limit)
+
+	kind, name, err := resolveWorkload(ctx, client, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &ResourceChange{
+		Kind:       kind,
+		Name:       name,
+		Namespace:  namespace,
+		Container:  containerName,
+		Resource:   string(resourceName),
+		NewRequest: requestQty.String(),
+		NewLimit:   limitQty.String(),
+		DryRun:     dryRun,
+	}
+
+	options := metav1.UpdateOptions{}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+
+	update := func(spec *corev1.PodSpec) error {
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if container.Name != containerName {
+				continue
+			}
+
+			current := container.Resources.Requests[resourceName]
+			change.CurrentRequest = current.String()
+			current = container.Resources.Limits[resourceName]
+			change.CurrentLimit = current.String()
+
+			if container.Resources.Requests == nil {
+				container.Resources.Requests = corev1.ResourceList{}
+			}
+			if container.Resources.Limits == nil {
+				container.Resources.Limits = corev1.ResourceList{}
+			}
+			container.Resources.Requests[resourceName] = requestQty
+			container.Resources.Limits[resourceName] = limitQty
+			return nil
+		}
+		return fmt.Errorf("container %s not found in %s %s/%s", containerName, kind, namespace, name)
+	}
+
+	switch kind {
+	case "Deployment":
+		obj, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting deployment %s/%s: %w", namespace, name, err)
+		}
+		if err := update(&obj.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		_, err = client.AppsV1().Deployments(namespace).Update(ctx, obj, options)
+		if err != nil {
+			return nil, fmt.Errorf("updating deployment %s/%s: %w", namespace, name, err)
+		}
+	case "StatefulSet":
+		obj, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting statefulset %s/%s: %w", namespace, name, err)
+		}
+		if err := update(&obj.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		_, err = client.AppsV1().StatefulSets(namespace).Update(ctx, obj, options)
+		if err != nil {
+			return nil, fmt.Errorf("updating statefulset %s/%s: %w", namespace, name, err)
+		}
+	case "DaemonSet":
+		obj, err := client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting daemonset %s/%s: %w", namespace, name, err)
+		}
+		if err := update(&obj.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		_, err = client.AppsV1().DaemonSets(namespace).Update(ctx, obj, options)
+		if err != nil {
+			return nil, fmt.Errorf("updating daemonset %s/%s: %w", namespace, name, err)
+		}
+	}
+
+	return change, nil
+}
+
+// resolveWorkload walks the pod's controller references up to the workload that owns
+// its template, following ReplicaSets to their Deployment
+func resolveWorkload(ctx context.Context, client kubernetes.Interface, namespace, podName string) (string, string, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("getting pod %s/%s: %w", namespace, podName, err)
+	}
+	return workloadOf(ctx, client, pod)
+}
+
+// workloadOf returns the kind and name of the workload owning the pod's template
+func workloadOf(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) (string, string, error) {
+	namespace, podName := pod.Namespace, pod.Name
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "", "", fmt.Errorf("pod %s/%s has no controller", namespace, podName)
+	}
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		rs, err := client.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", "", fmt.Errorf("getting replicaset %s/%s: %w", namespace, owner.Name, err)
+		}
+		rsOwner := metav1.GetControllerOf(rs)
+		if rsOwner == nil || rsOwner.Kind != "Deployment" {
+			return "", "", fmt.Errorf("replicaset %s/%s is not owned by a deployment", namespace, owner.Name)
+		}
+		return "Deployment", rsOwner.Name, nil
+	case "StatefulSet", "DaemonSet":
+		return owner.Kind, owner.Name, nil
+	default:
+		return "", "", fmt.Errorf("pod %s/%s is controlled by unsupported kind %s", namespace, podName, owner.Kind)
+	}
+}
@@ -0,0 +1,170 @@
+// Package mailer sends email with attachments over SMTP
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Line length of base64 encoded attachments, as RFC 2045 requires lines under 76
+const base64LineLength = 76
+
+// Config is the SMTP server mail is sent through
+type Config struct {
+	Host     string
+	Port     int
+	Username string // Empty sends without authentication
+	Password string
+	From     string
+}
+
+// Validate checks the settings needed to send are present
+func (c Config) Validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("SMTP host is required")
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("SMTP port must be between 1 and 65535, got %d", c.Port)
+	}
+	if c.From == "" {
+		return fmt.Errorf("sender address is required")
+	}
+	return nil
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Message is a plain text email with optional attachments
+type Message struct {
+	To          []string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Mailer sends messages through one SMTP server
+type Mailer struct {
+	config Config
+}
+
+// New creates a mailer for the SMTP server
+func New(config Config) (*Mailer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Mailer{config: config}, nil
+}
+
+// Send delivers the message to every recipient. The server's STARTTLS is used when
+// offered, and credentials are only sent over TLS or to localhost, as net/smtp enforces.
+func (m *Mailer) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+	for _, to := range msg.To {
+		if strings.ContainsAny(to, "\r\n") {
+			return fmt.Errorf("invalid recipient %q", to)
+		}
+	}
+
+	body, err := m.build(msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+
+	// smtp.SendMail takes no context, so give up waiting once it's done and let the
+	// send finish in the background
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.config.From, msg.To, body)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("sending mail via %s: %w", addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// build encodes the message as multipart/mixed MIME, with the body as the first part
+// and each attachment base64 encoded after it
+func (m *Mailer) build(msg Message) ([]byte, error) {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	writeHeader("From", m.config.From)
+	writeHeader("To", strings.Join(msg.To, ", "))
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+	writeHeader("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", boundary))
+	buf.WriteString("\r\n")
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	writeHeader("Content-Type", "text/plain; charset=utf-8")
+	writeHeader("Content-Transfer-Encoding", "8bit")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		writeHeader("Content-Type", contentType)
+		writeHeader("Content-Transfer-Encoding", "base64")
+		writeHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+		buf.WriteString("\r\n")
+
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > base64LineLength {
+			buf.WriteString(encoded[:base64LineLength])
+			buf.WriteString("\r\n")
+			encoded = encoded[base64LineLength:]
+		}
+		buf.WriteString(encoded)
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+func randomBoundary() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generating MIME boundary: %w", err)
+	}
+	return fmt.Sprintf("%x", b[:]), nil
+}
@@ -0,0 +1,103 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Notification kinds
+const (
+	KindSavings        = "savings"
+	KindAnomaly        = "anomaly"
+	KindBudgetAtRisk   = "budget_at_risk"
+	KindBudgetExceeded = "budget_exceeded"
+)
+
+// Notification is an alert about a namespace worth a human's attention
+type Notification struct {
+	Kind      string             `json:"kind"`
+	Namespace string             `json:"namespace"`
+	Title     string             `json:"title"`
+	Summary   string             `json:"summary"`
+	Details   map[string]float64 `json:"details,omitempty"`
+	Link      string             `json:"link,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// key identifies repeats of the same alert for deduplication
+func (n Notification) key() string {
+	return n.Kind + "/" + n.Namespace
+}
+
+// Notifier delivers notifications to one destination
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Dispatcher fans notifications out to every destination and suppresses repeats of
+// the same kind for the same namespace within the cooldown, since the checks run every
+// collection cycle and would otherwise alert on the same finding each time
+type Dispatcher struct {
+	notifiers []Notifier
+	cooldown  time.Duration
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+
+	log *logrus.Logger
+}
+
+// NewDispatcher creates a dispatcher for the given destinations
+func NewDispatcher(cooldown time.Duration, notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{
+		notifiers: notifiers,
+		cooldown:  cooldown,
+		sent:      make(map[string]time.Time),
+		log:       logrus.New(),
+	}
+}
+
+// Enabled reports whether any destination is configured
+func (d *Dispatcher) Enabled() bool {
+	return d != nil && len(d.notifiers) > 0
+}
+
+// Send delivers the notification to every destination unless the same alert was sent
+// within the cooldown. It returns an error if any destination failed; the alert then
+// isn't marked sent so the next cycle tries again.
+func (d *Dispatcher) Send(ctx context.Context, n Notification) error {
+	if !d.Enabled() {
+		return nil
+	}
+	if n.Timestamp.IsZero() {
+		n.Timestamp = time.Now()
+	}
+
+	d.mu.Lock()
+	last, seen := d.sent[n.key()]
+	d.mu.Unlock()
+	if seen && time.Since(last) < d.cooldown {
+		return nil
+	}
+
+	var failed []string
+	for _, notifier := range d.notifiers {
+		if err := notifier.Notify(ctx, n); err != nil {
+			d.log.Warnf("Failed to send %s notification for %s via %s: %v", n.Kind, n.Namespace, notifier.Name(), err)
+			failed = append(failed, notifier.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("notification failed for %v", failed)
+	}
+
+	d.mu.Lock()
+	d.sent[n.key()] = time.Now()
+	d.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,72 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SlackNotifier posts notifications to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates a notifier for the Slack incoming webhook URL
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (s *SlackNotifier) Name() string {
+	return "slack"
+}
+
+// Notify posts the notification as a Block Kit message with the details as fields
+func (s *SlackNotifier) Notify(ctx context.Context, n Notification) error {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]string{"type": "plain_text", "text": n.Title},
+		},
+		{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*Namespace:* `%s`\n%s", n.Namespace, n.Summary)},
+		},
+	}
+
+	if len(n.Details) > 0 {
+		names := make([]string, 0, len(n.Details))
+		for name := range n.Details {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var fields []map[string]string
+		for _, name := range names {
+			fields = append(fields, map[string]string{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s*\n%.2f", strings.ReplaceAll(name, "_", " "), n.Details[name]),
+			})
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields})
+	}
+
+	if n.Link != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("<%s|View in cost optimizer>", n.Link)},
+		})
+	}
+
+	payload := map[string]interface{}{
+		// Shown in notifications and clients without Block Kit support
+		"text":   fmt.Sprintf("%s: %s", n.Title, n.Summary),
+		"blocks": blocks,
+	}
+	return postJSON(ctx, s.client, s.webhookURL, nil, payload)
+}
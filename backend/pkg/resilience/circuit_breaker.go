@@ -24,6 +24,7 @@ type CircuitBreaker struct {
 	lastFailure time.Time
 	successes   int
 	successThreshold int
+	probing     bool // A half-open trial call is in flight
 }
 
 // NewCircuitBreaker creates a new circuit breaker
@@ -36,47 +37,69 @@ func NewCircuitBreaker(threshold int, timeout time.Duration) *CircuitBreaker {
 	}
 }
 
-// Execute runs a function with circuit breaker protection
+// errPanicked is recorded for a function that panicked instead of returning
+var errPanicked = errors.New("circuit breaker call panicked")
+
+// Execute runs a function with circuit breaker protection. A function that panics
+// counts as a failure and the panic continues up the stack.
 func (cb *CircuitBreaker) Execute(ctx context.Context, fn func() error) error {
-	if !cb.canExecute() {
+	probe, ok := cb.canExecute()
+	if !ok {
 		return errors.New("circuit breaker is open")
 	}
 
+	returned := false
+	defer func() {
+		if !returned {
+			cb.recordResult(errPanicked, probe)
+		}
+	}()
+
 	err := fn()
-	cb.recordResult(err)
+	returned = true
+	cb.recordResult(err, probe)
 	return err
 }
 
-// canExecute checks if the circuit breaker allows execution
-func (cb *CircuitBreaker) canExecute() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+// canExecute checks if the circuit breaker allows execution, and whether the call is
+// the half-open trial. The check and any state transition happen under a single write
+// lock, so when the timeout expires exactly one caller moves the breaker to half-open
+// and only one trial call is in flight at a time.
+func (cb *CircuitBreaker) canExecute() (probe, ok bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
 	switch cb.state {
 	case StateClosed:
-		return true
+		return false, true
 	case StateOpen:
 		if time.Since(cb.lastFailure) > cb.timeout {
-			cb.mu.RUnlock()
-			cb.mu.Lock()
 			cb.state = StateHalfOpen
-			cb.mu.Unlock()
-			cb.mu.RLock()
-			return true
+			cb.probing = true
+			return true, true
 		}
-		return false
+		return false, false
 	case StateHalfOpen:
-		return true
+		if cb.probing {
+			return false, false
+		}
+		cb.probing = true
+		return true, true
 	default:
-		return false
+		return false, false
 	}
 }
 
-// recordResult records the result of an execution
-func (cb *CircuitBreaker) recordResult(err error) {
+// recordResult records the result of an execution. Only the trial call ends the trial;
+// calls started before the breaker opened may still be finishing while it is half-open.
+func (cb *CircuitBreaker) recordResult(err error, probe bool) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
+	if probe {
+		cb.probing = false
+	}
+
 	if err != nil {
 		cb.failures++
 		cb.lastFailure = time.Now()
@@ -104,6 +127,36 @@ func (cb *CircuitBreaker) GetState() int {
 	return cb.state
 }
 
+// StateName returns "closed", "open" or "half_open"
+func (cb *CircuitBreaker) StateName() string {
+	switch cb.GetState() {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Ready reports whether Execute would run its function now, without starting a trial
+// call. An open breaker becomes ready once its timeout has passed.
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	switch cb.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		return time.Since(cb.lastFailure) > cb.timeout
+	case StateHalfOpen:
+		return !cb.probing
+	default:
+		return false
+	}
+}
+
 // GetStats returns circuit breaker statistics
 func (cb *CircuitBreaker) GetStats() map[string]interface{} {
 	cb.mu.RLock()
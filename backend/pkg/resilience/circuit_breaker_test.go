@@ -0,0 +1,186 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testTimeout = 20 * time.Millisecond
+
+var errTest = errors.New("dependency down")
+
+func succeed() error { return nil }
+func fail() error    { return errTest }
+
+// openBreaker returns a breaker tripped by threshold failures
+func openBreaker(t *testing.T) *CircuitBreaker {
+	t.Helper()
+	cb := NewCircuitBreaker(2, testTimeout)
+	for i := 0; i < 2; i++ {
+		cb.Execute(context.Background(), fail)
+	}
+	if cb.GetState() != StateOpen {
+		t.Fatalf("state after threshold failures = %s, want open", cb.StateName())
+	}
+	return cb
+}
+
+// halfOpenBreaker returns a breaker with its trial call blocked until release is closed
+func halfOpenBreaker(t *testing.T, result error) (cb *CircuitBreaker, release chan struct{}, done chan error) {
+	t.Helper()
+	cb = openBreaker(t)
+	time.Sleep(2 * testTimeout)
+
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan error, 1)
+	go func() {
+		done <- cb.Execute(context.Background(), func() error {
+			close(started)
+			<-release
+			return result
+		})
+	}()
+	<-started
+	return cb, release, done
+}
+
+func TestCircuitBreakerStateTransitions(t *testing.T) {
+	ctx := context.Background()
+	cb := NewCircuitBreaker(2, testTimeout)
+
+	cb.Execute(ctx, fail)
+	if cb.GetState() != StateClosed {
+		t.Fatalf("state after one failure = %s, want closed", cb.StateName())
+	}
+	cb.Execute(ctx, fail)
+	if cb.GetState() != StateOpen {
+		t.Fatalf("state after two failures = %s, want open", cb.StateName())
+	}
+
+	called := false
+	if err := cb.Execute(ctx, func() error { called = true; return nil }); err == nil || called {
+		t.Fatalf("open breaker ran its function (err %v)", err)
+	}
+	if cb.Ready() {
+		t.Error("open breaker is ready before its timeout")
+	}
+
+	time.Sleep(2 * testTimeout)
+	if !cb.Ready() {
+		t.Error("open breaker isn't ready after its timeout")
+	}
+
+	// The trial call fails, so the breaker opens again
+	cb.Execute(ctx, fail)
+	if cb.GetState() != StateOpen {
+		t.Fatalf("state after a failed trial = %s, want open", cb.StateName())
+	}
+
+	// It closes after the success threshold of trials succeed
+	time.Sleep(2 * testTimeout)
+	for i := 0; i < 3; i++ {
+		if err := cb.Execute(ctx, succeed); err != nil {
+			t.Fatalf("trial %d: %v", i+1, err)
+		}
+		want := StateHalfOpen
+		if i == 2 {
+			want = StateClosed
+		}
+		if cb.GetState() != want {
+			t.Fatalf("state after %d successful trials = %s", i+1, cb.StateName())
+		}
+	}
+}
+
+func TestCircuitBreakerAllowsOneTrialAtATime(t *testing.T) {
+	cb, release, done := halfOpenBreaker(t, nil)
+
+	if cb.GetState() != StateHalfOpen {
+		t.Fatalf("state during the trial = %s, want half_open", cb.StateName())
+	}
+	if err := cb.Execute(context.Background(), succeed); err == nil {
+		t.Error("second call ran while the trial was in flight")
+	}
+	if cb.Ready() {
+		t.Error("breaker is ready while the trial is in flight")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("trial: %v", err)
+	}
+	if !cb.Ready() {
+		t.Error("breaker isn't ready for the next trial")
+	}
+}
+
+func TestCircuitBreakerStragglerDoesntEndTrial(t *testing.T) {
+	cb := NewCircuitBreaker(1, testTimeout)
+
+	// A call starts while closed and is still running when another trips the breaker
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan error, 1)
+	go func() {
+		done <- cb.Execute(context.Background(), func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	cb.Execute(context.Background(), fail)
+	time.Sleep(2 * testTimeout)
+
+	trialRelease := make(chan struct{})
+	trialStarted, trialDone := make(chan struct{}), make(chan error, 1)
+	go func() {
+		trialDone <- cb.Execute(context.Background(), func() error {
+			close(trialStarted)
+			<-trialRelease
+			return nil
+		})
+	}()
+	<-trialStarted
+
+	close(release)
+	<-done
+	if err := cb.Execute(context.Background(), succeed); err == nil {
+		t.Error("a call ran alongside the trial after a straggler finished")
+	}
+
+	close(trialRelease)
+	<-trialDone
+}
+
+func TestCircuitBreakerPanickingTrialEndsTrial(t *testing.T) {
+	cb := openBreaker(t)
+	time.Sleep(2 * testTimeout)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic was swallowed")
+			}
+		}()
+		cb.Execute(context.Background(), func() error { panic("boom") })
+	}()
+
+	if cb.GetState() != StateOpen {
+		t.Fatalf("state after a panicking trial = %s, want open", cb.StateName())
+	}
+	time.Sleep(2 * testTimeout)
+	if err := cb.Execute(context.Background(), succeed); err != nil {
+		t.Errorf("breaker never allowed another trial: %v", err)
+	}
+}
+
+func TestCircuitBreakerFailedTrialReopens(t *testing.T) {
+	cb, release, done := halfOpenBreaker(t, errTest)
+	close(release)
+	if err := <-done; err != errTest {
+		t.Fatalf("trial returned %v, want %v", err, errTest)
+	}
+	if cb.GetState() != StateOpen {
+		t.Errorf("state after a failed trial = %s, want open", cb.StateName())
+	}
+}
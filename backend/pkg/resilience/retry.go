@@ -65,6 +65,11 @@ func Retry(ctx context.Context, config *RetryConfig, fn func() error) error {
 	return lastErr
 }
 
+// Backoff returns the delay before the given (zero-based) retry attempt
+func (rc *RetryConfig) Backoff(attempt int) time.Duration {
+	return rc.calculateDelay(attempt)
+}
+
 // calculateDelay calculates the delay for the given attempt
 func (rc *RetryConfig) calculateDelay(attempt int) time.Duration {
 	delay := float64(rc.InitialDelay) * math.Pow(rc.BackoffMultiplier, float64(attempt))
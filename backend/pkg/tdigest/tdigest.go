@@ -0,0 +1,188 @@
+package tdigest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultCompression bounds the digest to roughly a few hundred centroids while
+// keeping tail quantiles (P95/P99) accurate to well under 1%
+const DefaultCompression = 100
+
+// Centroid is a weighted cluster of nearby samples
+type Centroid struct {
+	Mean  float64 `json:"m"`
+	Count float64 `json:"c"`
+}
+
+// TDigest is a mergeable sketch for estimating quantiles of a stream of values
+type TDigest struct {
+	Compression float64    `json:"compression"`
+	Centroids   []Centroid `json:"centroids"`
+	Min         float64    `json:"min"`
+	Max         float64    `json:"max"`
+	unmerged    []Centroid
+}
+
+// New creates an empty digest with the given compression
+func New(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+
+	return &TDigest{
+		Compression: compression,
+		Min:         math.Inf(1),
+		Max:         math.Inf(-1),
+	}
+}
+
+// Decode restores a digest previously produced by Encode
+func Decode(data []byte) (*TDigest, error) {
+	td := New(DefaultCompression)
+	if err := json.Unmarshal(data, td); err != nil {
+		return nil, fmt.Errorf("decoding t-digest: %w", err)
+	}
+	if len(td.Centroids) == 0 {
+		// Encode drops the sentinels of an empty digest
+		td.Min, td.Max = math.Inf(1), math.Inf(-1)
+	}
+	return td, nil
+}
+
+// Encode serializes the digest after folding in any buffered samples
+func (td *TDigest) Encode() ([]byte, error) {
+	td.compress()
+	if len(td.Centroids) == 0 {
+		// Min/Max are still at their infinite sentinels, which JSON can't represent
+		return json.Marshal(&TDigest{Compression: td.Compression})
+	}
+	return json.Marshal(td)
+}
+
+// Add records a single sample
+func (td *TDigest) Add(value float64) {
+	td.AddWeighted(value, 1)
+}
+
+// AddWeighted records a sample with the given weight
+func (td *TDigest) AddWeighted(value, weight float64) {
+	if math.IsNaN(value) || weight <= 0 {
+		return
+	}
+
+	td.unmerged = append(td.unmerged, Centroid{Mean: value, Count: weight})
+	td.Min = math.Min(td.Min, value)
+	td.Max = math.Max(td.Max, value)
+
+	if len(td.unmerged) > int(td.Compression)*4 {
+		td.compress()
+	}
+}
+
+// Merge folds another digest into this one
+func (td *TDigest) Merge(other *TDigest) {
+	if other == nil || other.Count() == 0 {
+		return
+	}
+
+	td.unmerged = append(td.unmerged, other.Centroids...)
+	td.unmerged = append(td.unmerged, other.unmerged...)
+	td.Min = math.Min(td.Min, other.Min)
+	td.Max = math.Max(td.Max, other.Max)
+	td.compress()
+}
+
+// Count returns the total weight of all recorded samples
+func (td *TDigest) Count() float64 {
+	total := 0.0
+	for _, c := range td.Centroids {
+		total += c.Count
+	}
+	for _, c := range td.unmerged {
+		total += c.Count
+	}
+	return total
+}
+
+// Quantile estimates the value at quantile q (0..1), interpolating linearly between
+// centroid centers and towards the observed min/max at the tails
+func (td *TDigest) Quantile(q float64) float64 {
+	td.compress()
+
+	n := len(td.Centroids)
+	if n == 0 {
+		return 0
+	}
+	if n == 1 || q <= 0 {
+		if q <= 0 {
+			return td.Min
+		}
+		return td.Centroids[0].Mean
+	}
+	if q >= 1 {
+		return td.Max
+	}
+
+	total := td.Count()
+	index := q * total
+
+	first := td.Centroids[0]
+	if index < first.Count/2 {
+		return td.Min + (index/(first.Count/2))*(first.Mean-td.Min)
+	}
+
+	cumulative := first.Count / 2
+	for i := 0; i < n-1; i++ {
+		step := (td.Centroids[i].Count + td.Centroids[i+1].Count) / 2
+		if cumulative+step > index {
+			fraction := (index - cumulative) / step
+			return td.Centroids[i].Mean + fraction*(td.Centroids[i+1].Mean-td.Centroids[i].Mean)
+		}
+		cumulative += step
+	}
+
+	last := td.Centroids[n-1]
+	fraction := math.Min((index-cumulative)/(last.Count/2), 1)
+	return last.Mean + fraction*(td.Max-last.Mean)
+}
+
+// compress merges buffered samples into the centroid list. Centroids near the median
+// may absorb more weight than those at the tails, which keeps extreme quantiles precise.
+func (td *TDigest) compress() {
+	if len(td.unmerged) == 0 {
+		return
+	}
+
+	all := append(td.Centroids, td.unmerged...)
+	td.unmerged = nil
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+
+	total := 0.0
+	for _, c := range all {
+		total += c.Count
+	}
+
+	merged := make([]Centroid, 0, len(all))
+	current := all[0]
+	soFar := 0.0
+
+	for _, next := range all[1:] {
+		q := (soFar + (current.Count+next.Count)/2) / total
+		limit := 4 * total * q * (1 - q) / td.Compression
+
+		if current.Count+next.Count <= limit {
+			current.Count += next.Count
+			current.Mean += (next.Mean - current.Mean) * next.Count / current.Count
+			continue
+		}
+
+		soFar += current.Count
+		merged = append(merged, current)
+		current = next
+	}
+
+	td.Centroids = append(merged, current)
+}
@@ -0,0 +1,130 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// exactQuantile interpolates between the closest ranks, as Postgres' PERCENTILE_CONT does
+func exactQuantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower, upper := int(math.Floor(rank)), int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// distributions are usage-like sample sets: steady, skewed and spiky
+func distributions(n int) map[string][]float64 {
+	rng := rand.New(rand.NewSource(1))
+	sets := map[string][]float64{}
+	for i := 0; i < n; i++ {
+		sets["uniform"] = append(sets["uniform"], 100+rng.Float64()*400)
+		sets["lognormal"] = append(sets["lognormal"], math.Exp(5+rng.NormFloat64()*0.8))
+
+		spiky := 50 + rng.Float64()*10
+		if rng.Intn(50) == 0 {
+			spiky = 800 + rng.Float64()*400
+		}
+		sets["spiky"] = append(sets["spiky"], spiky)
+	}
+	return sets
+}
+
+func sortedCopy(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// checkQuantiles asserts the digest's quantiles are within 1% of the exact ones
+func checkQuantiles(t *testing.T, td *TDigest, values []float64) {
+	t.Helper()
+	sorted := sortedCopy(values)
+	for _, q := range []float64{0.50, 0.95, 0.99} {
+		want := exactQuantile(sorted, q)
+		got := td.Quantile(q)
+		if math.Abs(got-want) > 0.01*want {
+			t.Errorf("P%g = %v, want %v (error %.2f%%)", q*100, got, want, 100*math.Abs(got-want)/want)
+		}
+	}
+	if td.Quantile(0) != sorted[0] || td.Quantile(1) != sorted[len(sorted)-1] {
+		t.Errorf("extremes = %v, %v, want %v, %v", td.Quantile(0), td.Quantile(1), sorted[0], sorted[len(sorted)-1])
+	}
+}
+
+func TestQuantileAccuracy(t *testing.T) {
+	for name, values := range distributions(10000) {
+		t.Run(name, func(t *testing.T) {
+			td := New(DefaultCompression)
+			for _, v := range values {
+				td.Add(v)
+			}
+			if td.Count() != float64(len(values)) {
+				t.Errorf("Count = %v, want %d", td.Count(), len(values))
+			}
+			checkQuantiles(t, td, values)
+		})
+	}
+}
+
+// TestMergedHourlyDigests merges a week of hourly digests of 5-minute samples, each
+// round-tripped through Encode and Decode as the rollup table stores them
+func TestMergedHourlyDigests(t *testing.T) {
+	const hours, perHour = 7 * 24, 12
+
+	for name, values := range distributions(hours * perHour) {
+		t.Run(name, func(t *testing.T) {
+			merged := New(DefaultCompression)
+			for h := 0; h < hours; h++ {
+				hourly := New(DefaultCompression)
+				for _, v := range values[h*perHour : (h+1)*perHour] {
+					hourly.Add(v)
+				}
+				encoded, err := hourly.Encode()
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded, err := Decode(encoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				merged.Merge(decoded)
+			}
+
+			if merged.Count() != float64(len(values)) {
+				t.Errorf("Count = %v, want %d", merged.Count(), len(values))
+			}
+			if len(merged.Centroids) > 10*DefaultCompression {
+				t.Errorf("merged digest kept %d centroids", len(merged.Centroids))
+			}
+			checkQuantiles(t, merged, values)
+		})
+	}
+}
+
+func TestEmptyDigest(t *testing.T) {
+	td := New(DefaultCompression)
+	if got := td.Quantile(0.5); got != 0 {
+		t.Errorf("empty Quantile = %v, want 0", got)
+	}
+
+	encoded, err := td.Encode()
+	if err != nil {
+		t.Fatalf("encoding an empty digest: %v", err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Count() != 0 {
+		t.Errorf("decoded Count = %v, want 0", decoded.Count())
+	}
+
+	// Merging an empty digest leaves the extremes alone
+	decoded.Add(5)
+	decoded.Merge(New(DefaultCompression))
+	if decoded.Quantile(0) != 5 || decoded.Quantile(1) != 5 {
+		t.Errorf("extremes = %v, %v, want 5, 5", decoded.Quantile(0), decoded.Quantile(1))
+	}
+}
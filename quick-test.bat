@@ -116,7 +116,9 @@ if not errorlevel 1 (
     )
     
     REM Run migrations
-    docker exec -i k8s-kost-postgres-1 psql -U k8s_cost_user -d k8s_cost_optimizer < backend\internal\database\migrations.sql
+    for %%f in (backend\internal\database\migrations\*.up.sql) do (
+        docker exec -i k8s-kost-postgres-1 psql -U k8s_cost_user -d k8s_cost_optimizer < "%%f"
+    )
 )
 call :print_success "Database setup complete"
 
@@ -113,7 +113,9 @@ start_application() {
         }
         
         # Run migrations
-        docker exec -i k8s-kost-postgres-1 psql -U k8s_cost_user -d k8s_cost_optimizer < backend/internal/database/migrations.sql
+        for migration in backend/internal/database/migrations/*.up.sql; do
+            docker exec -i k8s-kost-postgres-1 psql -U k8s_cost_user -d k8s_cost_optimizer < "$migration"
+        done
     fi
     print_success "Database setup complete"
     